@@ -216,6 +216,13 @@ func (dn *DSNode) Execute(ctx context.Context, vars mathexp.Vars) (mathexp.Resul
 		return mathexp.Results{}, err
 	}
 
+	// the series over the limit are not converted
+	if limit := seriesLimitFromContext(ctx); limit != nil {
+		if err := limit.check(responseSeries(resp)); err != nil {
+			return mathexp.Results{}, err
+		}
+	}
+
 	vals := make([]mathexp.Value, 0)
 	for refID, qr := range resp.Responses {
 		if len(qr.Frames) == 1 {
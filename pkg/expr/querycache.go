@@ -0,0 +1,71 @@
+package expr
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+type queryCacheKey struct{}
+
+// QueryCache holds the successful datasource responses of an evaluation
+// so that retrying the evaluation only executes again the failed queries.
+// A QueryCache should be used by a single evaluation: the responses
+// are keyed by the datasource, the RefIDs and the time ranges of the queries.
+type QueryCache struct {
+	mu        sync.Mutex
+	responses map[string]*backend.QueryDataResponse
+}
+
+// NewQueryCache returns a new empty QueryCache.
+func NewQueryCache() *QueryCache {
+	return &QueryCache{responses: make(map[string]*backend.QueryDataResponse)}
+}
+
+// WithQueryCache returns a copy of ctx with the cache used by QueryData.
+func WithQueryCache(ctx context.Context, cache *QueryCache) context.Context {
+	return context.WithValue(ctx, queryCacheKey{}, cache)
+}
+
+func queryCacheFromContext(ctx context.Context) *QueryCache {
+	cache, _ := ctx.Value(queryCacheKey{}).(*QueryCache)
+	return cache
+}
+
+func (c *QueryCache) get(key string) (*backend.QueryDataResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res, ok := c.responses[key]
+	return res, ok
+}
+
+// set caches the response unless any of its queries has failed.
+func (c *QueryCache) set(key string, res *backend.QueryDataResponse) {
+	for _, r := range res.Responses {
+		if r.Error != nil {
+			return
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.responses[key] = res
+}
+
+func queryCacheKeyOf(req *backend.QueryDataRequest) string {
+	var b strings.Builder
+	if req.PluginContext.DataSourceInstanceSettings != nil {
+		b.WriteString(strconv.FormatInt(req.PluginContext.DataSourceInstanceSettings.ID, 10))
+	}
+	for _, q := range req.Queries {
+		b.WriteByte(0xff)
+		b.WriteString(q.RefID)
+		b.WriteByte(0xff)
+		b.WriteString(strconv.FormatInt(q.TimeRange.From.UnixNano(), 10))
+		b.WriteByte(0xff)
+		b.WriteString(strconv.FormatInt(q.TimeRange.To.UnixNano(), 10))
+	}
+	return b.String()
+}
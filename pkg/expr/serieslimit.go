@@ -0,0 +1,69 @@
+package expr
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+type seriesLimitKey struct{}
+
+// SeriesLimit is the maximum number of series a datasource response of a request
+// is converted to. The responses over the limit are not converted and the request fails;
+// the limit keeps the number of series of the first of them.
+type SeriesLimit struct {
+	max      int64
+	exceeded int64
+}
+
+// NewSeriesLimit returns a SeriesLimit of max series; it's disabled if max is not positive.
+func NewSeriesLimit(max int64) *SeriesLimit {
+	return &SeriesLimit{max: max}
+}
+
+// WithSeriesLimit returns a copy of ctx with the limit enforced by the datasource nodes.
+func WithSeriesLimit(ctx context.Context, limit *SeriesLimit) context.Context {
+	return context.WithValue(ctx, seriesLimitKey{}, limit)
+}
+
+func seriesLimitFromContext(ctx context.Context) *SeriesLimit {
+	limit, _ := ctx.Value(seriesLimitKey{}).(*SeriesLimit)
+	return limit
+}
+
+// Exceeded returns the number of series of the first response over the limit, if any.
+func (l *SeriesLimit) Exceeded() (int64, bool) {
+	n := atomic.LoadInt64(&l.exceeded)
+	return n, n > 0
+}
+
+// check returns an error if the n series of a response are over the limit.
+func (l *SeriesLimit) check(n int64) error {
+	if l.max <= 0 || n <= l.max {
+		return nil
+	}
+	atomic.CompareAndSwapInt64(&l.exceeded, 0, n)
+	return fmt.Errorf("too many series: %d > %d", n, l.max)
+}
+
+// responseSeries returns the number of series the frames of the response are converted to:
+// a series per row of a number table and a series per value field of a wide series frame.
+func responseSeries(resp *backend.QueryDataResponse) int64 {
+	var n int64
+	for _, qr := range resp.Responses {
+		if len(qr.Frames) == 1 {
+			frame := qr.Frames[0]
+			if frame.TimeSeriesSchema().Type == data.TimeSeriesTypeNot && isNumberTable(frame) {
+				n += int64(frame.Rows())
+				continue
+			}
+		}
+		for _, frame := range qr.Frames {
+			n += int64(len(frame.TimeSeriesSchema().ValueIndices))
+		}
+	}
+	return n
+}
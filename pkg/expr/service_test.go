@@ -74,6 +74,37 @@ func TestService(t *testing.T) {
 	}
 }
 
+func TestServiceSeriesLimit(t *testing.T) {
+	registerEndPoint(data.NewFrame("test",
+		data.NewField("time", nil, []*time.Time{utp(1)}),
+		data.NewField("a", nil, []*float64{fp(1)}),
+		data.NewField("b", nil, []*float64{fp(2)}),
+		data.NewField("c", nil, []*float64{fp(3)})))
+
+	s := Service{}
+	req := &backend.QueryDataRequest{Queries: []backend.DataQuery{
+		{
+			RefID: "A",
+			JSON:  json.RawMessage(`{ "datasource": "test", "datasourceId": 1, "orgId": 1, "intervalMs": 1000, "maxDataPoints": 1000 }`),
+		},
+	}}
+	pl, err := s.BuildPipeline(req)
+	require.NoError(t, err)
+
+	limit := NewSeriesLimit(3)
+	_, err = s.ExecutePipeline(WithSeriesLimit(context.Background(), limit), pl)
+	require.NoError(t, err)
+	_, exceeded := limit.Exceeded()
+	require.False(t, exceeded)
+
+	limit = NewSeriesLimit(2)
+	_, err = s.ExecutePipeline(WithSeriesLimit(context.Background(), limit), pl)
+	require.EqualError(t, err, "too many series: 3 > 2")
+	n, exceeded := limit.Exceeded()
+	require.True(t, exceeded)
+	require.Equal(t, int64(3), n)
+}
+
 func utp(sec int64) *time.Time {
 	t := time.Unix(sec, 0)
 	return &t
@@ -123,9 +123,46 @@ func hiddenRefIDs(queries []backend.DataQuery) (map[string]struct{}, error) {
 	return hidden, nil
 }
 
+// QueryDataFunc queries a single datasource.
+type QueryDataFunc func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error)
+
+type queryDataFuncKey struct{}
+
+// WithQueryDataFunc returns a copy of ctx with the function used by QueryData
+// to query the datasources instead of querying them directly, e.g. to batch the queries.
+func WithQueryDataFunc(ctx context.Context, fn QueryDataFunc) context.Context {
+	return context.WithValue(ctx, queryDataFuncKey{}, fn)
+}
+
 // QueryData is called used to query datasources that are not expression commands, but are used
 // alongside expressions and/or are the input of an expression command.
+// If ctx carries a QueryCache, the cached responses are returned instead of querying
+// the datasource again and the successful responses are cached.
+// If ctx carries a QueryDataFunc, it queries the datasources instead.
 func QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	query := QueryDataFunc(queryData)
+	if fn, ok := ctx.Value(queryDataFuncKey{}).(QueryDataFunc); ok {
+		query = fn
+	}
+
+	cache := queryCacheFromContext(ctx)
+	if cache == nil {
+		return query(ctx, req)
+	}
+
+	key := queryCacheKeyOf(req)
+	if res, ok := cache.get(key); ok {
+		return res, nil
+	}
+	res, err := query(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	cache.set(key, res)
+	return res, nil
+}
+
+func queryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
 	if len(req.Queries) == 0 {
 		return nil, fmt.Errorf("zero queries found in datasource request")
 	}
@@ -0,0 +1,160 @@
+package ngalert
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	activeTimeDateLayout = "2006-01-02"
+	minutesPerDay        = 24 * 60
+)
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// TimeInterval is a recurring time window, e.g. from monday to friday between 09:00 and 17:00.
+type TimeInterval struct {
+	// Weekdays are the names of the days the interval recurs on; if empty it recurs every day.
+	Weekdays []string `json:"weekdays"`
+	// StartTime and EndTime are the start and the exclusive end of the window as HH:MM;
+	// they default to the start and the end of the day.
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+}
+
+// ActiveTimeIntervals are the time windows an alert definition applies its evaluation results in,
+// e.g. to not alert on business metrics at weekends and on holidays: the inverse of the mute timings.
+type ActiveTimeIntervals struct {
+	// Location is the IANA name of the timezone the intervals are in; it defaults to UTC.
+	Location  string         `json:"location"`
+	Intervals []TimeInterval `json:"intervals"`
+	// ExcludedDates are the dates, as YYYY-MM-DD, outside the intervals, e.g. the holidays.
+	ExcludedDates []string `json:"excluded_dates"`
+}
+
+// IsZero returns true if no active time interval is set, i.e. the alert definition is always active.
+func (a ActiveTimeIntervals) IsZero() bool {
+	return len(a.Intervals) == 0 && len(a.ExcludedDates) == 0
+}
+
+// validate returns an error if the active time intervals are invalid.
+func (a ActiveTimeIntervals) validate() error {
+	if _, err := time.LoadLocation(a.Location); err != nil {
+		return fmt.Errorf("invalid active time intervals location: %w", err)
+	}
+	for _, date := range a.ExcludedDates {
+		if _, err := time.Parse(activeTimeDateLayout, date); err != nil {
+			return fmt.Errorf("invalid active time intervals excluded date %q: %w", date, err)
+		}
+	}
+	for _, interval := range a.Intervals {
+		if _, _, err := interval.window(); err != nil {
+			return err
+		}
+		for _, day := range interval.Weekdays {
+			if _, ok := weekdays[strings.ToLower(day)]; !ok {
+				return fmt.Errorf("invalid active time interval weekday: %q", day)
+			}
+		}
+	}
+	return nil
+}
+
+// isActive returns true if the time is within any of the intervals
+// and not on an excluded date, in the timezone of the intervals.
+func (a ActiveTimeIntervals) isActive(now time.Time) (bool, error) {
+	if a.IsZero() {
+		return true, nil
+	}
+	location, err := time.LoadLocation(a.Location)
+	if err != nil {
+		return false, fmt.Errorf("invalid active time intervals location: %w", err)
+	}
+	now = now.In(location)
+
+	date := now.Format(activeTimeDateLayout)
+	for _, excluded := range a.ExcludedDates {
+		if excluded == date {
+			return false, nil
+		}
+	}
+	// only dates are excluded
+	if len(a.Intervals) == 0 {
+		return true, nil
+	}
+
+	for _, interval := range a.Intervals {
+		active, err := interval.contains(now)
+		if err != nil {
+			return false, err
+		}
+		if active {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// contains returns true if the time, in the timezone of the interval, is within it.
+func (i TimeInterval) contains(now time.Time) (bool, error) {
+	if len(i.Weekdays) > 0 {
+		found := false
+		for _, day := range i.Weekdays {
+			if weekdays[strings.ToLower(day)] == now.Weekday() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+
+	start, end, err := i.window()
+	if err != nil {
+		return false, err
+	}
+	minute := now.Hour()*60 + now.Minute()
+	return minute >= start && minute < end, nil
+}
+
+// window returns the start and the end of the interval in minutes from the start of the day.
+func (i TimeInterval) window() (int, int, error) {
+	start, err := parseMinuteOfDay(i.StartTime, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := parseMinuteOfDay(i.EndTime, minutesPerDay)
+	if err != nil {
+		return 0, 0, err
+	}
+	if start >= end {
+		return 0, 0, fmt.Errorf("invalid active time interval: %s should be before %s", i.StartTime, i.EndTime)
+	}
+	return start, end, nil
+}
+
+// parseMinuteOfDay parses HH:MM into minutes from the start of the day;
+// 24:00 is the end of the day.
+func parseMinuteOfDay(s string, defaultMinute int) (int, error) {
+	if s == "" {
+		return defaultMinute, nil
+	}
+	if s == "24:00" {
+		return minutesPerDay, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid active time interval time %q: %w", s, err)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
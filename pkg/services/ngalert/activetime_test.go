@@ -0,0 +1,121 @@
+package ngalert
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var weekdaysOnly = ActiveTimeIntervals{
+	Location: "Europe/Paris",
+	Intervals: []TimeInterval{
+		{Weekdays: []string{"monday", "tuesday", "wednesday", "thursday", "friday"}, StartTime: "09:00", EndTime: "18:00"},
+	},
+	ExcludedDates: []string{"2021-01-01"},
+}
+
+func TestActiveTimeIntervals(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc     string
+		now      time.Time
+		expected bool
+	}{
+		{desc: "weekday within the hours", now: time.Date(2021, 1, 4, 10, 0, 0, 0, paris), expected: true},
+		{desc: "weekday before the hours", now: time.Date(2021, 1, 4, 8, 59, 0, 0, paris), expected: false},
+		{desc: "weekday at the end of the hours", now: time.Date(2021, 1, 4, 18, 0, 0, 0, paris), expected: false},
+		{desc: "weekend", now: time.Date(2021, 1, 2, 10, 0, 0, 0, paris), expected: false},
+		{desc: "holiday", now: time.Date(2021, 1, 1, 10, 0, 0, 0, paris), expected: false},
+		{desc: "in the timezone of the intervals", now: time.Date(2021, 1, 4, 8, 30, 0, 0, time.UTC), expected: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			active, err := weekdaysOnly.isActive(tc.now)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, active)
+		})
+	}
+
+	active, err := ActiveTimeIntervals{}.isActive(time.Date(2021, 1, 2, 10, 0, 0, 0, paris))
+	require.NoError(t, err)
+	assert.True(t, active, "an alert definition without active time intervals should always be active")
+
+	assert.NoError(t, weekdaysOnly.validate())
+	assert.Error(t, ActiveTimeIntervals{Intervals: []TimeInterval{{Weekdays: []string{"someday"}}}}.validate())
+	assert.Error(t, ActiveTimeIntervals{Intervals: []TimeInterval{{StartTime: "18:00", EndTime: "09:00"}}}.validate())
+	assert.Error(t, ActiveTimeIntervals{Location: "Nowhere/Atlantis", Intervals: []TimeInterval{{}}}.validate())
+}
+
+func TestAlertingTickerActiveTimeIntervals(t *testing.T) {
+	// runTick runs a tick at the given time and returns the events emitted by the evaluation
+	runTick := func(t *testing.T, now time.Time) []alertEvent {
+		ng := setupTestEnv(t)
+		t.Cleanup(registry.ClearOverrides)
+
+		mockedClock := clock.NewMock()
+		mockedClock.Set(now)
+		ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+		ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+			return eval.Results{{Instance: data.Labels{"host": "a"}, State: eval.Alerting}}, nil
+		})
+
+		alert := createTestAlertDefinition(t, ng, 1)
+		err := ng.updateAlertDefinition(&updateAlertDefinitionCommand{
+			ID:                  alert.ID,
+			OrgID:               alert.OrgID,
+			ActiveTimeIntervals: &weekdaysOnly,
+		})
+		require.NoError(t, err)
+
+		events, unsubscribe := ng.schedule.subscribers.subscribe(10)
+		defer unsubscribe()
+
+		evalAppliedCh := make(chan evalAppliedInfo, 1)
+		ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+			evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			_ = ng.alertingTicker(ctx)
+		}()
+		runtime.Gosched()
+
+		tick := advanceClock(t, mockedClock)
+		assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+
+		var emitted []alertEvent
+		for {
+			select {
+			case event := <-events:
+				emitted = append(emitted, event)
+			default:
+				return emitted
+			}
+		}
+	}
+
+	t.Run("the state changes should be suppressed at weekends", func(t *testing.T) {
+		saturday := time.Date(2021, 1, 2, 10, 0, 0, 0, time.UTC)
+		assert.Empty(t, runTick(t, saturday))
+	})
+
+	t.Run("the state changes should be emitted on weekdays", func(t *testing.T) {
+		monday := time.Date(2021, 1, 4, 10, 0, 0, 0, time.UTC)
+		events := runTick(t, monday)
+		require.Len(t, events, 1)
+		assert.Equal(t, eval.Alerting, events[0].Instance.State)
+	})
+}
@@ -0,0 +1,138 @@
+package ngalert
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+const (
+	// adaptiveStableEvaluations is the number of consecutive stable evaluations
+	// after which the adaptive interval is doubled.
+	adaptiveStableEvaluations = 5
+	// adaptiveThresholdMargin is the margin around the threshold, relative to it,
+	// the values of the condition are considered close to it in.
+	adaptiveThresholdMargin = 0.1
+)
+
+// hasAdaptiveInterval returns true if the interval of the alert definition adapts to the stability of its results.
+func (alertDefinition *AlertDefinition) hasAdaptiveInterval() bool {
+	return alertDefinition.IntervalSeconds > 0 && alertDefinition.MaxIntervalSeconds > alertDefinition.IntervalSeconds
+}
+
+// adaptiveInterval backs off the evaluations of an alert definition whose results are stable,
+// i.e. whose instances keep their state with values far from the threshold,
+// and evaluates it at its interval again once they are not.
+// The effective interval is the interval of the alert definition multiplied by the factor.
+type adaptiveInterval struct {
+	mu sync.Mutex
+	// factor is doubled every adaptiveStableEvaluations stable evaluations
+	factor int64
+	stable int
+	// states are the states of the instances of the previous evaluation by their labels
+	states map[string]eval.State
+}
+
+// update records the results of an evaluation. If the threshold is known
+// the results with a value close to it reset the factor.
+func (a *adaptiveInterval) update(results eval.Results, threshold float64, hasThreshold bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	states := make(map[string]eval.State, len(results))
+	stable := a.states != nil && len(results) == len(a.states)
+	for _, r := range results {
+		labels := r.Instance.String()
+		states[labels] = r.State
+		if previous, ok := a.states[labels]; !ok || previous != r.State || r.State == eval.Error {
+			stable = false
+		}
+		if hasThreshold && nearThreshold(comparedValue(r), threshold) {
+			stable = false
+		}
+	}
+	a.states = states
+
+	if !stable {
+		a.factor, a.stable = 1, 0
+		return
+	}
+	a.stable++
+	if a.stable >= adaptiveStableEvaluations {
+		a.factor = a.getFactor() * 2
+		a.stable = 0
+	}
+}
+
+// reset evaluates the alert definition at its interval again, e.g. after a failed evaluation.
+func (a *adaptiveInterval) reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.factor, a.stable = 1, 0
+}
+
+// factorFor returns the factor of the interval of the alert definition,
+// bounded so that the effective interval does not exceed its maximum interval.
+func (a *adaptiveInterval) factorFor(alertDefinition *AlertDefinition) int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	maxFactor := alertDefinition.MaxIntervalSeconds / alertDefinition.IntervalSeconds
+	if factor := a.getFactor(); factor < maxFactor {
+		return factor
+	}
+	return maxFactor
+}
+
+// effectiveInterval returns the interval the alert definition is currently evaluated at.
+func (a *adaptiveInterval) effectiveInterval(alertDefinition *AlertDefinition) time.Duration {
+	interval := time.Duration(alertDefinition.IntervalSeconds) * time.Second
+	if !alertDefinition.hasAdaptiveInterval() {
+		return interval
+	}
+	return interval * time.Duration(a.factorFor(alertDefinition))
+}
+
+func (a *adaptiveInterval) getFactor() int64 {
+	if a.factor < 1 {
+		return 1
+	}
+	return a.factor
+}
+
+// comparedValue returns the value of the result compared to the threshold:
+// the value of the condition is the result of the comparison if it's evaluated by the fast path.
+func comparedValue(r eval.Result) float64 {
+	if r.ComparedValue != nil {
+		return *r.ComparedValue
+	}
+	return r.Value
+}
+
+// nearThreshold returns true if the value is within the threshold margin;
+// a zero threshold has an absolute margin.
+func nearThreshold(value, threshold float64) bool {
+	margin := math.Abs(threshold) * adaptiveThresholdMargin
+	if threshold == 0 {
+		margin = adaptiveThresholdMargin
+	}
+	return math.Abs(value-threshold) <= margin
+}
+
+// validateMaxInterval validates that the maximum interval of the alert definition, if any,
+// is a multiple of the scheduler interval not smaller than the interval.
+func (ng *AlertNG) validateMaxInterval(alertDefinition *AlertDefinition) error {
+	if alertDefinition.MaxIntervalSeconds == 0 {
+		return nil
+	}
+	maxInterval := time.Duration(alertDefinition.MaxIntervalSeconds) * time.Second
+	if alertDefinition.MaxIntervalSeconds < 0 || maxInterval%ng.schedule.baseInterval != 0 {
+		return fmt.Errorf("invalid maximum interval: %v: maximum interval should be divided exactly by scheduler interval: %v", maxInterval, ng.schedule.baseInterval)
+	}
+	if alertDefinition.MaxIntervalSeconds < alertDefinition.IntervalSeconds {
+		return fmt.Errorf("invalid maximum interval: %v: maximum interval should not be smaller than the interval: %v", maxInterval, time.Duration(alertDefinition.IntervalSeconds)*time.Second)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package ngalert
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdaptiveInterval(t *testing.T) {
+	alertDefinition := &AlertDefinition{IntervalSeconds: 60, MaxIntervalSeconds: 300}
+	adaptive := &adaptiveInterval{}
+	const threshold = 80.0
+
+	results := func(value float64) eval.Results {
+		return eval.Results{{Instance: data.Labels{"host": "a"}, State: eval.Normal, Value: value}}
+	}
+
+	assert.Equal(t, time.Minute, adaptive.effectiveInterval(alertDefinition))
+
+	// the first evaluation has nothing to be compared to
+	adaptive.update(results(10), threshold, true)
+	for i := 0; i < adaptiveStableEvaluations; i++ {
+		assert.Equal(t, time.Minute, adaptive.effectiveInterval(alertDefinition))
+		adaptive.update(results(10), threshold, true)
+	}
+	assert.Equal(t, 2*time.Minute, adaptive.effectiveInterval(alertDefinition), "the interval should increase after stable evaluations")
+
+	for i := 0; i < 2*adaptiveStableEvaluations; i++ {
+		adaptive.update(results(10), threshold, true)
+	}
+	assert.Equal(t, 5*time.Minute, adaptive.effectiveInterval(alertDefinition), "the interval should not exceed the maximum interval")
+
+	adaptive.update(results(75), threshold, true)
+	assert.Equal(t, time.Minute, adaptive.effectiveInterval(alertDefinition), "the interval should decrease near the threshold")
+
+	for i := 0; i < adaptiveStableEvaluations; i++ {
+		adaptive.update(results(10), threshold, true)
+	}
+	assert.Equal(t, 2*time.Minute, adaptive.effectiveInterval(alertDefinition))
+
+	adaptive.update(eval.Results{{Instance: data.Labels{"host": "a"}, State: eval.Alerting, Value: 90}}, 0, false)
+	assert.Equal(t, time.Minute, adaptive.effectiveInterval(alertDefinition), "the interval should decrease once the state changes")
+
+	for i := 0; i < adaptiveStableEvaluations; i++ {
+		adaptive.update(eval.Results{{Instance: data.Labels{"host": "a"}, State: eval.Alerting, Value: 90}}, 0, false)
+	}
+	assert.Equal(t, 2*time.Minute, adaptive.effectiveInterval(alertDefinition))
+	adaptive.reset()
+	assert.Equal(t, time.Minute, adaptive.effectiveInterval(alertDefinition), "the interval should decrease once the evaluation fails")
+}
+
+func TestAlertingTickerAdaptiveInterval(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	var evaluated []int64
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), func(_ int64, now time.Time) {
+		evaluated = append(evaluated, now.Unix())
+	})
+	ng.schedule.synchronous = true
+
+	// the maximum interval of the alert definition is fetched by the scheduler
+	var interval int64 = 1
+	cmd := saveAlertDefinitionCommand{
+		OrgID: 1,
+		Title: "adaptive",
+		Condition: eval.Condition{
+			RefID: "B",
+			QueriesAndExpressions: []eval.AlertQuery{
+				{
+					RefID:             "A",
+					RelativeTimeRange: eval.RelativeTimeRange{From: eval.Duration(5 * time.Minute)},
+					Model:             json.RawMessage(`{"datasource": "test", "datasourceId": 1}`),
+				},
+				{
+					RefID: "B",
+					Model: json.RawMessage(`{"datasource": "__expr__", "type": "math", "expression": "$A > 80"}`),
+				},
+			},
+		},
+		IntervalSeconds:    &interval,
+		MaxIntervalSeconds: 8,
+	}
+	require.NoError(t, ng.saveAlertDefinition(&cmd))
+
+	// the results are the ones of the fast path: the value is the one of the comparison
+	value := 10.0
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		compared := value
+		return eval.Results{{Instance: data.Labels{"host": "a"}, State: eval.Normal, ComparedValue: &compared}}, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	tick := func(from, to int64) {
+		for tick := from; tick <= to; tick++ {
+			require.NoError(t, ng.tickSynchronously(ctx, time.Unix(tick, 0)))
+		}
+	}
+
+	// the interval doubles after the first evaluation and adaptiveStableEvaluations stable ones
+	tick(1, 9)
+	assert.Equal(t, []int64{1, 2, 3, 4, 5, 6, 8}, evaluated)
+
+	// the compared value is close to the threshold: the alert definition is evaluated at its interval again
+	value = 79
+	tick(10, 12)
+	assert.Equal(t, []int64{1, 2, 3, 4, 5, 6, 8, 10, 11, 12}, evaluated)
+}
+
+func TestAdaptiveIntervalDisabled(t *testing.T) {
+	alertDefinition := &AlertDefinition{IntervalSeconds: 60}
+	adaptive := &adaptiveInterval{factor: 4}
+	assert.False(t, alertDefinition.hasAdaptiveInterval())
+	assert.Equal(t, time.Minute, adaptive.effectiveInterval(alertDefinition))
+}
+
+func TestValidateMaxInterval(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	interval := int64(ng.schedule.baseInterval.Seconds())
+	assert.NoError(t, ng.validateMaxInterval(&AlertDefinition{IntervalSeconds: interval}))
+	assert.NoError(t, ng.validateMaxInterval(&AlertDefinition{IntervalSeconds: interval, MaxIntervalSeconds: 10 * interval}))
+	assert.Error(t, ng.validateMaxInterval(&AlertDefinition{IntervalSeconds: 2 * interval, MaxIntervalSeconds: interval}))
+	assert.Error(t, ng.validateMaxInterval(&AlertDefinition{IntervalSeconds: interval, MaxIntervalSeconds: -interval}))
+}
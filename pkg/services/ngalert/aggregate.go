@@ -0,0 +1,33 @@
+package ngalert
+
+import (
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+// withMinAlerting returns the results with the Alerting ones replaced by Normal ones
+// unless at least min of them are Alerting, e.g. to alert only if more than 3 hosts are down.
+// The results are returned unchanged if min is not positive.
+func withMinAlerting(results eval.Results, min int64) eval.Results {
+	if min <= 0 {
+		return results
+	}
+
+	var alerting int64
+	for _, r := range results {
+		if r.State == eval.Alerting {
+			alerting++
+		}
+	}
+	if alerting >= min {
+		return results
+	}
+
+	normalized := make(eval.Results, len(results))
+	for i, r := range results {
+		if r.State == eval.Alerting {
+			r.State = eval.Normal
+		}
+		normalized[i] = r
+	}
+	return normalized
+}
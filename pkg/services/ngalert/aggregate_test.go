@@ -0,0 +1,50 @@
+package ngalert
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithMinAlerting(t *testing.T) {
+	// results returns the results of 5 hosts, the first alerting ones of them being Alerting
+	results := func(alerting int) eval.Results {
+		r := make(eval.Results, 0, 5)
+		for i := 0; i < 5; i++ {
+			state := eval.Normal
+			if i < alerting {
+				state = eval.Alerting
+			}
+			r = append(r, eval.Result{Instance: data.Labels{"host": fmt.Sprintf("host-%d", i)}, State: state})
+		}
+		return r
+	}
+	countAlerting := func(results eval.Results) int {
+		n := 0
+		for _, r := range results {
+			if r.State == eval.Alerting {
+				n++
+			}
+		}
+		return n
+	}
+
+	t.Run("below the threshold no instance is alerting", func(t *testing.T) {
+		filtered := withMinAlerting(results(2), 3)
+		assert.Len(t, filtered, 5)
+		assert.Equal(t, 0, countAlerting(filtered))
+	})
+
+	t.Run("at or above the threshold the instances are alerting", func(t *testing.T) {
+		filtered := withMinAlerting(results(4), 3)
+		assert.Equal(t, results(4), filtered)
+		assert.Equal(t, 3, countAlerting(withMinAlerting(results(3), 3)))
+	})
+
+	t.Run("without threshold the results are unchanged", func(t *testing.T) {
+		assert.Equal(t, results(1), withMinAlerting(results(1), 0))
+	})
+}
@@ -3,11 +3,69 @@ package ngalert
 import (
 	"fmt"
 	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
 )
 
 // timeNow makes it possible to test usage of time
 var timeNow = time.Now
 
+// getCondition returns the condition of the alert definition.
+// If the alert definition has a relative time range it's applied to all the condition queries.
+func (alertDefinition *AlertDefinition) getCondition() eval.Condition {
+	condition := eval.Condition{
+		RefID:                 alertDefinition.Condition,
+		OrgID:                 alertDefinition.OrgID,
+		QueriesAndExpressions: alertDefinition.Data,
+	}
+	if !alertDefinition.RelativeTimeRange.IsZero() {
+		condition = condition.WithRelativeTimeRange(alertDefinition.RelativeTimeRange)
+	}
+	if !alertDefinition.Trend.IsZero() {
+		trend := alertDefinition.Trend
+		condition.Trend = &trend
+	}
+	if !alertDefinition.Baseline.IsZero() {
+		baseline := alertDefinition.Baseline
+		condition.Baseline = &baseline
+	}
+	return condition
+}
+
+// getGuardCondition returns the guard condition of the alert definition
+// or nil if the alert definition has none.
+// The guard condition is evaluated on the same queries and expressions as the condition.
+func (alertDefinition *AlertDefinition) getGuardCondition() *eval.Condition {
+	if alertDefinition.GuardCondition == "" {
+		return nil
+	}
+	guard := alertDefinition.getCondition()
+	guard.RefID = alertDefinition.GuardCondition
+	return &guard
+}
+
+// getConfirmCondition returns the confirm condition of the alert definition
+// or nil if it has none. Like the guard condition it's evaluated
+// on the same queries and expressions as the condition.
+func (alertDefinition *AlertDefinition) getConfirmCondition() *eval.Condition {
+	if alertDefinition.ConfirmCondition == "" {
+		return nil
+	}
+	confirm := alertDefinition.getCondition()
+	confirm.RefID = alertDefinition.ConfirmCondition
+	return &confirm
+}
+
+// hasQuery returns true if the alert definition has a query or expression with the RefID.
+func (alertDefinition *AlertDefinition) hasQuery(refID string) bool {
+	for _, q := range alertDefinition.Data {
+		if q.RefID == refID {
+			return true
+		}
+	}
+	return false
+}
+
 // preSave sets datasource and loads the updated model for each alert query.
 func (alertDefinition *AlertDefinition) preSave() error {
 	for i, q := range alertDefinition.Data {
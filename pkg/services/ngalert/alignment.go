@@ -0,0 +1,32 @@
+package ngalert
+
+import (
+	"fmt"
+	"time"
+)
+
+// isDue returns true if the alert definition evaluated every frequency ticks
+// is due on the given tick, i.e. if the tick is an interval boundary plus the alignment offset.
+func (sch *schedule) isDue(tickNum int64, frequency int64, alignmentOffset time.Duration) bool {
+	offsetTicks := int64(alignmentOffset / sch.baseInterval)
+	return (tickNum-offsetTicks)%frequency == 0
+}
+
+// validateAlignmentOffset validates that the alignment offset of the alert definition
+// is a multiple of the scheduler interval within the alert definition interval.
+func (ng *AlertNG) validateAlignmentOffset(alertDefinition *AlertDefinition) error {
+	offset := alertDefinition.AlignmentOffset
+	if offset == 0 {
+		return nil
+	}
+	if offset < 0 {
+		return fmt.Errorf("invalid alignment offset: %v: alignment offset should not be negative", offset)
+	}
+	if offset%ng.schedule.baseInterval != 0 {
+		return fmt.Errorf("invalid alignment offset: %v: alignment offset should be divided exactly by scheduler interval: %v", offset, ng.schedule.baseInterval)
+	}
+	if interval := time.Duration(alertDefinition.IntervalSeconds) * time.Second; alertDefinition.IntervalSeconds > 0 && offset >= interval {
+		return fmt.Errorf("invalid alignment offset: %v: alignment offset should be smaller than the interval: %v", offset, interval)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package ngalert
+
+import (
+	"fmt"
+
+	"github.com/grafana/grafana/pkg/services/annotations"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+// AnnotationWriter writes annotations for the alert instances that start firing.
+type AnnotationWriter interface {
+	WriteAnnotation(alertDefinition *AlertDefinition, instance alertInstance) error
+}
+
+// repositoryAnnotationWriter is the AnnotationWriter saving the annotations
+// using the Grafana annotations repository.
+type repositoryAnnotationWriter struct{}
+
+func (repositoryAnnotationWriter) WriteAnnotation(alertDefinition *AlertDefinition, instance alertInstance) error {
+	repo := annotations.GetRepository()
+	if repo == nil {
+		return fmt.Errorf("annotations repository is not initialised")
+	}
+
+	return repo.Save(&annotations.Item{
+		OrgId:       alertDefinition.OrgID,
+		DashboardId: alertDefinition.DashboardID,
+		PanelId:     alertDefinition.PanelID,
+		PrevState:   instance.PreviousState.String(),
+		NewState:    eval.Alerting.String(),
+		Text:        fmt.Sprintf("%s %s", alertDefinition.Title, instance.Labels.String()),
+		Epoch:       instance.LastEvaluatedAt.UnixNano() / int64(1e6),
+	})
+}
+
+// writeAnnotations writes an annotation for every instance that should be notified.
+// An annotation is written on the transition to Alerting and, if the alert definition
+// has a repeat interval, every repeat interval while the instance keeps firing;
+// the silenced instances are not annotated.
+func (sch *schedule) writeAnnotations(alertDefinition *AlertDefinition, instances []alertInstance) {
+	if sch.annotationWriter == nil || alertDefinition.DashboardID == 0 {
+		return
+	}
+
+	for _, instance := range instances {
+		if !instance.Notify || instance.Silenced {
+			continue
+		}
+		if err := sch.annotationWriter.WriteAnnotation(alertDefinition, instance); err != nil {
+			sch.log.Error("failed to write alert definition annotation", "definitionID", alertDefinition.ID, "instance", instance.Labels, "error", err)
+		}
+	}
+}
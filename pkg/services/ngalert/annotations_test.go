@@ -0,0 +1,86 @@
+package ngalert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeAnnotationWriter struct {
+	annotations []alertInstance
+}
+
+func (w *fakeAnnotationWriter) WriteAnnotation(_ *AlertDefinition, instance alertInstance) error {
+	w.annotations = append(w.annotations, instance)
+	return nil
+}
+
+func TestWriteAnnotations(t *testing.T) {
+	mockedClock := clock.NewMock()
+	sch := newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	writer := &fakeAnnotationWriter{}
+	sch.annotationWriter = writer
+
+	alertDefinition := &AlertDefinition{ID: 1, OrgID: 1, UID: "uid", DashboardID: 1, PanelID: 2}
+	key := getKey(alertDefinition)
+	labels := data.Labels{"host": "a"}
+
+	evaluate := func(state eval.State) {
+		mockedClock.Add(time.Second)
+		instances := sch.stateTracker.setResults(key, alertDefinition, eval.Results{{Instance: labels, State: state}})
+		sch.writeAnnotations(alertDefinition, instances)
+	}
+
+	// first firing episode
+	evaluate(eval.Normal)
+	evaluate(eval.Alerting)
+	evaluate(eval.Alerting)
+	evaluate(eval.Alerting)
+	assert.Len(t, writer.annotations, 1)
+
+	// second firing episode
+	evaluate(eval.Normal)
+	evaluate(eval.Alerting)
+	evaluate(eval.Alerting)
+	assert.Len(t, writer.annotations, 2)
+}
+
+func TestWriteAnnotationsRepeatInterval(t *testing.T) {
+	mockedClock := clock.NewMock()
+	sch := newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	writer := &fakeAnnotationWriter{}
+	sch.annotationWriter = writer
+
+	alertDefinition := &AlertDefinition{ID: 1, OrgID: 1, UID: "uid", DashboardID: 1, PanelID: 2, RepeatInterval: time.Minute}
+	key := getKey(alertDefinition)
+	labels := data.Labels{"host": "a"}
+
+	evaluate := func(state eval.State) {
+		mockedClock.Add(10 * time.Second)
+		instances := sch.stateTracker.setResults(key, alertDefinition, eval.Results{{Instance: labels, State: state}})
+		sch.writeAnnotations(alertDefinition, instances)
+	}
+
+	evaluate(eval.Alerting)
+	assert.Len(t, writer.annotations, 1)
+
+	// the instance keeps firing without being notified until the repeat interval elapses
+	for i := 0; i < 5; i++ {
+		evaluate(eval.Alerting)
+	}
+	assert.Len(t, writer.annotations, 1)
+
+	evaluate(eval.Alerting)
+	assert.Len(t, writer.annotations, 2)
+	assert.Equal(t, eval.Alerting, writer.annotations[1].PreviousState, "the repeated notification should not be a state change")
+
+	for i := 0; i < 6; i++ {
+		evaluate(eval.Alerting)
+	}
+	assert.Len(t, writer.annotations, 3)
+}
@@ -1,6 +1,8 @@
 package ngalert
 
 import (
+	"bytes"
+
 	"github.com/go-macaron/binding"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
 	"github.com/grafana/grafana/pkg/api"
@@ -15,17 +17,28 @@ import (
 func (ng *AlertNG) registerAPIEndpoints() {
 	ng.RouteRegister.Group("/api/alert-definitions", func(alertDefinitions routing.RouteRegister) {
 		alertDefinitions.Get("", middleware.ReqSignedIn, api.Wrap(ng.listAlertDefinitions))
+		alertDefinitions.Get("/export/prometheus", middleware.ReqSignedIn, api.Wrap(ng.exportPrometheusRulesEndpoint))
 		alertDefinitions.Get("/eval/:alertDefinitionId", ng.validateOrgAlertDefinition, api.Wrap(ng.alertDefinitionEvalEndpoint))
 		alertDefinitions.Post("/eval", middleware.ReqSignedIn, binding.Bind(evalAlertConditionCommand{}), api.Wrap(ng.conditionEvalEndpoint))
+		alertDefinitions.Post("/eval/stream", middleware.ReqSignedIn, binding.Bind(evalAlertConditionStreamCommand{}), ng.conditionEvalStreamEndpoint)
+		alertDefinitions.Post("/eval/explain", middleware.ReqSignedIn, binding.Bind(evalAlertConditionCommand{}), api.Wrap(ng.conditionEvalExplainEndpoint))
 		alertDefinitions.Get("/:alertDefinitionId", ng.validateOrgAlertDefinition, api.Wrap(ng.getAlertDefinitionEndpoint))
 		alertDefinitions.Delete("/:alertDefinitionId", ng.validateOrgAlertDefinition, api.Wrap(ng.deleteAlertDefinitionEndpoint))
 		alertDefinitions.Post("/", middleware.ReqSignedIn, binding.Bind(saveAlertDefinitionCommand{}), api.Wrap(ng.createAlertDefinitionEndpoint))
 		alertDefinitions.Put("/:alertDefinitionId", ng.validateOrgAlertDefinition, binding.Bind(updateAlertDefinitionCommand{}), api.Wrap(ng.updateAlertDefinitionEndpoint))
 	})
 
+	ng.RouteRegister.Get("/api/alert-instances/firing", middleware.ReqSignedIn, api.Wrap(ng.listFiringAlertsEndpoint))
+	ng.RouteRegister.Get("/api/alert-instances/metrics", middleware.ReqSignedIn, api.Wrap(ng.alertInstancesMetricsEndpoint))
+
 	ng.RouteRegister.Group("/api/ngalert/", func(schedulerRouter routing.RouteRegister) {
 		schedulerRouter.Post("/pause", api.Wrap(ng.pauseScheduler))
 		schedulerRouter.Post("/unpause", api.Wrap(ng.unpauseScheduler))
+		schedulerRouter.Post("/folders/:folderUID/pause", api.Wrap(ng.pauseFolderEndpoint))
+		schedulerRouter.Post("/folders/:folderUID/unpause", api.Wrap(ng.unpauseFolderEndpoint))
+		schedulerRouter.Get("/config", api.Wrap(ng.schedulerConfigEndpoint))
+		schedulerRouter.Get("/health", api.Wrap(ng.schedulerHealthEndpoint))
+		schedulerRouter.Get("/ready", api.Wrap(ng.schedulerReadinessEndpoint))
 	}, middleware.ReqOrgAdmin)
 }
 
@@ -35,7 +48,7 @@ func (ng *AlertNG) conditionEvalEndpoint(c *models.ReqContext, dto evalAlertCond
 		return api.Error(400, "invalid condition", err)
 	}
 
-	evalResults, err := eval.ConditionEval(&dto.Condition, timeNow())
+	evalResults, err := ng.evalConditionNow(c.Req.Context(), &dto.Condition, timeNow())
 	if err != nil {
 		return api.Error(400, "Failed to evaluate conditions", err)
 	}
@@ -52,6 +65,30 @@ func (ng *AlertNG) conditionEvalEndpoint(c *models.ReqContext, dto evalAlertCond
 	})
 }
 
+// conditionEvalExplainEndpoint handles POST /api/alert-definitions/eval/explain.
+func (ng *AlertNG) conditionEvalExplainEndpoint(c *models.ReqContext, dto evalAlertConditionCommand) api.Response {
+	if err := ng.validateCondition(dto.Condition, c.SignedInUser); err != nil {
+		return api.Error(400, "invalid condition", err)
+	}
+
+	evalResults, explanation, err := eval.ConditionEvalExplain(c.Req.Context(), &dto.Condition, timeNow())
+	if err != nil {
+		return api.Error(400, "Failed to evaluate conditions", err)
+	}
+
+	frame := evalResults.AsDataFrame()
+	df := tsdb.NewDecodedDataFrames([]*data.Frame{&frame})
+	instances, err := df.Encoded()
+	if err != nil {
+		return api.Error(400, "Failed to encode result dataframes", err)
+	}
+
+	return api.JSON(200, util.DynMap{
+		"instances":   instances,
+		"explanation": explanation,
+	})
+}
+
 // alertDefinitionEvalEndpoint handles GET /api/alert-definitions/eval/:dashboardId/:panelId/:refId".
 func (ng *AlertNG) alertDefinitionEvalEndpoint(c *models.ReqContext) api.Response {
 	alertDefinitionID := c.ParamsInt64(":alertDefinitionId")
@@ -65,7 +102,7 @@ func (ng *AlertNG) alertDefinitionEvalEndpoint(c *models.ReqContext) api.Respons
 		return api.Error(400, "invalid condition", err)
 	}
 
-	evalResults, err := eval.ConditionEval(condition, timeNow())
+	evalResults, err := ng.EvalDefinitionNow(c.Req.Context(), alertDefinitionID, condition, timeNow())
 	if err != nil {
 		return api.Error(400, "Failed to evaludate alert", err)
 	}
@@ -166,6 +203,57 @@ func (ng *AlertNG) listAlertDefinitions(c *models.ReqContext) api.Response {
 	return api.JSON(200, util.DynMap{"results": query.Result})
 }
 
+// exportPrometheusRulesEndpoint handles GET /api/alert-definitions/export/prometheus.
+func (ng *AlertNG) exportPrometheusRulesEndpoint(c *models.ReqContext) api.Response {
+	query := listAlertDefinitionsQuery{OrgID: c.SignedInUser.OrgId}
+
+	if err := ng.getOrgAlertDefinitions(&query); err != nil {
+		return api.Error(500, "Failed to list alert definitions", err)
+	}
+
+	var buf bytes.Buffer
+	if err := exportPrometheusRules(&buf, query.Result); err != nil {
+		return api.Error(500, "Failed to export alert definitions", err)
+	}
+
+	return api.Respond(200, buf.Bytes()).Header("Content-Type", "application/yaml")
+}
+
+// listFiringAlertsEndpoint handles GET /api/alert-instances/firing.
+func (ng *AlertNG) listFiringAlertsEndpoint(c *models.ReqContext) api.Response {
+	return api.JSON(200, util.DynMap{"results": ng.FiringAlerts(c.SignedInUser.OrgId)})
+}
+
+// alertInstancesMetricsEndpoint handles GET /api/alert-instances/metrics.
+func (ng *AlertNG) alertInstancesMetricsEndpoint(c *models.ReqContext) api.Response {
+	var buf bytes.Buffer
+	if err := writeOpenMetrics(&buf, ng.schedule.stateTracker.active(c.SignedInUser.OrgId)); err != nil {
+		return api.Error(500, "Failed to write alert instances metrics", err)
+	}
+
+	return api.Respond(200, buf.Bytes()).Header("Content-Type", openMetricsContentType)
+}
+
+// schedulerConfigEndpoint handles GET /api/ngalert/config.
+func (ng *AlertNG) schedulerConfigEndpoint() api.Response {
+	return api.JSON(200, ng.schedule.Config())
+}
+
+// schedulerHealthEndpoint handles GET /api/ngalert/health.
+func (ng *AlertNG) schedulerHealthEndpoint() api.Response {
+	return api.JSON(200, ng.Health())
+}
+
+// schedulerReadinessEndpoint handles GET /api/ngalert/ready;
+// it responds with 503 if the scheduler is overloaded.
+func (ng *AlertNG) schedulerReadinessEndpoint() api.Response {
+	readiness := ng.Readiness()
+	if readiness.Status == readinessOverloaded {
+		return api.JSON(503, readiness)
+	}
+	return api.JSON(200, readiness)
+}
+
 func (ng *AlertNG) pauseScheduler() api.Response {
 	err := ng.schedule.pause()
 	if err != nil {
@@ -181,3 +269,19 @@ func (ng *AlertNG) unpauseScheduler() api.Response {
 	}
 	return api.JSON(200, util.DynMap{"message": "alert definition scheduler unpaused"})
 }
+
+// pauseFolderEndpoint handles POST /api/ngalert/folders/:folderUID/pause.
+func (ng *AlertNG) pauseFolderEndpoint(c *models.ReqContext) api.Response {
+	if err := ng.schedule.pauseFolder(c.SignedInUser.OrgId, c.Params(":folderUID")); err != nil {
+		return api.Error(400, "Failed to pause folder", err)
+	}
+	return api.JSON(200, util.DynMap{"message": "alert definition folder paused"})
+}
+
+// unpauseFolderEndpoint handles POST /api/ngalert/folders/:folderUID/unpause.
+func (ng *AlertNG) unpauseFolderEndpoint(c *models.ReqContext) api.Response {
+	if err := ng.schedule.unpauseFolder(c.SignedInUser.OrgId, c.Params(":folderUID")); err != nil {
+		return api.Error(400, "Failed to unpause folder", err)
+	}
+	return api.JSON(200, util.DynMap{"message": "alert definition folder unpaused"})
+}
@@ -0,0 +1,151 @@
+package ngalert
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana/pkg/infra/log"
+)
+
+// AuditAction is the scheduler decision an audit record is about.
+type AuditAction string
+
+const (
+	// AuditRoutineCreated is recorded when the routine of an alert definition is started.
+	AuditRoutineCreated AuditAction = "routine_created"
+	// AuditRoutineStopped is recorded when the routine of a deleted or disabled alert definition is stopped.
+	AuditRoutineStopped AuditAction = "routine_stopped"
+	// AuditDefinitionsPaused is recorded when alert definitions are paused by labels.
+	AuditDefinitionsPaused AuditAction = "definitions_paused"
+	// AuditDefinitionsUnpaused is recorded when alert definitions are unpaused by labels.
+	AuditDefinitionsUnpaused AuditAction = "definitions_unpaused"
+	// AuditSchedulerPaused is recorded when the scheduler is paused.
+	AuditSchedulerPaused AuditAction = "scheduler_paused"
+	// AuditSchedulerUnpaused is recorded when the scheduler is unpaused.
+	AuditSchedulerUnpaused AuditAction = "scheduler_unpaused"
+	// AuditFolderPaused is recorded when the alert definitions of a folder are paused.
+	AuditFolderPaused AuditAction = "folder_paused"
+	// AuditFolderUnpaused is recorded when the alert definitions of a folder are unpaused.
+	AuditFolderUnpaused AuditAction = "folder_unpaused"
+	// AuditFrequencyBoosted is recorded when the evaluation frequency of an alert definition is boosted.
+	AuditFrequencyBoosted AuditAction = "frequency_boosted"
+	// AuditFrequencyReverted is recorded when the boost of the evaluation frequency of an alert definition ends.
+	AuditFrequencyReverted AuditAction = "frequency_reverted"
+	// AuditDefinitionQuarantined is recorded when an alert definition is quarantined after consecutive failed evaluations.
+	AuditDefinitionQuarantined AuditAction = "definition_quarantined"
+	// AuditDefinitionReleased is recorded when a quarantined alert definition is evaluated successfully.
+	AuditDefinitionReleased AuditAction = "definition_released"
+	// AuditVersionUpgraded is recorded when a routine fetches a new version of its alert definition.
+	AuditVersionUpgraded AuditAction = "version_upgraded"
+	// AuditEvaluationSucceeded is recorded when an evaluation succeeds.
+	AuditEvaluationSucceeded AuditAction = "evaluation_succeeded"
+	// AuditEvaluationFailed is recorded when all the attempts of an evaluation fail.
+	AuditEvaluationFailed AuditAction = "evaluation_failed"
+	// AuditStateTransition is recorded when an alert instance changes state.
+	AuditStateTransition AuditAction = "state_transition"
+)
+
+// AuditRecord is a structured record of a scheduler decision.
+// Seq increases with every record so that the consumers can detect the missing ones.
+type AuditRecord struct {
+	Seq          int64                  `json:"seq"`
+	At           time.Time              `json:"at"`
+	Action       AuditAction            `json:"action"`
+	Key          string                 `json:"key,omitempty"`
+	DefinitionID int64                  `json:"definition_id,omitempty"`
+	EvalID       int64                  `json:"eval_id,omitempty"`
+	Details      map[string]interface{} `json:"details,omitempty"`
+}
+
+// AuditSink receives the audit records in the order they are recorded.
+// It's called synchronously by the scheduler so it should not block.
+type AuditSink interface {
+	Write(record AuditRecord) error
+}
+
+// AuditSinkFunc is an AuditSink function.
+type AuditSinkFunc func(record AuditRecord) error
+
+// Write calls f(record).
+func (f AuditSinkFunc) Write(record AuditRecord) error {
+	return f(record)
+}
+
+type noopAuditSink struct{}
+
+func (noopAuditSink) Write(AuditRecord) error {
+	return nil
+}
+
+// jsonAuditSink appends the audit records to a writer as JSON lines.
+type jsonAuditSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONAuditSink returns an AuditSink appending the audit records to w as JSON lines.
+func NewJSONAuditSink(w io.Writer) AuditSink {
+	return &jsonAuditSink{enc: json.NewEncoder(w)}
+}
+
+func (s *jsonAuditSink) Write(record AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(record)
+}
+
+// auditLog numbers the audit records and writes them to the sink.
+// The records the sink fails to write are logged and dropped
+// so that auditing never affects the scheduling.
+type auditLog struct {
+	clock clock.Clock
+	log   log.Logger
+
+	mu   sync.Mutex
+	seq  int64
+	sink AuditSink
+}
+
+func newAuditLog(c clock.Clock, logger log.Logger) *auditLog {
+	return &auditLog{clock: c, log: logger, sink: noopAuditSink{}}
+}
+
+func (a *auditLog) setSink(sink AuditSink) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if sink == nil {
+		sink = noopAuditSink{}
+	}
+	a.sink = sink
+}
+
+// record writes a record of the action with the optional details as key/value pairs.
+func (a *auditLog) record(action AuditAction, key string, definitionID, evalID int64, details ...interface{}) {
+	record := AuditRecord{At: a.clock.Now(), Action: action, Key: key, DefinitionID: definitionID, EvalID: evalID}
+	if len(details) > 0 {
+		record.Details = make(map[string]interface{}, len(details)/2)
+		for i := 0; i+1 < len(details); i += 2 {
+			if k, ok := details[i].(string); ok {
+				record.Details[k] = details[i+1]
+			}
+		}
+	}
+
+	// the records are numbered and written under the lock so that they are written in order
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.seq++
+	record.Seq = a.seq
+	if err := a.sink.Write(record); err != nil {
+		a.log.Warn("failed to write audit record", "seq", record.Seq, "action", record.Action, "key", key, "error", err)
+	}
+}
+
+// SetAuditSink sets the sink of the audit records of the scheduler decisions;
+// a nil sink discards them.
+func (ng *AlertNG) SetAuditSink(sink AuditSink) {
+	ng.schedule.audit.setSink(sink)
+}
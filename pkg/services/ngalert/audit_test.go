@@ -0,0 +1,98 @@
+package ngalert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertingTickerAuditLifecycle(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		return eval.Results{{Instance: data.Labels{"host": "a"}, State: eval.Alerting}}, nil
+	})
+
+	var mu sync.Mutex
+	var records []AuditRecord
+	ng.SetAuditSink(AuditSinkFunc(func(record AuditRecord) error {
+		mu.Lock()
+		defer mu.Unlock()
+		records = append(records, record)
+		return nil
+	}))
+
+	store := newInMemoryDefinitionStore()
+	alert := &AlertDefinition{ID: 1, OrgID: 1, UID: "audited", Condition: "A", IntervalSeconds: 1, Version: 1, Enabled: true}
+	store.add(alert)
+	ng.SetDefinitionStore(store)
+
+	evalAppliedCh := make(chan evalAppliedInfo, 1)
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	onTick := make(chan TickSummary, 1)
+	ng.schedule.onTick = func(summary TickSummary) {
+		onTick <- summary
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = ng.alertingTicker(ctx)
+	}()
+	runtime.Gosched()
+
+	tick := advanceClock(t, mockedClock)
+	<-onTick
+	assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+
+	store.remove(alert.OrgID, alert.UID)
+	advanceClock(t, mockedClock)
+	require.Equal(t, 1, (<-onTick).Deleted)
+
+	mu.Lock()
+	defer mu.Unlock()
+	actions := make([]AuditAction, 0, len(records))
+	for i, record := range records {
+		actions = append(actions, record.Action)
+		assert.Equal(t, int64(i+1), record.Seq)
+		assert.Equal(t, getKey(alert), record.Key)
+		assert.Equal(t, alert.ID, record.DefinitionID)
+	}
+	assert.Equal(t, []AuditAction{AuditRoutineCreated, AuditStateTransition, AuditEvaluationSucceeded, AuditRoutineStopped}, actions)
+	assert.Equal(t, map[string]interface{}{"labels": data.Labels{"host": "a"}.String(), "from": eval.Normal.String(), "to": eval.Alerting.String()}, records[1].Details)
+	assert.Equal(t, records[1].EvalID, records[2].EvalID)
+}
+
+func TestJSONAuditSink(t *testing.T) {
+	var buf bytes.Buffer
+	audit := newAuditLog(clock.NewMock(), log.New("ngalert.audit.test"))
+	audit.setSink(NewJSONAuditSink(&buf))
+
+	audit.record(AuditSchedulerPaused, "", 0, 0)
+	audit.record(AuditRoutineCreated, "1:uid", 1, 0, "version", 2)
+
+	dec := json.NewDecoder(&buf)
+	var first, second AuditRecord
+	require.NoError(t, dec.Decode(&first))
+	require.NoError(t, dec.Decode(&second))
+	assert.Equal(t, AuditRecord{Seq: 1, At: first.At, Action: AuditSchedulerPaused}, first)
+	assert.Equal(t, AuditRecord{Seq: 2, At: second.At, Action: AuditRoutineCreated, Key: "1:uid", DefinitionID: 1, Details: map[string]interface{}{"version": float64(2)}}, second)
+}
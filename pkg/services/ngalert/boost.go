@@ -0,0 +1,86 @@
+package ngalert
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// frequencyBoost overrides the interval of an alert definition until it ends.
+type frequencyBoost struct {
+	interval time.Duration
+	until    time.Time
+}
+
+// frequencyBoosts are the alert definitions evaluated more often for a bounded period,
+// e.g. during an incident. They are not persisted.
+type frequencyBoosts struct {
+	mu sync.RWMutex
+	// boosts are indexed by the key of the alert definition
+	boosts map[string]frequencyBoost
+}
+
+func newFrequencyBoosts() *frequencyBoosts {
+	return &frequencyBoosts{boosts: make(map[string]frequencyBoost)}
+}
+
+func (b *frequencyBoosts) set(key string, boost frequencyBoost) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.boosts[key] = boost
+}
+
+// intervalAt returns the boosted interval of the alert definition at the given time, if any.
+func (b *frequencyBoosts) intervalAt(key string, at time.Time) (time.Duration, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	boost, ok := b.boosts[key]
+	if !ok || !at.Before(boost.until) {
+		return 0, false
+	}
+	return boost.interval, true
+}
+
+// expire removes the boosts that have ended and returns the keys of the alert definitions they applied to.
+func (b *frequencyBoosts) expire(now time.Time) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var expired []string
+	for key, boost := range b.boosts {
+		if !now.Before(boost.until) {
+			delete(b.boosts, key)
+			expired = append(expired, key)
+		}
+	}
+	return expired
+}
+
+// BoostFrequency evaluates the alert definition every interval instead of its own interval
+// for the given duration, from the next tick; then it reverts to its own interval.
+// A new boost of the alert definition replaces the previous one.
+// The boost is not persisted.
+func (ng *AlertNG) BoostFrequency(uid string, orgID int64, interval, duration time.Duration) error {
+	if ng.schedule == nil {
+		return fmt.Errorf("scheduler is not initialised")
+	}
+	if duration <= 0 {
+		return fmt.Errorf("invalid boost duration: %v: it should be positive", duration)
+	}
+	if interval <= 0 || interval%ng.schedule.baseInterval != 0 {
+		return fmt.Errorf("invalid boost interval: %v: it should be divided exactly by scheduler interval: %v", interval, ng.schedule.baseInterval)
+	}
+	alertDefinition, err := ng.definitionStore().GetByUID(orgID, uid)
+	if err != nil {
+		return err
+	}
+	if definitionInterval := time.Duration(alertDefinition.IntervalSeconds) * time.Second; interval >= definitionInterval {
+		return fmt.Errorf("invalid boost interval: %v: it should be shorter than the interval of the alert definition: %v", interval, definitionInterval)
+	}
+
+	key := ng.schedule.keyFunc(alertDefinition)
+	until := ng.schedule.clock.Now().Add(duration)
+	ng.schedule.boosts.set(key, frequencyBoost{interval: interval, until: until})
+	ng.schedule.audit.record(AuditFrequencyBoosted, key, alertDefinition.ID, 0, "interval", interval, "until", until)
+	ng.schedule.log.Info("alert definition frequency boosted", "key", key, "orgID", orgID, "uid", uid, "interval", interval, "until", until)
+	return nil
+}
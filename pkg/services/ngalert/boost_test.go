@@ -0,0 +1,79 @@
+package ngalert
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoostFrequency(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, 10*time.Second, log.New("ngalert.schedule.test"), nil)
+
+	alertDefinition := createTestAlertDefinition(t, ng, 60)
+
+	summaries := make(chan TickSummary, 1)
+	ng.schedule.onTick = func(summary TickSummary) {
+		summaries <- summary
+	}
+
+	// the 1m alert definition is evaluated every 10s for 1m
+	require.NoError(t, ng.BoostFrequency(alertDefinition.UID, alertDefinition.OrgID, 10*time.Second, time.Minute))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		_ = ng.alertingTicker(ctx)
+	}()
+	runtime.Gosched()
+
+	// the evaluations dispatched on the ticks every 10s up to 2m;
+	// from 1m the alert definition is evaluated every minute again
+	expected := []int{1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0, 1}
+	for i, dispatched := range expected {
+		mockedClock.Add(10 * time.Second)
+		select {
+		case summary := <-summaries:
+			assert.Equal(t, dispatched, summary.Dispatched, "tick %d at %v", i+1, summary.Tick)
+		case <-time.After(time.Second):
+			t.Fatalf("tick %d was not handled", i+1)
+		}
+	}
+	_, boosted := ng.schedule.boosts.intervalAt(getKey(alertDefinition), mockedClock.Now())
+	assert.False(t, boosted)
+}
+
+func TestBoostFrequencyValidation(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	ng.schedule = newScheduler(clock.NewMock(), 10*time.Second, log.New("ngalert.schedule.test"), nil)
+	alertDefinition := createTestAlertDefinition(t, ng, 60)
+
+	testCases := []struct {
+		desc     string
+		uid      string
+		interval time.Duration
+		duration time.Duration
+	}{
+		{desc: "non positive duration", uid: alertDefinition.UID, interval: 10 * time.Second},
+		{desc: "interval not a multiple of the scheduler interval", uid: alertDefinition.UID, interval: 15 * time.Second, duration: time.Minute},
+		{desc: "interval not shorter than the alert definition interval", uid: alertDefinition.UID, interval: time.Minute, duration: time.Minute},
+		{desc: "unknown alert definition", uid: "unknown", interval: 10 * time.Second, duration: time.Minute},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			require.Error(t, ng.BoostFrequency(tc.uid, alertDefinition.OrgID, tc.interval, tc.duration))
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package ngalert
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+// definitionBundleVersion is the version of the format of the exported alert definitions.
+const definitionBundleVersion = 1
+
+// uidCollisionPolicy is the policy applied when an imported alert definition
+// has the UID of an existing alert definition of the organisation.
+type uidCollisionPolicy string
+
+const (
+	// skipOnUIDCollision keeps the existing alert definition.
+	skipOnUIDCollision uidCollisionPolicy = "skip"
+	// overwriteOnUIDCollision updates the existing alert definition with the imported one.
+	overwriteOnUIDCollision uidCollisionPolicy = "overwrite"
+)
+
+// definitionBundle is the versioned JSON format of the exported alert definitions.
+type definitionBundle struct {
+	Version     int                 `json:"version"`
+	Definitions []bundledDefinition `json:"definitions"`
+}
+
+// bundledDefinition is an exported alert definition.
+// The identifiers local to the instance, like the ID and the version, are not exported.
+type bundledDefinition struct {
+	UID                  string                  `json:"uid"`
+	Title                string                  `json:"title"`
+	Condition            string                  `json:"condition"`
+	Data                 []eval.AlertQuery       `json:"data"`
+	IntervalSeconds      int64                   `json:"interval_seconds"`
+	Enabled              bool                    `json:"enabled"`
+	Labels               map[string]string       `json:"labels,omitempty"`
+	KeepFiringFor        eval.Duration           `json:"keep_firing_for"`
+	For                  eval.Duration           `json:"for"`
+	RepeatInterval       eval.Duration           `json:"repeat_interval"`
+	DashboardID          int64                   `json:"dashboard_id"`
+	PanelID              int64                   `json:"panel_id"`
+	RelativeTimeRange    eval.RelativeTimeRange  `json:"relative_time_range"`
+	TemplateVariable     string                  `json:"template_variable,omitempty"`
+	TemplateValues       []string                `json:"template_values,omitempty"`
+	MaxSeries            int64                   `json:"max_series"`
+	GuardCondition       string                  `json:"guard_condition,omitempty"`
+	ConfirmCondition     string                  `json:"confirm_condition,omitempty"`
+	QueryCacheTTL        eval.Duration           `json:"query_cache_ttl"`
+	Priority             int64                   `json:"priority"`
+	ActiveTimeIntervals  ActiveTimeIntervals     `json:"active_time_intervals"`
+	Trend                *eval.TrendCondition    `json:"trend,omitempty"`
+	Baseline             *eval.BaselineCondition `json:"baseline,omitempty"`
+	MinAlertingInstances int64                   `json:"min_alerting_instances"`
+	MaxStaleness         eval.Duration           `json:"max_staleness"`
+	AlignmentOffset      eval.Duration           `json:"alignment_offset"`
+	MaxIntervalSeconds   int64                   `json:"max_interval_seconds"`
+	FolderUID            string                  `json:"folder_uid,omitempty"`
+	RuleGroup            string                  `json:"rule_group,omitempty"`
+	DependsOn            []string                `json:"depends_on,omitempty"`
+	Features             map[string]bool         `json:"features,omitempty"`
+}
+
+// ExportDefinitions returns the alert definitions of the organisation as a versioned JSON bundle.
+func (ng *AlertNG) ExportDefinitions(orgID int64) ([]byte, error) {
+	q := listAlertDefinitionsQuery{OrgID: orgID}
+	if err := ng.getOrgAlertDefinitions(&q); err != nil {
+		return nil, err
+	}
+
+	bundle := definitionBundle{Version: definitionBundleVersion, Definitions: make([]bundledDefinition, 0, len(q.Result))}
+	for _, d := range q.Result {
+		var trend *eval.TrendCondition
+		if !d.Trend.IsZero() {
+			definitionTrend := d.Trend
+			trend = &definitionTrend
+		}
+		var baseline *eval.BaselineCondition
+		if !d.Baseline.IsZero() {
+			definitionBaseline := d.Baseline
+			baseline = &definitionBaseline
+		}
+		bundle.Definitions = append(bundle.Definitions, bundledDefinition{
+			UID:                  d.UID,
+			Title:                d.Title,
+			Condition:            d.Condition,
+			Data:                 d.Data,
+			IntervalSeconds:      d.IntervalSeconds,
+			Enabled:              d.Enabled,
+			Labels:               d.Labels,
+			KeepFiringFor:        eval.Duration(d.KeepFiringFor),
+			For:                  eval.Duration(d.For),
+			RepeatInterval:       eval.Duration(d.RepeatInterval),
+			DashboardID:          d.DashboardID,
+			PanelID:              d.PanelID,
+			RelativeTimeRange:    d.RelativeTimeRange,
+			TemplateVariable:     d.TemplateVariable,
+			TemplateValues:       d.TemplateValues,
+			MaxSeries:            d.MaxSeries,
+			GuardCondition:       d.GuardCondition,
+			ConfirmCondition:     d.ConfirmCondition,
+			QueryCacheTTL:        eval.Duration(d.QueryCacheTTL),
+			Priority:             d.Priority,
+			ActiveTimeIntervals:  d.ActiveTimeIntervals,
+			Trend:                trend,
+			Baseline:             baseline,
+			MinAlertingInstances: d.MinAlertingInstances,
+			MaxStaleness:         eval.Duration(d.MaxStaleness),
+			AlignmentOffset:      eval.Duration(d.AlignmentOffset),
+			MaxIntervalSeconds:   d.MaxIntervalSeconds,
+			FolderUID:            d.FolderUID,
+			RuleGroup:            d.RuleGroup,
+			DependsOn:            d.DependsOn,
+			Features:             d.Features,
+		})
+	}
+	return json.Marshal(bundle)
+}
+
+// ImportDefinitions creates the alert definitions of the bundle in the organisation preserving their UIDs.
+// The alert definitions having the UID of an existing one are skipped or overwrite it
+// depending on the configured UID collision policy.
+// All the alert definitions are validated, e.g. their interval against the scheduler base interval,
+// before any is imported. The dependencies between the alert definitions are not checked:
+// a dependency on a missing alert definition never skips the evaluations of its dependent.
+func (ng *AlertNG) ImportDefinitions(orgID int64, b []byte) error {
+	var bundle definitionBundle
+	if err := json.Unmarshal(b, &bundle); err != nil {
+		return fmt.Errorf("invalid alert definition bundle: %w", err)
+	}
+	if bundle.Version != definitionBundleVersion {
+		return fmt.Errorf("unsupported alert definition bundle version: %d", bundle.Version)
+	}
+
+	uids := make(map[string]struct{}, len(bundle.Definitions))
+	for _, d := range bundle.Definitions {
+		if d.UID == "" {
+			return fmt.Errorf("alert definition %q has no UID", d.Title)
+		}
+		if _, ok := uids[d.UID]; ok {
+			return fmt.Errorf("duplicate alert definition UID in bundle: %s", d.UID)
+		}
+		uids[d.UID] = struct{}{}
+
+		alertDefinition := &AlertDefinition{
+			OrgID:               orgID,
+			UID:                 d.UID,
+			Title:               d.Title,
+			Data:                d.Data,
+			IntervalSeconds:     d.IntervalSeconds,
+			AlignmentOffset:     time.Duration(d.AlignmentOffset),
+			MaxIntervalSeconds:  d.MaxIntervalSeconds,
+			GuardCondition:      d.GuardCondition,
+			ConfirmCondition:    d.ConfirmCondition,
+			ActiveTimeIntervals: d.ActiveTimeIntervals,
+			DependsOn:           d.DependsOn,
+		}
+		if d.Trend != nil {
+			alertDefinition.Trend = *d.Trend
+		}
+		if d.Baseline != nil {
+			alertDefinition.Baseline = *d.Baseline
+		}
+		if err := ng.validateAlertDefinition(alertDefinition, false); err != nil {
+			return fmt.Errorf("invalid alert definition %s: %w", d.UID, err)
+		}
+	}
+
+	for _, d := range bundle.Definitions {
+		if err := ng.importDefinition(orgID, d); err != nil {
+			return fmt.Errorf("failed to import alert definition %s: %w", d.UID, err)
+		}
+	}
+	return nil
+}
+
+func (ng *AlertNG) importDefinition(orgID int64, d bundledDefinition) error {
+	condition := eval.Condition{RefID: d.Condition, OrgID: orgID, QueriesAndExpressions: d.Data, Trend: d.Trend, Baseline: d.Baseline}
+	intervalSeconds := d.IntervalSeconds
+	enabled := d.Enabled
+	keepFiringFor, forDuration, repeatInterval, queryCacheTTL, maxStaleness, alignmentOffset := d.KeepFiringFor, d.For, d.RepeatInterval, d.QueryCacheTTL, d.MaxStaleness, d.AlignmentOffset
+
+	q := getAlertDefinitionByUIDQuery{UID: d.UID, OrgID: orgID}
+	err := ng.getAlertDefinitionByUID(&q)
+	switch {
+	case err == nil && ng.importPolicy == overwriteOnUIDCollision:
+		ng.log.Info("overwriting the alert definition with the imported one", "uid", d.UID, "orgID", orgID)
+		return ng.updateAlertDefinition(&updateAlertDefinitionCommand{
+			ID:                   q.Result.ID,
+			OrgID:                orgID,
+			Title:                d.Title,
+			Condition:            condition,
+			IntervalSeconds:      &intervalSeconds,
+			Enabled:              &enabled,
+			KeepFiringFor:        &keepFiringFor,
+			For:                  &forDuration,
+			RepeatInterval:       &repeatInterval,
+			DashboardID:          &d.DashboardID,
+			PanelID:              &d.PanelID,
+			TemplateVariable:     &d.TemplateVariable,
+			TemplateValues:       d.TemplateValues,
+			MaxSeries:            &d.MaxSeries,
+			GuardCondition:       d.GuardCondition,
+			ConfirmCondition:     d.ConfirmCondition,
+			Labels:               d.Labels,
+			QueryCacheTTL:        &queryCacheTTL,
+			Priority:             &d.Priority,
+			ActiveTimeIntervals:  &d.ActiveTimeIntervals,
+			MinAlertingInstances: &d.MinAlertingInstances,
+			MaxStaleness:         &maxStaleness,
+			AlignmentOffset:      &alignmentOffset,
+			MaxIntervalSeconds:   &d.MaxIntervalSeconds,
+			FolderUID:            &d.FolderUID,
+			RuleGroup:            &d.RuleGroup,
+			DependsOn:            d.DependsOn,
+			Features:             d.Features,
+			RelativeTimeRange:    d.RelativeTimeRange,
+		})
+	case err == nil:
+		ng.log.Info("skipping the imported alert definition with an existing UID", "uid", d.UID, "orgID", orgID)
+		return nil
+	case !errors.Is(err, errAlertDefinitionNotFound):
+		return err
+	}
+
+	return ng.saveAlertDefinition(&saveAlertDefinitionCommand{
+		UID:                  d.UID,
+		OrgID:                orgID,
+		Title:                d.Title,
+		Condition:            condition,
+		IntervalSeconds:      &intervalSeconds,
+		Enabled:              &enabled,
+		KeepFiringFor:        &keepFiringFor,
+		For:                  &forDuration,
+		RepeatInterval:       &repeatInterval,
+		DashboardID:          d.DashboardID,
+		PanelID:              d.PanelID,
+		TemplateVariable:     d.TemplateVariable,
+		TemplateValues:       d.TemplateValues,
+		MaxSeries:            d.MaxSeries,
+		GuardCondition:       d.GuardCondition,
+		ConfirmCondition:     d.ConfirmCondition,
+		Labels:               d.Labels,
+		QueryCacheTTL:        &queryCacheTTL,
+		Priority:             d.Priority,
+		ActiveTimeIntervals:  d.ActiveTimeIntervals,
+		MinAlertingInstances: d.MinAlertingInstances,
+		MaxStaleness:         &maxStaleness,
+		AlignmentOffset:      &alignmentOffset,
+		MaxIntervalSeconds:   d.MaxIntervalSeconds,
+		FolderUID:            d.FolderUID,
+		RuleGroup:            d.RuleGroup,
+		DependsOn:            d.DependsOn,
+		Features:             d.Features,
+		RelativeTimeRange:    d.RelativeTimeRange,
+	})
+}
+
+// parseUIDCollisionPolicy returns the UID collision policy, skipping by default.
+func parseUIDCollisionPolicy(s string) (uidCollisionPolicy, error) {
+	switch policy := uidCollisionPolicy(s); policy {
+	case "", skipOnUIDCollision:
+		return skipOnUIDCollision, nil
+	case overwriteOnUIDCollision:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid alert definition import UID collision policy: %s", s)
+	}
+}
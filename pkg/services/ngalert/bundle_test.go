@@ -0,0 +1,118 @@
+package ngalert
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportImportDefinitions(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	for _, labels := range []map[string]string{{"team": "x"}, {"team": "y"}} {
+		alertDefinition := createTestAlertDefinition(t, ng, 60)
+		err := ng.updateAlertDefinition(&updateAlertDefinitionCommand{
+			ID:     alertDefinition.ID,
+			OrgID:  alertDefinition.OrgID,
+			Labels: labels,
+		})
+		require.NoError(t, err)
+	}
+
+	exported, err := ng.ExportDefinitions(1)
+	require.NoError(t, err)
+	bundle := unmarshalBundle(t, exported)
+	require.Len(t, bundle.Definitions, 2)
+
+	// a fresh store
+	fresh := setupTestEnv(t)
+	q := listAlertDefinitionsQuery{OrgID: 1}
+	require.NoError(t, fresh.getOrgAlertDefinitions(&q))
+	require.Empty(t, q.Result)
+
+	t.Run("reimporting the exported alert definitions should round-trip", func(t *testing.T) {
+		require.NoError(t, fresh.ImportDefinitions(1, exported))
+
+		reexported, err := fresh.ExportDefinitions(1)
+		require.NoError(t, err)
+		assertBundlesEqual(t, bundle, unmarshalBundle(t, reexported))
+	})
+
+	t.Run("the alert definitions with an existing UID should be skipped by default", func(t *testing.T) {
+		renamed := unmarshalBundle(t, exported)
+		renamed.Definitions[0].Title = "renamed"
+		require.NoError(t, fresh.ImportDefinitions(1, marshalBundle(t, renamed)))
+
+		reexported, err := fresh.ExportDefinitions(1)
+		require.NoError(t, err)
+		assertBundlesEqual(t, bundle, unmarshalBundle(t, reexported))
+	})
+
+	t.Run("the alert definitions with an existing UID should be overwritten if configured", func(t *testing.T) {
+		fresh.importPolicy = overwriteOnUIDCollision
+		renamed := unmarshalBundle(t, exported)
+		renamed.Definitions[0].Title = "renamed"
+		require.NoError(t, fresh.ImportDefinitions(1, marshalBundle(t, renamed)))
+
+		q := getAlertDefinitionByUIDQuery{UID: renamed.Definitions[0].UID, OrgID: 1}
+		require.NoError(t, fresh.getAlertDefinitionByUID(&q))
+		assert.Equal(t, "renamed", q.Result.Title)
+	})
+
+	t.Run("a bundle with an invalid interval should be rejected", func(t *testing.T) {
+		invalid := unmarshalBundle(t, exported)
+		invalid.Definitions[0].UID = "new-uid"
+		invalid.Definitions[0].IntervalSeconds = 61
+		require.Error(t, fresh.ImportDefinitions(1, marshalBundle(t, invalid)))
+
+		q := getAlertDefinitionByUIDQuery{UID: "new-uid", OrgID: 1}
+		assert.Error(t, fresh.getAlertDefinitionByUID(&q))
+	})
+
+	t.Run("a bundle of an unsupported version should be rejected", func(t *testing.T) {
+		future := unmarshalBundle(t, exported)
+		future.Version = definitionBundleVersion + 1
+		require.Error(t, fresh.ImportDefinitions(1, marshalBundle(t, future)))
+	})
+}
+
+func unmarshalBundle(t *testing.T, b []byte) definitionBundle {
+	t.Helper()
+	var bundle definitionBundle
+	require.NoError(t, json.Unmarshal(b, &bundle))
+	return bundle
+}
+
+func marshalBundle(t *testing.T, bundle definitionBundle) []byte {
+	t.Helper()
+	b, err := json.Marshal(bundle)
+	require.NoError(t, err)
+	return b
+}
+
+// assertBundlesEqual compares the bundles by UID; the query models are compared as JSON.
+func assertBundlesEqual(t *testing.T, expected, actual definitionBundle) {
+	t.Helper()
+	assert.Equal(t, expected.Version, actual.Version)
+	require.Len(t, actual.Definitions, len(expected.Definitions))
+
+	byUID := make(map[string]bundledDefinition, len(actual.Definitions))
+	for _, d := range actual.Definitions {
+		byUID[d.UID] = d
+	}
+	for _, e := range expected.Definitions {
+		a, ok := byUID[e.UID]
+		require.True(t, ok, "alert definition %s is missing", e.UID)
+		require.Len(t, a.Data, len(e.Data))
+		for i := range e.Data {
+			assert.Equal(t, e.Data[i].RefID, a.Data[i].RefID)
+			assert.JSONEq(t, string(e.Data[i].Model), string(a.Data[i].Model))
+		}
+		e.Data, a.Data = nil, nil
+		assert.Equal(t, e, a)
+	}
+}
@@ -0,0 +1,152 @@
+package ngalert
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+const (
+	// canaryUID is the UID of the built-in canary alert definition.
+	canaryUID = "__canary__"
+	// canaryDefinitionID is the ID of the canary alert definition;
+	// it's negative so that it never collides with the stored alert definitions.
+	canaryDefinitionID int64 = -1
+	// canaryStaleIntervals is the number of intervals after which
+	// the canary is unhealthy if it has not been evaluated.
+	canaryStaleIntervals = 2
+)
+
+// canary is the built-in alert definition evaluating an always true condition
+// through the scheduler, the evaluator and the emission of the results on every tick
+// so that the operators can alert on the alerting system itself.
+type canary struct {
+	definition *AlertDefinition
+
+	mu             sync.RWMutex
+	lastEvaluation time.Time
+}
+
+func newCanary(interval time.Duration) (*canary, error) {
+	definition := &AlertDefinition{
+		ID:        canaryDefinitionID,
+		UID:       canaryUID,
+		Title:     "ngalert canary",
+		Condition: "A",
+		Data: []eval.AlertQuery{
+			{
+				RefID: "A",
+				Model: json.RawMessage(`{
+					"datasource": "__expr__",
+					"type": "math",
+					"expression": "1 > 0"
+				}`),
+			},
+		},
+		IntervalSeconds: int64(interval.Seconds()),
+		Version:         1,
+		Enabled:         true,
+		Labels:          map[string]string{"canary": "true"},
+	}
+	if err := definition.preSave(); err != nil {
+		return nil, err
+	}
+	return &canary{definition: definition}, nil
+}
+
+// Receive records the evaluation of the canary once its results are emitted.
+func (c *canary) Receive(instances []alertInstance) error {
+	for _, instance := range instances {
+		if instance.DefinitionUID != canaryUID {
+			continue
+		}
+		c.mu.Lock()
+		if instance.LastEvaluatedAt.After(c.lastEvaluation) {
+			c.lastEvaluation = instance.LastEvaluatedAt
+		}
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+func (c *canary) lastEvaluated() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lastEvaluation
+}
+
+// canaryStore is a DefinitionStore serving the canary alert definition
+// in addition to the alert definitions of the wrapped store.
+type canaryStore struct {
+	DefinitionStore
+	canary *AlertDefinition
+}
+
+func (s canaryStore) GetByUID(orgID int64, uid string) (*AlertDefinition, error) {
+	if orgID == s.canary.OrgID && uid == s.canary.UID {
+		return s.canary, nil
+	}
+	return s.DefinitionStore.GetByUID(orgID, uid)
+}
+
+func (s canaryStore) GetByID(id int64) (*AlertDefinition, error) {
+	if id == s.canary.ID {
+		return s.canary, nil
+	}
+	return s.DefinitionStore.GetByID(id)
+}
+
+func (s canaryStore) FetchAll() ([]*AlertDefinition, error) {
+	alertDefinitions, err := s.DefinitionStore.FetchAll()
+	if err != nil {
+		return nil, err
+	}
+	return append(alertDefinitions, s.canary), nil
+}
+
+// enableCanary schedules the canary alert definition every base interval.
+func (ng *AlertNG) enableCanary() error {
+	c, err := newCanary(ng.schedule.baseInterval)
+	if err != nil {
+		return err
+	}
+	if _, err := ng.schedule.sinks.add("canary", c, 1); err != nil {
+		return err
+	}
+	ng.schedule.canary = c
+	return nil
+}
+
+// CanaryHealth is the health of the canary alert definition.
+type CanaryHealth struct {
+	// LastEvaluation is the time of the last evaluation of the canary; it's zero if it has not been evaluated yet.
+	LastEvaluation time.Time `json:"last_evaluation"`
+	// Healthy is false if the canary has not been evaluated for two intervals.
+	Healthy bool `json:"healthy"`
+}
+
+// SchedulerHealth is the health of the scheduler.
+type SchedulerHealth struct {
+	// Canary is nil unless the canary is enabled.
+	Canary *CanaryHealth `json:"canary,omitempty"`
+}
+
+// Health returns the health of the scheduler.
+func (ng *AlertNG) Health() SchedulerHealth {
+	var health SchedulerHealth
+	if c := ng.schedule.canary; c != nil {
+		lastEvaluation := c.lastEvaluated()
+		// the canary is given two intervals from the scheduler start to be evaluated
+		since := lastEvaluation
+		if since.IsZero() {
+			since = ng.schedule.startedAt
+		}
+		health.Canary = &CanaryHealth{
+			LastEvaluation: lastEvaluation,
+			Healthy:        ng.schedule.clock.Now().Sub(since) < canaryStaleIntervals*time.Duration(c.definition.IntervalSeconds)*time.Second,
+		}
+	}
+	return health
+}
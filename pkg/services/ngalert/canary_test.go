@@ -0,0 +1,59 @@
+package ngalert
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertingTickerCanary(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	assert.Nil(t, ng.Health().Canary, "the canary should be opt-in")
+	require.NoError(t, ng.enableCanary())
+
+	evalAppliedCh := make(chan evalAppliedInfo, 1)
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = ng.alertingTicker(ctx)
+	}()
+	runtime.Gosched()
+
+	// the canary is evaluated on every tick through the whole pipeline
+	var tick time.Time
+	for i := 0; i < 2; i++ {
+		tick = advanceClock(t, mockedClock)
+		assertEvalRun(t, evalAppliedCh, tick, canaryDefinitionID)
+	}
+	instances := ng.schedule.stateTracker.get(getKey(ng.schedule.canary.definition))
+	require.Len(t, instances, 1)
+	assert.Equal(t, eval.Alerting, instances[0].State)
+
+	require.Eventually(t, func() bool {
+		return ng.Health().Canary.LastEvaluation.Equal(tick)
+	}, time.Second, 10*time.Millisecond)
+	assert.True(t, ng.Health().Canary.Healthy)
+
+	// the canary is unhealthy once it has not been evaluated for two intervals
+	require.NoError(t, ng.schedule.pause())
+	mockedClock.Add(2 * time.Second)
+	health := ng.Health()
+	assert.Equal(t, tick, health.Canary.LastEvaluation)
+	assert.False(t, health.Canary.Healthy)
+}
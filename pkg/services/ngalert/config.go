@@ -0,0 +1,74 @@
+package ngalert
+
+import (
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+// evenSpread is the spread strategy dispatching the evaluations due on a tick
+// evenly over the base interval.
+const evenSpread = "even"
+
+// SchedulerConfig is the running configuration of the scheduler.
+type SchedulerConfig struct {
+	BaseInterval eval.Duration `json:"base_interval"`
+	MaxAttempts  int64         `json:"max_attempts"`
+	Backoff      eval.Duration `json:"backoff"`
+	// NoDataRetries is the number of retries of the evaluations without results, NoDataRetryBackoff apart.
+	NoDataRetries      int           `json:"nodata_retries"`
+	NoDataRetryBackoff eval.Duration `json:"nodata_retry_backoff"`
+	// MaxConcurrentEvaluations and MaxConcurrentEvaluationsPerOrg are 0 if the number is not limited.
+	MaxConcurrentEvaluations       int           `json:"max_concurrent_evaluations"`
+	MaxConcurrentEvaluationsPerOrg int           `json:"max_concurrent_evaluations_per_org"`
+	PriorityAging                  eval.Duration `json:"priority_aging"`
+	SaturationWindow               eval.Duration `json:"saturation_window"`
+	SaturationThreshold            float64       `json:"saturation_threshold"`
+	// QuarantineThreshold is 0 if the alert definitions are never quarantined.
+	QuarantineThreshold          int           `json:"quarantine_threshold"`
+	QuarantineFactor             int64         `json:"quarantine_factor"`
+	Spread                       string        `json:"spread"`
+	DispatchJitter               eval.Duration `json:"dispatch_jitter"`
+	MaxDispatchOffset            eval.Duration `json:"max_dispatch_offset"`
+	MaxRoutineStartupDelay       eval.Duration `json:"max_routine_startup_delay"`
+	EvaluationTimeAtDispatch     bool          `json:"evaluation_time_at_dispatch"`
+	CancelSupersededEvaluations  bool          `json:"cancel_superseded_evaluations"`
+	SupersededEvaluationDebounce eval.Duration `json:"superseded_evaluation_debounce"`
+	MaxSeries                    int64         `json:"max_series"`
+	StartupGracePeriod           eval.Duration `json:"startup_grace_period"`
+	ShutdownGracePeriod          eval.Duration `json:"shutdown_grace_period"`
+	ShutdownOrgPriorities        map[int64]int `json:"shutdown_org_priorities,omitempty"`
+	MaxRoutineLifetime           eval.Duration `json:"max_routine_lifetime"`
+	DatasourceWarmUp             bool          `json:"datasource_warmup"`
+	DatasourceWarmUpTimeout      eval.Duration `json:"datasource_warmup_timeout"`
+}
+
+// Config returns a snapshot of the running configuration of the scheduler.
+func (sch *schedule) Config() SchedulerConfig {
+	return SchedulerConfig{
+		BaseInterval:                   eval.Duration(sch.baseInterval),
+		MaxAttempts:                    sch.getMaxAttempts(),
+		Backoff:                        eval.Duration(sch.getBackoff()),
+		NoDataRetries:                  sch.noDataRetries,
+		NoDataRetryBackoff:             eval.Duration(sch.noDataBackoff),
+		MaxConcurrentEvaluations:       sch.evalSemaphore.size,
+		MaxConcurrentEvaluationsPerOrg: sch.orgEvalSemaphores.size,
+		PriorityAging:                  eval.Duration(sch.evalSemaphore.aging),
+		SaturationWindow:               eval.Duration(sch.saturation.window),
+		SaturationThreshold:            sch.saturation.threshold,
+		QuarantineThreshold:            sch.quarantines.threshold,
+		QuarantineFactor:               sch.quarantines.factor,
+		Spread:                         evenSpread,
+		DispatchJitter:                 eval.Duration(sch.dispatchJitter),
+		MaxDispatchOffset:              eval.Duration(sch.maxDispatchOffset),
+		MaxRoutineStartupDelay:         eval.Duration(sch.maxStartupDelay),
+		EvaluationTimeAtDispatch:       sch.evalAtDispatchTime,
+		CancelSupersededEvaluations:    sch.cancelSuperseded,
+		SupersededEvaluationDebounce:   eval.Duration(sch.supersedeDebounce),
+		MaxSeries:                      sch.maxSeries,
+		StartupGracePeriod:             eval.Duration(sch.startupGracePeriod),
+		ShutdownGracePeriod:            eval.Duration(sch.shutdownGracePeriod),
+		ShutdownOrgPriorities:          sch.orgShutdownPriorities,
+		MaxRoutineLifetime:             eval.Duration(sch.maxRoutineLifetime),
+		DatasourceWarmUp:               sch.datasourceWarmUp.enabled,
+		DatasourceWarmUpTimeout:        eval.Duration(sch.datasourceWarmUp.timeout),
+	}
+}
@@ -0,0 +1,63 @@
+package ngalert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedulerConfig(t *testing.T) {
+	sch := newScheduler(clock.NewMock(), 10*time.Second, log.New("ngalert.schedule.test"), nil)
+
+	assert.Equal(t, SchedulerConfig{
+		BaseInterval:                 eval.Duration(10 * time.Second),
+		MaxAttempts:                  maxAttempts,
+		PriorityAging:                eval.Duration(defaultPriorityAging),
+		SaturationWindow:             eval.Duration(defaultSaturationWindow),
+		SaturationThreshold:          defaultSaturationThreshold,
+		QuarantineThreshold:          defaultQuarantineThreshold,
+		QuarantineFactor:             defaultQuarantineFactor,
+		Spread:                       evenSpread,
+		SupersededEvaluationDebounce: eval.Duration(defaultSupersedeDebounce),
+		NoDataRetryBackoff:           eval.Duration(defaultNoDataRetryBackoff),
+		DatasourceWarmUpTimeout:      eval.Duration(defaultDatasourceWarmUpTimeout),
+	}, sch.Config())
+
+	sch.setMaxAttempts(5)
+	sch.setBackoff(time.Second)
+	sch.evalSemaphore = newEvalSemaphore(sch.clock, 4)
+	sch.orgEvalSemaphores = newOrgEvalSemaphores(2)
+	sch.dispatchJitter = 500 * time.Millisecond
+	sch.evalAtDispatchTime = true
+	sch.maxSeries = 1000
+	sch.startupGracePeriod = time.Minute
+	sch.shutdownGracePeriod = 30 * time.Second
+	sch.maxRoutineLifetime = 24 * time.Hour
+
+	assert.Equal(t, SchedulerConfig{
+		BaseInterval:                   eval.Duration(10 * time.Second),
+		MaxAttempts:                    5,
+		Backoff:                        eval.Duration(time.Second),
+		MaxConcurrentEvaluations:       4,
+		MaxConcurrentEvaluationsPerOrg: 2,
+		PriorityAging:                  eval.Duration(defaultPriorityAging),
+		SaturationWindow:               eval.Duration(defaultSaturationWindow),
+		SaturationThreshold:            defaultSaturationThreshold,
+		QuarantineThreshold:            defaultQuarantineThreshold,
+		QuarantineFactor:               defaultQuarantineFactor,
+		Spread:                         evenSpread,
+		DispatchJitter:                 eval.Duration(500 * time.Millisecond),
+		EvaluationTimeAtDispatch:       true,
+		SupersededEvaluationDebounce:   eval.Duration(defaultSupersedeDebounce),
+		NoDataRetryBackoff:             eval.Duration(defaultNoDataRetryBackoff),
+		MaxSeries:                      1000,
+		StartupGracePeriod:             eval.Duration(time.Minute),
+		ShutdownGracePeriod:            eval.Duration(30 * time.Second),
+		MaxRoutineLifetime:             eval.Duration(24 * time.Hour),
+		DatasourceWarmUpTimeout:        eval.Duration(defaultDatasourceWarmUpTimeout),
+	}, sch.Config())
+}
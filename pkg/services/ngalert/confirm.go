@@ -0,0 +1,49 @@
+package ngalert
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+// confirmResults evaluates the confirm condition if the condition is true for any instance
+// and makes Normal the Alerting instances the confirm condition is not true for,
+// so that they only fire if both conditions indicate a problem.
+// The confirm condition is not evaluated otherwise, saving the load of its queries.
+// A confirm result without labels applies to all the instances.
+func (sch *schedule) confirmResults(ctx context.Context, key string, results eval.Results, confirm *eval.Condition, now time.Time) (eval.Results, error) {
+	if !guardHolds(results) {
+		return results, nil
+	}
+
+	confirmResults, err := sch.evaluator.ConditionEval(ctx, confirm, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate confirm condition: %w", err)
+	}
+	confirmed := make(map[string]bool, len(confirmResults))
+	for _, r := range confirmResults {
+		confirmed[fingerprint(r.Instance)] = r.State == eval.Alerting
+	}
+	_, global := confirmed[fingerprint(nil)]
+
+	unconfirmed := 0
+	for i, r := range results {
+		if r.State != eval.Alerting {
+			continue
+		}
+		ok, found := confirmed[fingerprint(r.Instance)]
+		if !found && global {
+			ok = confirmed[fingerprint(nil)]
+		}
+		if !ok {
+			results[i].State = eval.Normal
+			unconfirmed++
+		}
+	}
+	if unconfirmed > 0 {
+		sch.log.Debug("alerting instances not confirmed by the confirm condition", "key", key, "confirm", confirm.RefID, "count", unconfirmed, "now", now)
+	}
+	return results, nil
+}
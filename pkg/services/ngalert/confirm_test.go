@@ -0,0 +1,135 @@
+package ngalert
+
+import (
+	"context"
+	"encoding/json"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertingTickerConfirmCondition(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+
+	var errorsElevated int32 = 1
+	var mu sync.Mutex
+	var evaluated []string
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(_ context.Context, condition *eval.Condition, _ time.Time) (eval.Results, error) {
+		mu.Lock()
+		evaluated = append(evaluated, condition.RefID)
+		mu.Unlock()
+
+		if condition.RefID == "latency" {
+			// the latency is never elevated
+			return eval.Results{{Instance: data.Labels{"host": "a"}, State: eval.Normal}}, nil
+		}
+		state := eval.Normal
+		if atomic.LoadInt32(&errorsElevated) == 1 {
+			state = eval.Alerting
+		}
+		return eval.Results{{Instance: data.Labels{"host": "a"}, State: state}}, nil
+	})
+
+	var intervalSeconds int64 = 1
+	cmd := saveAlertDefinitionCommand{
+		OrgID: 1,
+		Title: "error rate",
+		Condition: eval.Condition{
+			RefID: "errors",
+			QueriesAndExpressions: []eval.AlertQuery{
+				{RefID: "latency", Model: json.RawMessage(`{"datasource":"__expr__","type":"math","expression":"1 > 2"}`)},
+				{RefID: "errors", Model: json.RawMessage(`{"datasource":"__expr__","type":"math","expression":"5 > 1"}`)},
+			},
+		},
+		IntervalSeconds:  &intervalSeconds,
+		ConfirmCondition: "latency",
+	}
+	require.NoError(t, ng.saveAlertDefinition(&cmd))
+	alert := cmd.Result
+	key := getKey(alert)
+
+	evalAppliedCh := make(chan evalAppliedInfo, 1)
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		_ = ng.alertingTicker(ctx)
+	}()
+	runtime.Gosched()
+
+	takeEvaluated := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		refIDs := evaluated
+		evaluated = nil
+		return refIDs
+	}
+
+	t.Run("on 1st tick the condition is true but not confirmed and the instance should not be alerting", func(t *testing.T) {
+		tick := advanceClock(t, mockedClock)
+		assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+		assert.Equal(t, []string{"errors", "latency"}, takeEvaluated())
+
+		instances := ng.schedule.stateTracker.get(key)
+		require.Len(t, instances, 1)
+		assert.Equal(t, eval.Normal, instances[0].State)
+	})
+
+	atomic.StoreInt32(&errorsElevated, 0)
+
+	t.Run("on 2nd tick the condition is false and the confirm condition should not be evaluated", func(t *testing.T) {
+		tick := advanceClock(t, mockedClock)
+		assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+		assert.Equal(t, []string{"errors"}, takeEvaluated())
+	})
+}
+
+func TestConfirmResults(t *testing.T) {
+	sch := newScheduler(clock.NewMock(), time.Second, log.New("ngalert.schedule.test"), nil)
+	confirm := &eval.Condition{RefID: "latency"}
+	results := func() eval.Results {
+		return eval.Results{
+			{Instance: data.Labels{"host": "a"}, State: eval.Alerting},
+			{Instance: data.Labels{"host": "b"}, State: eval.Alerting},
+			{Instance: data.Labels{"host": "c"}, State: eval.Normal},
+		}
+	}
+
+	t.Run("the instances are confirmed by their labels", func(t *testing.T) {
+		sch.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+			return eval.Results{
+				{Instance: data.Labels{"host": "a"}, State: eval.Alerting},
+				{Instance: data.Labels{"host": "b"}, State: eval.Normal},
+			}, nil
+		})
+		confirmed, err := sch.confirmResults(context.Background(), "1:uid", results(), confirm, time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, []eval.State{eval.Alerting, eval.Normal, eval.Normal}, []eval.State{confirmed[0].State, confirmed[1].State, confirmed[2].State})
+	})
+
+	t.Run("a confirm result without labels applies to all the instances", func(t *testing.T) {
+		sch.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+			return eval.Results{{State: eval.Alerting}}, nil
+		})
+		confirmed, err := sch.confirmResults(context.Background(), "1:uid", results(), confirm, time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, []eval.State{eval.Alerting, eval.Alerting, eval.Normal}, []eval.State{confirmed[0].State, confirmed[1].State, confirmed[2].State})
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/grafana/grafana/pkg/services/sqlstore"
 	"github.com/grafana/grafana/pkg/util"
@@ -24,7 +25,7 @@ func getAlertDefinitionByID(alertDefinitionID int64, sess *sqlstore.DBSession) (
 // deleteAlertDefinitionByID is a handler for deleting an alert definition.
 // It returns models.ErrAlertDefinitionNotFound if no alert definition is found for the provided ID.
 func (ng *AlertNG) deleteAlertDefinitionByID(cmd *deleteAlertDefinitionByIDCommand) error {
-	return ng.SQLStore.WithTransactionalDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+	err := ng.SQLStore.WithTransactionalDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
 		res, err := sess.Exec("DELETE FROM alert_definition WHERE id = ?", cmd.ID)
 		if err != nil {
 			return err
@@ -41,6 +42,47 @@ func (ng *AlertNG) deleteAlertDefinitionByID(cmd *deleteAlertDefinitionByIDComma
 			return err
 		}
 
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// the lock is released right away rather than once the scheduler no longer fetches the alert definition
+	ng.schedule.definitionLocks.del(cmd.ID)
+	return nil
+}
+
+// deleteExpiredAlertDefinitionVersions is a handler for deleting the alert definition versions
+// exceeding the retention policy. The current version of each alert definition is always kept.
+func (ng *AlertNG) deleteExpiredAlertDefinitionVersions(cmd *deleteExpiredAlertDefinitionVersionsCommand) error {
+	return ng.SQLStore.WithTransactionalDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		currentVersion := "(SELECT version FROM alert_definition WHERE alert_definition.id = alert_definition_version.alert_definition_id)"
+
+		if !cmd.CreatedBefore.IsZero() {
+			res, err := sess.Exec("DELETE FROM alert_definition_version WHERE created < ? AND version < "+currentVersion, cmd.CreatedBefore)
+			if err != nil {
+				return err
+			}
+			rowsAffected, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			cmd.RowsAffected += rowsAffected
+		}
+
+		if cmd.MaxCount > 0 {
+			// versions are incremented by one on every update
+			res, err := sess.Exec("DELETE FROM alert_definition_version WHERE version <= "+currentVersion+" - ?", cmd.MaxCount)
+			if err != nil {
+				return err
+			}
+			rowsAffected, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			cmd.RowsAffected += rowsAffected
+		}
+
 		return nil
 	})
 }
@@ -58,6 +100,43 @@ func (ng *AlertNG) getAlertDefinitionByID(query *getAlertDefinitionByIDQuery) er
 	})
 }
 
+// getAlertDefinitionByUID is a handler for retrieving an alert definition from that database by its organisation and UID.
+// It returns errAlertDefinitionNotFound if no alert definition is found for the provided UID.
+func (ng *AlertNG) getAlertDefinitionByUID(query *getAlertDefinitionByUIDQuery) error {
+	return ng.SQLStore.WithTransactionalDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		alertDefinition := AlertDefinition{}
+		has, err := sess.Where("org_id=? AND uid=?", query.OrgID, query.UID).Get(&alertDefinition)
+		if err != nil {
+			return err
+		}
+		if !has {
+			return errAlertDefinitionNotFound
+		}
+		query.Result = &alertDefinition
+		return nil
+	})
+}
+
+// getAlertDefinitionVersion is a handler for retrieving a version of an alert definition from the database.
+// It returns errAlertDefinitionVersionNotFound if the alert definition of the organisation has no such version.
+func (ng *AlertNG) getAlertDefinitionVersion(query *getAlertDefinitionVersionQuery) error {
+	return ng.SQLStore.WithTransactionalDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		version := AlertDefinitionVersion{}
+		has, err := sess.SQL("SELECT alert_definition_version.* FROM alert_definition_version "+
+			"INNER JOIN alert_definition ON alert_definition.id = alert_definition_version.alert_definition_id "+
+			"WHERE alert_definition.org_id = ? AND alert_definition_version.alert_definition_uid = ? AND alert_definition_version.version = ?",
+			query.OrgID, query.UID, query.Version).Get(&version)
+		if err != nil {
+			return err
+		}
+		if !has {
+			return errAlertDefinitionVersionNotFound
+		}
+		query.Result = &version
+		return nil
+	})
+}
+
 // saveAlertDefinition is a handler for saving a new alert definition.
 func (ng *AlertNG) saveAlertDefinition(cmd *saveAlertDefinitionCommand) error {
 	return ng.SQLStore.WithTransactionalDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
@@ -68,9 +147,26 @@ func (ng *AlertNG) saveAlertDefinition(cmd *saveAlertDefinitionCommand) error {
 
 		var initialVersion int64 = 1
 
-		uid, err := generateNewAlertDefinitionUID(sess, cmd.OrgID)
-		if err != nil {
-			return fmt.Errorf("failed to generate UID for alert definition %q: %w", cmd.Title, err)
+		enabled := true
+		if cmd.Enabled != nil {
+			enabled = *cmd.Enabled
+		}
+
+		uid := cmd.UID
+		if uid == "" {
+			generated, err := generateNewAlertDefinitionUID(sess, cmd.OrgID)
+			if err != nil {
+				return fmt.Errorf("failed to generate UID for alert definition %q: %w", cmd.Title, err)
+			}
+			uid = generated
+		} else {
+			exists, err := sess.Where("org_id=? AND uid=?", cmd.OrgID, uid).Get(&AlertDefinition{})
+			if err != nil {
+				return err
+			}
+			if exists {
+				return fmt.Errorf("%w: %s", errAlertDefinitionUIDExists, uid)
+			}
 		}
 
 		alertDefinition := &AlertDefinition{
@@ -81,6 +177,49 @@ func (ng *AlertNG) saveAlertDefinition(cmd *saveAlertDefinitionCommand) error {
 			IntervalSeconds: intervalSeconds,
 			Version:         initialVersion,
 			UID:             uid,
+			Enabled:         enabled,
+			DashboardID:     cmd.DashboardID,
+			PanelID:         cmd.PanelID,
+
+			RelativeTimeRange:    cmd.RelativeTimeRange,
+			TemplateVariable:     cmd.TemplateVariable,
+			TemplateValues:       cmd.TemplateValues,
+			MaxSeries:            cmd.MaxSeries,
+			GuardCondition:       cmd.GuardCondition,
+			ConfirmCondition:     cmd.ConfirmCondition,
+			Labels:               cmd.Labels,
+			Priority:             cmd.Priority,
+			ActiveTimeIntervals:  cmd.ActiveTimeIntervals,
+			MinAlertingInstances: cmd.MinAlertingInstances,
+			MaxIntervalSeconds:   cmd.MaxIntervalSeconds,
+			FolderUID:            cmd.FolderUID,
+			RuleGroup:            cmd.RuleGroup,
+			DependsOn:            cmd.DependsOn,
+			Features:             cmd.Features,
+		}
+		if cmd.KeepFiringFor != nil {
+			alertDefinition.KeepFiringFor = time.Duration(*cmd.KeepFiringFor)
+		}
+		if cmd.For != nil {
+			alertDefinition.For = time.Duration(*cmd.For)
+		}
+		if cmd.RepeatInterval != nil {
+			alertDefinition.RepeatInterval = time.Duration(*cmd.RepeatInterval)
+		}
+		if cmd.QueryCacheTTL != nil {
+			alertDefinition.QueryCacheTTL = time.Duration(*cmd.QueryCacheTTL)
+		}
+		if cmd.MaxStaleness != nil {
+			alertDefinition.MaxStaleness = time.Duration(*cmd.MaxStaleness)
+		}
+		if cmd.AlignmentOffset != nil {
+			alertDefinition.AlignmentOffset = time.Duration(*cmd.AlignmentOffset)
+		}
+		if cmd.Condition.Trend != nil {
+			alertDefinition.Trend = *cmd.Condition.Trend
+		}
+		if cmd.Condition.Baseline != nil {
+			alertDefinition.Baseline = *cmd.Condition.Baseline
 		}
 
 		if err := ng.validateAlertDefinition(alertDefinition, false); err != nil {
@@ -104,6 +243,7 @@ func (ng *AlertNG) saveAlertDefinition(cmd *saveAlertDefinitionCommand) error {
 			Title:              alertDefinition.Title,
 			Data:               alertDefinition.Data,
 			IntervalSeconds:    alertDefinition.IntervalSeconds,
+			Labels:             alertDefinition.Labels,
 		}
 		if _, err := sess.Insert(alertDefVersion); err != nil {
 			return err
@@ -124,10 +264,110 @@ func (ng *AlertNG) updateAlertDefinition(cmd *updateAlertDefinitionCommand) erro
 			Condition: cmd.Condition.RefID,
 			Data:      cmd.Condition.QueriesAndExpressions,
 			OrgID:     cmd.OrgID,
+
+			RelativeTimeRange: cmd.RelativeTimeRange,
+			TemplateValues:    cmd.TemplateValues,
+			GuardCondition:    cmd.GuardCondition,
+			ConfirmCondition:  cmd.ConfirmCondition,
+			Labels:            cmd.Labels,
+			DependsOn:         cmd.DependsOn,
+			Features:          cmd.Features,
 		}
+		// mustCols are the columns of the options set by the command:
+		// they are updated even to their zero value
+		mustCols := make([]string, 0)
 		if cmd.IntervalSeconds != nil {
 			alertDefinition.IntervalSeconds = *cmd.IntervalSeconds
 		}
+		if cmd.Enabled != nil {
+			alertDefinition.Enabled = *cmd.Enabled
+		}
+		if cmd.KeepFiringFor != nil {
+			alertDefinition.KeepFiringFor = time.Duration(*cmd.KeepFiringFor)
+			mustCols = append(mustCols, "keep_firing_for")
+		}
+		if cmd.For != nil {
+			alertDefinition.For = time.Duration(*cmd.For)
+			mustCols = append(mustCols, "for_duration")
+		}
+		if cmd.RepeatInterval != nil {
+			alertDefinition.RepeatInterval = time.Duration(*cmd.RepeatInterval)
+			mustCols = append(mustCols, "repeat_interval")
+		}
+		if cmd.DashboardID != nil {
+			alertDefinition.DashboardID = *cmd.DashboardID
+			mustCols = append(mustCols, "dashboard_id")
+		}
+		if cmd.PanelID != nil {
+			alertDefinition.PanelID = *cmd.PanelID
+			mustCols = append(mustCols, "panel_id")
+		}
+		if cmd.TemplateVariable != nil {
+			alertDefinition.TemplateVariable = *cmd.TemplateVariable
+			mustCols = append(mustCols, "template_variable")
+		}
+		if cmd.TemplateValues != nil {
+			mustCols = append(mustCols, "template_values")
+		}
+		if cmd.MaxSeries != nil {
+			alertDefinition.MaxSeries = *cmd.MaxSeries
+			mustCols = append(mustCols, "max_series")
+		}
+		if cmd.Labels != nil {
+			mustCols = append(mustCols, "labels")
+		}
+		if cmd.QueryCacheTTL != nil {
+			alertDefinition.QueryCacheTTL = time.Duration(*cmd.QueryCacheTTL)
+			mustCols = append(mustCols, "query_cache_ttl")
+		}
+		if cmd.Priority != nil {
+			alertDefinition.Priority = *cmd.Priority
+			mustCols = append(mustCols, "priority")
+		}
+		if cmd.ActiveTimeIntervals != nil {
+			alertDefinition.ActiveTimeIntervals = *cmd.ActiveTimeIntervals
+			mustCols = append(mustCols, "active_time_intervals")
+		}
+		if cmd.MinAlertingInstances != nil {
+			alertDefinition.MinAlertingInstances = *cmd.MinAlertingInstances
+			mustCols = append(mustCols, "min_alerting_instances")
+		}
+		if cmd.MaxStaleness != nil {
+			alertDefinition.MaxStaleness = time.Duration(*cmd.MaxStaleness)
+			mustCols = append(mustCols, "max_staleness")
+		}
+		if cmd.AlignmentOffset != nil {
+			alertDefinition.AlignmentOffset = time.Duration(*cmd.AlignmentOffset)
+			mustCols = append(mustCols, "alignment_offset")
+		}
+		if cmd.MaxIntervalSeconds != nil {
+			alertDefinition.MaxIntervalSeconds = *cmd.MaxIntervalSeconds
+			mustCols = append(mustCols, "max_interval_seconds")
+		}
+		if cmd.FolderUID != nil {
+			alertDefinition.FolderUID = *cmd.FolderUID
+			mustCols = append(mustCols, "folder_uid")
+		}
+		if cmd.RuleGroup != nil {
+			alertDefinition.RuleGroup = *cmd.RuleGroup
+			mustCols = append(mustCols, "rule_group")
+		}
+		if cmd.DependsOn != nil {
+			mustCols = append(mustCols, "depends_on")
+		}
+		if cmd.Features != nil {
+			mustCols = append(mustCols, "features")
+		}
+		if cmd.Condition.Trend != nil {
+			alertDefinition.Trend = *cmd.Condition.Trend
+		}
+		if cmd.Condition.Baseline != nil {
+			alertDefinition.Baseline = *cmd.Condition.Baseline
+		}
+		// the options referring to the queries and expressions are replaced together with them
+		if cmd.Condition.QueriesAndExpressions != nil {
+			mustCols = append(mustCols, "guard_condition", "confirm_condition", "relative_time_range", "trend", "baseline")
+		}
 
 		if err := ng.validateAlertDefinition(alertDefinition, true); err != nil {
 			return err
@@ -149,6 +389,13 @@ func (ng *AlertNG) updateAlertDefinition(cmd *updateAlertDefinitionCommand) erro
 
 		alertDefinition.Version = existingAlertDefinition.Version + 1
 
+		if cmd.Enabled != nil {
+			sess.UseBool("enabled")
+		}
+		if len(mustCols) > 0 {
+			sess.MustCols(mustCols...)
+		}
+
 		affectedRows, err := sess.ID(cmd.ID).Update(alertDefinition)
 		if err != nil {
 			return err
@@ -170,6 +417,10 @@ func (ng *AlertNG) updateAlertDefinition(cmd *updateAlertDefinitionCommand) erro
 		if intervalSeconds == nil {
 			intervalSeconds = &existingAlertDefinition.IntervalSeconds
 		}
+		labels := cmd.Labels
+		if labels == nil {
+			labels = existingAlertDefinition.Labels
+		}
 
 		alertDefVersion := AlertDefinitionVersion{
 			AlertDefinitionID:  alertDefinition.ID,
@@ -181,6 +432,7 @@ func (ng *AlertNG) updateAlertDefinition(cmd *updateAlertDefinitionCommand) erro
 			Title:              title,
 			Data:               data,
 			IntervalSeconds:    *intervalSeconds,
+			Labels:             labels,
 		}
 		if _, err := sess.Insert(alertDefVersion); err != nil {
 			return err
@@ -192,6 +444,28 @@ func (ng *AlertNG) updateAlertDefinition(cmd *updateAlertDefinitionCommand) erro
 	})
 }
 
+// getAlertDefinitionsByLabels is a handler for retrieving the alert definitions of the organisation
+// whose labels match the selector. The labels are stored serialized
+// so the alert definitions of the organisation are filtered once fetched.
+func (ng *AlertNG) getAlertDefinitionsByLabels(query *listAlertDefinitionsByLabelsQuery) error {
+	return ng.SQLStore.WithTransactionalDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		alertDefinitions := make([]*AlertDefinition, 0)
+		if err := sess.SQL("SELECT id, org_id, uid, labels FROM alert_definition WHERE org_id = ?", query.OrgID).Find(&alertDefinitions); err != nil {
+			return err
+		}
+
+		matching := make([]*AlertDefinition, 0)
+		for _, alertDefinition := range alertDefinitions {
+			if matchesLabelSelector(alertDefinition.Labels, query.Selector) {
+				matching = append(matching, alertDefinition)
+			}
+		}
+
+		query.Result = matching
+		return nil
+	})
+}
+
 // getOrgAlertDefinitions is a handler for retrieving alert definitions of specific organisation.
 func (ng *AlertNG) getOrgAlertDefinitions(query *listAlertDefinitionsQuery) error {
 	return ng.SQLStore.WithTransactionalDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
@@ -206,10 +480,13 @@ func (ng *AlertNG) getOrgAlertDefinitions(query *listAlertDefinitionsQuery) erro
 	})
 }
 
+// scheduledAlertDefinitionColumns are the columns of the alert definitions fetched by the scheduler.
+const scheduledAlertDefinitionColumns = "id, org_id, uid, interval_seconds, version, enabled, updated, template_variable, template_values, priority, alignment_offset, folder_uid, max_interval_seconds"
+
 func (ng *AlertNG) getAlertDefinitions(query *listAlertDefinitionsQuery) error {
 	return ng.SQLStore.WithTransactionalDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
 		alerts := make([]*AlertDefinition, 0)
-		q := "SELECT id, interval_seconds, version FROM alert_definition"
+		q := "SELECT " + scheduledAlertDefinitionColumns + " FROM alert_definition"
 		if err := sess.SQL(q).Find(&alerts); err != nil {
 			return err
 		}
@@ -219,6 +496,22 @@ func (ng *AlertNG) getAlertDefinitions(query *listAlertDefinitionsQuery) error {
 	})
 }
 
+// getAlertDefinitionsUpdatedSince returns the alert definitions updated since the provided time.
+// The alert definitions updated at the provided time are included since the updates
+// of the same second may have been committed after the previous fetch.
+func (ng *AlertNG) getAlertDefinitionsUpdatedSince(query *listAlertDefinitionsUpdatedSinceQuery) error {
+	return ng.SQLStore.WithTransactionalDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		alerts := make([]*AlertDefinition, 0)
+		q := "SELECT " + scheduledAlertDefinitionColumns + " FROM alert_definition WHERE updated >= ?"
+		if err := sess.SQL(q, query.UpdatedSince).Find(&alerts); err != nil {
+			return err
+		}
+
+		query.Result = alerts
+		return nil
+	})
+}
+
 func generateNewAlertDefinitionUID(sess *sqlstore.DBSession, orgID int64) (string, error) {
 	for i := 0; i < 3; i++ {
 		uid := util.GenerateShortUID()
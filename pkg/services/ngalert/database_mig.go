@@ -36,6 +36,110 @@ func addAlertDefinitionMigrations(mg *migrator.Migrator) {
 
 	mg.AddMigration("alter alert_definition table data column to mediumtext in mysql", migrator.NewRawSQLMigration("").
 		Mysql("ALTER TABLE alert_definition MODIFY data MEDIUMTEXT;"))
+
+	mg.AddMigration("add column enabled to alert_definition table", migrator.NewAddColumnMigration(alertDefinition, &migrator.Column{
+		Name: "enabled", Type: migrator.DB_Bool, Nullable: false, Default: "1",
+	}))
+
+	mg.AddMigration("add column keep_firing_for to alert_definition table", migrator.NewAddColumnMigration(alertDefinition, &migrator.Column{
+		Name: "keep_firing_for", Type: migrator.DB_BigInt, Nullable: false, Default: "0",
+	}))
+
+	mg.AddMigration("add column dashboard_id to alert_definition table", migrator.NewAddColumnMigration(alertDefinition, &migrator.Column{
+		Name: "dashboard_id", Type: migrator.DB_BigInt, Nullable: true,
+	}))
+
+	mg.AddMigration("add column panel_id to alert_definition table", migrator.NewAddColumnMigration(alertDefinition, &migrator.Column{
+		Name: "panel_id", Type: migrator.DB_BigInt, Nullable: true,
+	}))
+
+	mg.AddMigration("add column relative_time_range to alert_definition table", migrator.NewAddColumnMigration(alertDefinition, &migrator.Column{
+		Name: "relative_time_range", Type: migrator.DB_Text, Nullable: true,
+	}))
+
+	mg.AddMigration("add column for_duration to alert_definition table", migrator.NewAddColumnMigration(alertDefinition, &migrator.Column{
+		Name: "for_duration", Type: migrator.DB_BigInt, Nullable: false, Default: "0",
+	}))
+
+	mg.AddMigration("add column template_variable to alert_definition table", migrator.NewAddColumnMigration(alertDefinition, &migrator.Column{
+		Name: "template_variable", Type: migrator.DB_NVarchar, Length: 190, Nullable: true,
+	}))
+
+	mg.AddMigration("add column template_values to alert_definition table", migrator.NewAddColumnMigration(alertDefinition, &migrator.Column{
+		Name: "template_values", Type: migrator.DB_Text, Nullable: true,
+	}))
+
+	mg.AddMigration("add column max_series to alert_definition table", migrator.NewAddColumnMigration(alertDefinition, &migrator.Column{
+		Name: "max_series", Type: migrator.DB_BigInt, Nullable: false, Default: "0",
+	}))
+
+	mg.AddMigration("add column guard_condition to alert_definition table", migrator.NewAddColumnMigration(alertDefinition, &migrator.Column{
+		Name: "guard_condition", Type: migrator.DB_NVarchar, Length: 190, Nullable: true,
+	}))
+
+	mg.AddMigration("add column repeat_interval to alert_definition table", migrator.NewAddColumnMigration(alertDefinition, &migrator.Column{
+		Name: "repeat_interval", Type: migrator.DB_BigInt, Nullable: false, Default: "0",
+	}))
+
+	mg.AddMigration("add column labels to alert_definition table", migrator.NewAddColumnMigration(alertDefinition, &migrator.Column{
+		Name: "labels", Type: migrator.DB_Text, Nullable: true,
+	}))
+
+	mg.AddMigration("add column query_cache_ttl to alert_definition table", migrator.NewAddColumnMigration(alertDefinition, &migrator.Column{
+		Name: "query_cache_ttl", Type: migrator.DB_BigInt, Nullable: false, Default: "0",
+	}))
+
+	mg.AddMigration("add column priority to alert_definition table", migrator.NewAddColumnMigration(alertDefinition, &migrator.Column{
+		Name: "priority", Type: migrator.DB_BigInt, Nullable: false, Default: "0",
+	}))
+
+	mg.AddMigration("add column trend to alert_definition table", migrator.NewAddColumnMigration(alertDefinition, &migrator.Column{
+		Name: "trend", Type: migrator.DB_Text, Nullable: true,
+	}))
+
+	mg.AddMigration("add column active_time_intervals to alert_definition table", migrator.NewAddColumnMigration(alertDefinition, &migrator.Column{
+		Name: "active_time_intervals", Type: migrator.DB_Text, Nullable: true,
+	}))
+
+	mg.AddMigration("add column min_alerting_instances to alert_definition table", migrator.NewAddColumnMigration(alertDefinition, &migrator.Column{
+		Name: "min_alerting_instances", Type: migrator.DB_BigInt, Nullable: false, Default: "0",
+	}))
+
+	mg.AddMigration("add column max_staleness to alert_definition table", migrator.NewAddColumnMigration(alertDefinition, &migrator.Column{
+		Name: "max_staleness", Type: migrator.DB_BigInt, Nullable: false, Default: "0",
+	}))
+
+	mg.AddMigration("add column features to alert_definition table", migrator.NewAddColumnMigration(alertDefinition, &migrator.Column{
+		Name: "features", Type: migrator.DB_Text, Nullable: true,
+	}))
+
+	mg.AddMigration("add column alignment_offset to alert_definition table", migrator.NewAddColumnMigration(alertDefinition, &migrator.Column{
+		Name: "alignment_offset", Type: migrator.DB_BigInt, Nullable: false, Default: "0",
+	}))
+
+	mg.AddMigration("add column max_interval_seconds to alert_definition table", migrator.NewAddColumnMigration(alertDefinition, &migrator.Column{
+		Name: "max_interval_seconds", Type: migrator.DB_BigInt, Nullable: false, Default: "0",
+	}))
+
+	mg.AddMigration("add column folder_uid to alert_definition table", migrator.NewAddColumnMigration(alertDefinition, &migrator.Column{
+		Name: "folder_uid", Type: migrator.DB_NVarchar, Length: 40, Nullable: true,
+	}))
+
+	mg.AddMigration("add column confirm_condition to alert_definition table", migrator.NewAddColumnMigration(alertDefinition, &migrator.Column{
+		Name: "confirm_condition", Type: migrator.DB_NVarchar, Length: 190, Nullable: true,
+	}))
+
+	mg.AddMigration("add column baseline to alert_definition table", migrator.NewAddColumnMigration(alertDefinition, &migrator.Column{
+		Name: "baseline", Type: migrator.DB_Text, Nullable: true,
+	}))
+
+	mg.AddMigration("add column rule_group to alert_definition table", migrator.NewAddColumnMigration(alertDefinition, &migrator.Column{
+		Name: "rule_group", Type: migrator.DB_NVarchar, Length: 190, Nullable: true,
+	}))
+
+	mg.AddMigration("add column depends_on to alert_definition table", migrator.NewAddColumnMigration(alertDefinition, &migrator.Column{
+		Name: "depends_on", Type: migrator.DB_Text, Nullable: true,
+	}))
 }
 
 func addAlertDefinitionVersionMigrations(mg *migrator.Migrator) {
@@ -67,4 +171,8 @@ func addAlertDefinitionVersionMigrations(mg *migrator.Migrator) {
 
 	mg.AddMigration("alter alert_definition_version table data column to mediumtext in mysql", migrator.NewRawSQLMigration("").
 		Mysql("ALTER TABLE alert_definition_version MODIFY data MEDIUMTEXT;"))
+
+	mg.AddMigration("add column labels to alert_definition_version table", migrator.NewAddColumnMigration(alertDefinitionVersion, &migrator.Column{
+		Name: "labels", Type: migrator.DB_Text, Nullable: true,
+	}))
 }
@@ -264,6 +264,55 @@ func TestUpdatingAlertDefinition(t *testing.T) {
 		}
 
 	})
+
+	t.Run("updating the options of an existing alert to their zero value", func(t *testing.T) {
+		ng := setupTestEnv(t)
+		alertDefinition := createTestAlertDefinition(t, ng, 60)
+		get := func() *AlertDefinition {
+			q := getAlertDefinitionByIDQuery{ID: alertDefinition.ID}
+			require.NoError(t, ng.getAlertDefinitionByID(&q))
+			return q.Result
+		}
+
+		forDuration, priority, folderUID := eval.Duration(time.Minute), int64(1), "ops"
+		require.NoError(t, ng.updateAlertDefinition(&updateAlertDefinitionCommand{
+			ID:             alertDefinition.ID,
+			OrgID:          alertDefinition.OrgID,
+			Condition:      alertDefinition.getCondition(),
+			GuardCondition: "A",
+			For:            &forDuration,
+			Priority:       &priority,
+			FolderUID:      &folderUID,
+			Labels:         map[string]string{"team": "a"},
+		}))
+
+		// the options that are not set are not updated
+		enabled := true
+		require.NoError(t, ng.updateAlertDefinition(&updateAlertDefinitionCommand{ID: alertDefinition.ID, OrgID: alertDefinition.OrgID, Enabled: &enabled}))
+		updated := get()
+		assert.Equal(t, "A", updated.GuardCondition)
+		assert.Equal(t, time.Minute, updated.For)
+		assert.Equal(t, int64(1), updated.Priority)
+		assert.Equal(t, "ops", updated.FolderUID)
+		assert.Equal(t, map[string]string{"team": "a"}, updated.Labels)
+
+		forDuration, priority, folderUID = 0, 0, ""
+		require.NoError(t, ng.updateAlertDefinition(&updateAlertDefinitionCommand{
+			ID:        alertDefinition.ID,
+			OrgID:     alertDefinition.OrgID,
+			Condition: alertDefinition.getCondition(),
+			For:       &forDuration,
+			Priority:  &priority,
+			FolderUID: &folderUID,
+			Labels:    map[string]string{},
+		}))
+		updated = get()
+		assert.Empty(t, updated.GuardCondition, "the guard condition should be replaced together with the queries")
+		assert.Zero(t, updated.For)
+		assert.Zero(t, updated.Priority)
+		assert.Empty(t, updated.FolderUID)
+		assert.Empty(t, updated.Labels)
+	})
 }
 
 func TestDeletingAlertDefinition(t *testing.T) {
@@ -0,0 +1,40 @@
+package ngalert
+
+import (
+	"sort"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+// alertDefinitionDatasources returns the identifiers of the datasources queried by the alert definition.
+// Datasources are identified by their ID in this version of the alert definitions.
+func alertDefinitionDatasources(def *AlertDefinition) []int64 {
+	return queriedDatasources(def.Data)
+}
+
+// queriedDatasources returns the sorted identifiers of the datasources queried by the queries, without duplicates.
+// The expressions are skipped since they reference other queries rather than a datasource
+// and so are the queries without a valid datasource.
+func queriedDatasources(queries []eval.AlertQuery) []int64 {
+	seen := make(map[int64]struct{}, len(queries))
+	datasourceIDs := make([]int64, 0, len(queries))
+	for i := range queries {
+		q := &queries[i]
+		if isExpression, err := q.IsExpression(); err != nil || isExpression {
+			continue
+		}
+		datasourceID, err := q.GetDatasource()
+		if err != nil {
+			continue
+		}
+		if _, ok := seen[datasourceID]; ok {
+			continue
+		}
+		seen[datasourceID] = struct{}{}
+		datasourceIDs = append(datasourceIDs, datasourceID)
+	}
+	sort.Slice(datasourceIDs, func(i, j int) bool {
+		return datasourceIDs[i] < datasourceIDs[j]
+	})
+	return datasourceIDs
+}
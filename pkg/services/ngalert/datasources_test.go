@@ -0,0 +1,53 @@
+package ngalert
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlertDefinitionDatasources(t *testing.T) {
+	def := &AlertDefinition{
+		Condition: "D",
+		Data: []eval.AlertQuery{
+			{
+				RefID: "A",
+				Model: json.RawMessage(`{"datasource": "prometheus", "datasourceId": 3}`),
+			},
+			{
+				RefID: "B",
+				Model: json.RawMessage(`{"datasource": "loki", "datasourceId": 1}`),
+			},
+			{
+				RefID: "C",
+				Model: json.RawMessage(`{"datasource": "prometheus", "datasourceId": 3}`),
+			},
+			{
+				RefID: "D",
+				Model: json.RawMessage(`{"datasource": "__expr__", "type": "math", "expression": "$A > $B && $C > 0"}`),
+			},
+			{
+				RefID: "E",
+				Model: json.RawMessage(`{"datasource": "unknown"}`),
+			},
+		},
+	}
+
+	assert.Equal(t, []int64{1, 3}, alertDefinitionDatasources(def))
+}
+
+func TestAlertDefinitionDatasourcesExpressionsOnly(t *testing.T) {
+	def := &AlertDefinition{
+		Condition: "A",
+		Data: []eval.AlertQuery{
+			{
+				RefID: "A",
+				Model: json.RawMessage(`{"datasource": "__expr__", "type": "math", "expression": "1 > 0"}`),
+			},
+		},
+	}
+
+	assert.Empty(t, alertDefinitionDatasources(def))
+}
@@ -0,0 +1,99 @@
+package ngalert
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+// errDependencyUnhealthy is returned by the evaluations skipped
+// because an alert definition their alert definition depends on is unhealthy.
+var errDependencyUnhealthy = errors.New("dependency unhealthy")
+
+// definitionRef identifies an alert definition of an organisation.
+type definitionRef struct {
+	orgID int64
+	uid   string
+}
+
+// unhealthyDefinition is the reason an alert definition is unhealthy.
+type unhealthyDefinition struct {
+	ref    definitionRef
+	reason string
+}
+
+// dependencyError and dependencyNoData are the reasons an alert definition is unhealthy for its dependents.
+const (
+	dependencyError  = "Error"
+	dependencyNoData = "NoData"
+)
+
+// definitionHealth keeps whether the last evaluation of every alert definition was healthy
+// so that the evaluations of its dependents are skipped while it's not:
+// their results would be misleading, e.g. if they query its output.
+// The alert definitions not evaluated yet are healthy. The health of a templated alert definition
+// is the one of its last evaluated expansion.
+// The skipped evaluations don't change the health of their alert definition
+// so that dependency cycles never skip the evaluations of their alert definitions forever.
+type definitionHealth struct {
+	mu sync.RWMutex
+	// unhealthy are the unhealthy alert definitions indexed by their key
+	unhealthy map[string]unhealthyDefinition
+}
+
+func newDefinitionHealth() *definitionHealth {
+	return &definitionHealth{unhealthy: make(map[string]unhealthyDefinition)}
+}
+
+// set records the reason the alert definition is unhealthy, or that it's healthy if the reason is empty.
+func (h *definitionHealth) set(key string, ref definitionRef, reason string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if reason == "" {
+		delete(h.unhealthy, key)
+		return
+	}
+	h.unhealthy[key] = unhealthyDefinition{ref: ref, reason: reason}
+}
+
+// del forgets a deleted alert definition; its dependents are evaluated again.
+func (h *definitionHealth) del(key string) {
+	h.set(key, definitionRef{}, "")
+}
+
+// check returns the first unhealthy dependency of the alert definition, if any, and the reason.
+// The dependencies are referred to by their UID: a dependency is unhealthy
+// if any alert definition of the organisation with its UID is.
+// The alert definition itself is ignored if it depends on itself.
+func (h *definitionHealth) check(alertDefinition *AlertDefinition) (string, string, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if len(h.unhealthy) == 0 {
+		return "", "", true
+	}
+	for _, uid := range alertDefinition.DependsOn {
+		if uid == alertDefinition.UID {
+			continue
+		}
+		for _, unhealthy := range h.unhealthy {
+			if unhealthy.ref == (definitionRef{orgID: alertDefinition.OrgID, uid: uid}) {
+				return uid, unhealthy.reason, false
+			}
+		}
+	}
+	return "", "", true
+}
+
+// resultsHealth returns the reason the results of an evaluation make its alert definition unhealthy, if any.
+func resultsHealth(results eval.Results) string {
+	if len(results) == 0 {
+		return dependencyNoData
+	}
+	for _, r := range results {
+		if r.State == eval.Error {
+			return dependencyError
+		}
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+package ngalert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDependencyUnhealthy(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.synchronous = true
+
+	upstream := &AlertDefinition{ID: 1, OrgID: 1, UID: "upstream", Condition: "A", IntervalSeconds: 1, Version: 1, Enabled: true}
+	dependent := &AlertDefinition{ID: 2, OrgID: 1, UID: "dependent", Condition: "A", IntervalSeconds: 1, Version: 1, Enabled: true, DependsOn: []string{"upstream"}}
+	store := newInMemoryDefinitionStore()
+	store.add(upstream, dependent)
+	ng.SetDefinitionStore(store)
+	// the upstream alert definition is evaluated first on every tick
+	ng.schedule.fetchDefinitions = func(time.Time) []*AlertDefinition {
+		return []*AlertDefinition{upstream, dependent}
+	}
+
+	// the upstream alert definition is in the Error state until fixed
+	upstreamState := eval.Error
+	var evaluated []string
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(_ context.Context, c *eval.Condition, now time.Time) (eval.Results, error) {
+		evaluated = append(evaluated, fmt.Sprintf("%s@%d", c.CacheKey, now.Unix()))
+		if c.CacheKey == getKey(upstream) {
+			return eval.Results{{Instance: data.Labels{"host": "a"}, State: upstreamState, Error: errors.New("upstream failure")}}, nil
+		}
+		return eval.Results{{Instance: data.Labels{"host": "a"}, State: eval.Normal}}, nil
+	})
+
+	var skipped []string
+	ng.SetAuditSink(AuditSinkFunc(func(record AuditRecord) error {
+		if record.Action == AuditEvaluationFailed && record.Key == getKey(dependent) {
+			skipped = append(skipped, fmt.Sprint(record.Details["error"]))
+		}
+		return nil
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	tick := func(from, to int64) {
+		for tick := from; tick <= to; tick++ {
+			require.NoError(t, ng.tickSynchronously(ctx, time.Unix(tick, 0)))
+		}
+	}
+
+	// the dependent alert definition is skipped while the upstream one is in the Error state
+	tick(1, 2)
+	upstreamKey, dependentKey := getKey(upstream), getKey(dependent)
+	assert.Equal(t, []string{upstreamKey + "@1", upstreamKey + "@2"}, evaluated)
+	require.Len(t, skipped, 2)
+	assert.Contains(t, skipped[0], "dependency unhealthy: alert definition upstream is Error")
+	assert.Empty(t, ng.schedule.history.list(dependentKey), "the skipped evaluations are not recorded")
+	assert.Empty(t, ng.schedule.stateTracker.get(dependentKey))
+
+	// the dependent alert definition is evaluated again once the upstream one is healthy
+	upstreamState = eval.Normal
+	tick(3, 3)
+	assert.Equal(t, []string{upstreamKey + "@1", upstreamKey + "@2", upstreamKey + "@3", dependentKey + "@3"}, evaluated)
+	assert.Len(t, ng.schedule.stateTracker.get(dependentKey), 1)
+}
+
+func TestDefinitionHealthNoData(t *testing.T) {
+	h := newDefinitionHealth()
+	dependent := &AlertDefinition{OrgID: 1, UID: "dependent", DependsOn: []string{"dependent", "upstream"}}
+
+	h.set("1:upstream", definitionRef{orgID: 1, uid: "upstream"}, resultsHealth(nil))
+	uid, reason, healthy := h.check(dependent)
+	assert.False(t, healthy)
+	assert.Equal(t, "upstream", uid)
+	assert.Equal(t, dependencyNoData, reason)
+
+	// the dependencies of other organisations and the alert definition itself are ignored
+	h.del("1:upstream")
+	h.set("2:upstream", definitionRef{orgID: 2, uid: "upstream"}, dependencyError)
+	h.set("1:dependent", definitionRef{orgID: 1, uid: "dependent"}, dependencyError)
+	_, _, healthy = h.check(dependent)
+	assert.True(t, healthy)
+}
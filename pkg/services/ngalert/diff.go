@@ -0,0 +1,160 @@
+package ngalert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+// FieldChange is a field of an alert definition that differs between two versions.
+// From is nil if the field was added and To is nil if it was removed.
+type FieldChange struct {
+	// Field is the name of the field: title, condition or interval_seconds,
+	// data[<refId>] for a query or expression and labels[<name>] for a label.
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// DefinitionDiff is the field-level difference between two versions of an alert definition.
+type DefinitionDiff struct {
+	OrgID       int64  `json:"orgId"`
+	UID         string `json:"uid"`
+	FromVersion int64  `json:"fromVersion"`
+	ToVersion   int64  `json:"toVersion"`
+	// Changes are sorted by field; they are empty if the versions are identical.
+	Changes []FieldChange `json:"changes"`
+}
+
+// Changed returns true if the field changed between the versions.
+func (d DefinitionDiff) Changed(field string) bool {
+	for _, c := range d.Changes {
+		if c.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// DiffVersions returns the field-level difference between the versions v1 and v2 of the alert definition,
+// e.g. for reviewing a change: the interval, the condition, the queries and expressions and the labels.
+func (ng *AlertNG) DiffVersions(uid string, orgID int64, v1, v2 int64) (DefinitionDiff, error) {
+	from := getAlertDefinitionVersionQuery{OrgID: orgID, UID: uid, Version: v1}
+	if err := ng.getAlertDefinitionVersion(&from); err != nil {
+		return DefinitionDiff{}, fmt.Errorf("failed to get version %d of alert definition %s: %w", v1, uid, err)
+	}
+	to := getAlertDefinitionVersionQuery{OrgID: orgID, UID: uid, Version: v2}
+	if err := ng.getAlertDefinitionVersion(&to); err != nil {
+		return DefinitionDiff{}, fmt.Errorf("failed to get version %d of alert definition %s: %w", v2, uid, err)
+	}
+	changes, err := diffVersions(from.Result, to.Result)
+	if err != nil {
+		return DefinitionDiff{}, err
+	}
+	return DefinitionDiff{OrgID: orgID, UID: uid, FromVersion: v1, ToVersion: v2, Changes: changes}, nil
+}
+
+// diffVersions returns the changes of the fields from one version to another, sorted by field.
+func diffVersions(from, to *AlertDefinitionVersion) ([]FieldChange, error) {
+	changes := make([]FieldChange, 0)
+	if from.Title != to.Title {
+		changes = append(changes, FieldChange{Field: "title", From: from.Title, To: to.Title})
+	}
+	if from.Condition != to.Condition {
+		changes = append(changes, FieldChange{Field: "condition", From: from.Condition, To: to.Condition})
+	}
+	if from.IntervalSeconds != to.IntervalSeconds {
+		changes = append(changes, FieldChange{Field: "interval_seconds", From: from.IntervalSeconds, To: to.IntervalSeconds})
+	}
+
+	dataChanges, err := diffData(from.Data, to.Data)
+	if err != nil {
+		return nil, err
+	}
+	changes = append(changes, dataChanges...)
+
+	for _, name := range unionKeys(from.Labels, to.Labels) {
+		fromValue, inFrom := from.Labels[name]
+		toValue, inTo := to.Labels[name]
+		if inFrom == inTo && fromValue == toValue {
+			continue
+		}
+		change := FieldChange{Field: fmt.Sprintf("labels[%s]", name)}
+		if inFrom {
+			change.From = fromValue
+		}
+		if inTo {
+			change.To = toValue
+		}
+		changes = append(changes, change)
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes, nil
+}
+
+// diffData returns the changes of the queries and expressions matched by RefID.
+// The queries are compared by their serialized form; their models are normalized when saved.
+func diffData(from, to []eval.AlertQuery) ([]FieldChange, error) {
+	index := func(queries []eval.AlertQuery) (map[string]eval.AlertQuery, map[string]string, error) {
+		byRefID := make(map[string]eval.AlertQuery, len(queries))
+		serialized := make(map[string]string, len(queries))
+		for _, q := range queries {
+			b, err := json.Marshal(q)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to serialize query %s: %w", q.RefID, err)
+			}
+			var compact bytes.Buffer
+			if err := json.Compact(&compact, b); err != nil {
+				return nil, nil, fmt.Errorf("failed to serialize query %s: %w", q.RefID, err)
+			}
+			byRefID[q.RefID] = q
+			serialized[q.RefID] = compact.String()
+		}
+		return byRefID, serialized, nil
+	}
+	fromQueries, fromSerialized, err := index(from)
+	if err != nil {
+		return nil, err
+	}
+	toQueries, toSerialized, err := index(to)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []FieldChange
+	for _, refID := range unionKeys(fromSerialized, toSerialized) {
+		fromQuery, inFrom := fromQueries[refID]
+		toQuery, inTo := toQueries[refID]
+		if inFrom && inTo && fromSerialized[refID] == toSerialized[refID] {
+			continue
+		}
+		change := FieldChange{Field: fmt.Sprintf("data[%s]", refID)}
+		if inFrom {
+			change.From = fromQuery
+		}
+		if inTo {
+			change.To = toQuery
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// unionKeys returns the sorted keys of both maps.
+func unionKeys(a, b map[string]string) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
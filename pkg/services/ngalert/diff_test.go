@@ -0,0 +1,84 @@
+package ngalert
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffVersions(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	alertDefinition := createTestAlertDefinition(t, ng, 60)
+
+	intervalSeconds := int64(120)
+	cmd := updateAlertDefinitionCommand{
+		ID:    alertDefinition.ID,
+		OrgID: alertDefinition.OrgID,
+		Condition: eval.Condition{
+			RefID: "A",
+			QueriesAndExpressions: []eval.AlertQuery{
+				{
+					Model: json.RawMessage(`{
+						"datasource": "__expr__",
+						"type":"math",
+						"expression":"2 + 3 > 1"
+					}`),
+					RelativeTimeRange: eval.RelativeTimeRange{
+						From: eval.Duration(5 * time.Hour),
+						To:   eval.Duration(3 * time.Hour),
+					},
+					RefID: "A",
+				},
+			},
+		},
+		IntervalSeconds: &intervalSeconds,
+		Labels:          map[string]string{"team": "ops"},
+	}
+	require.NoError(t, ng.updateAlertDefinition(&cmd))
+	require.Equal(t, alertDefinition.Version+1, cmd.Result.Version)
+
+	diff, err := ng.DiffVersions(alertDefinition.UID, alertDefinition.OrgID, alertDefinition.Version, cmd.Result.Version)
+	require.NoError(t, err)
+	assert.Equal(t, alertDefinition.Version, diff.FromVersion)
+	assert.Equal(t, cmd.Result.Version, diff.ToVersion)
+
+	fields := make([]string, 0, len(diff.Changes))
+	for _, c := range diff.Changes {
+		fields = append(fields, c.Field)
+	}
+	assert.Equal(t, []string{"data[A]", "interval_seconds", "labels[team]"}, fields)
+	assert.False(t, diff.Changed("title"))
+	assert.False(t, diff.Changed("condition"))
+	assert.Equal(t, FieldChange{Field: "interval_seconds", From: int64(60), To: int64(120)}, diff.Changes[1])
+	assert.Equal(t, FieldChange{Field: "labels[team]", From: nil, To: "ops"}, diff.Changes[2])
+
+	// the diff is serializable
+	_, err = json.Marshal(diff)
+	require.NoError(t, err)
+
+	t.Run("the versions are identical to themselves", func(t *testing.T) {
+		diff, err := ng.DiffVersions(alertDefinition.UID, alertDefinition.OrgID, cmd.Result.Version, cmd.Result.Version)
+		require.NoError(t, err)
+		assert.Empty(t, diff.Changes)
+	})
+
+	t.Run("an unknown version fails", func(t *testing.T) {
+		_, err := ng.DiffVersions(alertDefinition.UID, alertDefinition.OrgID, alertDefinition.Version, cmd.Result.Version+1)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, errAlertDefinitionVersionNotFound))
+	})
+
+	t.Run("the versions of another organisation are not found", func(t *testing.T) {
+		_, err := ng.DiffVersions(alertDefinition.UID, alertDefinition.OrgID+1, alertDefinition.Version, cmd.Result.Version)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, errAlertDefinitionVersionNotFound))
+	})
+}
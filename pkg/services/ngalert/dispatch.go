@@ -0,0 +1,71 @@
+package ngalert
+
+import (
+	"sync"
+
+	"github.com/benbjohnson/clock"
+)
+
+// pendingDispatches are the dispatches of the evaluations of a routine
+// delayed by their dispatch offset, stopped once the routine is stopped
+// so that they do not fire for a routine that is gone.
+type pendingDispatches struct {
+	mu     sync.Mutex
+	nextID int64
+	// timers are the timers of the pending dispatches by ID;
+	// a timer is nil until the dispatch has been scheduled
+	timers map[int64]*clock.Timer
+}
+
+func newPendingDispatches() *pendingDispatches {
+	return &pendingDispatches{timers: make(map[int64]*clock.Timer)}
+}
+
+// reserve registers a dispatch about to be scheduled and returns its ID.
+func (p *pendingDispatches) reserve() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.nextID++
+	p.timers[p.nextID] = nil
+	return p.nextID
+}
+
+// scheduled sets the timer of the dispatch unless it has already fired or been stopped.
+func (p *pendingDispatches) scheduled(id int64, timer *clock.Timer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.timers[id]; ok {
+		p.timers[id] = timer
+	}
+}
+
+// release unregisters the dispatch once it fires.
+// It returns false if the dispatch has been stopped in the meantime.
+func (p *pendingDispatches) release(id int64) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, ok := p.timers[id]
+	delete(p.timers, id)
+	return ok
+}
+
+// stop stops the pending dispatches and returns their number.
+func (p *pendingDispatches) stop() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := len(p.timers)
+	for id, timer := range p.timers {
+		if timer != nil {
+			timer.Stop()
+		}
+		delete(p.timers, id)
+	}
+	return n
+}
+
+// len returns the number of pending dispatches.
+func (p *pendingDispatches) len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.timers)
+}
@@ -0,0 +1,105 @@
+package ngalert
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+)
+
+func TestAlertingTickerDeletedDefinitionPendingDispatch(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		return eval.Results{}, nil
+	})
+
+	alerts := []*AlertDefinition{
+		createTestAlertDefinition(t, ng, 1),
+		createTestAlertDefinition(t, ng, 1),
+	}
+
+	evalAppliedCh := make(chan evalAppliedInfo, len(alerts))
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		_ = ng.alertingTicker(ctx)
+	}()
+	runtime.Gosched()
+
+	// the first alert definition is dispatched on the tick
+	// and the second one is pending half way through the tick
+	advanceClock(t, mockedClock)
+	var applied evalAppliedInfo
+	select {
+	case applied = <-evalAppliedCh:
+	case <-time.After(time.Second):
+		t.Fatal("no evaluation applied")
+	}
+	pending := alerts[0]
+	if applied.alertDefID == alerts[0].ID {
+		pending = alerts[1]
+	}
+	info, ok := ng.schedule.registry.get(getKey(pending))
+	require.True(t, ok)
+	require.Equal(t, 1, info.dispatches.len())
+
+	// no goroutine should outlive the deleted alert definition
+	ignored := goleak.IgnoreCurrent()
+
+	// the alert definition is deleted before its dispatch fires
+	ng.schedule.registry.del(getKey(pending))
+	assert.Equal(t, 0, info.dispatches.len())
+	mockedClock.Add(500 * time.Millisecond)
+
+	select {
+	case applied := <-evalAppliedCh:
+		t.Fatalf("the deleted alert definition %d should not be evaluated, got %d", pending.ID, applied.alertDefID)
+	case <-time.After(100 * time.Millisecond):
+	}
+	goleak.VerifyNone(t, ignored)
+}
+
+func TestPendingDispatches(t *testing.T) {
+	mockedClock := clock.NewMock()
+	dispatches := newPendingDispatches()
+
+	fired := 0
+	first := dispatches.reserve()
+	dispatches.scheduled(first, mockedClock.AfterFunc(time.Second, func() {
+		if dispatches.release(first) {
+			fired++
+		}
+	}))
+	second := dispatches.reserve()
+	dispatches.scheduled(second, mockedClock.AfterFunc(2*time.Second, func() {
+		if dispatches.release(second) {
+			fired++
+		}
+	}))
+	assert.Equal(t, 2, dispatches.len())
+
+	mockedClock.Add(time.Second)
+	assert.Equal(t, 1, fired)
+	assert.Equal(t, 1, dispatches.len())
+
+	assert.Equal(t, 1, dispatches.stop())
+	mockedClock.Add(time.Second)
+	assert.Equal(t, 1, fired, "the stopped dispatch should not fire")
+	assert.False(t, dispatches.release(second))
+}
@@ -49,6 +49,11 @@ func (rtr *RelativeTimeRange) isValid() bool {
 	return rtr.From > rtr.To
 }
 
+// IsZero returns true if the relative time range is not set.
+func (rtr RelativeTimeRange) IsZero() bool {
+	return rtr.From == 0 && rtr.To == 0
+}
+
 func (rtr *RelativeTimeRange) toTimeRange(now time.Time) backend.TimeRange {
 	return backend.TimeRange{
 		From: now.Add(-time.Duration(rtr.From)),
@@ -73,6 +78,10 @@ type AlertQuery struct {
 	// JSON is the raw JSON query and includes the above properties as well as custom properties.
 	Model json.RawMessage `json:"model"`
 
+	// Fallback if set is the value the query evaluates to when its datasource fails,
+	// so that the condition is still evaluated with the data of the other queries.
+	Fallback *float64 `json:"fallback,omitempty"`
+
 	modelProps map[string]interface{} `json:"-"`
 }
 
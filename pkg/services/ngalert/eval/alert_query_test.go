@@ -295,3 +295,39 @@ func TestAlertQueryMarshalling(t *testing.T) {
 		}
 	}
 }
+
+func TestConditionWithRelativeTimeRange(t *testing.T) {
+	condition := Condition{
+		RefID: "B",
+		OrgID: 1,
+		QueriesAndExpressions: []AlertQuery{
+			{
+				RefID: "A",
+				Model: json.RawMessage(`{
+					"datasource": "my datasource",
+					"datasourceId": 1
+				}`),
+				RelativeTimeRange: RelativeTimeRange{From: Duration(time.Hour), To: 0},
+			},
+			{
+				RefID: "B",
+				Model: json.RawMessage(`{
+					"datasource": "__expr__",
+					"type": "reduce",
+					"expression": "A"
+				}`),
+			},
+		},
+	}
+
+	rtr := RelativeTimeRange{From: Duration(5 * time.Minute), To: 0}
+	c := condition.WithRelativeTimeRange(rtr)
+
+	now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	tr := c.QueriesAndExpressions[0].RelativeTimeRange.toTimeRange(now)
+	assert.Equal(t, now.Add(-5*time.Minute), tr.From)
+	assert.Equal(t, now, tr.To)
+
+	assert.True(t, c.QueriesAndExpressions[1].RelativeTimeRange.IsZero(), "expressions should not be affected")
+	assert.Equal(t, Duration(time.Hour), condition.QueriesAndExpressions[0].RelativeTimeRange.From, "the original condition should not be modified")
+}
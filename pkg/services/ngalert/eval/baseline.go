@@ -0,0 +1,105 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// BaselineCondition expresses the values of a condition as a percentage of the values
+// of a baseline query or expression before comparing them to a threshold,
+// e.g. to alert when the traffic is below 40% of the traffic of last week.
+// The instance is Alerting if its percentage of the baseline compared by Op to Percentage holds,
+// e.g. current / baseline * 100 < 40.
+type BaselineCondition struct {
+	// RefID is the query or expression evaluating to the baseline values.
+	RefID      string  `json:"refId"`
+	Op         string  `json:"op"`
+	Percentage float64 `json:"percentage"`
+}
+
+// IsZero returns true if the baseline condition is not set.
+func (b BaselineCondition) IsZero() bool {
+	return b.RefID == "" && b.Op == "" && b.Percentage == 0
+}
+
+// Validate returns an error if the baseline condition can't be evaluated.
+func (b BaselineCondition) Validate() error {
+	if b.RefID == "" {
+		return fmt.Errorf("invalid baseline: the baseline query or expression is missing")
+	}
+	switch b.Op {
+	case ">", ">=", "<", "<=", "==", "!=":
+		return nil
+	default:
+		return fmt.Errorf("invalid baseline operator: %q", b.Op)
+	}
+}
+
+// evalBaseline executes the condition and transforms the value of every instance
+// into its percentage of the baseline before comparing it to the threshold.
+// The baseline without labels applies to all the instances.
+// The instances without baseline or whose baseline is 0 have no percentage: they are NotApplicable.
+func (c *Condition) evalBaseline(ctx context.Context, now time.Time, preQuery []QueryMiddleware) (Results, error) {
+	if err := c.Baseline.Validate(); err != nil {
+		return nil, err
+	}
+	if c.Baseline.RefID == c.RefID {
+		return nil, fmt.Errorf("invalid baseline: %s is the condition itself", c.Baseline.RefID)
+	}
+
+	execResult, err := c.execute(AlertExecCtx{OrgID: c.OrgID, Ctx: ctx, PreQuery: preQuery}, now)
+	if results, ok := c.tooManySeriesResults(err); ok {
+		return results, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute conditions: %w", err)
+	}
+	if c.exceedsMaxSeries(len(execResult.Results)) {
+		return c.tooManySeries(len(execResult.Results)), nil
+	}
+	current, err := evaluateExecutionResult(execResult)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate results: %w", err)
+	}
+
+	baselineFrames, ok := execResult.Responses[c.Baseline.RefID]
+	if !ok {
+		return nil, fmt.Errorf("baseline %s did not return any result", c.Baseline.RefID)
+	}
+	baseline, err := evaluateExecutionResult(&ExecutionResults{Results: baselineFrames})
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate the baseline results: %w", err)
+	}
+	baselineValues := make(map[string]float64, len(baseline))
+	for _, r := range baseline {
+		baselineValues[r.Instance.String()] = r.Value
+	}
+	sharedBaseline, hasSharedBaseline := baselineValues[data.Labels{}.String()]
+
+	threshold := thresholdCondition{op: c.Baseline.Op, threshold: c.Baseline.Percentage}
+	results := make(Results, 0, len(current))
+	for _, r := range current {
+		base, ok := baselineValues[r.Instance.String()]
+		if !ok && hasSharedBaseline {
+			base, ok = sharedBaseline, true
+		}
+		result := Result{Instance: r.Instance, State: NotApplicable}
+		if ok && base != 0 {
+			result.Value = percentageOf(r.Value, base)
+			result.State = Normal
+			if threshold.compare(result.Value) {
+				result.State = Alerting
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// percentageOf returns value as a percentage of base.
+func percentageOf(value, base float64) float64 {
+	return value / base * 100
+}
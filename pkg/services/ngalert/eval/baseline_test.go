@@ -0,0 +1,105 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// refIDEndpoint answers every query with the frames of its RefID.
+type refIDEndpoint struct {
+	frames map[string]data.Frames
+}
+
+func (e *refIDEndpoint) Query(_ context.Context, _ *models.DataSource, query *tsdb.TsdbQuery) (*tsdb.Response, error) {
+	results := make(map[string]*tsdb.QueryResult, len(query.Queries))
+	for _, q := range query.Queries {
+		results[q.RefId] = &tsdb.QueryResult{Dataframes: tsdb.NewDecodedDataFrames(e.frames[q.RefId])}
+	}
+	return &tsdb.Response{Results: results}, nil
+}
+
+func TestConditionEvalBaseline(t *testing.T) {
+	// the traffic of the hosts now and last week
+	traffic := func(a, b, c float64) data.Frames {
+		return data.Frames{data.NewFrame("",
+			data.NewField("host", nil, []string{"a", "b", "c"}),
+			data.NewField("value", nil, []*float64{fp(a), fp(b), fp(c)}),
+		)}
+	}
+	tsdb.RegisterTsdbQueryEndpoint("baseline-test", func(*models.DataSource) (tsdb.TsdbQueryEndpoint, error) {
+		return &refIDEndpoint{frames: map[string]data.Frames{
+			"A": traffic(20, 60, 5),
+			"B": traffic(100, 100, 0),
+		}}, nil
+	})
+	bus.AddHandler("test", func(query *models.GetDataSourceByIdQuery) error {
+		query.Result = &models.DataSource{Id: query.Id, OrgId: query.OrgId, Type: "baseline-test"}
+		return nil
+	})
+
+	condition := Condition{
+		RefID: "C",
+		OrgID: 1,
+		QueriesAndExpressions: []AlertQuery{
+			{
+				RefID:             "A",
+				RelativeTimeRange: RelativeTimeRange{From: Duration(time.Minute)},
+				Model:             json.RawMessage(`{"datasource": "baseline-test", "datasourceId": 1, "intervalMs": 1000, "maxDataPoints": 100}`),
+			},
+			{
+				RefID:             "B",
+				RelativeTimeRange: RelativeTimeRange{From: Duration(7*24*time.Hour + time.Minute), To: Duration(7 * 24 * time.Hour)},
+				Model:             json.RawMessage(`{"datasource": "baseline-test", "datasourceId": 1, "intervalMs": 1000, "maxDataPoints": 100}`),
+			},
+			{
+				RefID: "C",
+				Model: json.RawMessage(`{"datasource": "__expr__", "type": "math", "expression": "$A * 1"}`),
+			},
+			{
+				RefID: "D",
+				Model: json.RawMessage(`{"datasource": "__expr__", "type": "math", "expression": "$B * 1"}`),
+			},
+		},
+		// the traffic is less than half of the traffic of last week
+		Baseline: &BaselineCondition{RefID: "D", Op: "<", Percentage: 50},
+	}
+
+	results, err := conditionEval(context.Background(), &condition, time.Now())
+	require.NoError(t, err)
+	assert.ElementsMatch(t, Results{
+		{Instance: data.Labels{"host": "a"}, State: Alerting, Value: 20},
+		{Instance: data.Labels{"host": "b"}, State: Normal, Value: 60},
+		// the percentage of a zero baseline is undefined
+		{Instance: data.Labels{"host": "c"}, State: NotApplicable},
+	}, results)
+
+	threshold, ok := condition.Threshold()
+	require.True(t, ok)
+	assert.Equal(t, 50.0, threshold)
+
+	t.Run("an invalid baseline fails the evaluation", func(t *testing.T) {
+		c := condition
+		c.Baseline = &BaselineCondition{RefID: "D", Op: "=<", Percentage: 50}
+		_, err := conditionEval(context.Background(), &c, time.Now())
+		require.Error(t, err)
+
+		c.Baseline = &BaselineCondition{RefID: "C", Op: "<", Percentage: 50}
+		_, err = conditionEval(context.Background(), &c, time.Now())
+		require.Error(t, err)
+	})
+}
+
+func TestPercentageOf(t *testing.T) {
+	assert.Equal(t, 40.0, percentageOf(40, 100))
+	assert.Equal(t, 250.0, percentageOf(5, 2))
+	assert.Equal(t, -50.0, percentageOf(-1, 2))
+}
@@ -0,0 +1,161 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana/pkg/expr"
+)
+
+// queryDataFunc executes all the queries of the request against a single datasource.
+type queryDataFunc func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error)
+
+// batchKey identifies the queries that can be issued in a single request:
+// queries of the same organisation to the same datasource for the same time range.
+type batchKey struct {
+	orgID        int64
+	datasourceID int64
+	from         int64
+	to           int64
+}
+
+type queryBatch struct {
+	pluginCtx backend.PluginContext
+	queries   []backend.DataQuery
+	callers   int
+
+	done chan struct{}
+	res  *backend.QueryDataResponse
+	err  error
+}
+
+// BatchingEvaluator is an Evaluator that collects the queries of the conditions
+// evaluated within the same window and issues them as a single request per datasource.
+// Only the datasource requests are batched: the conditions are otherwise
+// evaluated like by the DefaultEvaluator.
+type BatchingEvaluator struct {
+	// PreQuery are run in order on the request of the condition queries before it's executed.
+	PreQuery []QueryMiddleware
+	// PostResult are run in order on the evaluation results.
+	PostResult []ResultMiddleware
+
+	window    time.Duration
+	queryData queryDataFunc
+
+	mu      sync.Mutex
+	batches map[batchKey]*queryBatch
+}
+
+// NewBatchingEvaluator returns a new BatchingEvaluator that waits for
+// the window duration before issuing the batched requests.
+func NewBatchingEvaluator(window time.Duration) *BatchingEvaluator {
+	return &BatchingEvaluator{
+		window:    window,
+		queryData: expr.QueryData,
+		batches:   make(map[batchKey]*queryBatch),
+	}
+}
+
+// ConditionEval executes conditions and evaluates the result
+// with the datasource queries issued as part of a batch.
+func (e *BatchingEvaluator) ConditionEval(ctx context.Context, condition *Condition, now time.Time) (Results, error) {
+	evalResults, err := conditionEval(expr.WithQueryDataFunc(ctx, e.queryBatched), condition, now, e.PreQuery...)
+	if err != nil {
+		return nil, err
+	}
+	return applyResultMiddlewares(ctx, e.PostResult, condition, evalResults)
+}
+
+// queryBatched issues the queries of the request to a single datasource as part of its pending batch.
+func (e *BatchingEvaluator) queryBatched(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	if len(req.Queries) == 0 {
+		return nil, fmt.Errorf("zero queries found in datasource request")
+	}
+
+	var datasourceID int64
+	if req.PluginContext.DataSourceInstanceSettings != nil {
+		datasourceID = req.PluginContext.DataSourceInstanceSettings.ID
+	}
+	responses, err := e.query(ctx, datasourceID, req)
+	if err != nil {
+		return nil, err
+	}
+	return &backend.QueryDataResponse{Responses: responses}, nil
+}
+
+// query adds the request queries to the pending batch and waits for its responses.
+func (e *BatchingEvaluator) query(ctx context.Context, datasourceID int64, req *backend.QueryDataRequest) (map[string]backend.DataResponse, error) {
+	timeRange := req.Queries[0].TimeRange
+	for _, q := range req.Queries {
+		if !q.TimeRange.From.Equal(timeRange.From) || !q.TimeRange.To.Equal(timeRange.To) {
+			return nil, fmt.Errorf("queries with different time ranges cannot be batched")
+		}
+	}
+
+	key := batchKey{orgID: req.PluginContext.OrgID, datasourceID: datasourceID, from: timeRange.From.UnixNano(), to: timeRange.To.UnixNano()}
+
+	e.mu.Lock()
+	b, ok := e.batches[key]
+	if !ok {
+		b = &queryBatch{
+			pluginCtx: backend.PluginContext{
+				OrgID:                      req.PluginContext.OrgID,
+				DataSourceInstanceSettings: &backend.DataSourceInstanceSettings{ID: datasourceID},
+			},
+			done: make(chan struct{}),
+		}
+		e.batches[key] = b
+		time.AfterFunc(e.window, func() {
+			e.flush(key, b)
+		})
+	}
+	// the RefIDs are prefixed so that they are unique within the batch
+	prefix := fmt.Sprintf("%d_", b.callers)
+	b.callers++
+	for _, q := range req.Queries {
+		q.RefID = prefix + q.RefID
+		b.queries = append(b.queries, q)
+	}
+	e.mu.Unlock()
+
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	responses := make(map[string]backend.DataResponse)
+	for refID, res := range b.res.Responses {
+		if strings.HasPrefix(refID, prefix) {
+			responses[strings.TrimPrefix(refID, prefix)] = res
+		}
+	}
+	return responses, nil
+}
+
+// flush issues the batched request and notifies the waiting evaluations.
+func (e *BatchingEvaluator) flush(key batchKey, b *queryBatch) {
+	e.mu.Lock()
+	delete(e.batches, key)
+	e.mu.Unlock()
+
+	ctx, cancelFn := context.WithTimeout(context.Background(), alertingEvaluationTimeout)
+	defer cancelFn()
+
+	b.res, b.err = e.queryData(ctx, &backend.QueryDataRequest{
+		PluginContext: b.pluginCtx,
+		Queries:       b.queries,
+	})
+	if b.err == nil && b.res == nil {
+		b.err = fmt.Errorf("empty response")
+	}
+	close(b.done)
+}
@@ -0,0 +1,170 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchingEvaluator(t *testing.T) {
+	var mu sync.Mutex
+	requests := make([]*backend.QueryDataRequest, 0)
+
+	evaluator := NewBatchingEvaluator(100 * time.Millisecond)
+	evaluator.queryData = func(_ context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+		mu.Lock()
+		requests = append(requests, req)
+		mu.Unlock()
+
+		res := &backend.QueryDataResponse{Responses: make(backend.Responses)}
+		for _, q := range req.Queries {
+			v := 1.0
+			res.Responses[q.RefID] = backend.DataResponse{
+				Frames: data.Frames{data.NewFrame("",
+					data.NewField("query", nil, []string{q.RefID}),
+					data.NewField("value", nil, []*float64{&v}),
+				)},
+			}
+		}
+		return res, nil
+	}
+
+	newCondition := func(i int) *Condition {
+		return &Condition{
+			RefID: "A",
+			OrgID: 1,
+			QueriesAndExpressions: []AlertQuery{
+				{
+					RefID: "A",
+					Model: json.RawMessage(fmt.Sprintf(`{
+						"datasource": "my datasource",
+						"datasourceId": 1,
+						"expr": "query %d"
+					}`, i)),
+					RelativeTimeRange: RelativeTimeRange{From: Duration(5 * time.Minute), To: 0},
+				},
+			},
+		}
+	}
+
+	now := time.Now()
+	var wg sync.WaitGroup
+	results := make([]Results, 3)
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r, err := evaluator.ConditionEval(context.Background(), newCondition(i), now)
+			require.NoError(t, err)
+			results[i] = r
+		}(i)
+	}
+	wg.Wait()
+
+	require.Len(t, requests, 1, "a single batched request should be made")
+	assert.Len(t, requests[0].Queries, 3)
+	assert.Equal(t, int64(1), requests[0].PluginContext.DataSourceInstanceSettings.ID)
+
+	seen := make(map[string]struct{})
+	for _, r := range results {
+		require.Len(t, r, 1)
+		assert.Equal(t, Alerting, r[0].State)
+		seen[r[0].Instance["query"]] = struct{}{}
+	}
+	assert.Len(t, seen, 3, "every evaluation should receive the results of its own query")
+}
+
+func TestBatchingEvaluatorSharedEvaluation(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+
+	var mu sync.Mutex
+	requests := make([]*backend.QueryDataRequest, 0)
+
+	evaluator := NewBatchingEvaluator(100 * time.Millisecond)
+	// the error rate of the host a doubles within the last 5 minutes
+	evaluator.queryData = func(_ context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+		mu.Lock()
+		requests = append(requests, req)
+		mu.Unlock()
+
+		res := &backend.QueryDataResponse{Responses: make(backend.Responses)}
+		for _, q := range req.Queries {
+			a, b := 10.0, 10.0
+			if !q.TimeRange.To.Before(now) {
+				a, b = 20, 11
+			}
+			res.Responses[q.RefID] = backend.DataResponse{
+				Frames: data.Frames{data.NewFrame("",
+					data.NewField("host", nil, []string{"a", "b"}),
+					data.NewField("value", nil, []*float64{fp(a), fp(b)}),
+				)},
+			}
+		}
+		return res, nil
+	}
+
+	trend := &Condition{
+		RefID: "B",
+		OrgID: 1,
+		QueriesAndExpressions: []AlertQuery{
+			{
+				RefID:             "A",
+				RelativeTimeRange: RelativeTimeRange{From: Duration(time.Minute)},
+				Model:             json.RawMessage(`{"datasource": "my datasource", "datasourceId": 1, "intervalMs": 1000, "maxDataPoints": 100}`),
+			},
+			{
+				RefID: "B",
+				Model: json.RawMessage(`{"datasource": "__expr__", "type": "math", "expression": "$A * 1"}`),
+			},
+		},
+		Trend: &TrendCondition{Offset: Duration(5 * time.Minute), Op: ">=", Ratio: 2},
+	}
+	limited := &Condition{
+		RefID: "A",
+		OrgID: 1,
+		QueriesAndExpressions: []AlertQuery{
+			{
+				RefID:             "A",
+				RelativeTimeRange: RelativeTimeRange{From: Duration(time.Minute)},
+				Model:             json.RawMessage(`{"datasource": "my datasource", "datasourceId": 1, "intervalMs": 1000, "maxDataPoints": 100}`),
+			},
+		},
+		MaxSeries: 1,
+	}
+
+	var wg sync.WaitGroup
+	var trendResults, limitedResults Results
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		r, err := evaluator.ConditionEval(context.Background(), trend, now)
+		require.NoError(t, err)
+		trendResults = r
+	}()
+	go func() {
+		defer wg.Done()
+		r, err := evaluator.ConditionEval(context.Background(), limited, now)
+		require.NoError(t, err)
+		limitedResults = r
+	}()
+	wg.Wait()
+
+	assert.ElementsMatch(t, Results{
+		{Instance: data.Labels{"host": "a"}, State: Alerting, Value: 2},
+		{Instance: data.Labels{"host": "b"}, State: Normal, Value: 1.1},
+	}, trendResults, "the trend of a batched condition should be evaluated")
+
+	require.Len(t, limitedResults, 1, "the maximum number of series of a batched condition should be enforced")
+	assert.Equal(t, Error, limitedResults[0].State)
+
+	require.NotEmpty(t, requests)
+	assert.Len(t, requests[0].Queries, 2, "the queries of both conditions at the current time should be batched")
+}
@@ -0,0 +1,106 @@
+package eval
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// CachingEvaluator is an Evaluator reusing the results of the conditions with a cache TTL
+// for the evaluations within the TTL of the previous one instead of querying the datasources again,
+// e.g. for expensive queries of slowly updated data.
+// The results are keyed by the cache key of the condition and its queries and expressions
+// so that updating them invalidates the cached results.
+type CachingEvaluator struct {
+	evaluator Evaluator
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	results   Results
+	evaluated time.Time
+	ttl       time.Duration
+}
+
+func (e cacheEntry) isFresh(now time.Time) bool {
+	return !now.Before(e.evaluated) && now.Sub(e.evaluated) < e.ttl
+}
+
+// NewCachingEvaluator returns a new CachingEvaluator.
+func NewCachingEvaluator(evaluator Evaluator) *CachingEvaluator {
+	return &CachingEvaluator{
+		evaluator: evaluator,
+		entries:   make(map[string]cacheEntry),
+	}
+}
+
+// ConditionEval returns the cached results of the condition if they are fresh at the evaluation time
+// otherwise it evaluates the condition and caches the results.
+// The conditions without cache key or TTL are always evaluated.
+func (e *CachingEvaluator) ConditionEval(ctx context.Context, condition *Condition, now time.Time) (Results, error) {
+	if condition.CacheKey == "" || condition.CacheTTL <= 0 {
+		return e.evaluator.ConditionEval(ctx, condition, now)
+	}
+	key, err := condition.cacheKey()
+	if err != nil {
+		return e.evaluator.ConditionEval(ctx, condition, now)
+	}
+
+	e.mu.Lock()
+	entry, ok := e.entries[key]
+	e.mu.Unlock()
+	if ok && entry.isFresh(now) {
+		return copyResults(entry.results), nil
+	}
+
+	results, err := e.evaluator.ConditionEval(ctx, condition, now)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	// the expired entries are evicted so that the entries
+	// of the updated or deleted alert definitions don't pile up
+	for k, entry := range e.entries {
+		if !entry.isFresh(now) {
+			delete(e.entries, k)
+		}
+	}
+	e.entries[key] = cacheEntry{results: copyResults(results), evaluated: now, ttl: condition.CacheTTL}
+	return results, nil
+}
+
+// cacheKey returns the key of the condition results:
+// its cache key and the hash of its queries and expressions, its trend and its baseline.
+func (c *Condition) cacheKey() (string, error) {
+	b, err := json.Marshal(c.QueriesAndExpressions)
+	if err != nil {
+		return "", err
+	}
+	trend, err := json.Marshal(c.Trend)
+	if err != nil {
+		return "", err
+	}
+	baseline, err := json.Marshal(c.Baseline)
+	if err != nil {
+		return "", err
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(c.RefID))
+	_, _ = h.Write(b)
+	_, _ = h.Write(trend)
+	_, _ = h.Write(baseline)
+	return c.CacheKey + "/" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func copyResults(results Results) Results {
+	c := make(Results, len(results))
+	copy(c, results)
+	return c
+}
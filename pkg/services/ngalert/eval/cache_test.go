@@ -0,0 +1,63 @@
+package eval
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachingEvaluator(t *testing.T) {
+	endpoint := registerFakeEndpoint(data.NewFrame("",
+		data.NewField("host", nil, []string{"a", "b"}),
+		data.NewField("value", nil, []*float64{fp(90), fp(10)}),
+	))
+
+	evaluator := NewCachingEvaluator(DefaultEvaluator{})
+	condition := thresholdTestCondition("$A > 80")
+	condition.CacheKey = "1:uid"
+	condition.CacheTTL = time.Hour
+
+	now := time.Now()
+	first, err := evaluator.ConditionEval(context.Background(), &condition, now)
+	require.NoError(t, err)
+	require.Len(t, first, 2)
+	require.Equal(t, 1, endpoint.calls)
+
+	t.Run("the evaluations within the TTL should not query the datasource", func(t *testing.T) {
+		for _, d := range []time.Duration{time.Minute, 30 * time.Minute, time.Hour - time.Second} {
+			results, err := evaluator.ConditionEval(context.Background(), &condition, now.Add(d))
+			require.NoError(t, err)
+			assert.Equal(t, first, results)
+		}
+		assert.Equal(t, 1, endpoint.calls)
+	})
+
+	t.Run("the evaluations after the TTL should query the datasource", func(t *testing.T) {
+		_, err := evaluator.ConditionEval(context.Background(), &condition, now.Add(time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, 2, endpoint.calls)
+	})
+
+	t.Run("updating the condition should invalidate the cached results", func(t *testing.T) {
+		updated := thresholdTestCondition("$A > 50")
+		updated.CacheKey = condition.CacheKey
+		updated.CacheTTL = condition.CacheTTL
+		_, err := evaluator.ConditionEval(context.Background(), &updated, now.Add(time.Hour+time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, 3, endpoint.calls)
+	})
+
+	t.Run("the conditions without TTL should always query the datasource", func(t *testing.T) {
+		uncached := thresholdTestCondition("$A > 80")
+		uncached.CacheKey = "2:uid"
+		for i := 0; i < 2; i++ {
+			_, err := evaluator.ConditionEval(context.Background(), &uncached, now)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 5, endpoint.calls)
+	})
+}
@@ -0,0 +1,52 @@
+package eval
+
+import (
+	"context"
+	"sync"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+type responseCaptureKey struct{}
+
+// ResponseCapture collects the raw frames returned by the queries and expressions
+// of the conditions evaluated with its context, by RefID.
+// The evaluations whose results are reused by the CachingEvaluator don't query anything
+// and so capture nothing.
+type ResponseCapture struct {
+	mu        sync.Mutex
+	responses map[string]data.Frames
+}
+
+// NewResponseCapture returns an empty ResponseCapture.
+func NewResponseCapture() *ResponseCapture {
+	return &ResponseCapture{responses: make(map[string]data.Frames)}
+}
+
+// WithResponseCapture returns a copy of ctx with the capture of the query responses.
+func WithResponseCapture(ctx context.Context, capture *ResponseCapture) context.Context {
+	return context.WithValue(ctx, responseCaptureKey{}, capture)
+}
+
+// CaptureResponse adds the frames of the query or expression to the capture of the context, if any.
+// The evaluators other than the default one call it to make their responses sampled.
+func CaptureResponse(ctx context.Context, refID string, frames data.Frames) {
+	capture, _ := ctx.Value(responseCaptureKey{}).(*ResponseCapture)
+	if capture == nil {
+		return
+	}
+	capture.mu.Lock()
+	defer capture.mu.Unlock()
+	capture.responses[refID] = frames
+}
+
+// Responses returns the captured frames by RefID.
+func (c *ResponseCapture) Responses() map[string]data.Frames {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	responses := make(map[string]data.Frames, len(c.responses))
+	for refID, frames := range c.responses {
+		responses[refID] = frames
+	}
+	return responses
+}
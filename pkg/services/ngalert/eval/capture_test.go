@@ -0,0 +1,28 @@
+package eval
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseCapture(t *testing.T) {
+	frames := data.Frames{data.NewFrame("", data.NewField("value", nil, []*float64{fp(1)}))}
+
+	// the responses are not captured without a capture in the context
+	CaptureResponse(context.Background(), "A", frames)
+
+	capture := NewResponseCapture()
+	ctx := WithResponseCapture(context.Background(), capture)
+	CaptureResponse(ctx, "A", frames)
+	CaptureResponse(ctx, "B", nil)
+
+	responses := capture.Responses()
+	assert.Equal(t, map[string]data.Frames{"A": frames, "B": nil}, responses)
+
+	// the returned responses are a copy
+	delete(responses, "A")
+	assert.Len(t, capture.Responses(), 2)
+}
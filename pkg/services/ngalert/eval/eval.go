@@ -4,6 +4,7 @@ package eval
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -33,6 +34,16 @@ func (e *invalidEvalResultFormatError) Unwrap() error {
 	return e.err
 }
 
+// tooManySeriesError is returned by the executions of a condition whose datasource responses
+// have more series than the condition accepts; the responses are not converted.
+type tooManySeriesError struct {
+	n int
+}
+
+func (e *tooManySeriesError) Error() string {
+	return fmt.Sprintf("too many series: %d", e.n)
+}
+
 // Condition contains backend expressions and queries and the RefID
 // of the query or expression that will be evaluated.
 type Condition struct {
@@ -40,6 +51,24 @@ type Condition struct {
 	OrgID int64  `json:"-"`
 
 	QueriesAndExpressions []AlertQuery `json:"queriesAndExpressions"`
+
+	// Trend if set evaluates the condition at two points in time and compares them.
+	Trend *TrendCondition `json:"trend,omitempty"`
+
+	// Baseline if set compares the values of the condition as a percentage of the values of a baseline.
+	Baseline *BaselineCondition `json:"baseline,omitempty"`
+
+	// MaxSeries if positive is the maximum number of series the evaluation accepts;
+	// beyond it the series are not evaluated and a single Error result is returned.
+	MaxSeries int64 `json:"-"`
+
+	// CacheKey identifies the alert definition of the condition
+	// and CacheTTL if positive is the time its results are reused by the CachingEvaluator.
+	CacheKey string        `json:"-"`
+	CacheTTL time.Duration `json:"-"`
+
+	// threshold is set by Prepare if the condition can be evaluated by the fast path.
+	threshold *thresholdCondition
 }
 
 // ExecutionResults contains the unevaluated results from executing
@@ -50,33 +79,54 @@ type ExecutionResults struct {
 	Error error
 
 	Results data.Frames
+
+	// Responses are the frames of every query and expression by RefID.
+	Responses map[string]data.Frames
 }
 
 // Results is a slice of evaluated alert instances states.
-type Results []result
+type Results []Result
 
-// result contains the evaluated state of an alert instance
+// Result contains the evaluated state of an alert instance
 // identified by its labels.
-type result struct {
+type Result struct {
 	Instance data.Labels
-	State    state // Enum
+	State    State // Enum
+	// Value is the value the condition evaluated to.
+	Value float64
+	// ComparedValue is the value compared to the threshold of a condition evaluated by the fast path,
+	// e.g. the value of $A in $A > 80, if any; the Value is the result of the comparison.
+	ComparedValue *float64
+	// Error is the reason of the Error state.
+	Error error
 }
 
-// state is an enum of the evaluation state for an alert instance.
-type state int
+// State is an enum of the evaluation state for an alert instance.
+type State int
 
 const (
 	// Normal is the eval state for an alert instance condition
 	// that evaluated to false.
-	Normal state = iota
+	Normal State = iota
 
 	// Alerting is the eval state for an alert instance condition
 	// that evaluated to false.
 	Alerting
+
+	// Pending is the state of an alert instance whose condition
+	// is true but not for long enough for the instance to fire.
+	Pending
+
+	// Error is the eval state of a condition that could not be evaluated.
+	Error
+
+	// NotApplicable is the neutral state of an alert instance
+	// whose condition is skipped because its guard condition does not hold.
+	NotApplicable
 )
 
-func (s state) String() string {
-	return [...]string{"Normal", "Alerting"}[s]
+func (s State) String() string {
+	return [...]string{"Normal", "Alerting", "Pending", "Error", "NotApplicable"}[s]
 }
 
 // IsValid checks the condition's validity.
@@ -85,11 +135,29 @@ func (c Condition) IsValid() bool {
 	return len(c.QueriesAndExpressions) != 0
 }
 
+// WithRelativeTimeRange returns a copy of the condition
+// with the relative time range of all its queries set to the provided one.
+// Expressions are left unchanged since they don't query any datasource.
+func (c Condition) WithRelativeTimeRange(rtr RelativeTimeRange) Condition {
+	queries := make([]AlertQuery, len(c.QueriesAndExpressions))
+	for i, q := range c.QueriesAndExpressions {
+		if isExpression, err := q.IsExpression(); err == nil && !isExpression {
+			q.RelativeTimeRange = rtr
+		}
+		queries[i] = q
+	}
+	c.QueriesAndExpressions = queries
+	return c
+}
+
 // AlertExecCtx is the context provided for executing an alert condition.
 type AlertExecCtx struct {
 	OrgID int64
 
 	Ctx context.Context
+
+	// PreQuery are run in order on the request before it's executed.
+	PreQuery []QueryMiddleware
 }
 
 // execute runs the Condition's expressions or queries.
@@ -100,9 +168,68 @@ func (c *Condition) execute(ctx AlertExecCtx, now time.Time) (*ExecutionResults,
 		// TODO: Things probably
 	}
 
+	queryDataReq, err := c.buildQueryDataRequest(ctx.OrgID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := resolveExternalValues(ctx.Ctx, queryDataReq); err != nil {
+		return nil, err
+	}
+
+	if err := applyQueryMiddlewares(ctx.Ctx, ctx.PreQuery, queryDataReq); err != nil {
+		return nil, err
+	}
+
+	// the datasource responses over the maximum number of series are not converted
+	limit := expr.NewSeriesLimit(c.MaxSeries)
+	transformCtx := expr.WithSeriesLimit(ctx.Ctx, limit)
+	pbRes, err := expr.TransformData(transformCtx, queryDataReq)
+	if n, exceeded := limit.Exceeded(); exceeded {
+		return &result, &tooManySeriesError{n: int(n)}
+	}
+	if err != nil && c.hasFallback() {
+		// find out the failing queries only once the evaluation has failed
+		// so that the queries are not issued twice when all datasources succeed
+		fallbackReq, replaced, fallbackErr := c.applyFallbacks(ctx.Ctx, queryDataReq, expr.QueryData)
+		if fallbackErr != nil {
+			return &result, fallbackErr
+		}
+		if replaced {
+			pbRes, err = expr.TransformData(transformCtx, fallbackReq)
+		}
+		if n, exceeded := limit.Exceeded(); exceeded {
+			return &result, &tooManySeriesError{n: int(n)}
+		}
+	}
+	if err != nil {
+		return &result, err
+	}
+
+	result.Responses = make(map[string]data.Frames, len(pbRes.Responses))
+	for refID, res := range pbRes.Responses {
+		result.Responses[refID] = res.Frames
+		CaptureResponse(ctx.Ctx, refID, res.Frames)
+		if refID != c.RefID {
+			continue
+		}
+		result.Results = res.Frames
+	}
+
+	if len(result.Results) == 0 {
+		err = fmt.Errorf("no GEL results")
+		result.Error = err
+		return &result, err
+	}
+
+	return &result, nil
+}
+
+// buildQueryDataRequest returns the request for executing the condition queries at the given time.
+func (c *Condition) buildQueryDataRequest(orgID int64, now time.Time) (*backend.QueryDataRequest, error) {
 	queryDataReq := &backend.QueryDataRequest{
 		PluginContext: backend.PluginContext{
-			OrgID: ctx.OrgID,
+			OrgID: orgID,
 		},
 		Queries: []backend.DataQuery{},
 	}
@@ -132,32 +259,13 @@ func (c *Condition) execute(ctx AlertExecCtx, now time.Time) (*ExecutionResults,
 			TimeRange:     q.RelativeTimeRange.toTimeRange(now),
 		})
 	}
-
-	pbRes, err := expr.TransformData(ctx.Ctx, queryDataReq)
-	if err != nil {
-		return &result, err
-	}
-
-	for refID, res := range pbRes.Responses {
-		if refID != c.RefID {
-			continue
-		}
-		result.Results = res.Frames
-	}
-
-	if len(result.Results) == 0 {
-		err = fmt.Errorf("no GEL results")
-		result.Error = err
-		return &result, err
-	}
-
-	return &result, nil
+	return queryDataReq, nil
 }
 
 // evaluateExecutionResult takes the ExecutionResult, and returns a frame where
 // each column is a string type that holds a string representing its state.
 func evaluateExecutionResult(results *ExecutionResults) (Results, error) {
-	evalResults := make([]result, 0)
+	evalResults := make([]Result, 0)
 	labels := make(map[string]bool)
 	for _, f := range results.Results {
 		rowLen, err := f.RowLen()
@@ -189,14 +297,36 @@ func evaluateExecutionResult(results *ExecutionResults) (Results, error) {
 			state = Alerting
 		}
 
-		evalResults = append(evalResults, result{
+		evalResults = append(evalResults, Result{
 			Instance: f.Fields[0].Labels,
 			State:    state,
+			Value:    val,
 		})
 	}
 	return evalResults, nil
 }
 
+// exceedsMaxSeries returns true if the number of series is over the condition limit.
+func (c *Condition) exceedsMaxSeries(n int) bool {
+	return c.MaxSeries > 0 && int64(n) > c.MaxSeries
+}
+
+// tooManySeries returns the single Error result replacing the results
+// of an evaluation whose number of series is over the condition limit.
+func (c *Condition) tooManySeries(n int) Results {
+	return Results{{State: Error, Error: fmt.Errorf("too many series: %d > %d", n, c.MaxSeries)}}
+}
+
+// tooManySeriesResults returns the single Error result replacing the results
+// of an execution failed because its number of series is over the condition limit, if it did.
+func (c *Condition) tooManySeriesResults(err error) (Results, bool) {
+	var tooMany *tooManySeriesError
+	if !errors.As(err, &tooMany) {
+		return nil, false
+	}
+	return c.tooManySeries(tooMany.n), true
+}
+
 // AsDataFrame forms the EvalResults in Frame suitable for displaying in the table panel of the front end.
 // This may be temporary, as there might be a fair amount we want to display in the frontend, and it might not make sense to store that in data.Frame.
 // For the first pass, I would expect a Frame with a single row, and a column for each instance with a boolean value.
@@ -209,18 +339,78 @@ func (evalResults Results) AsDataFrame() data.Frame {
 	return *f
 }
 
+// Evaluator evaluates the condition of an alert definition.
+type Evaluator interface {
+	// ConditionEval executes the condition at the given time and evaluates the result.
+	ConditionEval(ctx context.Context, condition *Condition, now time.Time) (Results, error)
+}
+
+// EvaluatorFunc is an adapter to allow the use of ordinary functions as Evaluators.
+type EvaluatorFunc func(ctx context.Context, condition *Condition, now time.Time) (Results, error)
+
+// ConditionEval calls f(ctx, condition, now).
+func (f EvaluatorFunc) ConditionEval(ctx context.Context, condition *Condition, now time.Time) (Results, error) {
+	return f(ctx, condition, now)
+}
+
+// DefaultEvaluator is the Evaluator that executes the condition queries against the datasources.
+type DefaultEvaluator struct {
+	// PreQuery are run in order on the request of the condition queries before it's executed.
+	PreQuery []QueryMiddleware
+	// PostResult are run in order on the evaluation results.
+	PostResult []ResultMiddleware
+}
+
+// ConditionEval executes conditions and evaluates the result.
+func (e DefaultEvaluator) ConditionEval(ctx context.Context, condition *Condition, now time.Time) (Results, error) {
+	evalResults, err := conditionEval(ctx, condition, now, e.PreQuery...)
+	if err != nil {
+		return nil, err
+	}
+	return applyResultMiddlewares(ctx, e.PostResult, condition, evalResults)
+}
+
 // ConditionEval executes conditions and evaluates the result.
 func ConditionEval(condition *Condition, now time.Time) (Results, error) {
-	alertCtx, cancelFn := context.WithTimeout(context.Background(), alertingEvaluationTimeout)
+	return conditionEval(context.Background(), condition, now)
+}
+
+func conditionEval(ctx context.Context, condition *Condition, now time.Time, preQuery ...QueryMiddleware) (Results, error) {
+	alertCtx, cancelFn := context.WithTimeout(ctx, alertingEvaluationTimeout)
 	defer cancelFn()
 
-	alertExecCtx := AlertExecCtx{OrgID: condition.OrgID, Ctx: alertCtx}
+	if condition.Trend != nil {
+		return condition.evalTrend(alertCtx, now, preQuery)
+	}
+
+	if condition.Baseline != nil {
+		return condition.evalBaseline(alertCtx, now, preQuery)
+	}
+
+	if condition.threshold != nil {
+		evalResults, err := condition.threshold.eval(alertCtx, condition, now, expr.QueryData, preQuery)
+		if err == nil {
+			return evalResults, nil
+		}
+		if !errors.Is(err, errFastPathUnsupported) {
+			return nil, fmt.Errorf("failed to execute conditions: %w", err)
+		}
+	}
+
+	alertExecCtx := AlertExecCtx{OrgID: condition.OrgID, Ctx: alertCtx, PreQuery: preQuery}
 
 	execResult, err := condition.execute(alertExecCtx, now)
+	if results, ok := condition.tooManySeriesResults(err); ok {
+		return results, nil
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute conditions: %w", err)
 	}
 
+	if condition.exceedsMaxSeries(len(execResult.Results)) {
+		return condition.tooManySeries(len(execResult.Results)), nil
+	}
+
 	evalResults, err := evaluateExecutionResult(execResult)
 	if err != nil {
 		return nil, fmt.Errorf("failed to evaluate results: %w", err)
@@ -0,0 +1,179 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Explanation traces how the condition has been evaluated:
+// the values returned by each query and expression and the final comparison.
+type Explanation struct {
+	Steps      []ExplainStep     `json:"steps"`
+	Comparison ExplainComparison `json:"comparison"`
+}
+
+// ExplainStep is the result of a query or an expression of the condition.
+type ExplainStep struct {
+	RefID string `json:"refId"`
+	// Type is the type of the expression, e.g. reduce or math, or query for the datasource queries.
+	Type string `json:"type"`
+	// Expression is the math expression or the input of the other expressions.
+	Expression string `json:"expression,omitempty"`
+	// Reducer is the reduce function of the reduce expressions.
+	Reducer string          `json:"reducer,omitempty"`
+	Series  []ExplainSeries `json:"series"`
+}
+
+// ExplainSeries are the values of a series in order; nil values are missing.
+type ExplainSeries struct {
+	Labels data.Labels `json:"labels"`
+	Values []*float64  `json:"values"`
+}
+
+// ExplainComparison is the final comparison of the condition.
+// If the condition expression compares a query or an expression to a constant, e.g. $B > 80,
+// the compared RefID, the operator and the threshold are set
+// and each result has the compared value of its series.
+type ExplainComparison struct {
+	RefID         string          `json:"refId"`
+	Expression    string          `json:"expression,omitempty"`
+	ComparedRefID string          `json:"comparedRefId,omitempty"`
+	Op            string          `json:"op,omitempty"`
+	Threshold     *float64        `json:"threshold,omitempty"`
+	Results       []ExplainResult `json:"results"`
+}
+
+// ExplainResult is the evaluated state of an alert instance
+// with the value compared to the threshold, if any.
+type ExplainResult struct {
+	Labels        data.Labels `json:"labels"`
+	ComparedValue *float64    `json:"comparedValue,omitempty"`
+	State         string      `json:"state"`
+}
+
+// ConditionEvalExplain evaluates the condition like ConditionEval
+// and returns the explanation of the evaluation along with the results.
+// The condition is always evaluated by the expression engine, even if it's prepared for the fast path,
+// so that the results of the intermediate expressions are available.
+func ConditionEvalExplain(ctx context.Context, condition *Condition, now time.Time) (Results, *Explanation, error) {
+	alertCtx, cancelFn := context.WithTimeout(ctx, alertingEvaluationTimeout)
+	defer cancelFn()
+
+	execResult, err := condition.execute(AlertExecCtx{OrgID: condition.OrgID, Ctx: alertCtx}, now)
+	tooMany, exceeded := condition.tooManySeriesResults(err)
+	if err != nil && !exceeded {
+		return nil, nil, fmt.Errorf("failed to execute conditions: %w", err)
+	}
+
+	var evalResults Results
+	switch {
+	case exceeded:
+		evalResults = tooMany
+	case condition.exceedsMaxSeries(len(execResult.Results)):
+		evalResults = condition.tooManySeries(len(execResult.Results))
+	default:
+		evalResults, err = evaluateExecutionResult(execResult)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to evaluate results: %w", err)
+		}
+	}
+
+	explanation, err := condition.explain(execResult.Responses, evalResults)
+	if err != nil {
+		return nil, nil, err
+	}
+	return evalResults, explanation, nil
+}
+
+// explain builds the explanation from the frames of the queries and expressions and the evaluation results.
+func (c *Condition) explain(responses map[string]data.Frames, evalResults Results) (*Explanation, error) {
+	explanation := &Explanation{
+		Steps:      make([]ExplainStep, 0, len(c.QueriesAndExpressions)),
+		Comparison: ExplainComparison{RefID: c.RefID, Results: make([]ExplainResult, 0, len(evalResults))},
+	}
+
+	steps := make(map[string]ExplainStep, len(c.QueriesAndExpressions))
+	for i := range c.QueriesAndExpressions {
+		q := &c.QueriesAndExpressions[i]
+		step := ExplainStep{RefID: q.RefID, Type: "query", Series: explainSeries(responses[q.RefID])}
+
+		isExpression, err := q.IsExpression()
+		if err != nil {
+			return nil, err
+		}
+		if isExpression {
+			model := struct {
+				Type       string `json:"type"`
+				Expression string `json:"expression"`
+				Reducer    string `json:"reducer"`
+			}{}
+			if err := json.Unmarshal(q.Model, &model); err != nil {
+				return nil, fmt.Errorf("failed to get the model of expression %s: %w", q.RefID, err)
+			}
+			step.Type = model.Type
+			step.Expression = model.Expression
+			step.Reducer = model.Reducer
+		}
+
+		explanation.Steps = append(explanation.Steps, step)
+		steps[q.RefID] = step
+	}
+
+	comparison := &explanation.Comparison
+	comparison.Expression = steps[c.RefID].Expression
+	var compared map[string]*float64
+	if match := thresholdExpressionRegexp.FindStringSubmatch(comparison.Expression); match != nil {
+		if threshold, err := strconv.ParseFloat(match[3], 64); err == nil {
+			comparison.ComparedRefID = match[1]
+			comparison.Op = match[2]
+			comparison.Threshold = &threshold
+			compared = make(map[string]*float64)
+			for _, s := range steps[match[1]].Series {
+				if len(s.Values) > 0 {
+					compared[s.Labels.String()] = s.Values[len(s.Values)-1]
+				}
+			}
+		}
+	}
+
+	for _, r := range evalResults {
+		result := ExplainResult{Labels: r.Instance, State: r.State.String()}
+		if compared != nil {
+			result.ComparedValue = compared[r.Instance.String()]
+		}
+		comparison.Results = append(comparison.Results, result)
+	}
+	return explanation, nil
+}
+
+// explainSeries returns the values of every numeric field of the frames.
+func explainSeries(frames data.Frames) []ExplainSeries {
+	series := make([]ExplainSeries, 0)
+	for _, f := range frames {
+		for _, field := range f.Fields {
+			if !field.Type().Numeric() {
+				continue
+			}
+			s := ExplainSeries{Labels: field.Labels, Values: make([]*float64, 0, field.Len())}
+			for i := 0; i < field.Len(); i++ {
+				if _, ok := field.ConcreteAt(i); !ok {
+					s.Values = append(s.Values, nil)
+					continue
+				}
+				v, err := field.FloatAt(i)
+				if err != nil {
+					s.Values = append(s.Values, nil)
+					continue
+				}
+				s.Values = append(s.Values, &v)
+			}
+			series = append(series, s)
+		}
+	}
+	return series
+}
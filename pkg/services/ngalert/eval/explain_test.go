@@ -0,0 +1,73 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConditionEvalExplain(t *testing.T) {
+	now := time.Now()
+	registerFakeEndpoint(data.NewFrame("",
+		data.NewField("time", nil, []time.Time{now.Add(-time.Minute), now}),
+		data.NewField("value", data.Labels{"host": "a"}, []*float64{fp(70), fp(90)}),
+	))
+
+	condition := Condition{
+		RefID: "C",
+		OrgID: 1,
+		QueriesAndExpressions: []AlertQuery{
+			{
+				RefID:             "A",
+				RelativeTimeRange: RelativeTimeRange{From: Duration(5 * time.Minute)},
+				Model:             json.RawMessage(`{"datasource": "fastpath-test", "datasourceId": 1, "intervalMs": 1000, "maxDataPoints": 100}`),
+			},
+			{
+				RefID: "B",
+				Model: json.RawMessage(`{"datasource": "__expr__", "type": "reduce", "expression": "$A", "reducer": "last"}`),
+			},
+			{
+				RefID: "C",
+				Model: json.RawMessage(`{"datasource": "__expr__", "type": "math", "expression": "$B > 80"}`),
+			},
+		},
+	}
+
+	results, explanation, err := ConditionEvalExplain(context.Background(), &condition, now)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, Alerting, results[0].State)
+
+	require.Len(t, explanation.Steps, 3)
+	query, reduce := explanation.Steps[0], explanation.Steps[1]
+	assert.Equal(t, "query", query.Type)
+	require.Len(t, query.Series, 1)
+	assert.Equal(t, []*float64{fp(70), fp(90)}, query.Series[0].Values)
+
+	t.Run("the explanation should include the intermediate reduce value", func(t *testing.T) {
+		assert.Equal(t, "B", reduce.RefID)
+		assert.Equal(t, "reduce", reduce.Type)
+		assert.Equal(t, "last", reduce.Reducer)
+		require.Len(t, reduce.Series, 1)
+		assert.Equal(t, data.Labels{"host": "a"}, reduce.Series[0].Labels)
+		assert.Equal(t, []*float64{fp(90)}, reduce.Series[0].Values)
+	})
+
+	t.Run("the explanation should include the final comparison", func(t *testing.T) {
+		comparison := explanation.Comparison
+		assert.Equal(t, "C", comparison.RefID)
+		assert.Equal(t, "$B > 80", comparison.Expression)
+		assert.Equal(t, "B", comparison.ComparedRefID)
+		assert.Equal(t, ">", comparison.Op)
+		assert.Equal(t, fp(80), comparison.Threshold)
+		require.Len(t, comparison.Results, 1)
+		assert.Equal(t, data.Labels{"host": "a"}, comparison.Results[0].Labels)
+		assert.Equal(t, fp(90), comparison.Results[0].ComparedValue)
+		assert.Equal(t, "Alerting", comparison.Results[0].State)
+	})
+}
@@ -0,0 +1,83 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana/pkg/expr"
+)
+
+// externalExpressionType is the type of the expressions evaluating to a value of the ExternalValueProvider,
+// e.g. {"datasource": "__expr__", "type": "external", "name": "rollout_percentage"}.
+const externalExpressionType = "external"
+
+// ErrNoExternalValueProvider is returned by the evaluation of a condition referencing an external value
+// if no ExternalValueProvider is set in its context.
+var ErrNoExternalValueProvider = errors.New("no external value provider")
+
+// ExternalValueProvider provides the named values the conditions can reference
+// that don't come from a datasource, e.g. the percentage of a feature flag rollout.
+type ExternalValueProvider interface {
+	// ExternalValue returns the current value of the organisation with the given name.
+	ExternalValue(ctx context.Context, orgID int64, name string) (float64, error)
+}
+
+// ExternalValueProviderFunc is an adapter to allow the use of ordinary functions as ExternalValueProviders.
+type ExternalValueProviderFunc func(ctx context.Context, orgID int64, name string) (float64, error)
+
+// ExternalValue calls f(ctx, orgID, name).
+func (f ExternalValueProviderFunc) ExternalValue(ctx context.Context, orgID int64, name string) (float64, error) {
+	return f(ctx, orgID, name)
+}
+
+type externalValueProviderKey struct{}
+
+// WithExternalValueProvider returns a copy of ctx with the provider of the external values
+// of the conditions evaluated with it.
+func WithExternalValueProvider(ctx context.Context, provider ExternalValueProvider) context.Context {
+	return context.WithValue(ctx, externalValueProviderKey{}, provider)
+}
+
+// resolveExternalValues replaces in the request every external expression by a math expression
+// evaluating to the value of the provider of the context, so that the other expressions reference it like a query.
+// The request is left unchanged if the condition has no external expression.
+func resolveExternalValues(ctx context.Context, req *backend.QueryDataRequest) error {
+	for i, q := range req.Queries {
+		model := struct {
+			Datasource string `json:"datasource"`
+			Type       string `json:"type"`
+			Name       string `json:"name"`
+		}{}
+		if err := json.Unmarshal(q.JSON, &model); err != nil || model.Datasource != expr.DatasourceName || model.Type != externalExpressionType {
+			continue
+		}
+		if model.Name == "" {
+			return fmt.Errorf("external expression %s has no value name", q.RefID)
+		}
+
+		provider, _ := ctx.Value(externalValueProviderKey{}).(ExternalValueProvider)
+		if provider == nil {
+			return fmt.Errorf("%w: external expression %s", ErrNoExternalValueProvider, q.RefID)
+		}
+		value, err := provider.ExternalValue(ctx, req.PluginContext.OrgID, model.Name)
+		if err != nil {
+			return fmt.Errorf("failed to get the external value %s of expression %s: %w", model.Name, q.RefID, err)
+		}
+
+		resolved, err := json.Marshal(map[string]interface{}{
+			"datasource":   expr.DatasourceName,
+			"datasourceId": expr.DatasourceID,
+			"type":         "math",
+			"expression":   strconv.FormatFloat(value, 'g', -1, 64),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to resolve the external expression %s: %w", q.RefID, err)
+		}
+		req.Queries[i].JSON = resolved
+	}
+	return nil
+}
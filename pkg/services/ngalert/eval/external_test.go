@@ -0,0 +1,66 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConditionEvalExternalValue(t *testing.T) {
+	registerFakeEndpoint(data.NewFrame("",
+		data.NewField("host", nil, []string{"a", "b"}),
+		data.NewField("value", nil, []*float64{fp(20), fp(60)}),
+	))
+
+	// the error rate of the hosts is compared to the rollout percentage of a feature flag
+	condition := Condition{
+		RefID: "C",
+		OrgID: 1,
+		QueriesAndExpressions: []AlertQuery{
+			{
+				RefID:             "A",
+				RelativeTimeRange: RelativeTimeRange{From: Duration(5 * time.Minute)},
+				Model:             json.RawMessage(`{"datasource": "fastpath-test", "datasourceId": 1, "intervalMs": 1000, "maxDataPoints": 100}`),
+			},
+			{
+				RefID: "B",
+				Model: json.RawMessage(`{"datasource": "__expr__", "type": "external", "name": "rollout_percentage"}`),
+			},
+			{
+				RefID: "C",
+				Model: json.RawMessage(`{"datasource": "__expr__", "type": "math", "expression": "$A > $B"}`),
+			},
+		},
+	}
+
+	t.Run("without provider the evaluation fails", func(t *testing.T) {
+		c := condition
+		_, err := conditionEval(context.Background(), &c, time.Now())
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrNoExternalValueProvider))
+	})
+
+	t.Run("the condition evaluates the value of the provider", func(t *testing.T) {
+		var requested []string
+		provider := ExternalValueProviderFunc(func(_ context.Context, orgID int64, name string) (float64, error) {
+			requested = append(requested, name)
+			assert.Equal(t, int64(1), orgID)
+			return 50, nil
+		})
+
+		c := condition
+		results, err := conditionEval(WithExternalValueProvider(context.Background(), provider), &c, time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, []string{"rollout_percentage"}, requested)
+		assert.ElementsMatch(t, Results{
+			{Instance: data.Labels{"host": "a"}, State: Normal, Value: 0},
+			{Instance: data.Labels{"host": "b"}, State: Alerting, Value: 1},
+		}, results)
+	})
+}
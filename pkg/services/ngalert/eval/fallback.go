@@ -0,0 +1,71 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana/pkg/expr"
+)
+
+// hasFallback returns true if any of the condition queries has a fallback value.
+func (c *Condition) hasFallback() bool {
+	for _, q := range c.QueriesAndExpressions {
+		if q.Fallback != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// applyFallbacks returns a copy of the request where every query with a fallback value
+// whose datasource fails is replaced by a math expression evaluating to the fallback value.
+// It returns false if no query has been replaced.
+func (c *Condition) applyFallbacks(ctx context.Context, req *backend.QueryDataRequest, queryData queryDataFunc) (*backend.QueryDataRequest, bool, error) {
+	fallbackReq := &backend.QueryDataRequest{
+		PluginContext: req.PluginContext,
+		Queries:       make([]backend.DataQuery, len(req.Queries)),
+	}
+	copy(fallbackReq.Queries, req.Queries)
+
+	replaced := false
+	for i := range c.QueriesAndExpressions {
+		q := &c.QueriesAndExpressions[i]
+		if q.Fallback == nil || i >= len(fallbackReq.Queries) {
+			continue
+		}
+		datasourceID, err := q.GetDatasource()
+		if err != nil {
+			return nil, false, err
+		}
+		if datasourceID == expr.DatasourceID {
+			continue
+		}
+
+		res, err := queryData(ctx, &backend.QueryDataRequest{
+			PluginContext: backend.PluginContext{
+				OrgID:                      req.PluginContext.OrgID,
+				DataSourceInstanceSettings: &backend.DataSourceInstanceSettings{ID: datasourceID},
+			},
+			Queries: []backend.DataQuery{req.Queries[i]},
+		})
+		if err == nil && res != nil && res.Responses[q.RefID].Error == nil {
+			continue
+		}
+
+		model, err := json.Marshal(map[string]interface{}{
+			"datasource":   expr.DatasourceName,
+			"datasourceId": expr.DatasourceID,
+			"type":         "math",
+			"expression":   strconv.FormatFloat(*q.Fallback, 'g', -1, 64),
+		})
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to build the fallback of query %s: %w", q.RefID, err)
+		}
+		fallbackReq.Queries[i].JSON = model
+		replaced = true
+	}
+	return fallbackReq, replaced, nil
+}
@@ -0,0 +1,81 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingEndpoint struct{}
+
+func (failingEndpoint) Query(context.Context, *models.DataSource, *tsdb.TsdbQuery) (*tsdb.Response, error) {
+	return nil, errors.New("datasource is down")
+}
+
+func TestConditionEvalFallback(t *testing.T) {
+	registerFakeEndpoint(data.NewFrame("",
+		data.NewField("host", nil, []string{"a", "b"}),
+		data.NewField("value", nil, []*float64{fp(1), fp(90)}),
+	))
+	tsdb.RegisterTsdbQueryEndpoint("failing-test", func(*models.DataSource) (tsdb.TsdbQueryEndpoint, error) {
+		return failingEndpoint{}, nil
+	})
+	// the datasource 2 is failing
+	bus.AddHandler("test", func(query *models.GetDataSourceByIdQuery) error {
+		dsType := "fastpath-test"
+		if query.Id == 2 {
+			dsType = "failing-test"
+		}
+		query.Result = &models.DataSource{Id: query.Id, OrgId: query.OrgId, Type: dsType}
+		return nil
+	})
+
+	condition := func(fallback *float64) Condition {
+		return Condition{
+			RefID: "C",
+			OrgID: 1,
+			QueriesAndExpressions: []AlertQuery{
+				{
+					RefID:             "A",
+					RelativeTimeRange: RelativeTimeRange{From: Duration(5 * time.Minute)},
+					Model:             json.RawMessage(`{"datasource": "fastpath-test", "datasourceId": 1, "intervalMs": 1000, "maxDataPoints": 100}`),
+				},
+				{
+					RefID:             "B",
+					RelativeTimeRange: RelativeTimeRange{From: Duration(5 * time.Minute)},
+					Model:             json.RawMessage(`{"datasource": "failing-test", "datasourceId": 2, "intervalMs": 1000, "maxDataPoints": 100}`),
+					Fallback:          fallback,
+				},
+				{
+					RefID: "C",
+					Model: json.RawMessage(`{"datasource": "__expr__", "type": "math", "expression": "$A + $B > 80"}`),
+				},
+			},
+		}
+	}
+
+	t.Run("without fallback the evaluation fails", func(t *testing.T) {
+		c := condition(nil)
+		_, err := conditionEval(context.Background(), &c, time.Now())
+		require.Error(t, err)
+	})
+
+	t.Run("with fallback the failing query evaluates to the fallback value", func(t *testing.T) {
+		c := condition(fp(0))
+		results, err := conditionEval(context.Background(), &c, time.Now())
+		require.NoError(t, err)
+		assert.ElementsMatch(t, Results{
+			{Instance: data.Labels{"host": "a"}, State: Normal, Value: 0},
+			{Instance: data.Labels{"host": "b"}, State: Alerting, Value: 1},
+		}, results)
+	})
+}
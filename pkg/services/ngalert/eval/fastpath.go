@@ -0,0 +1,273 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// errFastPathUnsupported is returned by the fast path
+// if the query result should be evaluated by the expression engine.
+var errFastPathUnsupported = errors.New("result not supported by the fast path")
+
+// thresholdExpressionRegexp matches math expressions comparing a query to a constant, e.g. $A > 80.
+var thresholdExpressionRegexp = regexp.MustCompile(`^\s*\$\{?([A-Za-z0-9_]+)\}?\s*(>=|<=|==|!=|>|<)\s*([-+]?(?:[0-9]+\.?[0-9]*|\.[0-9]+)(?:[eE][-+]?[0-9]+)?)\s*$`)
+
+// thresholdCondition is a condition consisting of a single datasource query
+// and a math expression comparing its result to a constant.
+type thresholdCondition struct {
+	refID     string
+	op        string
+	threshold float64
+}
+
+// Prepare detects whether the condition is a single query compared to a threshold;
+// such a condition is evaluated by a fast path that bypasses the expression engine.
+// It should be called once when the condition is registered rather than on every evaluation.
+func (c *Condition) Prepare() {
+	c.threshold = c.detectThreshold()
+}
+
+func (c *Condition) detectThreshold() *thresholdCondition {
+	if len(c.QueriesAndExpressions) != 2 {
+		return nil
+	}
+
+	var condition, query *AlertQuery
+	for i := range c.QueriesAndExpressions {
+		q := &c.QueriesAndExpressions[i]
+		if q.RefID == c.RefID {
+			condition = q
+		} else {
+			query = q
+		}
+	}
+	if condition == nil || query == nil {
+		return nil
+	}
+
+	if isExpression, err := condition.IsExpression(); err != nil || !isExpression {
+		return nil
+	}
+	if isExpression, err := query.IsExpression(); err != nil || isExpression {
+		return nil
+	}
+	// the fallback value is applied by the expression engine
+	if query.Fallback != nil {
+		return nil
+	}
+
+	model := struct {
+		Type       string `json:"type"`
+		Expression string `json:"expression"`
+	}{}
+	if err := json.Unmarshal(condition.Model, &model); err != nil || model.Type != "math" {
+		return nil
+	}
+
+	match := thresholdExpressionRegexp.FindStringSubmatch(model.Expression)
+	if match == nil || match[1] != query.RefID {
+		return nil
+	}
+	threshold, err := strconv.ParseFloat(match[3], 64)
+	if err != nil {
+		return nil
+	}
+
+	return &thresholdCondition{refID: query.RefID, op: match[2], threshold: threshold}
+}
+
+// WithThreshold returns a copy of the condition with the constant of its
+// threshold expression, e.g. 80 in $A > 80, replaced by the provided one.
+// It returns an error if the condition is not a query compared to a threshold.
+func (c Condition) WithThreshold(threshold float64) (Condition, error) {
+	t := c.detectThreshold()
+	if t == nil {
+		return c, fmt.Errorf("condition %s is not a query compared to a threshold", c.RefID)
+	}
+
+	queries := make([]AlertQuery, len(c.QueriesAndExpressions))
+	for i, q := range c.QueriesAndExpressions {
+		if q.RefID == c.RefID {
+			model := make(map[string]interface{})
+			if err := json.Unmarshal(q.Model, &model); err != nil {
+				return c, fmt.Errorf("failed to get condition model: %w", err)
+			}
+			model["expression"] = fmt.Sprintf("$%s %s %s", t.refID, t.op, strconv.FormatFloat(threshold, 'g', -1, 64))
+			raw, err := json.Marshal(model)
+			if err != nil {
+				return c, err
+			}
+			// the parsed model of the original query should not be reused
+			q = AlertQuery{
+				RefID:             q.RefID,
+				QueryType:         q.QueryType,
+				RelativeTimeRange: q.RelativeTimeRange,
+				DatasourceID:      q.DatasourceID,
+				Model:             raw,
+				Fallback:          q.Fallback,
+			}
+		}
+		queries[i] = q
+	}
+	c.QueriesAndExpressions = queries
+	c.threshold = nil
+	return c, nil
+}
+
+// Threshold returns the constant the condition query is compared to, e.g. 80 in $A > 80,
+// if the condition has been prepared for the fast path.
+func (c *Condition) Threshold() (float64, bool) {
+	// the values of the instances are percentages of the baseline
+	if c.Baseline != nil {
+		return c.Baseline.Percentage, true
+	}
+	if c.threshold == nil {
+		return 0, false
+	}
+	return c.threshold.threshold, true
+}
+
+// eval executes the threshold condition query and compares its result to the threshold.
+// It returns errFastPathUnsupported if the query result is not a set of numbers
+// that the expression engine would evaluate the same way.
+func (t *thresholdCondition) eval(ctx context.Context, c *Condition, now time.Time, queryData queryDataFunc, preQuery []QueryMiddleware) (Results, error) {
+	var query *AlertQuery
+	for i := range c.QueriesAndExpressions {
+		if c.QueriesAndExpressions[i].RefID == t.refID {
+			query = &c.QueriesAndExpressions[i]
+		}
+	}
+	if query == nil {
+		return nil, errFastPathUnsupported
+	}
+
+	single := Condition{RefID: t.refID, OrgID: c.OrgID, QueriesAndExpressions: []AlertQuery{*query}}
+	req, err := single.buildQueryDataRequest(c.OrgID, now)
+	if err != nil {
+		return nil, err
+	}
+	datasourceID, err := query.GetDatasource()
+	if err != nil {
+		return nil, err
+	}
+	req.PluginContext.DataSourceInstanceSettings = &backend.DataSourceInstanceSettings{ID: datasourceID}
+	if err := applyQueryMiddlewares(ctx, preQuery, req); err != nil {
+		return nil, err
+	}
+
+	res, err := queryData(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	r, ok := res.Responses[t.refID]
+	if !ok {
+		return nil, errFastPathUnsupported
+	}
+	if r.Error != nil {
+		return nil, r.Error
+	}
+	CaptureResponse(ctx, t.refID, r.Frames)
+	if len(r.Frames) != 1 {
+		return nil, errFastPathUnsupported
+	}
+	// every row of a number table is a series
+	frame := r.Frames[0]
+	if frame.TimeSeriesSchema().Type == data.TimeSeriesTypeNot && c.exceedsMaxSeries(frame.Rows()) {
+		return c.tooManySeries(frame.Rows()), nil
+	}
+
+	return t.evaluateFrame(frame)
+}
+
+// evaluateFrame compares every row of a number table to the threshold.
+// The labels of each row are taken from its string fields like the expression engine does.
+func (t *thresholdCondition) evaluateFrame(frame *data.Frame) (Results, error) {
+	if frame.TimeSeriesSchema().Type != data.TimeSeriesTypeNot {
+		return nil, errFastPathUnsupported
+	}
+
+	numericField := -1
+	var stringFields []int
+	for i, field := range frame.Fields {
+		fType := field.Type()
+		switch {
+		case fType.Numeric():
+			if numericField != -1 {
+				return nil, errFastPathUnsupported
+			}
+			numericField = i
+		case fType == data.FieldTypeString || fType == data.FieldTypeNullableString:
+			stringFields = append(stringFields, i)
+		default:
+			return nil, errFastPathUnsupported
+		}
+	}
+	if numericField == -1 || frame.Rows() == 0 {
+		return nil, errFastPathUnsupported
+	}
+
+	results := make(Results, 0, frame.Rows())
+	seen := make(map[string]struct{}, frame.Rows())
+	for row := 0; row < frame.Rows(); row++ {
+		var labels data.Labels
+		for i, idx := range stringFields {
+			if i == 0 {
+				labels = make(data.Labels)
+			}
+			v, ok := frame.ConcreteAt(idx, row)
+			if !ok {
+				return nil, errFastPathUnsupported
+			}
+			labels[frame.Fields[idx].Name] = v.(string)
+		}
+		if _, ok := seen[labels.String()]; ok {
+			return nil, errFastPathUnsupported
+		}
+		seen[labels.String()] = struct{}{}
+
+		val, err := frame.FloatAt(numericField, row)
+		if err != nil {
+			return nil, errFastPathUnsupported
+		}
+
+		// the value is the one of the comparison in the expression engine
+		state, value := Normal, 0.0
+		switch {
+		case math.IsNaN(val):
+			state, value = Alerting, val
+		case t.compare(val):
+			state, value = Alerting, 1
+		}
+		results = append(results, Result{Instance: labels, State: state, Value: value, ComparedValue: &val})
+	}
+	return results, nil
+}
+
+// compare returns true if the value crosses the threshold.
+func (t *thresholdCondition) compare(val float64) bool {
+	switch t.op {
+	case ">":
+		return val > t.threshold
+	case ">=":
+		return val >= t.threshold
+	case "<":
+		return val < t.threshold
+	case "<=":
+		return val <= t.threshold
+	case "==":
+		return val == t.threshold
+	case "!=":
+		return val != t.threshold
+	default:
+		return false
+	}
+}
@@ -0,0 +1,220 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeEndpoint struct {
+	frames data.Frames
+	// lastQuery is the last query received by the endpoint
+	lastQuery *tsdb.TsdbQuery
+	// calls is the number of queries received by the endpoint
+	calls int
+}
+
+func (e *fakeEndpoint) Query(ctx context.Context, ds *models.DataSource, query *tsdb.TsdbQuery) (*tsdb.Response, error) {
+	e.lastQuery = query
+	e.calls++
+	return &tsdb.Response{
+		Results: map[string]*tsdb.QueryResult{
+			"A": {
+				Dataframes: tsdb.NewDecodedDataFrames(e.frames),
+			},
+		},
+	}, nil
+}
+
+func registerFakeEndpoint(frames ...*data.Frame) *fakeEndpoint {
+	e := &fakeEndpoint{frames: frames}
+	tsdb.RegisterTsdbQueryEndpoint("fastpath-test", func(*models.DataSource) (tsdb.TsdbQueryEndpoint, error) {
+		return e, nil
+	})
+	bus.AddHandler("test", func(query *models.GetDataSourceByIdQuery) error {
+		query.Result = &models.DataSource{Id: query.Id, OrgId: query.OrgId, Type: "fastpath-test"}
+		return nil
+	})
+	return e
+}
+
+func thresholdTestCondition(expression string) Condition {
+	return Condition{
+		RefID: "B",
+		OrgID: 1,
+		QueriesAndExpressions: []AlertQuery{
+			{
+				RefID:             "A",
+				RelativeTimeRange: RelativeTimeRange{From: Duration(5 * time.Minute)},
+				Model:             json.RawMessage(`{"datasource": "fastpath-test", "datasourceId": 1, "intervalMs": 1000, "maxDataPoints": 100}`),
+			},
+			{
+				RefID: "B",
+				Model: json.RawMessage(`{"datasource": "__expr__", "type": "math", "expression": "` + expression + `"}`),
+			},
+		},
+	}
+}
+
+func TestConditionPrepare(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		expression string
+		expected   *thresholdCondition
+	}{
+		{
+			desc:       "threshold",
+			expression: "$A > 80",
+			expected:   &thresholdCondition{refID: "A", op: ">", threshold: 80},
+		},
+		{
+			desc:       "threshold with braces",
+			expression: "${A}<=-0.5",
+			expected:   &thresholdCondition{refID: "A", op: "<=", threshold: -0.5},
+		},
+		{
+			desc:       "arithmetic",
+			expression: "$A * 2 > 80",
+		},
+		{
+			desc:       "other query",
+			expression: "$C > 80",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			condition := thresholdTestCondition(tc.expression)
+			condition.Prepare()
+			assert.Equal(t, tc.expected, condition.threshold)
+		})
+	}
+}
+
+func TestConditionEvalFastPath(t *testing.T) {
+	registerFakeEndpoint(data.NewFrame("",
+		data.NewField("host", nil, []string{"a", "b", "c", "d"}),
+		data.NewField("value", nil, []*float64{fp(1), fp(90), fp(80), nil}),
+	))
+
+	now := time.Now()
+	for _, expression := range []string{"$A > 80", "$A >= 80", "$A < 80", "$A == 80", "$A != 80"} {
+		t.Run(expression, func(t *testing.T) {
+			general := thresholdTestCondition(expression)
+			expected, err := conditionEval(context.Background(), &general, now)
+			require.NoError(t, err)
+
+			fast := thresholdTestCondition(expression)
+			fast.Prepare()
+			require.NotNil(t, fast.threshold)
+			results, err := conditionEval(context.Background(), &fast, now)
+			require.NoError(t, err)
+
+			// the values are formatted since NaN values are never equal
+			format := func(results Results) map[string]string {
+				m := make(map[string]string, len(results))
+				for _, r := range results {
+					m[r.Instance.String()] = fmt.Sprintf("%s %v", r.State, r.Value)
+				}
+				return m
+			}
+			assert.Equal(t, format(expected), format(results))
+
+			// the values compared to the threshold are the ones of the query
+			compared := make(map[string]float64, len(results))
+			for _, r := range results {
+				require.NotNil(t, r.ComparedValue)
+				compared[r.Instance["host"]] = *r.ComparedValue
+			}
+			assert.Equal(t, 90.0, compared["b"])
+			assert.Equal(t, 80.0, compared["c"])
+		})
+	}
+}
+
+func BenchmarkConditionEval(b *testing.B) {
+	registerFakeEndpoint(data.NewFrame("",
+		data.NewField("host", nil, []string{"a", "b", "c"}),
+		data.NewField("value", nil, []*float64{fp(1), fp(90), fp(80)}),
+	))
+
+	now := time.Now()
+	general := thresholdTestCondition("$A > 80")
+	fast := thresholdTestCondition("$A > 80")
+	fast.Prepare()
+
+	b.Run("general path", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := conditionEval(context.Background(), &general, now); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("fast path", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := conditionEval(context.Background(), &fast, now); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func fp(f float64) *float64 {
+	return &f
+}
+
+func TestConditionEvalMaxSeries(t *testing.T) {
+	registerFakeEndpoint(data.NewFrame("",
+		data.NewField("host", nil, []string{"a", "b", "c", "d"}),
+		data.NewField("value", nil, []*float64{fp(1), fp(90), fp(80), fp(100)}),
+	))
+
+	now := time.Now()
+	for _, fast := range []bool{false, true} {
+		t.Run(fmt.Sprintf("fast path %v", fast), func(t *testing.T) {
+			condition := thresholdTestCondition("$A > 80")
+			if fast {
+				condition.Prepare()
+				require.NotNil(t, condition.threshold)
+			}
+
+			condition.MaxSeries = 4
+			results, err := conditionEval(context.Background(), &condition, now)
+			require.NoError(t, err)
+			assert.Len(t, results, 4)
+
+			condition.MaxSeries = 3
+			results, err = conditionEval(context.Background(), &condition, now)
+			require.NoError(t, err)
+			// the series are not evaluated
+			require.Len(t, results, 1)
+			assert.Equal(t, Error, results[0].State)
+			assert.Nil(t, results[0].Instance)
+			assert.EqualError(t, results[0].Error, "too many series: 4 > 3")
+		})
+	}
+}
+
+func TestConditionWithThreshold(t *testing.T) {
+	condition := thresholdTestCondition("${A} >= 80")
+	overridden, err := condition.WithThreshold(95.5)
+	require.NoError(t, err)
+
+	overridden.Prepare()
+	assert.Equal(t, &thresholdCondition{refID: "A", op: ">=", threshold: 95.5}, overridden.threshold)
+	// the original condition is unchanged
+	assert.Contains(t, string(condition.QueriesAndExpressions[1].Model), "${A} >= 80")
+
+	_, err = thresholdTestCondition("$A * 2 > 80").WithThreshold(95)
+	require.Error(t, err)
+}
@@ -0,0 +1,75 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoldenEvaluator(t *testing.T) {
+	evaluator, err := NewGoldenEvaluator()
+	require.NoError(t, err)
+
+	testCases := []struct {
+		golden   string
+		expected map[string]string
+	}{
+		{
+			golden:   "all_normal",
+			expected: map[string]string{"a": "Normal", "b": "Normal", "c": "Normal"},
+		},
+		{
+			golden:   "one_alerting",
+			expected: map[string]string{"a": "Alerting", "b": "Normal", "c": "Normal"},
+		},
+		{
+			golden:   "all_alerting",
+			expected: map[string]string{"a": "Alerting", "b": "Alerting", "c": "Alerting"},
+		},
+		{
+			golden:   "missing_value",
+			expected: map[string]string{"a": "Normal", "b": "Alerting"},
+		},
+		{
+			golden:   "no_data",
+			expected: map[string]string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.golden, func(t *testing.T) {
+			condition := &Condition{
+				RefID: "A",
+				OrgID: 1,
+				QueriesAndExpressions: []AlertQuery{
+					{RefID: "A", Model: json.RawMessage(`{"golden": "` + tc.golden + `"}`)},
+				},
+			}
+
+			// the outputs are stable whatever the evaluation time
+			for _, now := range []time.Time{time.Unix(0, 0), time.Now()} {
+				results, err := evaluator.ConditionEval(context.Background(), condition, now)
+				require.NoError(t, err)
+
+				states := make(map[string]string, len(results))
+				for _, r := range results {
+					states[r.Instance["host"]] = r.State.String()
+				}
+				assert.Equal(t, tc.expected, states)
+			}
+		})
+	}
+
+	t.Run("unknown series set", func(t *testing.T) {
+		condition := &Condition{
+			RefID:                 "A",
+			QueriesAndExpressions: []AlertQuery{{RefID: "A", Model: json.RawMessage(`{"golden": "unknown"}`)}},
+		}
+		_, err := evaluator.ConditionEval(context.Background(), condition, time.Now())
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,39 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// QueryMiddleware modifies the request of the condition queries before it's executed,
+// e.g. to rewrite the queries of a datasource.
+type QueryMiddleware func(ctx context.Context, req *backend.QueryDataRequest) error
+
+// ResultMiddleware transforms the evaluation results of the condition,
+// e.g. to rewrite the instance labels.
+type ResultMiddleware func(ctx context.Context, condition *Condition, results Results) (Results, error)
+
+// applyQueryMiddlewares runs the query middlewares in order.
+func applyQueryMiddlewares(ctx context.Context, middlewares []QueryMiddleware, req *backend.QueryDataRequest) error {
+	for i, m := range middlewares {
+		if err := m(ctx, req); err != nil {
+			return fmt.Errorf("query middleware %d failed: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// applyResultMiddlewares runs the result middlewares in order
+// passing the results returned by each one to the next.
+func applyResultMiddlewares(ctx context.Context, middlewares []ResultMiddleware, condition *Condition, results Results) (Results, error) {
+	for i, m := range middlewares {
+		var err error
+		results, err = m(ctx, condition, results)
+		if err != nil {
+			return nil, fmt.Errorf("result middleware %d failed: %w", i, err)
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,83 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultEvaluatorMiddlewares(t *testing.T) {
+	endpoint := registerFakeEndpoint(data.NewFrame("",
+		data.NewField("host", nil, []string{"a", "b"}),
+		data.NewField("value", nil, []*float64{fp(1), fp(90)}),
+	))
+
+	var calls []string
+	// setMaxDataPoints returns a query middleware updating the max data points of the query A
+	setMaxDataPoints := func(name string, update func(float64) float64) QueryMiddleware {
+		return func(ctx context.Context, req *backend.QueryDataRequest) error {
+			calls = append(calls, name)
+			for i, q := range req.Queries {
+				if q.RefID != "A" {
+					continue
+				}
+				model := make(map[string]interface{})
+				if err := json.Unmarshal(q.JSON, &model); err != nil {
+					return err
+				}
+				maxDataPoints, _ := model["maxDataPoints"].(float64)
+				model["maxDataPoints"] = update(maxDataPoints)
+				b, err := json.Marshal(model)
+				if err != nil {
+					return err
+				}
+				req.Queries[i].JSON = b
+			}
+			return nil
+		}
+	}
+	// setLabel returns a result middleware setting the label of all the instances
+	setLabel := func(name, label string, value func(data.Labels) string) ResultMiddleware {
+		return func(ctx context.Context, condition *Condition, results Results) (Results, error) {
+			calls = append(calls, name)
+			for i := range results {
+				labels := results[i].Instance.Copy()
+				labels[label] = value(labels)
+				results[i].Instance = labels
+			}
+			return results, nil
+		}
+	}
+
+	evaluator := DefaultEvaluator{
+		PreQuery: []QueryMiddleware{
+			setMaxDataPoints("set", func(float64) float64 { return 10 }),
+			setMaxDataPoints("double", func(v float64) float64 { return v * 2 }),
+		},
+		PostResult: []ResultMiddleware{
+			setLabel("unit", "unit", func(data.Labels) string { return "ms" }),
+			setLabel("description", "description", func(l data.Labels) string { return l["host"] + " in " + l["unit"] }),
+		},
+	}
+
+	condition := thresholdTestCondition("$A > 80")
+	results, err := evaluator.ConditionEval(context.Background(), &condition, time.Now())
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"set", "double", "unit", "description"}, calls)
+
+	require.NotNil(t, endpoint.lastQuery)
+	require.Len(t, endpoint.lastQuery.Queries, 1)
+	assert.Equal(t, int64(20), endpoint.lastQuery.Queries[0].MaxDataPoints)
+
+	assert.ElementsMatch(t, Results{
+		{Instance: data.Labels{"host": "a", "unit": "ms", "description": "a in ms"}, State: Normal, Value: 0},
+		{Instance: data.Labels{"host": "b", "unit": "ms", "description": "b in ms"}, State: Alerting, Value: 1},
+	}, results)
+}
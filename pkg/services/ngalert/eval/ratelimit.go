@@ -0,0 +1,66 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// ErrRateLimited is returned when an evaluation exceeding the evaluation rate limit
+// cannot wait for its turn.
+var ErrRateLimited = errors.New("evaluation rate limit exceeded")
+
+// RateLimitedEvaluator is an Evaluator that caps the total number of evaluations per second
+// using a token bucket shared by all the evaluations.
+type RateLimitedEvaluator struct {
+	evaluator Evaluator
+	limiter   *rate.Limiter
+	// the maximum time an evaluation waits for its turn
+	maxWait time.Duration
+}
+
+// NewRateLimitedEvaluator returns a new RateLimitedEvaluator
+// allowing up to evalsPerSecond evaluations per second.
+func NewRateLimitedEvaluator(evaluator Evaluator, evalsPerSecond float64) *RateLimitedEvaluator {
+	burst := int(evalsPerSecond)
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimitedEvaluator{
+		evaluator: evaluator,
+		limiter:   rate.NewLimiter(rate.Limit(evalsPerSecond), burst),
+		maxWait:   alertingEvaluationTimeout,
+	}
+}
+
+// ConditionEval evaluates the condition once its turn comes, counting from the evaluation time.
+// If the turn does not come before the context deadline or within the maximum wait,
+// it returns ErrRateLimited without evaluating it and gives back its turn.
+func (e *RateLimitedEvaluator) ConditionEval(ctx context.Context, condition *Condition, now time.Time) (Results, error) {
+	r := e.limiter.ReserveN(now, 1)
+	if !r.OK() {
+		return nil, ErrRateLimited
+	}
+	if delay := r.DelayFrom(now); delay > 0 {
+		maxWait := e.maxWait
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < maxWait {
+			maxWait = time.Until(deadline)
+		}
+		if delay > maxWait {
+			r.CancelAt(now)
+			return nil, ErrRateLimited
+		}
+
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			r.CancelAt(now)
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return e.evaluator.ConditionEval(ctx, condition, now)
+}
@@ -0,0 +1,70 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitedEvaluator(t *testing.T) {
+	condition := &Condition{RefID: "A", OrgID: 1}
+	newEvaluator := func(evalsPerSecond float64) (*RateLimitedEvaluator, *int) {
+		evaluated := 0
+		return NewRateLimitedEvaluator(EvaluatorFunc(func(context.Context, *Condition, time.Time) (Results, error) {
+			evaluated++
+			return nil, nil
+		}), evalsPerSecond), &evaluated
+	}
+
+	t.Run("the evaluations over the limit should be deferred", func(t *testing.T) {
+		evaluator, evaluated := newEvaluator(100)
+		now := time.Now()
+		start := time.Now()
+		// the burst and two evaluations over the limit
+		for i := 0; i < 102; i++ {
+			_, err := evaluator.ConditionEval(context.Background(), condition, now)
+			require.NoError(t, err)
+		}
+
+		assert.Equal(t, 102, *evaluated)
+		assert.GreaterOrEqual(t, int64(time.Since(start)), int64(20*time.Millisecond), "the evaluations over the limit should wait for their turn")
+	})
+
+	t.Run("the evaluations whose turn doesn't come within the maximum wait should be rate limited", func(t *testing.T) {
+		evaluator, evaluated := newEvaluator(2)
+		evaluator.maxWait = 100 * time.Millisecond
+		now := time.Unix(0, 0)
+		for i := 0; i < 2; i++ {
+			_, err := evaluator.ConditionEval(context.Background(), condition, now)
+			require.NoError(t, err)
+		}
+
+		_, err := evaluator.ConditionEval(context.Background(), condition, now)
+		assert.True(t, errors.Is(err, ErrRateLimited))
+		assert.Equal(t, 2, *evaluated)
+
+		// the rate limited evaluation gave back its turn
+		_, err = evaluator.ConditionEval(context.Background(), condition, now.Add(500*time.Millisecond))
+		require.NoError(t, err)
+		assert.Equal(t, 3, *evaluated)
+	})
+
+	t.Run("the evaluations whose turn doesn't come before the deadline should be rate limited", func(t *testing.T) {
+		evaluator, evaluated := newEvaluator(2)
+		now := time.Unix(0, 0)
+		for i := 0; i < 2; i++ {
+			_, err := evaluator.ConditionEval(context.Background(), condition, now)
+			require.NoError(t, err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := evaluator.ConditionEval(ctx, condition, now)
+		assert.True(t, errors.Is(err, ErrRateLimited))
+		assert.Equal(t, 2, *evaluated)
+	})
+}
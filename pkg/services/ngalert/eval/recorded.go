@@ -0,0 +1,69 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// RecordedSeries is a recorded condition result for a single alert instance.
+type RecordedSeries struct {
+	Labels data.Labels `json:"labels"`
+	Value  *float64    `json:"value"`
+}
+
+// RecordedResponse is the recorded response of a condition at a specific time.
+type RecordedResponse struct {
+	RefID  string           `json:"refId"`
+	Time   int64            `json:"time"`
+	Series []RecordedSeries `json:"series"`
+}
+
+// RecordedEvaluator is an Evaluator that instead of querying the datasources
+// reads the condition results from recorded responses keyed by the condition RefID
+// and the evaluation time, so that a scheduler run can be replayed deterministically.
+type RecordedEvaluator struct {
+	responses map[string]data.Frames
+}
+
+// NewRecordedEvaluator returns a RecordedEvaluator for the JSON encoded list of recorded responses.
+func NewRecordedEvaluator(r io.Reader) (*RecordedEvaluator, error) {
+	var recorded []RecordedResponse
+	if err := json.NewDecoder(r).Decode(&recorded); err != nil {
+		return nil, fmt.Errorf("failed to decode recorded responses: %w", err)
+	}
+
+	e := &RecordedEvaluator{responses: make(map[string]data.Frames, len(recorded))}
+	for _, resp := range recorded {
+		frames := make(data.Frames, 0, len(resp.Series))
+		for _, s := range resp.Series {
+			f := data.NewFrame("", data.NewField("", s.Labels, []*float64{s.Value}))
+			f.RefID = resp.RefID
+			frames = append(frames, f)
+		}
+		e.responses[recordedKey(resp.RefID, time.Unix(resp.Time, 0))] = frames
+	}
+	return e, nil
+}
+
+// ConditionEval evaluates the recorded response of the condition at the given time.
+func (e *RecordedEvaluator) ConditionEval(_ context.Context, condition *Condition, now time.Time) (Results, error) {
+	frames, ok := e.responses[recordedKey(condition.RefID, now)]
+	if !ok {
+		return nil, fmt.Errorf("no recorded response for condition %s at %v", condition.RefID, now)
+	}
+
+	evalResults, err := evaluateExecutionResult(&ExecutionResults{Results: frames})
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate results: %w", err)
+	}
+	return evalResults, nil
+}
+
+func recordedKey(refID string, now time.Time) string {
+	return fmt.Sprintf("%s@%d", refID, now.Unix())
+}
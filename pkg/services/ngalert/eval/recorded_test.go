@@ -0,0 +1,47 @@
+package eval
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const recordedFixture = `[
+	{"refId": "A", "time": 10, "series": [{"labels": {"host": "a"}, "value": 0}, {"labels": {"host": "b"}, "value": 0}]},
+	{"refId": "A", "time": 20, "series": [{"labels": {"host": "a"}, "value": 1}, {"labels": {"host": "b"}, "value": 0}]},
+	{"refId": "A", "time": 30, "series": [{"labels": {"host": "a"}, "value": 1}, {"labels": {"host": "b"}, "value": 1}]},
+	{"refId": "A", "time": 40, "series": [{"labels": {"host": "a"}, "value": 0}, {"labels": {"host": "b"}, "value": null}]}
+]`
+
+func TestRecordedEvaluator(t *testing.T) {
+	evaluator, err := NewRecordedEvaluator(strings.NewReader(recordedFixture))
+	require.NoError(t, err)
+
+	condition := &Condition{RefID: "A", OrgID: 1}
+
+	expected := []map[string]string{
+		{"a": "Normal", "b": "Normal"},
+		{"a": "Alerting", "b": "Normal"},
+		{"a": "Alerting", "b": "Alerting"},
+		{"a": "Normal", "b": "Alerting"},
+	}
+
+	for i, exp := range expected {
+		now := time.Unix(int64(i+1)*10, 0)
+		results, err := evaluator.ConditionEval(context.Background(), condition, now)
+		require.NoError(t, err)
+
+		states := make(map[string]string, len(results))
+		for _, r := range results {
+			states[r.Instance["host"]] = r.State.String()
+		}
+		assert.Equal(t, exp, states, "unexpected states at %v", now)
+	}
+
+	_, err = evaluator.ConditionEval(context.Background(), condition, time.Unix(50, 0))
+	require.Error(t, err)
+}
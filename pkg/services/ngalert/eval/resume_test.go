@@ -0,0 +1,108 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/expr"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyEndpoint fails the first failures queries then answers with its frames.
+type flakyEndpoint struct {
+	frames   data.Frames
+	failures int
+	calls    int
+}
+
+func (e *flakyEndpoint) Query(_ context.Context, _ *models.DataSource, query *tsdb.TsdbQuery) (*tsdb.Response, error) {
+	e.calls++
+	if e.calls <= e.failures {
+		return nil, errors.New("datasource is temporarily down")
+	}
+	return &tsdb.Response{
+		Results: map[string]*tsdb.QueryResult{
+			query.Queries[0].RefId: {
+				Dataframes: tsdb.NewDecodedDataFrames(e.frames),
+			},
+		},
+	}, nil
+}
+
+func TestConditionEvalQueryCache(t *testing.T) {
+	stable := registerFakeEndpoint(data.NewFrame("",
+		data.NewField("host", nil, []string{"a", "b"}),
+		data.NewField("value", nil, []*float64{fp(1), fp(90)}),
+	))
+	flaky := &flakyEndpoint{
+		frames: data.Frames{data.NewFrame("",
+			data.NewField("host", nil, []string{"a", "b"}),
+			data.NewField("value", nil, []*float64{fp(2), fp(3)}),
+		)},
+		failures: 1,
+	}
+	tsdb.RegisterTsdbQueryEndpoint("flaky-test", func(*models.DataSource) (tsdb.TsdbQueryEndpoint, error) {
+		return flaky, nil
+	})
+	// the datasource 2 fails once
+	bus.AddHandler("test", func(query *models.GetDataSourceByIdQuery) error {
+		dsType := "fastpath-test"
+		if query.Id == 2 {
+			dsType = "flaky-test"
+		}
+		query.Result = &models.DataSource{Id: query.Id, OrgId: query.OrgId, Type: dsType}
+		return nil
+	})
+
+	condition := Condition{
+		RefID: "C",
+		OrgID: 1,
+		QueriesAndExpressions: []AlertQuery{
+			{
+				RefID:             "A",
+				RelativeTimeRange: RelativeTimeRange{From: Duration(5 * time.Minute)},
+				Model:             json.RawMessage(`{"datasource": "fastpath-test", "datasourceId": 1, "intervalMs": 1000, "maxDataPoints": 100}`),
+			},
+			{
+				RefID:             "B",
+				RelativeTimeRange: RelativeTimeRange{From: Duration(5 * time.Minute)},
+				Model:             json.RawMessage(`{"datasource": "flaky-test", "datasourceId": 2, "intervalMs": 1000, "maxDataPoints": 100}`),
+			},
+			{
+				RefID: "C",
+				Model: json.RawMessage(`{"datasource": "__expr__", "type": "math", "expression": "$A + $B > 80"}`),
+			},
+		},
+	}
+
+	now := time.Now()
+	ctx := expr.WithQueryCache(context.Background(), expr.NewQueryCache())
+
+	_, err := conditionEval(ctx, &condition, now)
+	require.Error(t, err)
+
+	// the retry only executes the failed query
+	results, err := conditionEval(ctx, &condition, now)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, Results{
+		{Instance: data.Labels{"host": "a"}, State: Normal, Value: 0},
+		{Instance: data.Labels{"host": "b"}, State: Alerting, Value: 1},
+	}, results)
+	assert.Equal(t, 1, stable.calls)
+	assert.Equal(t, 2, flaky.calls)
+
+	t.Run("the responses are not reused at another evaluation time", func(t *testing.T) {
+		_, err := conditionEval(ctx, &condition, now.Add(time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, 2, stable.calls)
+		assert.Equal(t, 3, flaky.calls)
+	})
+}
@@ -0,0 +1,27 @@
+package eval
+
+import (
+	"unsafe"
+)
+
+var (
+	resultSize = int64(unsafe.Sizeof(Result{}))
+	// labelSize is the size of the name and value headers of a label
+	labelSize = int64(2 * unsafe.Sizeof(""))
+)
+
+// SizeBytes returns the approximate memory footprint of the results in bytes:
+// the results themselves, their labels and their error messages.
+// It ignores the overhead of the maps of the labels.
+func (evalResults Results) SizeBytes() int64 {
+	size := int64(len(evalResults)) * resultSize
+	for _, r := range evalResults {
+		for k, v := range r.Instance {
+			size += labelSize + int64(len(k)+len(v))
+		}
+		if r.Error != nil {
+			size += int64(len(r.Error.Error()))
+		}
+	}
+	return size
+}
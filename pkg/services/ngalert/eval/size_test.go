@@ -0,0 +1,38 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResultsSizeBytes(t *testing.T) {
+	results := func(n int) Results {
+		r := make(Results, 0, n)
+		for i := 0; i < n; i++ {
+			r = append(r, Result{Instance: data.Labels{"host": fmt.Sprintf("host-%03d", i)}, State: Alerting})
+		}
+		return r
+	}
+
+	assert.Equal(t, int64(0), Results{}.SizeBytes())
+
+	t.Run("the size should scale with the number of results", func(t *testing.T) {
+		one := results(1).SizeBytes()
+		assert.Greater(t, one, int64(0))
+		assert.Equal(t, 10*one, results(10).SizeBytes())
+		assert.Equal(t, 100*one, results(100).SizeBytes())
+	})
+
+	t.Run("the size should account for the labels and the errors", func(t *testing.T) {
+		bare := Results{{State: Normal}}.SizeBytes()
+		labeled := Results{{State: Normal, Instance: data.Labels{"host": "a"}}}.SizeBytes()
+		assert.Equal(t, bare+labelSize+int64(len("host")+len("a")), labeled)
+
+		failed := Results{{State: Error, Error: errors.New("boom")}}.SizeBytes()
+		assert.Equal(t, bare+int64(len("boom")), failed)
+	})
+}
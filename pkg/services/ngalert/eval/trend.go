@@ -0,0 +1,96 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TrendCondition compares the values of a condition at the evaluation time
+// to its values Offset earlier, e.g. to alert when an error rate doubled in 5m.
+// The instance is Alerting if the ratio of its current value to its previous one
+// compared by Op to Ratio holds, e.g. current / previous >= 2.
+type TrendCondition struct {
+	// Offset is the time before the evaluation time the previous values are evaluated at.
+	Offset Duration `json:"offset"`
+	Op     string   `json:"op"`
+	Ratio  float64  `json:"ratio"`
+}
+
+// IsZero returns true if the trend condition is not set.
+func (t TrendCondition) IsZero() bool {
+	return t.Offset == 0 && t.Op == "" && t.Ratio == 0
+}
+
+// Validate returns an error if the trend condition can't be evaluated.
+func (t TrendCondition) Validate() error {
+	if t.Offset <= 0 {
+		return fmt.Errorf("invalid trend offset: %v: it should be positive", t.Offset)
+	}
+	switch t.Op {
+	case ">", ">=", "<", "<=", "==", "!=":
+		return nil
+	default:
+		return fmt.Errorf("invalid trend operator: %q", t.Op)
+	}
+}
+
+// evalTrend executes the condition at the evaluation time and Offset earlier
+// and evaluates the trend of every instance between the two.
+// The instances without previous value are Normal.
+func (c *Condition) evalTrend(ctx context.Context, now time.Time, preQuery []QueryMiddleware) (Results, error) {
+	if err := c.Trend.Validate(); err != nil {
+		return nil, err
+	}
+
+	evaluateAt := func(at time.Time) (Results, error) {
+		execResult, err := c.execute(AlertExecCtx{OrgID: c.OrgID, Ctx: ctx, PreQuery: preQuery}, at)
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute conditions at %v: %w", at, err)
+		}
+		if c.exceedsMaxSeries(len(execResult.Results)) {
+			return nil, &tooManySeriesError{n: len(execResult.Results)}
+		}
+		results, err := evaluateExecutionResult(execResult)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate results at %v: %w", at, err)
+		}
+		return results, nil
+	}
+
+	// the series over the limit are not evaluated at either point in time
+	current, err := evaluateAt(now)
+	if results, ok := c.tooManySeriesResults(err); ok {
+		return results, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	previous, err := evaluateAt(now.Add(-time.Duration(c.Trend.Offset)))
+	if results, ok := c.tooManySeriesResults(err); ok {
+		return results, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	previousValues := make(map[string]float64, len(previous))
+	for _, r := range previous {
+		previousValues[r.Instance.String()] = r.Value
+	}
+
+	ratio := thresholdCondition{op: c.Trend.Op, threshold: c.Trend.Ratio}
+	results := make(Results, 0, len(current))
+	for _, r := range current {
+		result := Result{Instance: r.Instance, State: Normal}
+		if prev, ok := previousValues[r.Instance.String()]; ok {
+			// the ratio is infinite if the previous value is 0 and NaN if both are
+			result.Value = r.Value / prev
+			if ratio.compare(result.Value) {
+				result.State = Alerting
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,82 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// timeDependentEndpoint answers with the frames returned by its function of the end of the query time range.
+type timeDependentEndpoint struct {
+	frames func(to time.Time) data.Frames
+}
+
+func (e *timeDependentEndpoint) Query(_ context.Context, _ *models.DataSource, query *tsdb.TsdbQuery) (*tsdb.Response, error) {
+	return &tsdb.Response{
+		Results: map[string]*tsdb.QueryResult{
+			query.Queries[0].RefId: {
+				Dataframes: tsdb.NewDecodedDataFrames(e.frames(query.TimeRange.GetToAsTimeUTC())),
+			},
+		},
+	}, nil
+}
+
+func TestConditionEvalTrend(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	// the error rate of the host a doubles within the last 5 minutes
+	tsdb.RegisterTsdbQueryEndpoint("trend-test", func(*models.DataSource) (tsdb.TsdbQueryEndpoint, error) {
+		return &timeDependentEndpoint{frames: func(to time.Time) data.Frames {
+			a, b := 10.0, 10.0
+			if !to.Before(now) {
+				a, b = 20, 11
+			}
+			return data.Frames{data.NewFrame("",
+				data.NewField("host", nil, []string{"a", "b"}),
+				data.NewField("value", nil, []*float64{fp(a), fp(b)}),
+			)}
+		}}, nil
+	})
+	bus.AddHandler("test", func(query *models.GetDataSourceByIdQuery) error {
+		query.Result = &models.DataSource{Id: query.Id, OrgId: query.OrgId, Type: "trend-test"}
+		return nil
+	})
+
+	condition := Condition{
+		RefID: "B",
+		OrgID: 1,
+		QueriesAndExpressions: []AlertQuery{
+			{
+				RefID:             "A",
+				RelativeTimeRange: RelativeTimeRange{From: Duration(time.Minute)},
+				Model:             json.RawMessage(`{"datasource": "trend-test", "datasourceId": 1, "intervalMs": 1000, "maxDataPoints": 100}`),
+			},
+			{
+				RefID: "B",
+				Model: json.RawMessage(`{"datasource": "__expr__", "type": "math", "expression": "$A * 1"}`),
+			},
+		},
+		Trend: &TrendCondition{Offset: Duration(5 * time.Minute), Op: ">=", Ratio: 2},
+	}
+
+	results, err := conditionEval(context.Background(), &condition, now)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, Results{
+		{Instance: data.Labels{"host": "a"}, State: Alerting, Value: 2},
+		{Instance: data.Labels{"host": "b"}, State: Normal, Value: 1.1},
+	}, results)
+
+	t.Run("an invalid trend fails the evaluation", func(t *testing.T) {
+		c := condition
+		c.Trend = &TrendCondition{Offset: Duration(5 * time.Minute), Op: "=>", Ratio: 2}
+		_, err := conditionEval(context.Background(), &c, now)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,76 @@
+package ngalert
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+// ChangedDefinitionEvaluation is the out-of-band evaluation of an alert definition changed since a time.
+type ChangedDefinitionEvaluation struct {
+	DefinitionUID string `json:"definitionUid"`
+	Version       int64  `json:"version"`
+	// TemplateValue is the template value of an expanded template alert definition.
+	TemplateValue string       `json:"templateValue,omitempty"`
+	Results       eval.Results `json:"results"`
+	// Error is the reason the alert definition could not be evaluated, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// EvalChangedSince evaluates the alert definitions of the organisation updated since the given time,
+// e.g. for validating only the alert definitions affected by a bulk edit. The template alert definitions
+// are evaluated for every template value. Like any preview the evaluations do not update
+// the state of the alert instances, and the alert definitions failing to evaluate do not stop
+// the evaluation of the other ones but are reported in the summary.
+func (ng *AlertNG) EvalChangedSince(ctx context.Context, orgID int64, since time.Time, now time.Time) ([]ChangedDefinitionEvaluation, error) {
+	if since.IsZero() {
+		// a zero time would fetch all the alert definitions
+		return []ChangedDefinitionEvaluation{}, nil
+	}
+	changed, err := ng.definitionStore().FetchDeltas(since)
+	if err != nil {
+		return nil, err
+	}
+
+	orgDefinitions := make([]*AlertDefinition, 0, len(changed))
+	for _, alertDefinition := range changed {
+		if alertDefinition.OrgID == orgID {
+			orgDefinitions = append(orgDefinitions, alertDefinition)
+		}
+	}
+	sort.Slice(orgDefinitions, func(i, j int) bool {
+		return orgDefinitions[i].ID < orgDefinitions[j].ID
+	})
+
+	evaluations := make([]ChangedDefinitionEvaluation, 0, len(orgDefinitions))
+	for _, alertDefinition := range expandTemplates(orgDefinitions) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		evaluation := ChangedDefinitionEvaluation{
+			DefinitionUID: alertDefinition.UID,
+			Version:       alertDefinition.Version,
+			TemplateValue: alertDefinition.templateValue,
+		}
+		if alertDefinition.templateValue != "" {
+			expanded, err := alertDefinition.expand(alertDefinition.templateValue)
+			if err != nil {
+				evaluation.Error = err.Error()
+				evaluations = append(evaluations, evaluation)
+				continue
+			}
+			alertDefinition = expanded
+		}
+		condition := alertDefinition.getCondition()
+		results, err := ng.EvalDefinitionNow(ctx, alertDefinition.ID, &condition, now)
+		if err != nil {
+			ng.log.Warn("failed to evaluate the changed alert definition", "uid", alertDefinition.UID, "orgID", orgID, "error", err)
+			evaluation.Error = err.Error()
+		}
+		evaluation.Results = results
+		evaluations = append(evaluations, evaluation)
+	}
+	return evaluations, nil
+}
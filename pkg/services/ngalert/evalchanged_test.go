@@ -0,0 +1,83 @@
+package ngalert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvalChangedSince(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	var mu sync.Mutex
+	var evaluated []string
+	ng.schedule.previewEvaluator = eval.EvaluatorFunc(func(_ context.Context, condition *eval.Condition, _ time.Time) (eval.Results, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		evaluated = append(evaluated, condition.RefID)
+		return eval.Results{{Instance: data.Labels{"condition": condition.RefID}, State: eval.Alerting}}, nil
+	})
+
+	created := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	since := created.Add(time.Hour)
+	edited := since.Add(time.Minute)
+
+	store := newInMemoryDefinitionStore()
+	for i := 1; i <= 4; i++ {
+		store.add(&AlertDefinition{ID: int64(i), OrgID: 1, UID: fmt.Sprintf("definition-%d", i), Condition: fmt.Sprintf("C%d", i), Version: 1, Updated: created})
+	}
+	// the bulk edit updated two of the alert definitions
+	store.add(&AlertDefinition{ID: 2, OrgID: 1, UID: "definition-2", Condition: "C2", Version: 2, Updated: edited})
+	store.add(&AlertDefinition{ID: 4, OrgID: 1, UID: "definition-4", Condition: "C4", Version: 2, Updated: edited})
+	// the alert definitions of the other organisations are not evaluated
+	store.add(&AlertDefinition{ID: 5, OrgID: 2, UID: "definition-5", Condition: "C5", Version: 2, Updated: edited})
+	ng.SetDefinitionStore(store)
+
+	evaluations, err := ng.EvalChangedSince(context.Background(), 1, since, edited)
+	require.NoError(t, err)
+	require.Len(t, evaluations, 2)
+	assert.Equal(t, "definition-2", evaluations[0].DefinitionUID)
+	assert.Equal(t, int64(2), evaluations[0].Version)
+	assert.Equal(t, eval.Results{{Instance: data.Labels{"condition": "C2"}, State: eval.Alerting}}, evaluations[0].Results)
+	assert.Equal(t, "definition-4", evaluations[1].DefinitionUID)
+	assert.Empty(t, evaluations[1].Error)
+	assert.ElementsMatch(t, []string{"C2", "C4"}, evaluated)
+}
+
+func TestEvalChangedSinceReportsFailures(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	ng.schedule.previewEvaluator = eval.EvaluatorFunc(func(_ context.Context, condition *eval.Condition, _ time.Time) (eval.Results, error) {
+		if condition.RefID == "broken" {
+			return nil, errors.New("query failed")
+		}
+		return eval.Results{{State: eval.Normal}}, nil
+	})
+
+	edited := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := newInMemoryDefinitionStore()
+	store.add(
+		&AlertDefinition{ID: 1, OrgID: 1, UID: "broken", Condition: "broken", Updated: edited},
+		&AlertDefinition{ID: 2, OrgID: 1, UID: "working", Condition: "A", Updated: edited},
+	)
+	ng.SetDefinitionStore(store)
+
+	evaluations, err := ng.EvalChangedSince(context.Background(), 1, edited.Add(-time.Minute), edited)
+	require.NoError(t, err)
+	require.Len(t, evaluations, 2)
+	assert.Equal(t, "query failed", evaluations[0].Error)
+	assert.Nil(t, evaluations[0].Results)
+	assert.Empty(t, evaluations[1].Error)
+	assert.Len(t, evaluations[1].Results, 1)
+}
@@ -0,0 +1,148 @@
+package ngalert
+
+import (
+	"encoding/hex"
+	"hash/fnv"
+	"sort"
+	"sync"
+)
+
+// alertEvent is emitted to the subscribers for every evaluated alert instance.
+type alertEvent struct {
+	Instance alertInstance
+	// RoutingKey is the hash of the routing labels of the instance;
+	// it's empty if no routing labels are configured.
+	RoutingKey string
+	// Test is true if the event is a test notification rather than an evaluated alert instance.
+	Test bool
+	// Flapping is true if the instance is flapping.
+	Flapping bool
+	// Stale is true if the alert definition has not been evaluated successfully
+	// for longer than its maximum staleness: the alert itself is unreliable.
+	// The instance is the alert definition rather than an evaluated alert instance.
+	Stale bool
+	// Quarantined is true if the alert definition has failed on too many consecutive evaluations
+	// and is evaluated at a slower cadence until it succeeds again.
+	// The instance is the alert definition rather than an evaluated alert instance.
+	Quarantined bool
+	// EvalID is the ID of the evaluation that emitted the event.
+	EvalID int64
+}
+
+// eventSubscribers fan out the alert events to the subscribers.
+// The events are sent without blocking so that a stalled subscriber
+// never stalls the evaluations: the events it can't receive are dropped.
+type eventSubscribers struct {
+	mu     sync.RWMutex
+	nextID int
+	subs   map[int]chan alertEvent
+}
+
+func newEventSubscribers() *eventSubscribers {
+	return &eventSubscribers{subs: make(map[int]chan alertEvent)}
+}
+
+// subscribe returns a channel receiving the alert events buffered up to size
+// and the function unsubscribing it; the channel is closed once unsubscribed.
+func (s *eventSubscribers) subscribe(size int) (<-chan alertEvent, func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := s.nextID
+	s.nextID++
+	ch := make(chan alertEvent, size)
+	s.subs[id] = ch
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			delete(s.subs, id)
+			close(ch)
+		})
+	}
+	return ch, unsubscribe
+}
+
+// emit sends an event per instance to every subscriber
+// and counts the events dropped because the subscriber buffer is full.
+func (s *eventSubscribers) emit(instances []alertInstance, routingLabels []string) {
+	s.send(instances, routingLabels, alertEvent{})
+}
+
+// emitTest sends a test event per instance to every subscriber like emit.
+func (s *eventSubscribers) emitTest(instances []alertInstance, routingLabels []string) {
+	s.send(instances, routingLabels, alertEvent{Test: true})
+}
+
+// emitStale sends a stale event of the alert definition instance to every subscriber like emit.
+func (s *eventSubscribers) emitStale(instance alertInstance, routingLabels []string) {
+	s.send([]alertInstance{instance}, routingLabels, alertEvent{Stale: true})
+}
+
+// emitQuarantined sends a quarantined event of the alert definition instance to every subscriber like emit.
+func (s *eventSubscribers) emitQuarantined(instance alertInstance, routingLabels []string) {
+	s.send([]alertInstance{instance}, routingLabels, alertEvent{Quarantined: true})
+}
+
+// send sends an event per instance to every subscriber; the events are flagged like kind.
+func (s *eventSubscribers) send(instances []alertInstance, routingLabels []string, kind alertEvent) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.subs) == 0 {
+		return
+	}
+	events := make([]alertEvent, 0, len(instances))
+	for _, instance := range instances {
+		event := kind
+		event.Instance = instance
+		event.RoutingKey = routingKey(instance, routingLabels)
+		event.Flapping = instance.Flapping
+		event.EvalID = instance.EvalID
+		events = append(events, event)
+	}
+
+	for _, ch := range s.subs {
+		for _, event := range events {
+			select {
+			case ch <- event:
+			default:
+				eventsDropped.Inc()
+			}
+		}
+	}
+}
+
+// routingKey returns the deterministic hash of the values of the routing labels of the instance.
+// Unless the instance has such labels, alertname and alert_definition_uid
+// are the title and the UID of its alert definition; other missing labels are empty.
+func routingKey(instance alertInstance, routingLabels []string) string {
+	if len(routingLabels) == 0 {
+		return ""
+	}
+
+	names := make([]string, len(routingLabels))
+	copy(names, routingLabels)
+	sort.Strings(names)
+
+	h := fnv.New64a()
+	for _, name := range names {
+		value, ok := instance.Labels[name]
+		if !ok {
+			switch name {
+			case "alertname":
+				value = instance.DefinitionTitle
+			case "alert_definition_uid":
+				value = instance.DefinitionUID
+			}
+		}
+		// 0xff never occurs in UTF-8 so it separates the names and values unambiguously
+		_, _ = h.Write([]byte(name))
+		_, _ = h.Write([]byte{0xff})
+		_, _ = h.Write([]byte(value))
+		_, _ = h.Write([]byte{0xff})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
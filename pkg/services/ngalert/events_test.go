@@ -0,0 +1,121 @@
+package ngalert
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertingTickerStalledSubscriber(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		return eval.Results{
+			{Instance: data.Labels{"host": "a"}, State: eval.Alerting},
+			{Instance: data.Labels{"host": "b"}, State: eval.Normal},
+			{Instance: data.Labels{"host": "c"}, State: eval.Normal},
+		}, nil
+	})
+
+	// the subscriber never receives: only its buffer is filled
+	events, unsubscribe := ng.schedule.subscribers.subscribe(1)
+	defer unsubscribe()
+	dropped := testutil.ToFloat64(eventsDropped)
+
+	alert := createTestAlertDefinition(t, ng, 1)
+
+	evalAppliedCh := make(chan evalAppliedInfo, 1)
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	ctx := context.Background()
+	go func() {
+		err := ng.alertingTicker(ctx)
+		require.NoError(t, err)
+	}()
+	runtime.Gosched()
+
+	for i := 0; i < 2; i++ {
+		tick := advanceClock(t, mockedClock)
+		assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+	}
+
+	// one event is buffered and the others are dropped
+	assert.Len(t, events, 1)
+	assert.Equal(t, float64(5), testutil.ToFloat64(eventsDropped)-dropped)
+}
+
+func TestEventRoutingKey(t *testing.T) {
+	subscribers := newEventSubscribers()
+	events, unsubscribe := subscribers.subscribe(3)
+	defer unsubscribe()
+
+	instance := func(labels data.Labels) alertInstance {
+		return alertInstance{DefinitionUID: "uid", DefinitionTitle: "high cpu", Labels: labels}
+	}
+	subscribers.emit([]alertInstance{
+		instance(data.Labels{"cluster": "eu", "host": "a"}),
+		instance(data.Labels{"cluster": "eu", "host": "b"}),
+		instance(data.Labels{"cluster": "us", "host": "a"}),
+	}, []string{"cluster", "alertname"})
+
+	first, second, third := <-events, <-events, <-events
+	assert.NotEmpty(t, first.RoutingKey)
+	assert.Equal(t, first.RoutingKey, second.RoutingKey, "instances differing only in a non-routing label should share a routing key")
+	assert.NotEqual(t, first.RoutingKey, third.RoutingKey)
+
+	// the routing key doesn't depend on the order of the routing labels
+	assert.Equal(t, first.RoutingKey, routingKey(first.Instance, []string{"alertname", "cluster"}))
+	assert.Empty(t, routingKey(first.Instance, nil))
+}
+
+func TestEventEvalID(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.synchronous = true
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		return eval.Results{{Instance: data.Labels{"host": "a"}, State: eval.Alerting}}, nil
+	})
+
+	alertDefinition := &AlertDefinition{ID: 1, OrgID: 1, UID: "uid", Condition: "A", IntervalSeconds: 1, Version: 1, Enabled: true}
+	store := newInMemoryDefinitionStore()
+	store.add(alertDefinition)
+	ng.SetDefinitionStore(store)
+	key := getKey(alertDefinition)
+
+	events, unsubscribe := ng.schedule.subscribers.subscribe(2)
+	defer unsubscribe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	evalIDs := make([]int64, 0, 2)
+	for i := int64(1); i <= 2; i++ {
+		require.NoError(t, ng.tickSynchronously(ctx, time.Unix(i, 0)))
+		event := <-events
+		assert.NotZero(t, event.EvalID)
+		assert.Equal(t, event.EvalID, event.Instance.EvalID)
+
+		instances := ng.schedule.stateTracker.get(key)
+		require.Len(t, instances, 1)
+		assert.Equal(t, event.EvalID, instances[0].EvalID, "the tracked instance should carry the ID of the evaluation that last updated it")
+		evalIDs = append(evalIDs, event.EvalID)
+	}
+	assert.NotEqual(t, evalIDs[0], evalIDs[1], "every evaluation should have its own ID")
+}
@@ -0,0 +1,182 @@
+package ngalert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana/pkg/expr"
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+)
+
+// mathVariableRegexp matches the query references of a math expression, e.g. $A or ${A}.
+var mathVariableRegexp = regexp.MustCompile(`\$\{?([A-Za-z0-9_]+)\}?`)
+
+// promRuleFile is the Prometheus rule file format.
+type promRuleFile struct {
+	Groups []promRuleGroup `yaml:"groups"`
+}
+
+// promRuleGroup is a Prometheus rule group.
+type promRuleGroup struct {
+	Name     string         `yaml:"name"`
+	Interval model.Duration `yaml:"interval,omitempty"`
+	Rules    []promRule     `yaml:"rules"`
+}
+
+// promRule is a Prometheus alerting rule.
+type promRule struct {
+	Alert         string            `yaml:"alert"`
+	Expr          string            `yaml:"expr"`
+	For           model.Duration    `yaml:"for,omitempty"`
+	KeepFiringFor model.Duration    `yaml:"keep_firing_for,omitempty"`
+	Annotations   map[string]string `yaml:"annotations,omitempty"`
+}
+
+// promRuleGroupKey identifies the rule group an alert definition is exported to.
+type promRuleGroupKey struct {
+	orgID           int64
+	intervalSeconds int64
+}
+
+// exportPrometheusRules writes the alert definitions as a Prometheus rule file.
+// The mapping is best-effort: the alert definitions are grouped by organisation and interval
+// and the features that cannot be mapped are reported in comments at the top of the file.
+// Alert definitions whose condition cannot be translated to PromQL are not exported.
+func exportPrometheusRules(w io.Writer, alertDefinitions []*AlertDefinition) error {
+	var comments bytes.Buffer
+	groups := make(map[promRuleGroupKey]*promRuleGroup)
+	for _, alertDefinition := range alertDefinitions {
+		flag := func(format string, args ...interface{}) {
+			fmt.Fprintf(&comments, "# %q (uid %s): %s\n", alertDefinition.Title, alertDefinition.UID, fmt.Sprintf(format, args...))
+		}
+
+		if !alertDefinition.Enabled {
+			flag("not exported: alert definition is disabled")
+			continue
+		}
+
+		promQL, err := promExpr(alertDefinition)
+		if err != nil {
+			flag("not exported: %s", err)
+			continue
+		}
+
+		if !alertDefinition.RelativeTimeRange.IsZero() {
+			flag("relative time range %s-%s is not supported", alertDefinition.RelativeTimeRange.From, alertDefinition.RelativeTimeRange.To)
+		}
+
+		rule := promRule{
+			Alert:         alertDefinition.Title,
+			Expr:          promQL,
+			For:           model.Duration(alertDefinition.For),
+			KeepFiringFor: model.Duration(alertDefinition.KeepFiringFor),
+			Annotations:   map[string]string{"grafana_uid": alertDefinition.UID},
+		}
+		if alertDefinition.DashboardID != 0 {
+			rule.Annotations["grafana_dashboard_id"] = strconv.FormatInt(alertDefinition.DashboardID, 10)
+			rule.Annotations["grafana_panel_id"] = strconv.FormatInt(alertDefinition.PanelID, 10)
+		}
+
+		key := promRuleGroupKey{orgID: alertDefinition.OrgID, intervalSeconds: alertDefinition.IntervalSeconds}
+		group, ok := groups[key]
+		if !ok {
+			interval := time.Duration(alertDefinition.IntervalSeconds) * time.Second
+			group = &promRuleGroup{
+				Name:     fmt.Sprintf("org_%d_%s", key.orgID, model.Duration(interval)),
+				Interval: model.Duration(interval),
+			}
+			groups[key] = group
+		}
+		group.Rules = append(group.Rules, rule)
+	}
+
+	keys := make([]promRuleGroupKey, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].orgID != keys[j].orgID {
+			return keys[i].orgID < keys[j].orgID
+		}
+		return keys[i].intervalSeconds < keys[j].intervalSeconds
+	})
+
+	file := promRuleFile{Groups: make([]promRuleGroup, 0, len(keys))}
+	for _, key := range keys {
+		file.Groups = append(file.Groups, *groups[key])
+	}
+
+	out, err := yaml.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("failed to marshal rule file: %w", err)
+	}
+
+	if _, err := w.Write(comments.Bytes()); err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// promExpr translates the condition of the alert definition to PromQL.
+// The condition should be either a Prometheus query
+// or a math expression referring to Prometheus queries.
+func promExpr(alertDefinition *AlertDefinition) (string, error) {
+	queryModels := make(map[string]map[string]interface{}, len(alertDefinition.Data))
+	for _, q := range alertDefinition.Data {
+		m := make(map[string]interface{})
+		if err := json.Unmarshal(q.Model, &m); err != nil {
+			return "", fmt.Errorf("failed to unmarshal query %s model: %w", q.RefID, err)
+		}
+		queryModels[q.RefID] = m
+	}
+
+	queryExpr := func(refID string) (string, error) {
+		m, ok := queryModels[refID]
+		if !ok {
+			return "", fmt.Errorf("query %s not found", refID)
+		}
+		promQL, ok := m["expr"].(string)
+		if !ok || promQL == "" {
+			return "", fmt.Errorf("query %s is not a Prometheus query", refID)
+		}
+		return promQL, nil
+	}
+
+	m, ok := queryModels[alertDefinition.Condition]
+	if !ok {
+		return "", fmt.Errorf("condition %s not found", alertDefinition.Condition)
+	}
+	if m["datasource"] != expr.DatasourceName {
+		return queryExpr(alertDefinition.Condition)
+	}
+
+	if m["type"] != "math" {
+		return "", fmt.Errorf("%v expression %s is not supported", m["type"], alertDefinition.Condition)
+	}
+	expression, ok := m["expression"].(string)
+	if !ok {
+		return "", fmt.Errorf("math expression %s is empty", alertDefinition.Condition)
+	}
+
+	var translateErr error
+	promQL := mathVariableRegexp.ReplaceAllStringFunc(expression, func(variable string) string {
+		refID := mathVariableRegexp.FindStringSubmatch(variable)[1]
+		q, err := queryExpr(refID)
+		if err != nil && translateErr == nil {
+			translateErr = err
+		}
+		return "(" + q + ")"
+	})
+	if translateErr != nil {
+		return "", translateErr
+	}
+	return promQL, nil
+}
@@ -0,0 +1,119 @@
+package ngalert
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestExportPrometheusRules(t *testing.T) {
+	promQuery := eval.AlertQuery{
+		RefID: "A",
+		Model: json.RawMessage(`{
+			"datasource": "Prometheus",
+			"datasourceId": 2,
+			"expr": "rate(http_requests_total[5m])"
+		}`),
+	}
+	mathExpression := eval.AlertQuery{
+		RefID: "B",
+		Model: json.RawMessage(`{
+			"datasource": "__expr__",
+			"type": "math",
+			"expression": "$A > 10"
+		}`),
+	}
+	reduceExpression := eval.AlertQuery{
+		RefID: "C",
+		Model: json.RawMessage(`{
+			"datasource": "__expr__",
+			"type": "reduce",
+			"expression": "A",
+			"reducer": "mean"
+		}`),
+	}
+
+	alertDefinitions := []*AlertDefinition{
+		{
+			OrgID:           1,
+			UID:             "query",
+			Title:           "query condition",
+			Condition:       "A",
+			Data:            []eval.AlertQuery{promQuery},
+			IntervalSeconds: 60,
+			Enabled:         true,
+			For:             5 * time.Minute,
+		},
+		{
+			OrgID:             1,
+			UID:               "math",
+			Title:             "math condition",
+			Condition:         "B",
+			Data:              []eval.AlertQuery{promQuery, mathExpression},
+			IntervalSeconds:   60,
+			Enabled:           true,
+			DashboardID:       3,
+			PanelID:           4,
+			RelativeTimeRange: eval.RelativeTimeRange{From: eval.Duration(time.Hour)},
+		},
+		{
+			OrgID:           1,
+			UID:             "reduce",
+			Title:           "reduce condition",
+			Condition:       "C",
+			Data:            []eval.AlertQuery{promQuery, reduceExpression},
+			IntervalSeconds: 60,
+			Enabled:         true,
+		},
+		{
+			OrgID:           1,
+			UID:             "disabled",
+			Title:           "disabled",
+			Condition:       "A",
+			Data:            []eval.AlertQuery{promQuery},
+			IntervalSeconds: 10,
+		},
+	}
+
+	var buf bytes.Buffer
+	err := exportPrometheusRules(&buf, alertDefinitions)
+	require.NoError(t, err)
+
+	var file promRuleFile
+	err = yaml.UnmarshalStrict(buf.Bytes(), &file)
+	require.NoError(t, err)
+
+	require.Len(t, file.Groups, 1)
+	group := file.Groups[0]
+	assert.Equal(t, "org_1_1m", group.Name)
+	assert.Equal(t, model.Duration(time.Minute), group.Interval)
+	require.Len(t, group.Rules, 2)
+
+	assert.Equal(t, promRule{
+		Alert:       "query condition",
+		Expr:        "rate(http_requests_total[5m])",
+		For:         model.Duration(5 * time.Minute),
+		Annotations: map[string]string{"grafana_uid": "query"},
+	}, group.Rules[0])
+	assert.Equal(t, promRule{
+		Alert: "math condition",
+		Expr:  "(rate(http_requests_total[5m])) > 10",
+		Annotations: map[string]string{
+			"grafana_uid":          "math",
+			"grafana_dashboard_id": "3",
+			"grafana_panel_id":     "4",
+		},
+	}, group.Rules[1])
+
+	out := buf.String()
+	assert.Contains(t, out, `# "math condition" (uid math): relative time range 1h0m0s-0s is not supported`)
+	assert.Contains(t, out, `# "reduce condition" (uid reduce): not exported: reduce expression C is not supported`)
+	assert.Contains(t, out, `# "disabled" (uid disabled): not exported: alert definition is disabled`)
+}
@@ -0,0 +1,37 @@
+package ngalert
+
+import (
+	"context"
+	"sync"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+// externalValues holds the optional provider of the external values the conditions can reference.
+type externalValues struct {
+	mu       sync.RWMutex
+	provider eval.ExternalValueProvider
+}
+
+func (e *externalValues) setProvider(provider eval.ExternalValueProvider) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.provider = provider
+}
+
+// context returns a copy of ctx with the provider, or ctx itself if no provider is set:
+// the evaluations of the conditions referencing an external value then fail.
+func (e *externalValues) context(ctx context.Context) context.Context {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.provider == nil {
+		return ctx
+	}
+	return eval.WithExternalValueProvider(ctx, e.provider)
+}
+
+// SetExternalValueProvider sets the provider of the values the conditions reference
+// with an external expression, e.g. {"datasource": "__expr__", "type": "external", "name": "rollout_percentage"}.
+func (ng *AlertNG) SetExternalValueProvider(provider eval.ExternalValueProvider) {
+	ng.schedule.externalValues.setProvider(provider)
+}
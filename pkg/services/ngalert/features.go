@@ -0,0 +1,14 @@
+package ngalert
+
+// The features of the alert definitions toggling experimental behaviors
+// for a subset of the alert definitions rather than globally.
+const (
+	// featureShadowMode evaluates the alert definition without changing the state
+	// of its alert instances nor emitting events: the results are only logged.
+	featureShadowMode = "shadow_mode"
+)
+
+// hasFeature returns true if the feature is enabled for the alert definition.
+func (alertDefinition *AlertDefinition) hasFeature(name string) bool {
+	return alertDefinition.Features[name]
+}
@@ -0,0 +1,70 @@
+package ngalert
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertingTickerShadowModeFeature(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		return eval.Results{{Instance: data.Labels{"host": "a"}, State: eval.Alerting}}, nil
+	})
+
+	store := newInMemoryDefinitionStore()
+	shadowed := &AlertDefinition{ID: 1, OrgID: 1, UID: "shadowed", Condition: "A", IntervalSeconds: 1, Version: 1, Enabled: true,
+		Features: map[string]bool{featureShadowMode: true}}
+	// the unknown features are ignored
+	live := &AlertDefinition{ID: 2, OrgID: 1, UID: "live", Condition: "A", IntervalSeconds: 1, Version: 1, Enabled: true,
+		Features: map[string]bool{"unknown": true, featureShadowMode: false}}
+	store.add(shadowed, live)
+	ng.SetDefinitionStore(store)
+
+	events, unsubscribe := ng.schedule.subscribers.subscribe(10)
+	defer unsubscribe()
+
+	evalAppliedCh := make(chan evalAppliedInfo, 2)
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = ng.alertingTicker(ctx)
+	}()
+	runtime.Gosched()
+
+	advanceClock(t, mockedClock)
+	// the second dispatched evaluation is offset by half the base interval
+	for range []*AlertDefinition{shadowed, live} {
+		select {
+		case <-evalAppliedCh:
+		case <-time.After(100 * time.Millisecond):
+			mockedClock.Add(500 * time.Millisecond)
+			<-evalAppliedCh
+		}
+	}
+
+	assert.Empty(t, ng.schedule.stateTracker.get(getKey(shadowed)), "the shadowed alert definition should not change state")
+	instances := ng.schedule.stateTracker.get(getKey(live))
+	require.Len(t, instances, 1)
+	assert.Equal(t, eval.Alerting, instances[0].State)
+
+	require.Len(t, events, 1)
+	assert.Equal(t, live.UID, (<-events).Instance.DefinitionUID)
+}
@@ -1,15 +1,146 @@
 package ngalert
 
 import (
+	"errors"
+	"sort"
+	"sync"
 	"time"
 )
 
-func (ng *AlertNG) fetchAllDetails(now time.Time) []*AlertDefinition {
-	q := listAlertDefinitionsQuery{}
-	err := ng.getAlertDefinitions(&q)
-	if err != nil {
-		ng.schedule.log.Error("failed to fetch alert definitions", "now", now, "err", err)
+// errFetchBudgetExceeded is returned if fetching the alert definitions exceeds the tick budget.
+var errFetchBudgetExceeded = errors.New("fetching alert definitions exceeded the tick budget")
+
+// definitionCache keeps the alert definitions fetched by the scheduler
+// so that only the ones updated since the previous fetch are fetched again.
+// Since the deleted alert definitions can't be fetched incrementally
+// all the alert definitions are fetched again every full fetch interval.
+type definitionCache struct {
+	// fullFetchInterval if not positive disables the incremental fetches.
+	fullFetchInterval time.Duration
+
+	// mu serializes the fetches since a fetch exceeding the tick budget
+	// may still be running on the next tick
+	mu            sync.Mutex
+	definitions   map[int64]*AlertDefinition
+	watermark     time.Time
+	lastFullFetch time.Time
+}
+
+func newDefinitionCache(fullFetchInterval time.Duration) *definitionCache {
+	return &definitionCache{fullFetchInterval: fullFetchInterval}
+}
+
+// needsFullFetch returns true if all the alert definitions should be fetched.
+func (c *definitionCache) needsFullFetch(now time.Time) bool {
+	return c.fullFetchInterval <= 0 || c.definitions == nil || now.Sub(c.lastFullFetch) >= c.fullFetchInterval
+}
+
+// reset replaces the cached alert definitions with the ones of a full fetch.
+// They are kept even if the incremental fetches are disabled so that a failed fetch can reuse them.
+func (c *definitionCache) reset(alertDefinitions []*AlertDefinition, now time.Time) {
+	c.definitions = make(map[int64]*AlertDefinition, len(alertDefinitions))
+	c.watermark = time.Time{}
+	c.merge(alertDefinitions)
+	c.lastFullFetch = now
+}
+
+// merge updates the cached alert definitions with the ones of an incremental fetch.
+func (c *definitionCache) merge(alertDefinitions []*AlertDefinition) {
+	for _, alertDefinition := range alertDefinitions {
+		c.definitions[alertDefinition.ID] = alertDefinition
+		if alertDefinition.Updated.After(c.watermark) {
+			c.watermark = alertDefinition.Updated
+		}
+	}
+}
+
+// lastFetched returns the alert definitions of the last successful fetch, nil if there is none.
+func (c *definitionCache) lastFetched() []*AlertDefinition {
+	if c.definitions == nil {
 		return nil
 	}
-	return q.Result
+	return c.list()
+}
+
+// list returns the cached alert definitions ordered by ID.
+func (c *definitionCache) list() []*AlertDefinition {
+	alertDefinitions := make([]*AlertDefinition, 0, len(c.definitions))
+	for _, alertDefinition := range c.definitions {
+		alertDefinitions = append(alertDefinitions, alertDefinition)
+	}
+	sort.Slice(alertDefinitions, func(i, j int) bool {
+		return alertDefinitions[i].ID < alertDefinitions[j].ID
+	})
+	return alertDefinitions
+}
+
+// fetchAllDetails fetches the alert definitions, incrementally if enabled.
+// A failed fetch returns the error with the last fetched alert definitions, if any,
+// and leaves the watermark unchanged: the scheduler would otherwise handle all the alert definitions as deleted.
+func (ng *AlertNG) fetchAllDetails(now time.Time) ([]*AlertDefinition, error) {
+	cache := ng.schedule.definitionCache
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	store := ng.definitionStore()
+	if cache.needsFullFetch(now) {
+		alertDefinitions, err := store.FetchAll()
+		if err != nil {
+			ng.schedule.log.Error("failed to fetch alert definitions", "now", now, "err", err)
+			return cache.lastFetched(), err
+		}
+		cache.reset(alertDefinitions, now)
+		if cache.fullFetchInterval <= 0 {
+			return alertDefinitions, nil
+		}
+		return cache.list(), nil
+	}
+
+	alertDefinitions, err := store.FetchDeltas(cache.watermark)
+	if err != nil {
+		ng.schedule.log.Error("failed to fetch updated alert definitions", "now", now, "since", cache.watermark, "err", err)
+		return cache.list(), err
+	}
+	ng.schedule.log.Debug("updated alert definitions fetched", "now", now, "since", cache.watermark, "count", len(alertDefinitions))
+	cache.merge(alertDefinitions)
+	return cache.list(), nil
+}
+
+// fetchAllDetailsWithBudget fetches the alert definitions in a separate goroutine
+// so that a slow store cannot delay the tick beyond the scheduler fetch budget.
+// It returns errFetchBudgetExceeded if the fetch did not complete within the budget
+// and the error of the fetch if it failed.
+func (ng *AlertNG) fetchAllDetailsWithBudget(now time.Time) ([]*AlertDefinition, error) {
+	fetch := ng.fetchAllDetails
+	if ng.schedule.fetchDefinitions != nil {
+		fetch = func(now time.Time) ([]*AlertDefinition, error) {
+			return ng.schedule.fetchDefinitions(now), nil
+		}
+	}
+	if ng.schedule.synchronous {
+		return fetch(now)
+	}
+
+	type fetchResult struct {
+		alertDefinitions []*AlertDefinition
+		err              error
+	}
+	// the channel is buffered so that the goroutine can exit
+	// even if the result is abandoned
+	resultCh := make(chan fetchResult, 1)
+	go func() {
+		alertDefinitions, err := fetch(now)
+		resultCh <- fetchResult{alertDefinitions: alertDefinitions, err: err}
+	}()
+
+	// the budget is measured by the scheduler clock like the ticks
+	timer := ng.schedule.clock.Timer(ng.schedule.fetchBudget)
+	defer timer.Stop()
+
+	select {
+	case result := <-resultCh:
+		return result.alertDefinitions, result.err
+	case <-timer.C:
+		return nil, errFetchBudgetExceeded
+	}
 }
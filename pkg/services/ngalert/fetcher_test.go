@@ -0,0 +1,137 @@
+package ngalert
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchAllDetailsIncremental(t *testing.T) {
+	mockTimeNow()
+	defer resetTimeNow()
+
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+	ng.schedule.definitionCache = newDefinitionCache(time.Hour)
+
+	updated := createTestAlertDefinition(t, ng, 60)
+	unchanged := createTestAlertDefinition(t, ng, 60)
+	deleted := createTestAlertDefinition(t, ng, 60)
+
+	ids := func(alertDefinitions []*AlertDefinition) []int64 {
+		ids := make([]int64, 0, len(alertDefinitions))
+		for _, alertDefinition := range alertDefinitions {
+			ids = append(ids, alertDefinition.ID)
+		}
+		return ids
+	}
+
+	now := time.Now()
+	t.Run("the first fetch should fetch all the alert definitions", func(t *testing.T) {
+		alertDefinitions, err := ng.fetchAllDetails(now)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{updated.ID, unchanged.ID, deleted.ID}, ids(alertDefinitions))
+	})
+
+	watermark := ng.schedule.definitionCache.watermark
+	enabled := false
+	err := ng.updateAlertDefinition(&updateAlertDefinitionCommand{ID: updated.ID, OrgID: updated.OrgID, Enabled: &enabled})
+	require.NoError(t, err)
+
+	t.Run("only the updated alert definitions should be fetched again", func(t *testing.T) {
+		q := listAlertDefinitionsUpdatedSinceQuery{UpdatedSince: watermark}
+		require.NoError(t, ng.getAlertDefinitionsUpdatedSince(&q))
+		// the alert definition updated at the watermark is fetched again
+		assert.ElementsMatch(t, []int64{updated.ID, deleted.ID}, ids(q.Result))
+		assert.NotContains(t, ids(q.Result), unchanged.ID)
+
+		alertDefinitions, err := ng.fetchAllDetails(now.Add(time.Minute))
+		require.NoError(t, err)
+		require.Equal(t, []int64{updated.ID, unchanged.ID, deleted.ID}, ids(alertDefinitions))
+		assert.False(t, alertDefinitions[0].Enabled)
+		assert.Equal(t, int64(2), alertDefinitions[0].Version)
+		assert.True(t, alertDefinitions[1].Enabled)
+	})
+
+	err = ng.deleteAlertDefinitionByID(&deleteAlertDefinitionByIDCommand{ID: deleted.ID, OrgID: deleted.OrgID})
+	require.NoError(t, err)
+
+	t.Run("the deleted alert definitions should be kept until the next full fetch", func(t *testing.T) {
+		alertDefinitions, err := ng.fetchAllDetails(now.Add(2 * time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, []int64{updated.ID, unchanged.ID, deleted.ID}, ids(alertDefinitions))
+
+		alertDefinitions, err = ng.fetchAllDetails(now.Add(time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, []int64{updated.ID, unchanged.ID}, ids(alertDefinitions))
+	})
+}
+
+// failingStore is an in-memory store whose fetches fail once failing is set.
+type failingStore struct {
+	*inMemoryDefinitionStore
+	failing bool
+}
+
+func (s *failingStore) FetchAll() ([]*AlertDefinition, error) {
+	if s.failing {
+		return nil, errors.New("database is unavailable")
+	}
+	return s.inMemoryDefinitionStore.FetchAll()
+}
+
+func (s *failingStore) FetchDeltas(since time.Time) ([]*AlertDefinition, error) {
+	if s.failing {
+		return nil, errors.New("database is unavailable")
+	}
+	return s.inMemoryDefinitionStore.FetchDeltas(since)
+}
+
+func TestFetchAllDetailsFailure(t *testing.T) {
+	for _, fullFetchInterval := range []time.Duration{0, time.Hour} {
+		t.Run(fullFetchInterval.String(), func(t *testing.T) {
+			ng := setupTestEnv(t)
+			t.Cleanup(registry.ClearOverrides)
+
+			mockedClock := clock.NewMock()
+			ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+			ng.schedule.synchronous = true
+			ng.schedule.definitionCache = newDefinitionCache(fullFetchInterval)
+			ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+				return eval.Results{{Instance: data.Labels{"host": "a"}, State: eval.Alerting}}, nil
+			})
+
+			alertDefinition := &AlertDefinition{ID: 1, OrgID: 1, UID: "uid", Condition: "A", IntervalSeconds: 1, Version: 1, Enabled: true, Updated: time.Unix(0, 0)}
+			store := &failingStore{inMemoryDefinitionStore: newInMemoryDefinitionStore()}
+			store.add(alertDefinition)
+			ng.SetDefinitionStore(store)
+			key := getKey(alertDefinition)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			t.Cleanup(cancel)
+			require.NoError(t, ng.tickSynchronously(ctx, time.Unix(1, 0)))
+			require.Len(t, ng.schedule.stateTracker.get(key), 1)
+
+			// the alert definitions are not handled as deleted while the store is failing
+			store.failing = true
+			require.NoError(t, ng.tickSynchronously(ctx, time.Unix(2, 0)))
+			assert.True(t, ng.schedule.registry.exists(key))
+			assert.Len(t, ng.schedule.stateTracker.get(key), 1)
+			assert.True(t, time.Unix(0, 0).Equal(ng.schedule.definitionCache.watermark), "the watermark should be unchanged")
+
+			// the failure is reported with the last fetched alert definitions
+			alertDefinitions, err := ng.fetchAllDetails(time.Unix(3, 0))
+			assert.Error(t, err)
+			assert.Len(t, alertDefinitions, 1)
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package ngalert
+
+import (
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// FiringAlert is an alert instance currently in Alerting state.
+type FiringAlert struct {
+	DefinitionUID string      `json:"definitionUid"`
+	Labels        data.Labels `json:"labels"`
+	// Value is the value of the condition on the last evaluation.
+	Value float64 `json:"value"`
+	// Since is the start of the firing episode.
+	Since time.Time `json:"since"`
+}
+
+// FiringAlerts returns the alert instances of the organisation currently in Alerting state,
+// oldest first. It reads the in-memory state without waiting for the running evaluations.
+func (ng *AlertNG) FiringAlerts(orgID int64) []FiringAlert {
+	instances := ng.schedule.stateTracker.firing(orgID)
+
+	alerts := make([]FiringAlert, 0, len(instances))
+	for _, instance := range instances {
+		alerts = append(alerts, FiringAlert{
+			DefinitionUID: instance.DefinitionUID,
+			Labels:        instance.Labels,
+			Value:         instance.Value,
+			Since:         instance.FiringSince,
+		})
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if !alerts[i].Since.Equal(alerts[j].Since) {
+			return alerts[i].Since.Before(alerts[j].Since)
+		}
+		if alerts[i].DefinitionUID != alerts[j].DefinitionUID {
+			return alerts[i].DefinitionUID < alerts[j].DefinitionUID
+		}
+		return alerts[i].Labels.String() < alerts[j].Labels.String()
+	})
+	return alerts
+}
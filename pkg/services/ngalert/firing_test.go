@@ -0,0 +1,47 @@
+package ngalert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFiringAlerts(t *testing.T) {
+	mockedClock := clock.NewMock()
+	ng := &AlertNG{schedule: newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)}
+	st := ng.schedule.stateTracker
+
+	cpu := &AlertDefinition{OrgID: 1, UID: "cpu"}
+	memory := &AlertDefinition{OrgID: 1, UID: "memory", For: time.Minute}
+	otherOrg := &AlertDefinition{OrgID: 2, UID: "cpu"}
+
+	st.setResults(getKey(cpu), cpu, eval.Results{
+		{Instance: data.Labels{"host": "a"}, State: eval.Alerting, Value: 1},
+		{Instance: data.Labels{"host": "b"}, State: eval.Normal},
+	})
+	firingSince := mockedClock.Now()
+
+	mockedClock.Add(time.Second)
+	st.setResults(getKey(cpu), cpu, eval.Results{
+		{Instance: data.Labels{"host": "a"}, State: eval.Alerting, Value: 2},
+		{Instance: data.Labels{"host": "b"}, State: eval.Normal},
+	})
+	// pending instances are not firing yet
+	st.setResults(getKey(memory), memory, eval.Results{
+		{Instance: data.Labels{"host": "a"}, State: eval.Alerting, Value: 1},
+	})
+	st.setResults(getKey(otherOrg), otherOrg, eval.Results{
+		{Instance: data.Labels{"host": "a"}, State: eval.Alerting, Value: 1},
+	})
+
+	assert.Equal(t, []FiringAlert{
+		{DefinitionUID: "cpu", Labels: data.Labels{"host": "a"}, Value: 2, Since: firingSince},
+	}, ng.FiringAlerts(1))
+	assert.Len(t, ng.FiringAlerts(2), 1)
+	assert.Empty(t, ng.FiringAlerts(3))
+}
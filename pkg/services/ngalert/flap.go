@@ -0,0 +1,70 @@
+package ngalert
+
+import (
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+const (
+	// defaultFlapWindow is the number of the latest states of an instance
+	// the default flap detector looks at.
+	defaultFlapWindow = 10
+	// defaultFlapThreshold is the number of state changes within the window
+	// the default flap detector considers an instance flapping from.
+	defaultFlapThreshold = 4
+	// maxRecentStates bounds the states of an instance passed to the flap detector.
+	maxRecentStates = 100
+)
+
+// FlapDetector tells whether an alert instance is flapping
+// from the sequence of its recent states, the oldest first.
+type FlapDetector interface {
+	IsFlapping(states []eval.State) bool
+}
+
+// FlapDetectorFunc is an adapter to allow the use of ordinary functions as FlapDetectors.
+type FlapDetectorFunc func(states []eval.State) bool
+
+// IsFlapping calls f(states).
+func (f FlapDetectorFunc) IsFlapping(states []eval.State) bool {
+	return f(states)
+}
+
+// slidingWindowFlapDetector considers an instance flapping if its state changed
+// at least threshold times within its latest window states.
+type slidingWindowFlapDetector struct {
+	window    int
+	threshold int
+}
+
+// newSlidingWindowFlapDetector returns the default FlapDetector;
+// it never detects flapping if the window or the threshold isn't positive.
+func newSlidingWindowFlapDetector(window, threshold int) slidingWindowFlapDetector {
+	return slidingWindowFlapDetector{window: window, threshold: threshold}
+}
+
+func (d slidingWindowFlapDetector) IsFlapping(states []eval.State) bool {
+	if d.window <= 0 || d.threshold <= 0 {
+		return false
+	}
+	if len(states) > d.window {
+		states = states[len(states)-d.window:]
+	}
+	changes := 0
+	for i := 1; i < len(states); i++ {
+		if states[i] != states[i-1] {
+			changes++
+		}
+	}
+	return changes >= d.threshold
+}
+
+// appendRecentState returns a new slice of the recent states with the state appended
+// so that the copies of an instance never share the states of the tracked one.
+func appendRecentState(states []eval.State, state eval.State) []eval.State {
+	if len(states) >= maxRecentStates {
+		states = states[len(states)-maxRecentStates+1:]
+	}
+	updated := make([]eval.State, len(states), len(states)+1)
+	copy(updated, states)
+	return append(updated, state)
+}
@@ -0,0 +1,69 @@
+package ngalert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlidingWindowFlapDetector(t *testing.T) {
+	detector := newSlidingWindowFlapDetector(defaultFlapWindow, defaultFlapThreshold)
+
+	oscillating := []eval.State{eval.Normal, eval.Alerting, eval.Normal, eval.Alerting, eval.Normal, eval.Alerting}
+	assert.True(t, detector.IsFlapping(oscillating))
+
+	steady := []eval.State{eval.Normal, eval.Normal, eval.Alerting, eval.Alerting, eval.Alerting, eval.Normal}
+	assert.False(t, detector.IsFlapping(steady))
+
+	// the changes older than the window are ignored
+	settled := append(oscillating, eval.Normal, eval.Normal, eval.Normal, eval.Normal, eval.Normal, eval.Normal, eval.Normal, eval.Normal)
+	assert.False(t, detector.IsFlapping(settled))
+
+	assert.False(t, newSlidingWindowFlapDetector(defaultFlapWindow, 0).IsFlapping(oscillating), "a threshold of 0 should disable the detection")
+}
+
+func TestStateTrackerFlapping(t *testing.T) {
+	mockedClock := clock.NewMock()
+	st := newStateTracker(mockedClock)
+
+	alertDefinition := &AlertDefinition{OrgID: 1, UID: "uid"}
+	key := getKey(alertDefinition)
+	labels := data.Labels{"host": "a"}
+
+	evaluate := func(state eval.State) alertInstance {
+		mockedClock.Add(10 * time.Second)
+		instances := st.setResults(key, alertDefinition, eval.Results{{Instance: labels, State: state}})
+		require.Len(t, instances, 1)
+		return instances[0]
+	}
+
+	for _, state := range []eval.State{eval.Alerting, eval.Normal, eval.Alerting, eval.Normal} {
+		require.False(t, evaluate(state).Flapping)
+	}
+	instance := evaluate(eval.Alerting)
+	assert.True(t, instance.Flapping)
+	assert.True(t, instance.Notify, "the flapping instances should be notified unless suppressed")
+
+	t.Run("the notifications of the flapping instances are suppressed if configured", func(t *testing.T) {
+		st.suppressFlapping = true
+		require.False(t, evaluate(eval.Normal).Notify)
+		instance := evaluate(eval.Alerting)
+		assert.True(t, instance.Flapping)
+		assert.False(t, instance.Notify)
+	})
+
+	t.Run("the events of the flapping instances are flagged", func(t *testing.T) {
+		subscribers := newEventSubscribers()
+		events, unsubscribe := subscribers.subscribe(1)
+		defer unsubscribe()
+
+		subscribers.emit(st.get(key), nil)
+		event := <-events
+		assert.True(t, event.Flapping)
+	})
+}
@@ -0,0 +1,79 @@
+package ngalert
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var errEmptyFolderUID = errors.New("folder UID should not be empty")
+
+// folderRef identifies a folder of an organisation.
+type folderRef struct {
+	orgID     int64
+	folderUID string
+}
+
+// folderPauses are the folders whose alert definitions are not dispatched.
+// Like PauseByLabels the alert definitions are not disabled: their routines and
+// the state of their alert instances are kept, and the pauses are not persisted.
+type folderPauses struct {
+	mu     sync.RWMutex
+	paused map[folderRef]struct{}
+}
+
+func newFolderPauses() *folderPauses {
+	return &folderPauses{paused: make(map[folderRef]struct{})}
+}
+
+func (p *folderPauses) set(orgID int64, folderUID string, paused bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if paused {
+		p.paused[folderRef{orgID: orgID, folderUID: folderUID}] = struct{}{}
+		return
+	}
+	delete(p.paused, folderRef{orgID: orgID, folderUID: folderUID})
+}
+
+// isPaused returns true if the folder of the organisation is paused;
+// the alert definitions without a folder are never paused.
+func (p *folderPauses) isPaused(orgID int64, folderUID string) bool {
+	if folderUID == "" {
+		return false
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	_, ok := p.paused[folderRef{orgID: orgID, folderUID: folderUID}]
+	return ok
+}
+
+// pauseFolder stops dispatching the evaluations of the alert definitions of the folder from the next tick.
+// The evaluations in flight complete.
+func (sch *schedule) pauseFolder(orgID int64, folderUID string) error {
+	if sch == nil {
+		return fmt.Errorf("scheduler is not initialised")
+	}
+	if folderUID == "" {
+		return errEmptyFolderUID
+	}
+	sch.folderPauses.set(orgID, folderUID, true)
+	sch.audit.record(AuditFolderPaused, "", 0, 0, "orgID", orgID, "folderUID", folderUID)
+	sch.log.Info("alert definition folder paused", "orgID", orgID, "folderUID", folderUID)
+	return nil
+}
+
+// unpauseFolder resumes dispatching the evaluations of the alert definitions of the folder from the next tick.
+func (sch *schedule) unpauseFolder(orgID int64, folderUID string) error {
+	if sch == nil {
+		return fmt.Errorf("scheduler is not initialised")
+	}
+	if folderUID == "" {
+		return errEmptyFolderUID
+	}
+	sch.folderPauses.set(orgID, folderUID, false)
+	sch.audit.record(AuditFolderUnpaused, "", 0, 0, "orgID", orgID, "folderUID", folderUID)
+	sch.log.Info("alert definition folder unpaused", "orgID", orgID, "folderUID", folderUID)
+	return nil
+}
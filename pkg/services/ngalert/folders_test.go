@@ -0,0 +1,150 @@
+package ngalert
+
+import (
+	"context"
+	"runtime"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertingTickerPauseFolder(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		return eval.Results{}, nil
+	})
+
+	store := newInMemoryDefinitionStore()
+	ops1 := &AlertDefinition{ID: 1, OrgID: 1, UID: "ops-1", FolderUID: "ops", Condition: "A", IntervalSeconds: 1, Version: 1, Enabled: true}
+	ops2 := &AlertDefinition{ID: 2, OrgID: 1, UID: "ops-2", FolderUID: "ops", Condition: "A", IntervalSeconds: 1, Version: 1, Enabled: true}
+	dev := &AlertDefinition{ID: 3, OrgID: 1, UID: "dev-1", FolderUID: "dev", Condition: "A", IntervalSeconds: 1, Version: 1, Enabled: true}
+	store.add(ops1, ops2, dev)
+	ng.SetDefinitionStore(store)
+
+	evalAppliedCh := make(chan int64, 3)
+	ng.schedule.evalApplied = func(alertDefID int64, _ time.Time) {
+		evalAppliedCh <- alertDefID
+	}
+	summaries := make(chan TickSummary, 1)
+	ng.schedule.onTick = func(summary TickSummary) {
+		summaries <- summary
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		_ = ng.alertingTicker(ctx)
+	}()
+	runtime.Gosched()
+
+	// tick runs a tick and returns its summary and the alert definitions evaluated within it
+	tick := func(t *testing.T) (TickSummary, []int64) {
+		mockedClock.Add(time.Millisecond)
+		var summary TickSummary
+		select {
+		case summary = <-summaries:
+		case <-time.After(time.Second):
+			t.Fatal("no tick summary reported")
+		}
+		// the evaluations are spread within the tick
+		mockedClock.Add(time.Second - time.Millisecond)
+
+		var evaluated []int64
+		timeout := time.After(500 * time.Millisecond)
+		for {
+			select {
+			case id := <-evalAppliedCh:
+				evaluated = append(evaluated, id)
+			case <-timeout:
+				sort.Slice(evaluated, func(i, j int) bool { return evaluated[i] < evaluated[j] })
+				return summary, evaluated
+			}
+		}
+	}
+	mockedClock.Add(time.Second - time.Millisecond)
+
+	summary, evaluated := tick(t)
+	assert.Equal(t, []int64{1, 2, 3}, evaluated)
+	assert.Equal(t, map[string]int{"ops": 2, "dev": 1}, summary.DispatchedByFolder)
+
+	require.NoError(t, ng.schedule.pauseFolder(1, "ops"))
+	summary, evaluated = tick(t)
+	assert.Equal(t, []int64{3}, evaluated, "only the alert definition of the other folder should be evaluated")
+	assert.Equal(t, 2, summary.Skipped[SkipFolderPaused])
+	assert.Equal(t, map[string]int{"dev": 1}, summary.DispatchedByFolder)
+	assert.Zero(t, summary.Deleted, "the routines of the paused folder should be kept")
+	assert.True(t, ng.schedule.registry.exists(getKey(ops1)))
+	assert.True(t, ng.schedule.registry.exists(getKey(ops2)))
+
+	// the folders are paused by organisation
+	require.NoError(t, ng.schedule.unpauseFolder(2, "ops"))
+	_, evaluated = tick(t)
+	assert.Equal(t, []int64{3}, evaluated)
+
+	require.NoError(t, ng.schedule.unpauseFolder(1, "ops"))
+	_, evaluated = tick(t)
+	assert.Equal(t, []int64{1, 2, 3}, evaluated)
+}
+
+func TestPauseFolderEmptyUID(t *testing.T) {
+	sch := newScheduler(clock.NewMock(), time.Second, log.New("ngalert.schedule.test"), nil)
+	assert.Equal(t, errEmptyFolderUID, sch.pauseFolder(1, ""))
+	assert.Equal(t, errEmptyFolderUID, sch.unpauseFolder(1, ""))
+	assert.False(t, sch.folderPauses.isPaused(1, ""))
+}
+
+func TestPauseFolderSQLStore(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	var evaluated []int64
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), func(alertDefID int64, _ time.Time) {
+		evaluated = append(evaluated, alertDefID)
+	})
+	ng.schedule.synchronous = true
+
+	// the folders of the alert definitions are fetched by the scheduler
+	var interval int64 = 1
+	inFolder := func(folderUID string) *AlertDefinition {
+		alertDefinition := createTestAlertDefinition(t, ng, interval)
+		cmd := updateAlertDefinitionCommand{ID: alertDefinition.ID, OrgID: alertDefinition.OrgID, IntervalSeconds: &interval, FolderUID: &folderUID}
+		require.NoError(t, ng.updateAlertDefinition(&cmd))
+		return cmd.Result
+	}
+	ops1, ops2, dev := inFolder("ops"), inFolder("ops"), inFolder("dev")
+
+	summaries := make([]TickSummary, 0)
+	ng.schedule.onTick = func(summary TickSummary) {
+		summaries = append(summaries, summary)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	require.NoError(t, ng.schedule.pauseFolder(1, "ops"))
+	require.NoError(t, ng.tickSynchronously(ctx, time.Unix(1, 0)))
+	assert.Equal(t, []int64{dev.ID}, evaluated)
+	require.Len(t, summaries, 1)
+	assert.Equal(t, 2, summaries[0].Skipped[SkipFolderPaused])
+	assert.Equal(t, map[string]int{"dev": 1}, summaries[0].DispatchedByFolder)
+	assert.True(t, ng.schedule.registry.exists(getKey(ops1)))
+	assert.True(t, ng.schedule.registry.exists(getKey(ops2)))
+
+	require.NoError(t, ng.schedule.unpauseFolder(1, "ops"))
+	evaluated = nil
+	require.NoError(t, ng.tickSynchronously(ctx, time.Unix(2, 0)))
+	sort.Slice(evaluated, func(i, j int) bool { return evaluated[i] < evaluated[j] })
+	assert.Equal(t, []int64{ops1.ID, ops2.ID, dev.ID}, evaluated)
+}
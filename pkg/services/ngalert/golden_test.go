@@ -0,0 +1,85 @@
+package ngalert
+
+import (
+	"context"
+	"encoding/json"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertingTickerGoldenEvaluator(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	evaluator, err := eval.NewGoldenEvaluator()
+	require.NoError(t, err)
+	ng.schedule.evaluator = evaluator
+
+	createGoldenDefinition := func(golden string) *AlertDefinition {
+		var intervalSeconds int64 = 1
+		cmd := saveAlertDefinitionCommand{
+			OrgID: 1,
+			Title: golden,
+			Condition: eval.Condition{
+				RefID: "A",
+				QueriesAndExpressions: []eval.AlertQuery{
+					{RefID: "A", Model: json.RawMessage(`{"golden": "` + golden + `"}`)},
+				},
+			},
+			IntervalSeconds: &intervalSeconds,
+		}
+		require.NoError(t, ng.saveAlertDefinition(&cmd))
+		return cmd.Result
+	}
+	expected := map[*AlertDefinition]map[string]eval.State{
+		createGoldenDefinition("all_normal"):    {"a": eval.Normal, "b": eval.Normal, "c": eval.Normal},
+		createGoldenDefinition("one_alerting"):  {"a": eval.Alerting, "b": eval.Normal, "c": eval.Normal},
+		createGoldenDefinition("missing_value"): {"a": eval.Normal, "b": eval.Alerting},
+	}
+
+	evalAppliedCh := make(chan evalAppliedInfo, len(expected))
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	ctx := context.Background()
+	go func() {
+		err := ng.alertingTicker(ctx)
+		require.NoError(t, err)
+	}()
+	runtime.Gosched()
+
+	// the outputs are the same on every tick
+	for tickNum := 0; tickNum < 2; tickNum++ {
+		advanceClock(t, mockedClock)
+		for i := 0; i < len(expected); i++ {
+			if i > 0 {
+				mockedClock.Add(time.Second / time.Duration(len(expected)))
+			}
+			select {
+			case <-evalAppliedCh:
+			case <-time.After(time.Second):
+				t.Fatalf("tick %d: alert definition %d was not evaluated", tickNum, i)
+			}
+		}
+
+		for alertDefinition, states := range expected {
+			instances := ng.schedule.stateTracker.get(getKey(alertDefinition))
+			actual := make(map[string]eval.State, len(instances))
+			for _, instance := range instances {
+				actual[instance.Labels["host"]] = instance.State
+			}
+			assert.Equal(t, states, actual, "unexpected states of %s", alertDefinition.Title)
+		}
+	}
+}
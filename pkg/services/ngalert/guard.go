@@ -0,0 +1,46 @@
+package ngalert
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+// evaluateGuarded evaluates the condition unless the guard condition,
+// if any, does not hold; then the condition is skipped, the current
+// instances of the alert definition are reported NotApplicable and it returns true.
+func (sch *schedule) evaluateGuarded(ctx context.Context, key string, condition *eval.Condition, guard *eval.Condition, now time.Time) (eval.Results, bool, error) {
+	if guard != nil {
+		guardResults, err := sch.evaluator.ConditionEval(ctx, guard, now)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to evaluate guard condition: %w", err)
+		}
+		if !guardHolds(guardResults) {
+			sch.log.Debug("guard condition does not hold; skipping the condition", "key", key, "guard", guard.RefID, "now", now)
+			return notApplicableResults(sch.stateTracker.get(key)), true, nil
+		}
+	}
+	results, err := sch.evaluator.ConditionEval(ctx, condition, now)
+	return results, false, err
+}
+
+// guardHolds returns true if the guard condition is true for any instance.
+func guardHolds(results eval.Results) bool {
+	for _, r := range results {
+		if r.State == eval.Alerting {
+			return true
+		}
+	}
+	return false
+}
+
+// notApplicableResults returns a NotApplicable result for each of the instances.
+func notApplicableResults(instances []alertInstance) eval.Results {
+	results := make(eval.Results, 0, len(instances))
+	for _, instance := range instances {
+		results = append(results, eval.Result{Instance: instance.Labels, State: eval.NotApplicable})
+	}
+	return results
+}
@@ -0,0 +1,106 @@
+package ngalert
+
+import (
+	"context"
+	"encoding/json"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertingTickerGuardCondition(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+
+	var guardHolds int32 = 1
+	var mu sync.Mutex
+	var evaluated []string
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(_ context.Context, condition *eval.Condition, _ time.Time) (eval.Results, error) {
+		mu.Lock()
+		evaluated = append(evaluated, condition.RefID)
+		mu.Unlock()
+
+		if condition.RefID == "traffic" {
+			state := eval.Normal
+			if atomic.LoadInt32(&guardHolds) == 1 {
+				state = eval.Alerting
+			}
+			return eval.Results{{State: state}}, nil
+		}
+		return eval.Results{{Instance: data.Labels{"host": "a"}, State: eval.Alerting}}, nil
+	})
+
+	var intervalSeconds int64 = 1
+	cmd := saveAlertDefinitionCommand{
+		OrgID: 1,
+		Title: "error rate",
+		Condition: eval.Condition{
+			RefID: "errors",
+			QueriesAndExpressions: []eval.AlertQuery{
+				{RefID: "traffic", Model: json.RawMessage(`{"datasource":"__expr__","type":"math","expression":"100 > 10"}`)},
+				{RefID: "errors", Model: json.RawMessage(`{"datasource":"__expr__","type":"math","expression":"5 > 1"}`)},
+			},
+		},
+		IntervalSeconds: &intervalSeconds,
+		GuardCondition:  "traffic",
+	}
+	require.NoError(t, ng.saveAlertDefinition(&cmd))
+	alert := cmd.Result
+	key := getKey(alert)
+
+	evalAppliedCh := make(chan evalAppliedInfo, 1)
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	ctx := context.Background()
+	go func() {
+		err := ng.alertingTicker(ctx)
+		require.NoError(t, err)
+	}()
+	runtime.Gosched()
+
+	takeEvaluated := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		refIDs := evaluated
+		evaluated = nil
+		return refIDs
+	}
+
+	t.Run("on 1st tick the guard holds and the condition should be evaluated", func(t *testing.T) {
+		tick := advanceClock(t, mockedClock)
+		assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+		assert.Equal(t, []string{"traffic", "errors"}, takeEvaluated())
+
+		instances := ng.schedule.stateTracker.get(key)
+		require.Len(t, instances, 1)
+		assert.Equal(t, eval.Alerting, instances[0].State)
+	})
+
+	atomic.StoreInt32(&guardHolds, 0)
+
+	t.Run("on 2nd tick the guard does not hold and the condition should be skipped", func(t *testing.T) {
+		tick := advanceClock(t, mockedClock)
+		assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+		assert.Equal(t, []string{"traffic"}, takeEvaluated())
+
+		instances := ng.schedule.stateTracker.get(key)
+		require.Len(t, instances, 1)
+		assert.Equal(t, data.Labels{"host": "a"}, instances[0].Labels)
+		assert.Equal(t, eval.NotApplicable, instances[0].State)
+	})
+}
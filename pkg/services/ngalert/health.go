@@ -0,0 +1,123 @@
+package ngalert
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+// errDatasourceUnhealthy is returned by the evaluations skipped
+// because a datasource of their condition is unhealthy.
+var errDatasourceUnhealthy = errors.New("datasource unhealthy")
+
+// DatasourceHealthProvider tells whether a datasource is healthy,
+// e.g. from the cached result of a periodic health probe.
+// It's consulted before every evaluation so it should not query the datasource itself.
+type DatasourceHealthProvider interface {
+	Healthy(orgID, datasourceID int64) bool
+}
+
+// DatasourceHealthCache is a DatasourceHealthProvider
+// keeping the health reported by a probe for every datasource.
+// The datasources not reported yet are healthy.
+type DatasourceHealthCache struct {
+	mu        sync.RWMutex
+	unhealthy map[datasourceRef]struct{}
+}
+
+type datasourceRef struct {
+	orgID int64
+	id    int64
+}
+
+// NewDatasourceHealthCache returns a new DatasourceHealthCache.
+func NewDatasourceHealthCache() *DatasourceHealthCache {
+	return &DatasourceHealthCache{unhealthy: make(map[datasourceRef]struct{})}
+}
+
+// Set records the health of the datasource.
+func (c *DatasourceHealthCache) Set(orgID, datasourceID int64, healthy bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ref := datasourceRef{orgID: orgID, id: datasourceID}
+	if healthy {
+		delete(c.unhealthy, ref)
+		return
+	}
+	c.unhealthy[ref] = struct{}{}
+}
+
+// Healthy returns false if the datasource has been reported unhealthy.
+func (c *DatasourceHealthCache) Healthy(orgID, datasourceID int64) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	_, unhealthy := c.unhealthy[datasourceRef{orgID: orgID, id: datasourceID}]
+	return !unhealthy
+}
+
+// datasourceHealthGate skips the evaluations of the conditions querying an unhealthy datasource
+// instead of issuing queries bound to fail, and keeps track of the skipped alert definitions.
+type datasourceHealthGate struct {
+	mu       sync.Mutex
+	provider DatasourceHealthProvider
+	// skipped are the unhealthy datasources by the key of the skipped alert definitions
+	skipped map[string]int64
+	log     log.Logger
+}
+
+func newDatasourceHealthGate(logger log.Logger) *datasourceHealthGate {
+	return &datasourceHealthGate{skipped: make(map[string]int64), log: logger}
+}
+
+// setProvider sets the provider consulted before every evaluation;
+// if it's nil the health of the datasources is not checked.
+func (g *datasourceHealthGate) setProvider(provider DatasourceHealthProvider) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.provider = provider
+}
+
+// check returns the first unhealthy datasource queried by the condition, if any,
+// and records whether the alert definition with the given key is skipped.
+func (g *datasourceHealthGate) check(key string, condition *eval.Condition) (int64, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.provider == nil {
+		return 0, true
+	}
+
+	for _, datasourceID := range queriedDatasources(condition.QueriesAndExpressions) {
+		if !g.provider.Healthy(condition.OrgID, datasourceID) {
+			if _, ok := g.skipped[key]; !ok {
+				g.log.Warn("skipping the alert definition evaluations: datasource unhealthy", "key", key, "datasourceID", datasourceID)
+			}
+			g.skipped[key] = datasourceID
+			return datasourceID, false
+		}
+	}
+
+	if datasourceID, ok := g.skipped[key]; ok {
+		g.log.Info("resuming the alert definition evaluations: datasource healthy", "key", key, "datasourceID", datasourceID)
+		delete(g.skipped, key)
+	}
+	return 0, true
+}
+
+// isSkipped returns true if the last evaluation of the alert definition
+// with the given key has been skipped because of an unhealthy datasource.
+func (g *datasourceHealthGate) isSkipped(key string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	_, ok := g.skipped[key]
+	return ok
+}
+
+// SetDatasourceHealthProvider sets the provider of the datasource health the evaluations are gated by.
+func (ng *AlertNG) SetDatasourceHealthProvider(provider DatasourceHealthProvider) {
+	ng.schedule.datasourceHealth.setProvider(provider)
+}
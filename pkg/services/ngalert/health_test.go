@@ -0,0 +1,95 @@
+package ngalert
+
+import (
+	"context"
+	"encoding/json"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatasourceHealthGating(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+
+	var mu sync.Mutex
+	evaluated := make(map[string]int)
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(_ context.Context, condition *eval.Condition, _ time.Time) (eval.Results, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		evaluated[condition.CacheKey]++
+		return eval.Results{{Instance: data.Labels{"host": "a"}, State: eval.Normal}}, nil
+	})
+	evaluations := func(key string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return evaluated[key]
+	}
+
+	// the alert definition queries the given datasource
+	createDefinition := func(datasourceID int64) *AlertDefinition {
+		alertDefinition := createTestAlertDefinition(t, ng, 1)
+		cmd := updateAlertDefinitionCommand{
+			ID:    alertDefinition.ID,
+			OrgID: alertDefinition.OrgID,
+			Condition: eval.Condition{
+				RefID: "A",
+				QueriesAndExpressions: []eval.AlertQuery{
+					{
+						RefID:             "A",
+						RelativeTimeRange: eval.RelativeTimeRange{From: eval.Duration(5 * time.Minute)},
+						Model:             json.RawMessage(`{"datasource": "test", "datasourceId": ` + strconv.FormatInt(datasourceID, 10) + `, "intervalMs": 1000, "maxDataPoints": 100}`),
+					},
+				},
+			},
+		}
+		require.NoError(t, ng.updateAlertDefinition(&cmd))
+		return alertDefinition
+	}
+	healthy := createDefinition(1)
+	dependent := createDefinition(2)
+
+	health := NewDatasourceHealthCache()
+	health.Set(1, 2, false)
+	ng.SetDatasourceHealthProvider(health)
+
+	evalAppliedCh := make(chan evalAppliedInfo, 2)
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		_ = ng.alertingTicker(ctx)
+	}()
+	runtime.Gosched()
+
+	tick := advanceClock(t, mockedClock)
+	assertEvalRun(t, evalAppliedCh, tick, healthy.ID, dependent.ID)
+	assert.Equal(t, 1, evaluations(getKey(healthy)))
+	assert.Equal(t, 0, evaluations(getKey(dependent)), "the definition querying the unhealthy datasource should be skipped")
+	assert.True(t, ng.schedule.datasourceHealth.isSkipped(getKey(dependent)))
+	assert.False(t, ng.schedule.datasourceHealth.isSkipped(getKey(healthy)))
+
+	// the skip is cleared once the datasource recovers
+	health.Set(1, 2, true)
+	tick = advanceClock(t, mockedClock)
+	assertEvalRun(t, evalAppliedCh, tick, healthy.ID, dependent.ID)
+	assert.Equal(t, 2, evaluations(getKey(healthy)))
+	assert.Equal(t, 1, evaluations(getKey(dependent)))
+	assert.False(t, ng.schedule.datasourceHealth.isSkipped(getKey(dependent)))
+}
@@ -0,0 +1,139 @@
+package ngalert
+
+import (
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+const (
+	// defaultHistorySize is the number of evaluations kept per alert definition.
+	defaultHistorySize = 100
+	// lastStatesCount is the number of the last states returned by the stats.
+	lastStatesCount = 10
+)
+
+// evaluationRecord is the outcome of an evaluation of an alert definition.
+type evaluationRecord struct {
+	At       time.Time
+	Duration time.Duration
+	Failed   bool
+	// State is the most severe state of the alert instances.
+	State eval.State
+}
+
+// evaluationHistory keeps the last evaluations of every alert definition
+// in a ring buffer per alert definition.
+type evaluationHistory struct {
+	size int
+
+	mu sync.RWMutex
+	// records are indexed by the key of the alert definition
+	records map[string]*evaluationRing
+}
+
+type evaluationRing struct {
+	records []evaluationRecord
+	// next is the index the next record is written at once the ring is full
+	next int
+}
+
+func newEvaluationHistory(size int) *evaluationHistory {
+	if size <= 0 {
+		size = defaultHistorySize
+	}
+	return &evaluationHistory{size: size, records: make(map[string]*evaluationRing)}
+}
+
+// add records an evaluation of the alert definition, overwriting the oldest one once the ring is full.
+func (h *evaluationHistory) add(key string, record evaluationRecord) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ring, ok := h.records[key]
+	if !ok {
+		ring = &evaluationRing{records: make([]evaluationRecord, 0, h.size)}
+		h.records[key] = ring
+	}
+	if len(ring.records) < h.size {
+		ring.records = append(ring.records, record)
+		return
+	}
+	ring.records[ring.next] = record
+	ring.next = (ring.next + 1) % h.size
+}
+
+// list returns a copy of the records of the alert definition from the oldest to the latest.
+func (h *evaluationHistory) list(key string) []evaluationRecord {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	ring, ok := h.records[key]
+	if !ok {
+		return nil
+	}
+	records := make([]evaluationRecord, 0, len(ring.records))
+	records = append(records, ring.records[ring.next:]...)
+	records = append(records, ring.records[:ring.next]...)
+	return records
+}
+
+// mostSevereState returns the most severe state of the alert instances:
+// Error, Alerting, Pending, Normal, then NotApplicable.
+func mostSevereState(instances []alertInstance) eval.State {
+	severity := map[eval.State]int{eval.NotApplicable: 0, eval.Normal: 1, eval.Pending: 2, eval.Alerting: 3, eval.Error: 4}
+	state := eval.Normal
+	for i, instance := range instances {
+		if i == 0 || severity[instance.State] > severity[state] {
+			state = instance.State
+		}
+	}
+	return state
+}
+
+// DefinitionStats summarizes the evaluations of an alert definition within a time window.
+type DefinitionStats struct {
+	Evaluations     int           `json:"evaluations"`
+	Failures        int           `json:"failures"`
+	AverageDuration time.Duration `json:"averageDuration"`
+	// LastStates are the most severe states of the alert instances of the last evaluations
+	// from the oldest to the latest; the failed evaluations are in the Error state.
+	LastStates []eval.State `json:"lastStates"`
+}
+
+// Stats returns the stats of the evaluations of the alert definition within the window up to now.
+// They are computed from the last evaluations kept in memory by this instance.
+// The stats of an unknown alert definition are empty.
+func (ng *AlertNG) Stats(uid string, orgID int64, window time.Duration) DefinitionStats {
+	since := ng.schedule.clock.Now().Add(-window)
+
+	var stats DefinitionStats
+	var total time.Duration
+	states := make([]eval.State, 0)
+	var records []evaluationRecord
+	if key, err := ng.definitionKey(orgID, uid); err == nil {
+		records = ng.schedule.history.list(key)
+	}
+	for _, record := range records {
+		if record.At.Before(since) {
+			continue
+		}
+		stats.Evaluations++
+		total += record.Duration
+		state := record.State
+		if record.Failed {
+			stats.Failures++
+			state = eval.Error
+		}
+		states = append(states, state)
+	}
+	if stats.Evaluations > 0 {
+		stats.AverageDuration = total / time.Duration(stats.Evaluations)
+	}
+	if len(states) > lastStatesCount {
+		states = states[len(states)-lastStatesCount:]
+	}
+	stats.LastStates = states
+	return stats
+}
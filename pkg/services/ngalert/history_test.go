@@ -0,0 +1,125 @@
+package ngalert
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluationHistoryRing(t *testing.T) {
+	h := newEvaluationHistory(3)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		h.add("1:uid", evaluationRecord{At: start.Add(time.Duration(i) * time.Second)})
+	}
+
+	records := h.list("1:uid")
+	require.Len(t, records, 3)
+	for i, record := range records {
+		assert.Equal(t, start.Add(time.Duration(i+2)*time.Second), record.At)
+	}
+	assert.Empty(t, h.list("1:other"))
+	assert.Empty(t, h.list("2:uid"))
+}
+
+func TestStats(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	alertDefinition := &AlertDefinition{ID: 1, OrgID: 1, UID: "uid", Condition: "A", IntervalSeconds: 1, Version: 1, Enabled: true}
+	store := newInMemoryDefinitionStore()
+	store.add(alertDefinition)
+	ng.SetDefinitionStore(store)
+
+	now := mockedClock.Now()
+	records := []evaluationRecord{
+		{At: now.Add(-time.Hour), Duration: time.Minute, State: eval.Alerting},
+		{At: now.Add(-4 * time.Minute), Duration: time.Second, State: eval.Normal},
+		{At: now.Add(-3 * time.Minute), Duration: 3 * time.Second, Failed: true},
+		{At: now.Add(-2 * time.Minute), Duration: 2 * time.Second, State: eval.Alerting},
+	}
+	for _, record := range records {
+		ng.schedule.history.add(getKey(alertDefinition), record)
+	}
+
+	stats := ng.Stats("uid", 1, 5*time.Minute)
+	assert.Equal(t, 3, stats.Evaluations)
+	assert.Equal(t, 1, stats.Failures)
+	assert.Equal(t, 2*time.Second, stats.AverageDuration)
+	assert.Equal(t, []eval.State{eval.Normal, eval.Error, eval.Alerting}, stats.LastStates)
+
+	assert.Equal(t, DefinitionStats{LastStates: []eval.State{}}, ng.Stats("unknown", 1, 5*time.Minute))
+}
+
+func TestAlertingTickerStats(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.setMaxAttempts(1)
+
+	// the outcomes of the successive evaluations
+	outcomes := []func() (eval.Results, error){
+		func() (eval.Results, error) {
+			return eval.Results{{Instance: data.Labels{"host": "a"}, State: eval.Alerting}, {Instance: data.Labels{"host": "b"}, State: eval.Normal}}, nil
+		},
+		func() (eval.Results, error) { return nil, errors.New("datasource unavailable") },
+		func() (eval.Results, error) {
+			return eval.Results{{Instance: data.Labels{"host": "a"}, State: eval.Normal}}, nil
+		},
+		func() (eval.Results, error) {
+			return eval.Results{{Instance: data.Labels{"host": "a"}, State: eval.Error, Error: errors.New("no data")}}, nil
+		},
+	}
+	var calls int32
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		i := atomic.AddInt32(&calls, 1) - 1
+		return outcomes[int(i)%len(outcomes)]()
+	})
+
+	alert := createTestAlertDefinition(t, ng, 1)
+
+	evalAppliedCh := make(chan evalAppliedInfo, 1)
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	ctx := context.Background()
+	go func() {
+		err := ng.alertingTicker(ctx)
+		require.NoError(t, err)
+	}()
+	runtime.Gosched()
+
+	for range outcomes {
+		tick := advanceClock(t, mockedClock)
+		assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+	}
+
+	stats := ng.Stats(alert.UID, alert.OrgID, time.Minute)
+	assert.Equal(t, 4, stats.Evaluations)
+	assert.Equal(t, 1, stats.Failures)
+	assert.GreaterOrEqual(t, int64(stats.AverageDuration), int64(0))
+	assert.Equal(t, []eval.State{eval.Alerting, eval.Error, eval.Normal, eval.Error}, stats.LastStates)
+
+	t.Run("the evaluations out of the window should not be counted", func(t *testing.T) {
+		stats := ng.Stats(alert.UID, alert.OrgID, time.Second)
+		assert.Equal(t, 2, stats.Evaluations)
+		assert.Equal(t, 0, stats.Failures)
+		assert.Equal(t, []eval.State{eval.Normal, eval.Error}, stats.LastStates)
+	})
+}
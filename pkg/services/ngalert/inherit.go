@@ -0,0 +1,140 @@
+package ngalert
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+var errEmptyRuleGroup = errors.New("rule group should not be empty")
+
+// groupRef identifies a group of alert definitions within a folder of an organisation.
+type groupRef struct {
+	folderRef
+	group string
+}
+
+// inheritedLabels are the labels defined at the folder and the group level
+// inherited by the alert definitions of the folders and the groups.
+// They are not persisted.
+type inheritedLabels struct {
+	mu      sync.RWMutex
+	folders map[folderRef]map[string]string
+	groups  map[groupRef]map[string]string
+}
+
+func newInheritedLabels() *inheritedLabels {
+	return &inheritedLabels{
+		folders: make(map[folderRef]map[string]string),
+		groups:  make(map[groupRef]map[string]string),
+	}
+}
+
+func (l *inheritedLabels) setFolder(orgID int64, folderUID string, labels map[string]string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ref := folderRef{orgID: orgID, folderUID: folderUID}
+	if len(labels) == 0 {
+		delete(l.folders, ref)
+		return
+	}
+	l.folders[ref] = copyLabels(labels)
+}
+
+func (l *inheritedLabels) setGroup(orgID int64, folderUID, group string, labels map[string]string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ref := groupRef{folderRef: folderRef{orgID: orgID, folderUID: folderUID}, group: group}
+	if len(labels) == 0 {
+		delete(l.groups, ref)
+		return
+	}
+	l.groups[ref] = copyLabels(labels)
+}
+
+// resolve returns the labels the alert definition inherits from its folder and its group:
+// the labels of the group take precedence over the labels of the folder
+// and the labels of the alert definition take precedence over both.
+// Only the inherited label names are returned; it's nil if there are none.
+func (l *inheritedLabels) resolve(alertDefinition *AlertDefinition) data.Labels {
+	if alertDefinition.FolderUID == "" && alertDefinition.RuleGroup == "" {
+		return nil
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	folder := folderRef{orgID: alertDefinition.OrgID, folderUID: alertDefinition.FolderUID}
+	folderLabels := l.folders[folder]
+	groupLabels := l.groups[groupRef{folderRef: folder, group: alertDefinition.RuleGroup}]
+	if len(folderLabels) == 0 && len(groupLabels) == 0 {
+		return nil
+	}
+
+	resolved := make(data.Labels, len(folderLabels)+len(groupLabels))
+	for _, labels := range []map[string]string{folderLabels, groupLabels} {
+		for k, v := range labels {
+			resolved[k] = v
+		}
+	}
+	for k := range resolved {
+		if v, ok := alertDefinition.Labels[k]; ok {
+			resolved[k] = v
+		}
+	}
+	return resolved
+}
+
+// withInheritedLabels adds the inherited labels to the instances of the results;
+// the instance labels take precedence over the inherited ones.
+func withInheritedLabels(results eval.Results, inherited data.Labels) eval.Results {
+	if len(inherited) == 0 {
+		return results
+	}
+	merged := make(eval.Results, len(results))
+	for i, r := range results {
+		instance := make(data.Labels, len(inherited)+len(r.Instance))
+		for k, v := range inherited {
+			instance[k] = v
+		}
+		for k, v := range r.Instance {
+			instance[k] = v
+		}
+		r.Instance = instance
+		merged[i] = r
+	}
+	return merged
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	c := make(map[string]string, len(labels))
+	for k, v := range labels {
+		c[k] = v
+	}
+	return c
+}
+
+// SetFolderLabels sets the labels inherited by the alert definitions of the folder
+// from their next evaluation; no labels removes them.
+func (ng *AlertNG) SetFolderLabels(orgID int64, folderUID string, labels map[string]string) error {
+	if folderUID == "" {
+		return errEmptyFolderUID
+	}
+	ng.schedule.inheritedLabels.setFolder(orgID, folderUID, labels)
+	ng.schedule.registry.refresh(orgID)
+	return nil
+}
+
+// SetGroupLabels sets the labels inherited by the alert definitions of the group of the folder
+// from their next evaluation; no labels removes them.
+func (ng *AlertNG) SetGroupLabels(orgID int64, folderUID, group string, labels map[string]string) error {
+	if group == "" {
+		return errEmptyRuleGroup
+	}
+	ng.schedule.inheritedLabels.setGroup(orgID, folderUID, group, labels)
+	ng.schedule.registry.refresh(orgID)
+	return nil
+}
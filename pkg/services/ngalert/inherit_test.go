@@ -0,0 +1,94 @@
+package ngalert
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInheritedLabelsResolve(t *testing.T) {
+	l := newInheritedLabels()
+	l.setFolder(1, "ops", map[string]string{"team": "ops", "severity": "low", "env": "prod"})
+	l.setGroup(1, "ops", "api", map[string]string{"severity": "high"})
+
+	alertDefinition := &AlertDefinition{OrgID: 1, FolderUID: "ops", RuleGroup: "api", Labels: map[string]string{"env": "staging", "owner": "alice"}}
+	// folder < group < alert definition; the labels of the alert definition are not inherited
+	assert.Equal(t, data.Labels{"team": "ops", "severity": "high", "env": "staging"}, l.resolve(alertDefinition))
+
+	// the groups are scoped to their folder and the folders to their organisation
+	assert.Equal(t, data.Labels{"team": "ops", "severity": "low", "env": "prod"}, l.resolve(&AlertDefinition{OrgID: 1, FolderUID: "ops", RuleGroup: "web"}))
+	assert.Nil(t, l.resolve(&AlertDefinition{OrgID: 1, FolderUID: "dev", RuleGroup: "api"}))
+	assert.Nil(t, l.resolve(&AlertDefinition{OrgID: 2, FolderUID: "ops", RuleGroup: "api"}))
+	assert.Nil(t, l.resolve(&AlertDefinition{OrgID: 1}))
+
+	// no labels removes them
+	l.setFolder(1, "ops", nil)
+	assert.Equal(t, data.Labels{"severity": "high"}, l.resolve(&AlertDefinition{OrgID: 1, FolderUID: "ops", RuleGroup: "api"}))
+}
+
+func TestWithInheritedLabels(t *testing.T) {
+	results := eval.Results{
+		{Instance: data.Labels{"host": "a"}, State: eval.Alerting},
+		{Instance: data.Labels{"host": "b", "team": "db"}, State: eval.Normal},
+	}
+	merged := withInheritedLabels(results, data.Labels{"team": "ops"})
+	// the instance labels take precedence
+	assert.Equal(t, data.Labels{"host": "a", "team": "ops"}, merged[0].Instance)
+	assert.Equal(t, data.Labels{"host": "b", "team": "db"}, merged[1].Instance)
+	// the results are not modified
+	assert.Equal(t, data.Labels{"host": "a"}, results[0].Instance)
+}
+
+func TestAlertingTickerInheritedLabels(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		return eval.Results{{Instance: data.Labels{"host": "a", "team": "web"}, State: eval.Alerting}}, nil
+	})
+
+	store := newInMemoryDefinitionStore()
+	inherits := &AlertDefinition{ID: 1, OrgID: 1, UID: "inherits", FolderUID: "ops", Condition: "A", IntervalSeconds: 1, Version: 1, Enabled: true}
+	overrides := &AlertDefinition{ID: 2, OrgID: 1, UID: "overrides", FolderUID: "ops", Condition: "A", IntervalSeconds: 1, Version: 1, Enabled: true, Labels: map[string]string{"severity": "high"}}
+	store.add(inherits, overrides)
+	ng.SetDefinitionStore(store)
+	require.NoError(t, ng.SetFolderLabels(1, "ops", map[string]string{"severity": "low", "team": "ops"}))
+	require.Error(t, ng.SetFolderLabels(1, "", map[string]string{"severity": "low"}))
+
+	evalAppliedCh := make(chan evalAppliedInfo, 2)
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		_ = ng.alertingTicker(ctx)
+	}()
+	runtime.Gosched()
+
+	tick := advanceClock(t, mockedClock)
+	// the second alert definition is dispatched half a tick later
+	mockedClock.Add(500 * time.Millisecond)
+	assertEvalRun(t, evalAppliedCh, tick, inherits.ID, overrides.ID)
+
+	labelsOf := func(alertDefinition *AlertDefinition) data.Labels {
+		instances := ng.schedule.stateTracker.get(getKey(alertDefinition))
+		require.Len(t, instances, 1)
+		return instances[0].Labels
+	}
+	// the folder label appears on the results unless overridden by the alert definition or the instance
+	assert.Equal(t, data.Labels{"host": "a", "team": "web", "severity": "low"}, labelsOf(inherits))
+	assert.Equal(t, data.Labels{"host": "a", "team": "web", "severity": "high"}, labelsOf(overrides))
+}
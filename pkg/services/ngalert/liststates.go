@@ -0,0 +1,73 @@
+package ngalert
+
+import (
+	"encoding/base64"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// defaultStatesPageSize is the number of alert states of a page if the page size is not positive.
+const defaultStatesPageSize = 100
+
+// AlertState is the current state of an alert instance.
+type AlertState struct {
+	DefinitionUID   string      `json:"definitionUid"`
+	DefinitionTitle string      `json:"definitionTitle"`
+	Labels          data.Labels `json:"labels"`
+	State           string      `json:"state"`
+	// Value is the value of the condition on the last evaluation.
+	Value           float64   `json:"value"`
+	LastEvaluatedAt time.Time `json:"lastEvaluatedAt"`
+
+	// cursor is the position of the state in the stable ordering of the states.
+	cursor string
+}
+
+// ListAlertStates returns a page of the current states of the alert instances of the organisation
+// and the token of the next page, empty after the last page.
+// The states are ordered by alert definition and labels, and a page starts after the last state
+// of the previous one, so that the states created or deleted between two pages
+// do not shift the other ones. An invalid page token returns no states.
+func (ng *AlertNG) ListAlertStates(orgID int64, pageToken string, pageSize int) ([]AlertState, string) {
+	if pageSize <= 0 {
+		pageSize = defaultStatesPageSize
+	}
+	after, err := base64.RawURLEncoding.DecodeString(pageToken)
+	if err != nil {
+		ng.log.Warn("invalid alert states page token", "orgID", orgID, "token", pageToken, "error", err)
+		return []AlertState{}, ""
+	}
+
+	instances := ng.schedule.stateTracker.all(orgID)
+	states := make([]AlertState, 0, len(instances))
+	for _, instance := range instances {
+		states = append(states, AlertState{
+			DefinitionUID:   instance.DefinitionUID,
+			DefinitionTitle: instance.DefinitionTitle,
+			Labels:          instance.Labels,
+			State:           instance.State.String(),
+			Value:           instance.Value,
+			LastEvaluatedAt: instance.LastEvaluatedAt,
+			// the keys are unique and the fingerprints unique by key
+			cursor: strings.Join([]string{instance.DefinitionKey, fingerprint(instance.Labels)}, "\x00"),
+		})
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].cursor < states[j].cursor
+	})
+
+	start := 0
+	if len(after) > 0 {
+		start = sort.Search(len(states), func(i int) bool {
+			return states[i].cursor > string(after)
+		})
+	}
+	end := start + pageSize
+	if end >= len(states) {
+		return states[start:], ""
+	}
+	return states[start:end], base64.RawURLEncoding.EncodeToString([]byte(states[end-1].cursor))
+}
@@ -0,0 +1,94 @@
+package ngalert
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListAlertStates(t *testing.T) {
+	logger := log.New("ngalert.schedule.test")
+	ng := &AlertNG{log: logger, schedule: newScheduler(clock.NewMock(), time.Second, logger, nil)}
+	st := ng.schedule.stateTracker
+
+	expected := make(map[string]struct{})
+	for d := 0; d < 5; d++ {
+		alertDefinition := &AlertDefinition{OrgID: 1, UID: fmt.Sprintf("definition-%d", d)}
+		var results eval.Results
+		for h := 0; h < 7; h++ {
+			host := fmt.Sprintf("host-%d", h)
+			results = append(results, eval.Result{Instance: data.Labels{"host": host}, State: eval.Normal})
+			expected[alertDefinition.UID+"/"+host] = struct{}{}
+		}
+		st.setResults(getKey(alertDefinition), alertDefinition, results)
+	}
+	otherOrg := &AlertDefinition{OrgID: 2, UID: "definition-0"}
+	st.setResults(getKey(otherOrg), otherOrg, eval.Results{{Instance: data.Labels{"host": "host-0"}, State: eval.Alerting}})
+
+	listed := make(map[string]struct{})
+	pages := 0
+	token := ""
+	for {
+		states, next := ng.ListAlertStates(1, token, 4)
+		pages++
+		require.LessOrEqual(t, len(states), 4)
+		for _, state := range states {
+			id := state.DefinitionUID + "/" + state.Labels["host"]
+			_, ok := listed[id]
+			require.False(t, ok, "state %s listed twice", id)
+			listed[id] = struct{}{}
+		}
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	assert.Equal(t, expected, listed)
+	assert.Equal(t, 9, pages)
+
+	states, next := ng.ListAlertStates(2, "", 0)
+	assert.Len(t, states, 1)
+	assert.Equal(t, "Alerting", states[0].State)
+	assert.Empty(t, next)
+
+	states, next = ng.ListAlertStates(1, "not a token!", 4)
+	assert.Empty(t, states)
+	assert.Empty(t, next)
+}
+
+func TestListAlertStatesStableAcrossChanges(t *testing.T) {
+	logger := log.New("ngalert.schedule.test")
+	ng := &AlertNG{log: logger, schedule: newScheduler(clock.NewMock(), time.Second, logger, nil)}
+	st := ng.schedule.stateTracker
+
+	alertDefinition := &AlertDefinition{OrgID: 1, UID: "cpu"}
+	st.setResults(getKey(alertDefinition), alertDefinition, eval.Results{
+		{Instance: data.Labels{"host": "b"}, State: eval.Normal},
+		{Instance: data.Labels{"host": "c"}, State: eval.Normal},
+		{Instance: data.Labels{"host": "d"}, State: eval.Normal},
+	})
+
+	states, next := ng.ListAlertStates(1, "", 2)
+	require.Len(t, states, 2)
+	assert.Equal(t, "b", states[0].Labels["host"])
+	assert.Equal(t, "c", states[1].Labels["host"])
+
+	// an instance sorted before the page token does not shift the next page
+	st.setResults(getKey(alertDefinition), alertDefinition, eval.Results{
+		{Instance: data.Labels{"host": "a"}, State: eval.Normal},
+		{Instance: data.Labels{"host": "b"}, State: eval.Normal},
+		{Instance: data.Labels{"host": "c"}, State: eval.Normal},
+		{Instance: data.Labels{"host": "d"}, State: eval.Normal},
+	})
+	states, next = ng.ListAlertStates(1, next, 2)
+	require.Len(t, states, 1)
+	assert.Equal(t, "d", states[0].Labels["host"])
+	assert.Empty(t, next)
+}
@@ -0,0 +1,124 @@
+package ngalert
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	evalInFlight     prometheus.Gauge
+	evalWaiting      prometheus.Gauge
+	evalSaturation   prometheus.Gauge
+	evalWaitDuration prometheus.Histogram
+	evalDeferred     prometheus.Counter
+	evalAttempts     prometheus.Histogram
+	eventsDropped    prometheus.Counter
+	evalResultBytes  prometheus.Histogram
+	dispatchLatency  prometheus.Histogram
+	// definitionsQuarantined is the number of the alert definition routines in quarantine
+	definitionsQuarantined prometheus.Gauge
+
+	// evalInFlightPerOrg is labeled by the organisation ID
+	evalInFlightPerOrg *prometheus.GaugeVec
+	// sinkErrors and sinkDropped are labeled by the result sink name
+	sinkErrors  *prometheus.CounterVec
+	sinkDropped *prometheus.CounterVec
+)
+
+func init() {
+	evalInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "grafana",
+		Subsystem: "ngalert",
+		Name:      "evaluations_in_flight",
+		Help:      "The number of alert definition evaluations currently running",
+	})
+
+	evalInFlightPerOrg = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "grafana",
+		Subsystem: "ngalert",
+		Name:      "org_evaluations_in_flight",
+		Help:      "The number of alert definition evaluations currently running per organisation",
+	}, []string{"org"})
+
+	evalWaiting = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "grafana",
+		Subsystem: "ngalert",
+		Name:      "evaluations_waiting",
+		Help:      "The number of alert definition evaluations currently blocked waiting for a concurrency slot",
+	})
+
+	evalSaturation = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "grafana",
+		Subsystem: "ngalert",
+		Name:      "evaluation_slots_saturation_ratio",
+		Help:      "The ratio of the recent ticks all the concurrency slots were in use with alert definition evaluations waiting",
+	})
+
+	definitionsQuarantined = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "grafana",
+		Subsystem: "ngalert",
+		Name:      "alert_definitions_quarantined",
+		Help:      "The number of alert definitions evaluated less often because their evaluations keep failing",
+	})
+
+	evalWaitDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "grafana",
+		Subsystem: "ngalert",
+		Name:      "evaluation_wait_duration_seconds",
+		Help:      "Time spent by alert definition evaluations waiting for a concurrency slot",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 9),
+	})
+
+	evalDeferred = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "grafana",
+		Subsystem: "ngalert",
+		Name:      "evaluations_deferred_total",
+		Help:      "The total number of alert definition evaluations deferred because of the evaluation rate limit",
+	})
+
+	evalAttempts = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "grafana",
+		Subsystem: "ngalert",
+		Name:      "evaluation_attempts",
+		Help:      "The number of attempts taken by the successful alert definition evaluations",
+		Buckets:   prometheus.LinearBuckets(1, 1, 5),
+	})
+
+	eventsDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "grafana",
+		Subsystem: "ngalert",
+		Name:      "events_dropped_total",
+		Help:      "The total number of alert instance events dropped because a subscriber was not keeping up",
+	})
+
+	evalResultBytes = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "grafana",
+		Subsystem: "ngalert",
+		Name:      "evaluation_result_bytes",
+		Help:      "The approximate memory footprint of the results of the successful alert definition evaluations",
+		Buckets:   prometheus.ExponentialBuckets(256, 4, 10),
+	})
+
+	dispatchLatency = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "grafana",
+		Subsystem: "ngalert",
+		Name:      "dispatch_latency_seconds",
+		Help:      "Time between a scheduler tick and the dispatch of each alert definition evaluation due on it",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+	})
+
+	sinkErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "grafana",
+		Subsystem: "ngalert",
+		Name:      "result_sink_errors_total",
+		Help:      "The total number of evaluation results a result sink failed to receive",
+	}, []string{"sink"})
+
+	sinkDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "grafana",
+		Subsystem: "ngalert",
+		Name:      "result_sink_dropped_total",
+		Help:      "The total number of evaluation results dropped because a result sink was not keeping up",
+	}, []string{"sink"})
+
+	prometheus.MustRegister(evalInFlight, evalInFlightPerOrg, evalWaiting, evalSaturation, definitionsQuarantined, evalWaitDuration, evalDeferred, evalAttempts, eventsDropped, evalResultBytes, dispatchLatency, sinkErrors, sinkDropped)
+}
@@ -10,6 +10,8 @@ import (
 
 var errAlertDefinitionFailedGenerateUniqueUID = errors.New("failed to generate alert definition UID")
 
+var errAlertDefinitionUIDExists = errors.New("alert definition UID already exists")
+
 // AlertDefinition is the model for alert definitions in Alerting NG.
 type AlertDefinition struct {
 	ID              int64 `xorm:"pk autoincr 'id'"`
@@ -21,6 +23,79 @@ type AlertDefinition struct {
 	IntervalSeconds int64
 	Version         int64
 	UID             string `xorm:"uid"`
+	// Enabled is false if the alert definition should not be scheduled at all.
+	Enabled bool
+	// KeepFiringFor is the duration a firing instance keeps firing
+	// after its condition is no longer true.
+	KeepFiringFor time.Duration
+	// RepeatInterval if positive is the interval a firing instance
+	// is notified again at while it keeps firing.
+	RepeatInterval time.Duration
+	// DashboardID and PanelID optionally refer to the panel
+	// annotated when the alert definition starts firing.
+	DashboardID int64 `xorm:"dashboard_id"`
+	PanelID     int64 `xorm:"panel_id"`
+	// RelativeTimeRange if set is applied uniformly to all the condition queries.
+	RelativeTimeRange eval.RelativeTimeRange
+	// For is the duration the condition of an instance should be true
+	// before the instance fires; meanwhile the instance is Pending.
+	For time.Duration `xorm:"for_duration"`
+	// TemplateVariable if set makes the alert definition a template
+	// expanded into an alert definition per each of the TemplateValues.
+	TemplateVariable string
+	TemplateValues   []string
+	// MaxSeries if positive overrides the maximum number of series
+	// an evaluation of the alert definition accepts.
+	MaxSeries int64
+	// GuardCondition if set is the RefID of the query or expression
+	// that should hold for the condition to be evaluated.
+	GuardCondition string
+	// ConfirmCondition if set is the RefID of the query or expression that should also be
+	// true for an instance to be Alerting. It's only evaluated if the condition is true for any instance.
+	ConfirmCondition string
+	// Labels are added to the labels of the alert instances
+	// and select the alert definition for bulk operations.
+	Labels map[string]string
+	// QueryCacheTTL if positive is the time the results of an evaluation
+	// are reused by the next evaluations instead of querying the datasources again.
+	QueryCacheTTL time.Duration
+	// Priority if positive makes the evaluations of the alert definition
+	// waiting for a concurrency slot be served before the other ones.
+	Priority int64
+	// Trend if set compares the values of the condition to its values some time earlier.
+	Trend eval.TrendCondition
+	// Baseline if set compares the values of the condition as a percentage of the values of a baseline.
+	Baseline eval.BaselineCondition
+	// ActiveTimeIntervals if set are the time windows the evaluation results
+	// change the state of the alert instances in; outside them the results are discarded.
+	ActiveTimeIntervals ActiveTimeIntervals
+	// MinAlertingInstances if positive is the number of instances that should be Alerting
+	// for any of them to be; below it they are all Normal.
+	MinAlertingInstances int64
+	// MaxStaleness if positive is the time after the last successful evaluation
+	// the alert definition is considered stale, i.e. its state unreliable.
+	MaxStaleness time.Duration
+	// AlignmentOffset is the offset within the interval the alert definition is evaluated at,
+	// e.g. 30s for a 1m interval evaluates it at :30 of each minute.
+	// It's a multiple of the scheduler interval smaller than the interval.
+	AlignmentOffset time.Duration
+	// MaxIntervalSeconds if greater than IntervalSeconds enables the adaptive interval:
+	// the interval of the stable alert definition is increased up to it
+	// and reset once its results change or approach the threshold.
+	MaxIntervalSeconds int64
+	// FolderUID is the UID of the folder the alert definition belongs to, if any.
+	FolderUID string
+	// RuleGroup is the group of the alert definition within its folder, if any.
+	RuleGroup string
+	// DependsOn are the UIDs of the alert definitions of the organisation the alert definition depends on,
+	// e.g. because it queries their output; it's not evaluated while any of them is unhealthy.
+	DependsOn []string
+	// Features toggle experimental behaviors of the alert definition, e.g. for a gradual rollout;
+	// the unknown features are ignored.
+	Features map[string]bool
+
+	// templateValue is the value an expanded alert definition has been expanded with.
+	templateValue string
 }
 
 // AlertDefinitionVersion is the model for alert definition versions in Alerting NG.
@@ -37,11 +112,14 @@ type AlertDefinitionVersion struct {
 	Condition       string
 	Data            []eval.AlertQuery
 	IntervalSeconds int64
+	Labels          map[string]string
 }
 
 var (
 	// errAlertDefinitionNotFound is an error for an unknown alert definition.
 	errAlertDefinitionNotFound = fmt.Errorf("could not find alert definition")
+	// errAlertDefinitionVersionNotFound is an error for an unknown version of an alert definition.
+	errAlertDefinitionVersionNotFound = fmt.Errorf("could not find alert definition version")
 )
 
 // getAlertDefinitionByIDQuery is the query for retrieving/deleting an alert definition by ID.
@@ -52,6 +130,14 @@ type getAlertDefinitionByIDQuery struct {
 	Result *AlertDefinition
 }
 
+// getAlertDefinitionByUIDQuery is the query for retrieving an alert definition by its organisation and UID.
+type getAlertDefinitionByUIDQuery struct {
+	UID   string
+	OrgID int64
+
+	Result *AlertDefinition
+}
+
 type deleteAlertDefinitionByIDCommand struct {
 	ID    int64
 	OrgID int64
@@ -59,36 +145,182 @@ type deleteAlertDefinitionByIDCommand struct {
 	RowsAffected int64
 }
 
+// getAlertDefinitionVersionQuery is the query for retrieving a version of an alert definition
+// by its organisation, UID and version.
+type getAlertDefinitionVersionQuery struct {
+	OrgID   int64
+	UID     string
+	Version int64
+
+	Result *AlertDefinitionVersion
+}
+
+// deleteExpiredAlertDefinitionVersionsCommand is the command for deleting
+// the alert definition versions exceeding the retention policy.
+type deleteExpiredAlertDefinitionVersionsCommand struct {
+	// CreatedBefore if not zero deletes the versions created before it.
+	CreatedBefore time.Time
+	// MaxCount if positive deletes all but the MaxCount latest versions of each alert definition.
+	MaxCount int64
+
+	RowsAffected int64
+}
+
 // saveAlertDefinitionCommand is the query for saving a new alert definition.
 type saveAlertDefinitionCommand struct {
 	Title           string         `json:"title"`
 	OrgID           int64          `json:"-"`
 	Condition       eval.Condition `json:"condition"`
 	IntervalSeconds *int64         `json:"interval_seconds"`
+	Enabled         *bool          `json:"enabled"`
+	KeepFiringFor   *eval.Duration `json:"keep_firing_for"`
+	For             *eval.Duration `json:"for"`
+	RepeatInterval  *eval.Duration `json:"repeat_interval"`
+	DashboardID     int64          `json:"dashboard_id"`
+	PanelID         int64          `json:"panel_id"`
+	// UID if set is the UID of the new alert definition instead of a generated one,
+	// e.g. to preserve the UIDs of the imported alert definitions.
+	UID string `json:"-"`
+
+	// TemplateVariable is substituted in the queries and the title by each of the TemplateValues.
+	TemplateVariable string   `json:"template_variable"`
+	TemplateValues   []string `json:"template_values"`
+
+	// MaxSeries if positive overrides the configured maximum number of series per evaluation.
+	MaxSeries int64 `json:"max_series"`
+	// GuardCondition if set is the RefID of the query or expression
+	// that should hold for the condition to be evaluated.
+	GuardCondition string `json:"guard_condition"`
+	// ConfirmCondition if set is the RefID of the query or expression
+	// that should also be true for an instance to be Alerting.
+	ConfirmCondition string `json:"confirm_condition"`
+
+	Labels map[string]string `json:"labels"`
+
+	// QueryCacheTTL if set is the time the results of an evaluation are reused by the next evaluations.
+	QueryCacheTTL *eval.Duration `json:"query_cache_ttl"`
+	// Priority if positive makes the evaluations be served first under concurrency pressure.
+	Priority int64 `json:"priority"`
+
+	// ActiveTimeIntervals if set are the only time windows the alert instances change state in.
+	ActiveTimeIntervals ActiveTimeIntervals `json:"active_time_intervals"`
+	// MinAlertingInstances if positive is the number of instances that should be Alerting for any of them to be.
+	MinAlertingInstances int64 `json:"min_alerting_instances"`
+	// MaxStaleness if set is the time after the last successful evaluation the alert definition is stale.
+	MaxStaleness *eval.Duration `json:"max_staleness"`
+	// AlignmentOffset if set is the offset within the interval the alert definition is evaluated at.
+	AlignmentOffset *eval.Duration `json:"alignment_offset"`
+	// MaxIntervalSeconds if greater than the interval is the maximum interval of the stable alert definition.
+	MaxIntervalSeconds int64 `json:"max_interval_seconds"`
+	// FolderUID is the UID of the folder of the alert definition.
+	FolderUID string `json:"folder_uid"`
+	// RuleGroup is the group of the alert definition within its folder.
+	RuleGroup string `json:"rule_group"`
+	// DependsOn are the UIDs of the alert definitions the alert definition depends on.
+	DependsOn []string `json:"depends_on"`
+	// Features toggle experimental behaviors of the alert definition.
+	Features map[string]bool `json:"features"`
+
+	RelativeTimeRange eval.RelativeTimeRange `json:"relative_time_range"`
 
 	Result *AlertDefinition
 }
 
 // updateAlertDefinitionCommand is the query for updating an existing alert definition.
+// The options left nil are not updated while the set ones are, even to their zero value.
+// The guard and confirm conditions, the relative time range, the trend and the baseline
+// are replaced together with the queries and expressions of the condition.
 type updateAlertDefinitionCommand struct {
 	ID              int64          `json:"-"`
 	Title           string         `json:"title"`
 	OrgID           int64          `json:"-"`
 	Condition       eval.Condition `json:"condition"`
 	IntervalSeconds *int64         `json:"interval_seconds"`
+	Enabled         *bool          `json:"enabled"`
+	KeepFiringFor   *eval.Duration `json:"keep_firing_for"`
+	For             *eval.Duration `json:"for"`
+	RepeatInterval  *eval.Duration `json:"repeat_interval"`
+	DashboardID     *int64         `json:"dashboard_id"`
+	PanelID         *int64         `json:"panel_id"`
 	UID             string         `json:"-"`
 
+	// TemplateVariable is substituted in the queries and the title by each of the TemplateValues.
+	TemplateVariable *string  `json:"template_variable"`
+	TemplateValues   []string `json:"template_values"`
+
+	// MaxSeries if positive overrides the configured maximum number of series per evaluation.
+	MaxSeries *int64 `json:"max_series"`
+	// GuardCondition if set is the RefID of the query or expression
+	// that should hold for the condition to be evaluated.
+	GuardCondition string `json:"guard_condition"`
+	// ConfirmCondition if set is the RefID of the query or expression
+	// that should also be true for an instance to be Alerting.
+	ConfirmCondition string `json:"confirm_condition"`
+
+	Labels map[string]string `json:"labels"`
+
+	// QueryCacheTTL if set is the time the results of an evaluation are reused by the next evaluations.
+	QueryCacheTTL *eval.Duration `json:"query_cache_ttl"`
+	// Priority if positive makes the evaluations be served first under concurrency pressure.
+	Priority *int64 `json:"priority"`
+
+	// ActiveTimeIntervals if set are the only time windows the alert instances change state in.
+	ActiveTimeIntervals *ActiveTimeIntervals `json:"active_time_intervals"`
+	// MinAlertingInstances if positive is the number of instances that should be Alerting for any of them to be.
+	MinAlertingInstances *int64 `json:"min_alerting_instances"`
+	// MaxStaleness if set is the time after the last successful evaluation the alert definition is stale.
+	MaxStaleness *eval.Duration `json:"max_staleness"`
+	// AlignmentOffset if set is the offset within the interval the alert definition is evaluated at.
+	AlignmentOffset *eval.Duration `json:"alignment_offset"`
+	// MaxIntervalSeconds if greater than the interval is the maximum interval of the stable alert definition.
+	MaxIntervalSeconds *int64 `json:"max_interval_seconds"`
+	// FolderUID is the UID of the folder of the alert definition.
+	FolderUID *string `json:"folder_uid"`
+	// RuleGroup is the group of the alert definition within its folder.
+	RuleGroup *string `json:"rule_group"`
+	// DependsOn are the UIDs of the alert definitions the alert definition depends on.
+	DependsOn []string `json:"depends_on"`
+	// Features toggle experimental behaviors of the alert definition.
+	Features map[string]bool `json:"features"`
+
+	RelativeTimeRange eval.RelativeTimeRange `json:"relative_time_range"`
+
 	RowsAffected int64
 	Result       *AlertDefinition
 }
 
+// listAlertDefinitionsByLabelsQuery is the query for retrieving
+// the alert definitions of an organisation whose labels match the selector.
+type listAlertDefinitionsByLabelsQuery struct {
+	OrgID    int64
+	Selector map[string]string
+
+	Result []*AlertDefinition
+}
+
 type evalAlertConditionCommand struct {
 	Condition eval.Condition `json:"condition"`
 	Now       time.Time      `json:"now"`
 }
 
+// evalAlertConditionStreamCommand is the command for evaluating a condition
+// at every interval of a time range.
+type evalAlertConditionStreamCommand struct {
+	Condition       eval.Condition `json:"condition"`
+	From            time.Time      `json:"from"`
+	To              time.Time      `json:"to"`
+	IntervalSeconds *int64         `json:"interval_seconds"`
+}
+
 type listAlertDefinitionsQuery struct {
 	OrgID int64 `json:"-"`
 
 	Result []*AlertDefinition
 }
+
+// listAlertDefinitionsUpdatedSinceQuery is the query for listing the alert definitions updated since a time.
+type listAlertDefinitionsUpdatedSinceQuery struct {
+	UpdatedSince time.Time
+
+	Result []*AlertDefinition
+}
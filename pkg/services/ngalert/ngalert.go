@@ -14,6 +14,7 @@ import (
 	"github.com/grafana/grafana/pkg/services/sqlstore"
 	"github.com/grafana/grafana/pkg/services/sqlstore/migrator"
 	"github.com/grafana/grafana/pkg/setting"
+	"golang.org/x/sync/errgroup"
 )
 
 const (
@@ -26,6 +27,8 @@ const (
 	baseIntervalSeconds = 10
 	// default alert definiiton interval
 	defaultIntervalSeconds int64 = 6 * baseIntervalSeconds
+	// default maximum number of series per evaluation (0 disables the limit)
+	defaultMaxSeries int64 = 0
 )
 
 // AlertNG is the service for evaluating the condition of an alert definition.
@@ -36,6 +39,11 @@ type AlertNG struct {
 	SQLStore        *sqlstore.SQLStore       `inject:""`
 	log             log.Logger
 	schedule        *schedule
+
+	versionRetention versionRetention
+
+	// importPolicy is applied to the imported alert definitions having the UID of an existing one
+	importPolicy uidCollisionPolicy
 }
 
 func init() {
@@ -48,13 +56,98 @@ func (ng *AlertNG) Init() error {
 
 	ng.registerAPIEndpoints()
 	ng.schedule = newScheduler(clock.New(), baseIntervalSeconds*time.Second, ng.log, nil)
+	ng.schedule.annotationWriter = repositoryAnnotationWriter{}
+
+	if ng.Cfg.Raw.Section("ngalert").Key("golden_evaluation").MustBool(false) {
+		// the conditions are evaluated against the embedded golden dataset instead of the datasources
+		evaluator, err := eval.NewGoldenEvaluator()
+		if err != nil {
+			return err
+		}
+		ng.log.Warn("alert definitions are evaluated against the golden dataset")
+		ng.schedule.evaluator = evaluator
+		ng.schedule.previewEvaluator = evaluator
+	} else if batchWindow := ng.Cfg.Raw.Section("ngalert").Key("evaluation_batch_window").MustDuration(0); batchWindow > 0 {
+		ng.schedule.evaluator = eval.NewBatchingEvaluator(batchWindow)
+	}
+
+	if evalsPerSecond := ng.Cfg.Raw.Section("ngalert").Key("max_evaluations_per_second").MustFloat64(0); evalsPerSecond > 0 {
+		ng.schedule.evaluator = eval.NewRateLimitedEvaluator(ng.schedule.evaluator, evalsPerSecond)
+	}
+	// the cached evaluations don't count against the rate limit
+	ng.schedule.evaluator = eval.NewCachingEvaluator(ng.schedule.evaluator)
+
+	ng.schedule.evalSemaphore = newEvalSemaphore(ng.schedule.clock, ng.Cfg.Raw.Section("ngalert").Key("max_concurrent_evaluations").MustInt(0))
+	ng.schedule.orgEvalSemaphores = newOrgEvalSemaphores(ng.Cfg.Raw.Section("ngalert").Key("max_concurrent_evaluations_per_org").MustInt(0))
+	ng.schedule.saturation = newSaturationMonitor(
+		ng.Cfg.Raw.Section("ngalert").Key("saturation_window").MustDuration(defaultSaturationWindow),
+		ng.Cfg.Raw.Section("ngalert").Key("saturation_threshold").MustFloat64(defaultSaturationThreshold),
+	)
+	ng.schedule.quarantines = newQuarantines(
+		ng.Cfg.Raw.Section("ngalert").Key("quarantine_threshold").MustInt(defaultQuarantineThreshold),
+		ng.Cfg.Raw.Section("ngalert").Key("quarantine_factor").MustInt64(defaultQuarantineFactor),
+	)
+	ng.schedule.maxSeries = ng.Cfg.Raw.Section("ngalert").Key("max_series_per_evaluation").MustInt64(defaultMaxSeries)
+	ng.schedule.definitionCache = newDefinitionCache(ng.Cfg.Raw.Section("ngalert").Key("definitions_full_fetch_interval").MustDuration(0))
+	ng.schedule.startupGracePeriod = ng.Cfg.Raw.Section("ngalert").Key("startup_grace_period").MustDuration(0)
+	ng.schedule.shutdownGracePeriod = ng.Cfg.Raw.Section("ngalert").Key("shutdown_grace_period").MustDuration(0)
+	orgShutdownPriorities, err := parseOrgPriorities(ng.Cfg.Raw.Section("ngalert").Key("shutdown_org_priorities").Strings(","))
+	if err != nil {
+		return err
+	}
+	ng.schedule.orgShutdownPriorities = orgShutdownPriorities
+	ng.schedule.routingLabels = ng.Cfg.Raw.Section("ngalert").Key("routing_labels").Strings(",")
+	ng.schedule.stateTracker.flapDetector = newSlidingWindowFlapDetector(
+		ng.Cfg.Raw.Section("ngalert").Key("flap_detection_window").MustInt(defaultFlapWindow),
+		ng.Cfg.Raw.Section("ngalert").Key("flap_detection_threshold").MustInt(defaultFlapThreshold),
+	)
+	ng.schedule.stateTracker.suppressFlapping = ng.Cfg.Raw.Section("ngalert").Key("suppress_flapping_notifications").MustBool(false)
+	ng.schedule.dispatchJitter = ng.Cfg.Raw.Section("ngalert").Key("dispatch_jitter").MustDuration(0)
+	ng.schedule.maxDispatchOffset = ng.Cfg.Raw.Section("ngalert").Key("max_dispatch_offset").MustDuration(0)
+	ng.schedule.maxStartupDelay = ng.Cfg.Raw.Section("ngalert").Key("max_routine_startup_delay").MustDuration(0)
+	ng.schedule.datasourceWarmUp.enabled = ng.Cfg.Raw.Section("ngalert").Key("datasource_warmup").MustBool(false)
+	ng.schedule.datasourceWarmUp.timeout = ng.Cfg.Raw.Section("ngalert").Key("datasource_warmup_timeout").MustDuration(defaultDatasourceWarmUpTimeout)
+	ng.schedule.maxRoutineLifetime = ng.Cfg.Raw.Section("ngalert").Key("max_routine_lifetime").MustDuration(0)
+	ng.schedule.noDataRetries = ng.Cfg.Raw.Section("ngalert").Key("nodata_retries").MustInt(0)
+	ng.schedule.noDataBackoff = ng.Cfg.Raw.Section("ngalert").Key("nodata_retry_backoff").MustDuration(defaultNoDataRetryBackoff)
+	ng.schedule.cancelSuperseded = ng.Cfg.Raw.Section("ngalert").Key("cancel_superseded_evaluations").MustBool(false)
+	ng.schedule.supersedeDebounce = ng.Cfg.Raw.Section("ngalert").Key("superseded_evaluation_debounce").MustDuration(defaultSupersedeDebounce)
+	ng.schedule.evalAtDispatchTime = ng.Cfg.Raw.Section("ngalert").Key("evaluation_time_at_dispatch").MustBool(false)
+	if seed := ng.Cfg.Raw.Section("ngalert").Key("scheduler_seed").MustInt64(0); seed != 0 {
+		ng.schedule.setSeed(seed)
+	}
+
+	if ng.Cfg.Raw.Section("ngalert").Key("canary_enabled").MustBool(false) {
+		if err := ng.enableCanary(); err != nil {
+			return err
+		}
+	}
+
+	importPolicy, err := parseUIDCollisionPolicy(ng.Cfg.Raw.Section("ngalert").Key("import_uid_collision_policy").MustString(string(skipOnUIDCollision)))
+	if err != nil {
+		return err
+	}
+	ng.importPolicy = importPolicy
+
+	ng.versionRetention = versionRetention{
+		maxAge:   ng.Cfg.Raw.Section("ngalert").Key("version_retention_max_age").MustDuration(0),
+		maxCount: ng.Cfg.Raw.Section("ngalert").Key("version_retention_max_count").MustInt64(0),
+		interval: ng.Cfg.Raw.Section("ngalert").Key("version_cleanup_interval").MustDuration(defaultVersionCleanupInterval),
+	}
 	return nil
 }
 
 // Run starts the scheduler
 func (ng *AlertNG) Run(ctx context.Context) error {
 	ng.log.Debug("ngalert starting")
-	return ng.alertingTicker(ctx)
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		return ng.versionCleanup(ctx)
+	})
+	g.Go(func() error {
+		return ng.alertingTicker(ctx)
+	})
+	return g.Wait()
 }
 
 // IsDisabled returns true if the alerting service is disable for this instance.
@@ -89,9 +182,6 @@ func (ng *AlertNG) LoadAlertCondition(alertDefinitionID int64) (*eval.Condition,
 		return nil, err
 	}
 
-	return &eval.Condition{
-		RefID:                 alertDefinition.Condition,
-		OrgID:                 alertDefinition.OrgID,
-		QueriesAndExpressions: alertDefinition.Data,
-	}, nil
+	condition := alertDefinition.getCondition()
+	return &condition, nil
 }
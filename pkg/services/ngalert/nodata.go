@@ -0,0 +1,36 @@
+package ngalert
+
+import (
+	"context"
+	"time"
+
+	"github.com/grafana/grafana/pkg/expr"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+// defaultNoDataRetryBackoff is the default time to wait before retrying a NoData evaluation.
+const defaultNoDataRetryBackoff = time.Second
+
+// evaluateRetryingNoData evaluates the condition and evaluates it again, up to noDataRetries times,
+// while it has no results, e.g. because of a scrape gap that resolves quickly.
+// Unlike the attempts of the evaluation the errors are not retried, and neither is
+// a condition skipped by its guard condition: it has no results because it's not evaluated.
+// The retries query the datasources again instead of reusing the cached responses;
+// the guard condition held so only the condition is evaluated again.
+func (sch *schedule) evaluateRetryingNoData(ctx context.Context, key string, condition *eval.Condition, guard *eval.Condition, now time.Time) (eval.Results, error) {
+	results, skipped, err := sch.evaluateGuarded(ctx, key, condition, guard, now)
+	for retry := 0; err == nil && !skipped && len(results) == 0 && retry < sch.noDataRetries; retry++ {
+		if sch.noDataBackoff > 0 {
+			select {
+			case <-sch.clock.After(sch.noDataBackoff):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		sch.log.Debug("retrying the evaluation without data", "key", key, "retry", retry+1, "now", now)
+		uncached := *condition
+		uncached.CacheTTL = 0
+		results, err = sch.evaluator.ConditionEval(expr.WithQueryCache(ctx, expr.NewQueryCache()), &uncached, now)
+	}
+	return results, err
+}
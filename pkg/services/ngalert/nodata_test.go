@@ -0,0 +1,95 @@
+package ngalert
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertingTickerRetriesNoData(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.noDataRetries = 2
+	ng.schedule.noDataBackoff = 0
+	var calls int64
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		// the first query returns no data because of a scrape gap
+		if atomic.AddInt64(&calls, 1) == 1 {
+			return eval.Results{}, nil
+		}
+		return eval.Results{{Instance: data.Labels{"host": "a"}, State: eval.Alerting}}, nil
+	})
+
+	alert := createTestAlertDefinition(t, ng, 1)
+
+	evalAppliedCh := make(chan evalAppliedInfo, 1)
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = ng.alertingTicker(ctx)
+	}()
+	runtime.Gosched()
+
+	tick := advanceClock(t, mockedClock)
+	assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+
+	assert.Equal(t, int64(2), atomic.LoadInt64(&calls), "the evaluation should be retried once")
+	instances := ng.schedule.stateTracker.get(getKey(alert))
+	require.Len(t, instances, 1)
+	assert.Equal(t, eval.Alerting, instances[0].State)
+}
+
+func TestEvaluateRetryingNoDataErrors(t *testing.T) {
+	sch := newScheduler(clock.NewMock(), time.Second, log.New("ngalert.schedule.test"), nil)
+	sch.noDataRetries = 3
+	sch.noDataBackoff = 0
+	var calls int64
+	sch.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		atomic.AddInt64(&calls, 1)
+		return eval.Results{{State: eval.Error}}, nil
+	})
+
+	results, err := sch.evaluateRetryingNoData(context.Background(), "1:uid", &eval.Condition{RefID: "A"}, nil, time.Now())
+	require.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, int64(1), calls, "the erroring results should not be retried")
+}
+
+func TestEvaluateRetryingNoDataGuardSkipped(t *testing.T) {
+	sch := newScheduler(clock.NewMock(), time.Second, log.New("ngalert.schedule.test"), nil)
+	sch.noDataRetries = 3
+	sch.noDataBackoff = 0
+	var guardCalls, conditionCalls int64
+	sch.evaluator = eval.EvaluatorFunc(func(_ context.Context, c *eval.Condition, _ time.Time) (eval.Results, error) {
+		if c.RefID == "guard" {
+			atomic.AddInt64(&guardCalls, 1)
+			return eval.Results{{State: eval.Normal}}, nil
+		}
+		atomic.AddInt64(&conditionCalls, 1)
+		return eval.Results{}, nil
+	})
+
+	// the alert definition has no instances yet so the skipped condition has no results
+	results, err := sch.evaluateRetryingNoData(context.Background(), "1:uid", &eval.Condition{RefID: "A"}, &eval.Condition{RefID: "guard"}, time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, results)
+	assert.Equal(t, int64(1), guardCalls, "the skipped condition should not be retried")
+	assert.Zero(t, conditionCalls)
+}
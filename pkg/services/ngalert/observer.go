@@ -0,0 +1,22 @@
+package ngalert
+
+// registryObserver is notified of the mutations of the alert definition registry
+// so that the indexes derived from it, e.g. by labels or by datasource,
+// are kept in sync without polling it. The notifications are sent outside
+// the registry lock so the observer can query the registry, but they are sent
+// synchronously by the ticker loop so the observer should not block.
+type registryObserver interface {
+	// created is notified when the routine of an alert definition is registered.
+	created(key string, info alertDefinitionInfo)
+	// updated is notified when the version of a registered alert definition changes.
+	updated(key string, info alertDefinitionInfo, previousVersion int64)
+	// deleted is notified when the routine of an alert definition is unregistered.
+	deleted(key string, info alertDefinitionInfo)
+}
+
+// setObserver sets the observer of the mutations of the registry; if it's nil they are not observed.
+func (r *alertDefinitionRegistry) setObserver(observer registryObserver) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.observer = observer
+}
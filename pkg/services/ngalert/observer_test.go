@@ -0,0 +1,79 @@
+package ngalert
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingObserver records the registry mutations it's notified of.
+type recordingObserver struct {
+	mu       sync.Mutex
+	registry *alertDefinitionRegistry
+	events   []string
+}
+
+func (o *recordingObserver) record(event string, key string) {
+	// the registry is not locked while notifying its observer
+	_, registered := o.registry.get(key)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if registered {
+		o.events = append(o.events, event+" "+key)
+	} else {
+		o.events = append(o.events, event+" "+key+" (unregistered)")
+	}
+}
+
+func (o *recordingObserver) created(key string, _ alertDefinitionInfo) {
+	o.record("created", key)
+}
+
+func (o *recordingObserver) updated(key string, info alertDefinitionInfo, previousVersion int64) {
+	o.record(fmt.Sprintf("updated from %d to %d", previousVersion, info.version), key)
+}
+
+func (o *recordingObserver) deleted(key string, _ alertDefinitionInfo) {
+	o.record("deleted", key)
+}
+
+func (o *recordingObserver) takeEvents() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	events := o.events
+	o.events = nil
+	return events
+}
+
+func TestRegistryObserver(t *testing.T) {
+	r := &alertDefinitionRegistry{alertDefinitionInfo: make(map[string]alertDefinitionInfo)}
+	observer := &recordingObserver{registry: r}
+	r.setObserver(observer)
+	ctx := context.Background()
+
+	r.getOrCreateInfo(ctx, "1:a", 1, "a", 1, 1, "")
+	assert.Equal(t, []string{"created 1:a"}, observer.takeEvents())
+
+	// registering the same version is not a mutation
+	r.getOrCreateInfo(ctx, "1:a", 1, "a", 1, 1, "")
+	assert.Empty(t, observer.takeEvents())
+
+	info := r.getOrCreateInfo(ctx, "1:a", 1, "a", 1, 2, "")
+	require.Equal(t, int64(2), info.version)
+	assert.Equal(t, []string{"updated from 1 to 2 1:a"}, observer.takeEvents())
+
+	r.del("1:a")
+	assert.Equal(t, []string{"deleted 1:a (unregistered)"}, observer.takeEvents())
+
+	// deleting an unregistered alert definition is not a mutation
+	r.del("1:a")
+	assert.Empty(t, observer.takeEvents())
+
+	r.setObserver(nil)
+	r.getOrCreateInfo(ctx, "1:b", 2, "b", 1, 1, "")
+	assert.Empty(t, observer.takeEvents())
+}
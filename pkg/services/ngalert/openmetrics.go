@@ -0,0 +1,91 @@
+package ngalert
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+const openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// alertsMetricName is the name of the metric exposing the alert instances,
+// the same as the one of the Prometheus alerting rules.
+const alertsMetricName = "ALERTS"
+
+// writeOpenMetrics writes the pending and firing alert instances in the OpenMetrics
+// exposition format as ALERTS{alertname="...",alertstate="firing|pending",...} 1 samples.
+// The alertname and alertstate labels take precedence over the instance labels.
+func writeOpenMetrics(w io.Writer, instances []alertInstance) error {
+	lines := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		state := "firing"
+		if instance.State == eval.Pending {
+			state = "pending"
+		}
+
+		labels := make(map[string]string, len(instance.Labels)+3)
+		for name, value := range instance.Labels {
+			labels[sanitizeLabelName(name)] = value
+		}
+		labels["alertname"] = instance.DefinitionTitle
+		labels["alertstate"] = state
+		labels["alert_definition_uid"] = instance.DefinitionUID
+
+		lines = append(lines, fmt.Sprintf("%s{%s} 1\n", alertsMetricName, formatLabels(labels)))
+	}
+	// the instances are sorted so that the exposition is stable
+	sort.Strings(lines)
+
+	if _, err := fmt.Fprintf(w, "# TYPE %s gauge\n# HELP %s Alert instances currently pending or firing.\n", alertsMetricName, alertsMetricName); err != nil {
+		return err
+	}
+	for _, line := range lines {
+		if _, err := io.WriteString(w, line); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "# EOF\n")
+	return err
+}
+
+// formatLabels formats the labels ordered by name.
+func formatLabels(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, labelValueEscaper.Replace(labels[name])))
+	}
+	return strings.Join(pairs, ",")
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// sanitizeLabelName replaces the characters not allowed in a label name by underscores.
+func sanitizeLabelName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z'):
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteRune('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
@@ -0,0 +1,40 @@
+package ngalert
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteOpenMetrics(t *testing.T) {
+	st := newStateTracker(clock.NewMock())
+
+	cpu := &AlertDefinition{OrgID: 1, UID: "cpu", Title: "high cpu"}
+	memory := &AlertDefinition{OrgID: 1, UID: "memory", Title: `memory "usage"`, For: time.Minute}
+	st.setResults(getKey(cpu), cpu, eval.Results{
+		{Instance: data.Labels{"host": "a", "1st-label": "x"}, State: eval.Alerting},
+		{Instance: data.Labels{"host": "b"}, State: eval.Normal},
+	})
+	st.setResults(getKey(memory), memory, eval.Results{
+		{Instance: data.Labels{"host": "a", "alertstate": "overridden"}, State: eval.Alerting},
+	})
+
+	var buf bytes.Buffer
+	require.NoError(t, writeOpenMetrics(&buf, st.active(1)))
+	assert.Equal(t, `# TYPE ALERTS gauge
+# HELP ALERTS Alert instances currently pending or firing.
+ALERTS{_1st_label="x",alert_definition_uid="cpu",alertname="high cpu",alertstate="firing",host="a"} 1
+ALERTS{alert_definition_uid="memory",alertname="memory \"usage\"",alertstate="pending",host="a"} 1
+# EOF
+`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, writeOpenMetrics(&buf, st.active(2)))
+	assert.Equal(t, "# TYPE ALERTS gauge\n# HELP ALERTS Alert instances currently pending or firing.\n# EOF\n", buf.String())
+}
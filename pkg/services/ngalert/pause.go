@@ -0,0 +1,104 @@
+package ngalert
+
+import (
+	"errors"
+	"sync"
+)
+
+var errEmptyLabelSelector = errors.New("label selector should not be empty")
+
+// labelPauses are the alert definitions paused by their labels; they are not dispatched.
+// Like folderPauses the alert definitions are not disabled: their routines and
+// the state of their alert instances are kept, and the pauses are not persisted.
+// The selectors are not kept: the alert definitions matching a selector when
+// it's applied are paused, and relabelling them doesn't change their pause.
+type labelPauses struct {
+	mu sync.RWMutex
+	// paused are indexed by the key of the alert definition
+	paused map[string]struct{}
+}
+
+func newLabelPauses() *labelPauses {
+	return &labelPauses{paused: make(map[string]struct{})}
+}
+
+// set pauses or unpauses the alert definitions and returns the number of them whose pause changed.
+func (p *labelPauses) set(keys []string, paused bool) int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var changed int64
+	for _, key := range keys {
+		if _, ok := p.paused[key]; ok == paused {
+			continue
+		}
+		if paused {
+			p.paused[key] = struct{}{}
+		} else {
+			delete(p.paused, key)
+		}
+		changed++
+	}
+	return changed
+}
+
+func (p *labelPauses) isPaused(key string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	_, ok := p.paused[key]
+	return ok
+}
+
+// PauseByLabels pauses the alert definitions of the organisation
+// whose labels match all the labels of the selector, e.g. all the alert definitions of a team.
+// It returns the number of alert definitions paused.
+// The scheduler stops dispatching their evaluations on its next tick.
+// Only the alert definitions matching the selector when it's called are paused:
+// the alert definitions created or labelled afterwards are not, until it's called again.
+func (ng *AlertNG) PauseByLabels(orgID int64, selector map[string]string) (int64, error) {
+	return ng.setPausedByLabels(orgID, selector, true)
+}
+
+// UnpauseByLabels unpauses the alert definitions of the organisation
+// whose labels match all the labels of the selector.
+// The disabled alert definitions stay disabled.
+// It returns the number of alert definitions unpaused.
+// The scheduler resumes dispatching their evaluations on its next tick.
+func (ng *AlertNG) UnpauseByLabels(orgID int64, selector map[string]string) (int64, error) {
+	return ng.setPausedByLabels(orgID, selector, false)
+}
+
+func (ng *AlertNG) setPausedByLabels(orgID int64, selector map[string]string, paused bool) (int64, error) {
+	// an empty selector would match all the alert definitions of the organisation
+	if len(selector) == 0 {
+		return 0, errEmptyLabelSelector
+	}
+
+	q := listAlertDefinitionsByLabelsQuery{OrgID: orgID, Selector: selector}
+	if err := ng.getAlertDefinitionsByLabels(&q); err != nil {
+		return 0, err
+	}
+	keys := make([]string, 0, len(q.Result))
+	for _, alertDefinition := range q.Result {
+		keys = append(keys, ng.schedule.keyFunc(alertDefinition))
+	}
+	count := ng.schedule.labelPauses.set(keys, paused)
+
+	ng.log.Info("alert definitions paused by labels", "orgID", orgID, "selector", selector, "paused", paused, "count", count)
+	action := AuditDefinitionsPaused
+	if !paused {
+		action = AuditDefinitionsUnpaused
+	}
+	ng.schedule.audit.record(action, "", 0, 0, "orgID", orgID, "selector", selector, "count", count)
+	return count, nil
+}
+
+// matchesLabelSelector returns true if the labels have all the labels of the selector.
+func matchesLabelSelector(labels map[string]string, selector map[string]string) bool {
+	for k, v := range selector {
+		if value, ok := labels[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
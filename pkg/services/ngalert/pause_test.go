@@ -0,0 +1,115 @@
+package ngalert
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPauseByLabels(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	var evaluated []int64
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), func(alertDefID int64, _ time.Time) {
+		evaluated = append(evaluated, alertDefID)
+	})
+	ng.schedule.synchronous = true
+
+	createLabeled := func(labels map[string]string) *AlertDefinition {
+		alertDefinition := createTestAlertDefinition(t, ng, 1)
+		err := ng.updateAlertDefinition(&updateAlertDefinitionCommand{
+			ID:     alertDefinition.ID,
+			OrgID:  alertDefinition.OrgID,
+			Labels: labels,
+		})
+		require.NoError(t, err)
+		return alertDefinition
+	}
+	first := createLabeled(map[string]string{"team": "x", "service": "api"})
+	second := createLabeled(map[string]string{"team": "x", "service": "db"})
+	third := createLabeled(map[string]string{"team": "y", "service": "api"})
+
+	// the disabled alert definition of the team should stay disabled once the team is unpaused
+	disabled := createLabeled(map[string]string{"team": "x", "service": "cache"})
+	enabled := false
+	require.NoError(t, ng.updateAlertDefinition(&updateAlertDefinitionCommand{ID: disabled.ID, OrgID: disabled.OrgID, Enabled: &enabled}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	var summary TickSummary
+	ng.schedule.onTick = func(s TickSummary) {
+		summary = s
+	}
+	var tickNum int64
+	tick := func() []int64 {
+		evaluated = nil
+		tickNum++
+		require.NoError(t, ng.tickSynchronously(ctx, time.Unix(tickNum, 0)))
+		sort.Slice(evaluated, func(i, j int) bool { return evaluated[i] < evaluated[j] })
+		return evaluated
+	}
+
+	assert.Equal(t, []int64{first.ID, second.ID, third.ID}, tick())
+
+	t.Run("pausing by the team label should only pause the alert definitions of the team", func(t *testing.T) {
+		paused, err := ng.PauseByLabels(1, map[string]string{"team": "x"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), paused)
+
+		assert.Equal(t, []int64{third.ID}, tick())
+		assert.Equal(t, 2, summary.Skipped[SkipLabelsPaused])
+
+		// the paused alert definitions are kept idle rather than disabled
+		assert.True(t, ng.schedule.registry.exists(getKey(first)))
+		assert.True(t, ng.schedule.registry.exists(getKey(second)))
+		q := getAlertDefinitionByIDQuery{ID: first.ID}
+		require.NoError(t, ng.getAlertDefinitionByID(&q))
+		assert.True(t, q.Result.Enabled)
+	})
+
+	t.Run("unpausing by the team label should resume the alert definitions of the team", func(t *testing.T) {
+		unpaused, err := ng.UnpauseByLabels(1, map[string]string{"team": "x"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), unpaused)
+
+		assert.Equal(t, []int64{first.ID, second.ID, third.ID}, tick())
+		assert.Equal(t, 1, summary.Skipped[SkipDisabled])
+		q := getAlertDefinitionByIDQuery{ID: disabled.ID}
+		require.NoError(t, ng.getAlertDefinitionByID(&q))
+		assert.False(t, q.Result.Enabled)
+	})
+
+	t.Run("an empty selector should be rejected", func(t *testing.T) {
+		_, err := ng.PauseByLabels(1, nil)
+		assert.True(t, errors.Is(err, errEmptyLabelSelector))
+	})
+	t.Run("the alert definitions labelled after the pause should not be paused", func(t *testing.T) {
+		paused, err := ng.PauseByLabels(1, map[string]string{"team": "y"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), paused)
+		assert.Equal(t, []int64{first.ID, second.ID}, tick())
+
+		// the selector is applied once rather than on every tick
+		fourth := createLabeled(map[string]string{"team": "y", "service": "queue"})
+		assert.Equal(t, []int64{first.ID, second.ID, fourth.ID}, tick())
+
+		// the pause keeps the alert definitions paused when their labels change
+		require.NoError(t, ng.updateAlertDefinition(&updateAlertDefinitionCommand{ID: third.ID, OrgID: third.OrgID, Labels: map[string]string{"team": "z"}}))
+		assert.Equal(t, []int64{first.ID, second.ID, fourth.ID}, tick())
+
+		paused, err = ng.PauseByLabels(1, map[string]string{"team": "y"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), paused, "pausing again should pause the newly labelled alert definitions")
+		assert.Equal(t, []int64{first.ID, second.ID}, tick())
+	})
+}
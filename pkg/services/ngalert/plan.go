@@ -0,0 +1,86 @@
+package ngalert
+
+import (
+	"sort"
+	"time"
+)
+
+// evalTiming is how an alert definition is scheduled.
+type evalTiming struct {
+	intervalSeconds    int64
+	maxIntervalSeconds int64
+	alignmentOffset    time.Duration
+	folderUID          string
+}
+
+// PlannedEval is an evaluation the scheduler is expected to dispatch.
+type PlannedEval struct {
+	Key          string    `json:"key"`
+	DefinitionID int64     `json:"definitionId"`
+	At           time.Time `json:"at"`
+}
+
+// Plan returns the evaluations that will be dispatched in the window starting at from,
+// ordered by time, given the alert definitions registered as of the last tick
+// with their intervals, alignment offsets, adaptive intervals and boosts, and the spread of the evaluations.
+// The dispatch jitter and the startup delay of the new routines are random and not accounted for.
+func (sch *schedule) Plan(from time.Time, window time.Duration) []PlannedEval {
+	baseSeconds := int64(sch.baseInterval.Seconds())
+	infos := sch.registry.snapshot()
+	keys := make([]string, 0, len(infos))
+	frequencies := make(map[string]int64, len(infos))
+	for key, info := range infos {
+		timing := info.timing
+		if timing.intervalSeconds == 0 || timing.intervalSeconds%baseSeconds != 0 {
+			continue
+		}
+		if sch.folderPauses.isPaused(info.orgID, timing.folderUID) || sch.labelPauses.isPaused(definitionKeyOf(key, info.templateValue)) {
+			continue
+		}
+		frequency := timing.intervalSeconds / baseSeconds
+		if def := (&AlertDefinition{IntervalSeconds: timing.intervalSeconds, MaxIntervalSeconds: timing.maxIntervalSeconds}); def.hasAdaptiveInterval() {
+			frequency *= info.adaptive.factorFor(def)
+		}
+		keys = append(keys, key)
+		frequencies[key] = frequency
+	}
+	sort.Strings(keys)
+
+	// the first tick at or after from
+	tick := sch.tickOrigin
+	if from.After(tick) {
+		tick = tick.Add(from.Sub(tick) / sch.baseInterval * sch.baseInterval)
+		if tick.Before(from) {
+			tick = tick.Add(sch.baseInterval)
+		}
+	}
+
+	var plan []PlannedEval
+	end := from.Add(window)
+	for ; tick.Before(end); tick = tick.Add(sch.baseInterval) {
+		tickNum := tick.Unix() / baseSeconds
+		var due []string
+		for _, key := range keys {
+			frequency := frequencies[key]
+			if interval, ok := sch.boosts.intervalAt(definitionKeyOf(key, infos[key].templateValue), tick); ok {
+				frequency = int64(interval / sch.baseInterval)
+			}
+			frequency *= sch.quarantines.frequencyFactor(key)
+			if sch.isDue(tickNum, frequency, infos[key].timing.alignmentOffset) {
+				due = append(due, key)
+			}
+		}
+		if len(due) == 0 {
+			continue
+		}
+		step := sch.dispatchSpread().Nanoseconds() / int64(len(due))
+		for i, key := range due {
+			plan = append(plan, PlannedEval{
+				Key:          key,
+				DefinitionID: infos[key].definitionID,
+				At:           tick.Add(sch.capDispatchOffset(time.Duration(int64(i) * step))),
+			})
+		}
+	}
+	return plan
+}
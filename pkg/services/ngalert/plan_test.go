@@ -0,0 +1,74 @@
+package ngalert
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedulePlan(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+
+	alertDefinition := createTestAlertDefinition(t, ng, 60)
+
+	evalAppliedCh := make(chan evalAppliedInfo, 1)
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		_ = ng.alertingTicker(ctx)
+	}()
+	runtime.Gosched()
+
+	tick := advanceClock(t, mockedClock)
+	assertEvalRun(t, evalAppliedCh, tick)
+
+	plan := ng.schedule.Plan(mockedClock.Now(), 5*time.Minute)
+	require.Len(t, plan, 5)
+	for i, planned := range plan {
+		assert.Equal(t, getKey(alertDefinition), planned.Key)
+		assert.Equal(t, alertDefinition.ID, planned.DefinitionID)
+		assert.Equal(t, time.Unix(int64(i+1)*60, 0).UTC(), planned.At.UTC())
+	}
+}
+
+func TestSchedulePlanSpread(t *testing.T) {
+	sch := newScheduler(clock.NewMock(), 10*time.Second, log.New("ngalert.schedule.test"), nil)
+	for i, key := range []string{"1:a", "1:b"} {
+		sch.registry.getOrCreateInfo(context.Background(), key, int64(i+1), key, 1, 1, "")
+		sch.registry.setTiming(key, evalTiming{intervalSeconds: 20})
+	}
+	// registered routines the ticker has not scheduled yet are not planned
+	sch.registry.getOrCreateInfo(context.Background(), "1:c", 3, "c", 1, 1, "")
+
+	plan := sch.Plan(time.Unix(0, 0), 40*time.Second)
+	require.Len(t, plan, 4)
+	assert.Equal(t, []PlannedEval{
+		{Key: "1:a", DefinitionID: 1, At: time.Unix(0, 0)},
+		{Key: "1:b", DefinitionID: 2, At: time.Unix(5, 0)},
+		{Key: "1:a", DefinitionID: 1, At: time.Unix(20, 0)},
+		{Key: "1:b", DefinitionID: 2, At: time.Unix(25, 0)},
+	}, plan)
+
+	// the alert definitions of the paused folders are not planned
+	sch.registry.getOrCreateInfo(context.Background(), "1:d", 4, "d", 1, 1, "")
+	sch.registry.setTiming("1:d", evalTiming{intervalSeconds: 10, folderUID: "paused"})
+	require.NoError(t, sch.pauseFolder(1, "paused"))
+	assert.Len(t, sch.Plan(time.Unix(0, 0), 40*time.Second), 4)
+	require.NoError(t, sch.unpauseFolder(1, "paused"))
+	assert.Len(t, sch.Plan(time.Unix(0, 0), 40*time.Second), 8)
+}
@@ -0,0 +1,66 @@
+package ngalert
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+// definitionLocks coordinate the evaluations of the same alert definition:
+// the live evaluations are exclusive while the previews can run concurrently.
+type definitionLocks struct {
+	mu sync.Mutex
+	// locks are indexed by the alert definition ID
+	locks map[int64]*sync.RWMutex
+}
+
+func newDefinitionLocks() *definitionLocks {
+	return &definitionLocks{locks: make(map[int64]*sync.RWMutex)}
+}
+
+func (l *definitionLocks) get(definitionID int64) *sync.RWMutex {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lock, ok := l.locks[definitionID]
+	if !ok {
+		lock = &sync.RWMutex{}
+		l.locks[definitionID] = lock
+	}
+	return lock
+}
+
+// del removes the lock of a deleted alert definition.
+// The lock is removed whichever way the alert definition was deleted:
+// once it's no longer fetched, its routine is stopped and its lock removed.
+func (l *definitionLocks) del(definitionID int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.locks, definitionID)
+}
+
+// EvalDefinitionNow evaluates the condition of the alert definition for previewing it.
+// It never overlaps with a live evaluation of the alert definition
+// and never updates the state of its alert instances.
+// It's evaluated by the preview evaluator: it's neither rate limited nor served from the cache.
+// The evaluation is cancelled when the context is done, e.g. when the client disconnects.
+func (ng *AlertNG) EvalDefinitionNow(ctx context.Context, alertDefinitionID int64, condition *eval.Condition, now time.Time) (eval.Results, error) {
+	lock := ng.schedule.definitionLocks.get(alertDefinitionID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	// the client may have disconnected while waiting for the live evaluation
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return ng.schedule.previewEvaluator.ConditionEval(ng.schedule.externalValues.context(ctx), condition, now)
+}
+
+// evalConditionNow evaluates a condition that does not belong to any alert definition for previewing it.
+// The evaluation is cancelled when the context is done, e.g. when the client disconnects.
+func (ng *AlertNG) evalConditionNow(ctx context.Context, condition *eval.Condition, now time.Time) (eval.Results, error) {
+	return ng.schedule.previewEvaluator.ConditionEval(ng.schedule.externalValues.context(ctx), condition, now)
+}
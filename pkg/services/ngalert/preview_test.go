@@ -0,0 +1,213 @@
+package ngalert
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvalDefinitionNowDuringScheduledEvaluation(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+
+	// the evaluator reports whether a live evaluation overlapped with a preview;
+	// the previews are evaluated at previewTime and the value of the results is the evaluation time
+	previewTime := time.Unix(1000, 0)
+	var liveInFlight, previewInFlight, overlaps int32
+	evaluator := eval.EvaluatorFunc(func(_ context.Context, _ *eval.Condition, now time.Time) (eval.Results, error) {
+		self, other := &liveInFlight, &previewInFlight
+		if now.Equal(previewTime) {
+			self, other = &previewInFlight, &liveInFlight
+		}
+		atomic.AddInt32(self, 1)
+		defer atomic.AddInt32(self, -1)
+		if atomic.LoadInt32(other) > 0 {
+			atomic.AddInt32(&overlaps, 1)
+		}
+		time.Sleep(10 * time.Millisecond)
+		return eval.Results{{Instance: data.Labels{"host": "a"}, State: eval.Alerting, Value: float64(now.Unix())}}, nil
+	})
+	ng.schedule.evaluator = evaluator
+	ng.schedule.previewEvaluator = evaluator
+
+	alert := createTestAlertDefinition(t, ng, 1)
+	key := getKey(alert)
+
+	evalAppliedCh := make(chan evalAppliedInfo, 1)
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	ctx := context.Background()
+	go func() {
+		err := ng.alertingTicker(ctx)
+		require.NoError(t, err)
+	}()
+	runtime.Gosched()
+
+	condition := alert.getCondition()
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				results, err := ng.EvalDefinitionNow(ctx, alert.ID, &condition, previewTime)
+				require.NoError(t, err)
+				require.Len(t, results, 1)
+			}
+		}()
+	}
+
+	var tick time.Time
+	for i := 0; i < 3; i++ {
+		tick = advanceClock(t, mockedClock)
+		assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+	}
+	close(done)
+	wg.Wait()
+
+	assert.Zero(t, atomic.LoadInt32(&overlaps), "a preview overlapped with a live evaluation")
+	instances := ng.schedule.stateTracker.get(key)
+	require.Len(t, instances, 1)
+	assert.Equal(t, float64(tick.Unix()), instances[0].Value, "the previews should not update the live state")
+	assert.Equal(t, mockedClock.Now(), instances[0].LastEvaluatedAt)
+}
+
+func TestEvalDefinitionNowCancelled(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	ng.schedule = newScheduler(clock.NewMock(), time.Second, log.New("ngalert.schedule.test"), nil)
+
+	started := make(chan struct{})
+	var calls int32
+	ng.schedule.previewEvaluator = eval.EvaluatorFunc(func(ctx context.Context, _ *eval.Condition, _ time.Time) (eval.Results, error) {
+		atomic.AddInt32(&calls, 1)
+		close(started)
+		// the evaluator blocks like a slow datasource until the evaluation is cancelled
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	alert := createTestAlertDefinition(t, ng, 1)
+	condition := alert.getCondition()
+
+	t.Run("cancelling the context mid-evaluation should stop the evaluation", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		errCh := make(chan error, 1)
+		go func() {
+			_, err := ng.EvalDefinitionNow(ctx, alert.ID, &condition, time.Now())
+			errCh <- err
+		}()
+
+		<-started
+		cancel()
+		select {
+		case err := <-errCh:
+			assert.True(t, errors.Is(err, context.Canceled))
+		case <-time.After(time.Second):
+			t.Fatal("the evaluation did not return after the context was cancelled")
+		}
+	})
+
+	t.Run("a cancelled context should not be evaluated", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := ng.EvalDefinitionNow(ctx, alert.ID, &condition, time.Now())
+		assert.True(t, errors.Is(err, context.Canceled))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+}
+
+func TestEvalDefinitionNowPreviewEvaluator(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	ng.schedule = newScheduler(clock.NewMock(), time.Second, log.New("ngalert.schedule.test"), nil)
+	// the scheduler evaluator is exhausted: the previews must not depend on it
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		return nil, eval.ErrRateLimited
+	})
+	ng.schedule.previewEvaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		return eval.Results{{State: eval.Alerting}}, nil
+	})
+
+	alert := createTestAlertDefinition(t, ng, 1)
+	condition := alert.getCondition()
+
+	results, err := ng.EvalDefinitionNow(context.Background(), alert.ID, &condition, time.Now())
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, eval.Alerting, results[0].State)
+
+	results, err = ng.evalConditionNow(context.Background(), &condition, time.Now())
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, eval.Alerting, results[0].State)
+}
+
+func TestDefinitionLocksReleased(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	ng.schedule = newScheduler(clock.NewMock(), time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.synchronous = true
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		return eval.Results{{State: eval.Normal}}, nil
+	})
+
+	hasLock := func(definitionID int64) bool {
+		ng.schedule.definitionLocks.mu.Lock()
+		defer ng.schedule.definitionLocks.mu.Unlock()
+		_, ok := ng.schedule.definitionLocks.locks[definitionID]
+		return ok
+	}
+
+	t.Run("the lock should be released when the alert definition is deleted from the store", func(t *testing.T) {
+		alert := createTestAlertDefinition(t, ng, 1)
+		ng.schedule.definitionLocks.get(alert.ID)
+
+		err := ng.deleteAlertDefinitionByID(&deleteAlertDefinitionByIDCommand{ID: alert.ID, OrgID: alert.OrgID})
+		require.NoError(t, err)
+		assert.False(t, hasLock(alert.ID))
+	})
+
+	t.Run("the lock should be released when the alert definition is no longer fetched", func(t *testing.T) {
+		alertDefinition := &AlertDefinition{ID: 100, OrgID: 1, UID: "uid", Condition: "A", IntervalSeconds: 1, Version: 1, Enabled: true}
+		store := newInMemoryDefinitionStore()
+		store.add(alertDefinition)
+		ng.SetDefinitionStore(store)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		require.NoError(t, ng.tickSynchronously(ctx, time.Unix(1, 0)))
+		require.True(t, hasLock(alertDefinition.ID), "the live evaluation should take the lock")
+
+		// e.g. the organisation of the alert definition is deleted
+		store.remove(alertDefinition.OrgID, alertDefinition.UID)
+		require.NoError(t, ng.tickSynchronously(ctx, time.Unix(2, 0)))
+		assert.False(t, hasLock(alertDefinition.ID))
+	})
+}
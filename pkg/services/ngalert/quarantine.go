@@ -0,0 +1,122 @@
+package ngalert
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+const (
+	// defaultQuarantineThreshold is the default number of consecutive failed evaluations
+	// after which an alert definition is quarantined.
+	defaultQuarantineThreshold = 5
+	// defaultQuarantineFactor is the default factor the interval of a quarantined alert definition is multiplied by.
+	defaultQuarantineFactor = 10
+)
+
+// errEvaluationPanicked is the error of an evaluation attempt that panicked.
+var errEvaluationPanicked = errors.New("alert definition evaluation panicked")
+
+// quarantines track the consecutive failed evaluations of the alert definition routines by key.
+// A routine whose evaluations have failed threshold times in a row is quarantined:
+// it is dispatched factor times less often until one of its evaluations succeeds,
+// so that a crash-looping alert definition does not waste the evaluation slots and flood the logs.
+// A threshold of 0 disables the quarantine.
+type quarantines struct {
+	mu          sync.Mutex
+	threshold   int
+	factor      int64
+	failures    map[string]int
+	quarantined map[string]struct{}
+}
+
+func newQuarantines(threshold int, factor int64) *quarantines {
+	if factor < 1 {
+		factor = 1
+	}
+	return &quarantines{
+		threshold:   threshold,
+		factor:      factor,
+		failures:    make(map[string]int),
+		quarantined: make(map[string]struct{}),
+	}
+}
+
+// failed records a failed evaluation of the routine
+// and returns true if the routine has just been quarantined.
+func (q *quarantines) failed(key string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.failures[key]++
+	if _, ok := q.quarantined[key]; ok || q.threshold <= 0 || q.failures[key] < q.threshold {
+		return false
+	}
+	q.quarantined[key] = struct{}{}
+	definitionsQuarantined.Set(float64(len(q.quarantined)))
+	return true
+}
+
+// succeeded resets the failed evaluations of the routine
+// and returns true if the routine has just been released from quarantine.
+func (q *quarantines) succeeded(key string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.failures, key)
+	_, ok := q.quarantined[key]
+	if ok {
+		delete(q.quarantined, key)
+		definitionsQuarantined.Set(float64(len(q.quarantined)))
+	}
+	return ok
+}
+
+// frequencyFactor returns the factor the frequency of the routine is multiplied by: 1 unless it's quarantined.
+func (q *quarantines) frequencyFactor(key string) int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.quarantined[key]; ok {
+		return q.factor
+	}
+	return 1
+}
+
+// del forgets the routine of a deleted alert definition.
+func (q *quarantines) del(key string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.failures, key)
+	if _, ok := q.quarantined[key]; ok {
+		delete(q.quarantined, key)
+		definitionsQuarantined.Set(float64(len(q.quarantined)))
+	}
+}
+
+// quarantinedInstance returns the instance of the quarantined event of the alert definition routine
+// last evaluated successfully at lastSuccess, like the stale one.
+// It has no labels if the alert definition could not be fetched.
+func quarantinedInstance(key string, definitionInfo alertDefinitionInfo, alertDefinition *AlertDefinition, lastSuccess time.Time) alertInstance {
+	if alertDefinition != nil {
+		return staleInstance(key, alertDefinition, lastSuccess)
+	}
+	return alertInstance{
+		DefinitionKey:   key,
+		OrgID:           definitionInfo.orgID,
+		DefinitionUID:   definitionInfo.uid,
+		State:           eval.Error,
+		LastEvaluatedAt: lastSuccess,
+	}
+}
+
+// evaluateRecovering runs the evaluation attempt and turns its panic, if any, into an error
+// so that a crash-looping alert definition fails like any other and does not crash the instance.
+func evaluateRecovering(evaluate func(attempt int64) error, attempt int64) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", errEvaluationPanicked, r)
+		}
+	}()
+	return evaluate(attempt)
+}
@@ -0,0 +1,85 @@
+package ngalert
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuarantineCrashLoopingDefinition(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.synchronous = true
+	ng.schedule.setMaxAttempts(1)
+	ng.schedule.quarantines = newQuarantines(3, 4)
+
+	// the evaluations panic until healed
+	healed := false
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		if !healed {
+			panic("crash")
+		}
+		return eval.Results{{Instance: data.Labels{"host": "a"}, State: eval.Normal}}, nil
+	})
+
+	alertDefinition := &AlertDefinition{ID: 1, OrgID: 1, UID: "crashing", Title: "crashing", Condition: "A", IntervalSeconds: 1, Version: 1, Enabled: true}
+	store := newInMemoryDefinitionStore()
+	store.add(alertDefinition)
+	ng.SetDefinitionStore(store)
+	ng.schedule.fetchDefinitions = func(time.Time) []*AlertDefinition {
+		return []*AlertDefinition{alertDefinition}
+	}
+
+	var evaluated []int64
+	ng.schedule.evalApplied = func(_ int64, now time.Time) {
+		evaluated = append(evaluated, now.Unix())
+	}
+	events, unsubscribe := ng.schedule.subscribers.subscribe(10)
+	defer unsubscribe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	tick := func(from, to int64) {
+		for tick := from; tick <= to; tick++ {
+			require.NoError(t, ng.tickSynchronously(ctx, time.Unix(tick, 0)))
+		}
+	}
+
+	// the alert definition is quarantined after the third failed evaluation
+	// and then evaluated every 4 ticks
+	tick(1, 9)
+	assert.Equal(t, []int64{1, 2, 3, 4, 8}, evaluated)
+	assert.Equal(t, int64(4), ng.schedule.quarantines.frequencyFactor(getKey(alertDefinition)))
+	require.Len(t, events, 1)
+	event := <-events
+	assert.True(t, event.Quarantined)
+	assert.Equal(t, "crashing", event.Instance.DefinitionUID)
+	assert.Equal(t, 1.0, testutil.ToFloat64(definitionsQuarantined))
+
+	// the alert definition is released after a successful evaluation
+	healed = true
+	tick(10, 14)
+	assert.Equal(t, []int64{1, 2, 3, 4, 8, 12, 13, 14}, evaluated)
+	assert.Equal(t, int64(1), ng.schedule.quarantines.frequencyFactor(getKey(alertDefinition)))
+	assert.Equal(t, 0.0, testutil.ToFloat64(definitionsQuarantined))
+}
+
+func TestQuarantinesDisabled(t *testing.T) {
+	q := newQuarantines(0, defaultQuarantineFactor)
+	for i := 0; i < 100; i++ {
+		assert.False(t, q.failed("key"))
+	}
+	assert.Equal(t, int64(1), q.frequencyFactor("key"))
+}
@@ -0,0 +1,11 @@
+package ngalert
+
+// RefreshAll makes the scheduled alert definitions of the organisation be refetched
+// on their next evaluation whatever their version, e.g. after they have been changed
+// in the store by a migration that did not bump their version.
+// It returns the number of alert definitions to be refetched.
+func (ng *AlertNG) RefreshAll(orgID int64) int {
+	n := ng.schedule.registry.refresh(orgID)
+	ng.log.Info("alert definitions refresh requested", "orgID", orgID, "count", n)
+	return n
+}
@@ -0,0 +1,71 @@
+package ngalert
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshAll(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		return eval.Results{{Instance: data.Labels{"host": "a"}, State: eval.Alerting}}, nil
+	})
+
+	alert := createTestAlertDefinition(t, ng, 1)
+	key := getKey(alert)
+
+	evalAppliedCh := make(chan evalAppliedInfo, 1)
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		_ = ng.alertingTicker(ctx)
+	}()
+	runtime.Gosched()
+
+	title := func() string {
+		instances := ng.schedule.stateTracker.get(key)
+		require.Len(t, instances, 1)
+		return instances[0].DefinitionTitle
+	}
+
+	tick := advanceClock(t, mockedClock)
+	assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+	require.Equal(t, alert.Title, title())
+
+	// the alert definition is changed in the store without a version bump
+	err := ng.SQLStore.WithTransactionalDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		_, err := sess.Exec("UPDATE alert_definition SET title = ? WHERE id = ?", "migrated", alert.ID)
+		return err
+	})
+	require.NoError(t, err)
+
+	tick = advanceClock(t, mockedClock)
+	assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+	require.Equal(t, alert.Title, title(), "the routine should not refetch an alert definition with the same version")
+
+	assert.Equal(t, 1, ng.RefreshAll(alert.OrgID))
+	assert.Equal(t, 0, ng.RefreshAll(alert.OrgID+1))
+
+	tick = advanceClock(t, mockedClock)
+	assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+	assert.Equal(t, "migrated", title())
+}
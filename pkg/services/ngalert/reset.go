@@ -0,0 +1,27 @@
+package ngalert
+
+// ResetState clears the state of the alert instances of the alert definition,
+// including their pending and firing times, so that it's evaluated from scratch.
+// The persisted state of its routines is cleared too so that it's not restored once they restart.
+// It's safe to call while the alert definition is being evaluated:
+// an evaluation completing after the reset starts from the initial state.
+func (ng *AlertNG) ResetState(uid string, orgID int64) {
+	resetKeys, removed := ng.schedule.stateTracker.reset(orgID, uid)
+	ng.log.Info("alert definition state reset", "uid", uid, "orgID", orgID, "instances", removed)
+
+	// the routines that have not loaded their persisted state yet have no instances to reset
+	keys := make(map[string]struct{}, len(resetKeys))
+	for _, key := range resetKeys {
+		keys[key] = struct{}{}
+	}
+	for key, info := range ng.schedule.registry.snapshot() {
+		if info.orgID == orgID && info.uid == uid {
+			keys[key] = struct{}{}
+		}
+	}
+	for key := range keys {
+		if err := ng.definitionStore().SaveState(key, nil); err != nil {
+			ng.log.Error("failed to clear the persisted state of the alert instances", "key", key, "error", err)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package ngalert
+
+import (
+	"context"
+	"time"
+)
+
+const defaultVersionCleanupInterval = time.Hour
+
+// versionRetention is the retention policy of the alert definition versions.
+// The zero value keeps all the versions.
+type versionRetention struct {
+	// maxAge if positive is the age after which a version is deleted.
+	maxAge time.Duration
+	// maxCount if positive is the number of versions kept per alert definition.
+	maxCount int64
+	// interval is the time between two cleanups.
+	interval time.Duration
+}
+
+func (r versionRetention) isEnabled() bool {
+	return r.maxAge > 0 || r.maxCount > 0
+}
+
+// versionCleanup periodically deletes the alert definition versions
+// exceeding the retention policy until the context is cancelled.
+// It runs independently of the ticker so that a slow cleanup does not delay the evaluations.
+func (ng *AlertNG) versionCleanup(ctx context.Context) error {
+	if !ng.versionRetention.isEnabled() {
+		return nil
+	}
+
+	interval := ng.versionRetention.interval
+	if interval <= 0 {
+		interval = defaultVersionCleanupInterval
+	}
+
+	for {
+		select {
+		case <-ng.schedule.clock.After(interval):
+			cmd := deleteExpiredAlertDefinitionVersionsCommand{MaxCount: ng.versionRetention.maxCount}
+			if ng.versionRetention.maxAge > 0 {
+				cmd.CreatedBefore = ng.schedule.clock.Now().Add(-ng.versionRetention.maxAge)
+			}
+			if err := ng.deleteExpiredAlertDefinitionVersions(&cmd); err != nil {
+				ng.log.Error("failed to delete expired alert definition versions", "error", err)
+				continue
+			}
+			ng.log.Debug("expired alert definition versions deleted", "count", cmd.RowsAffected)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package ngalert
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionCleanup(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.versionRetention = versionRetention{maxAge: 24 * time.Hour, interval: time.Minute}
+
+	alert := createTestAlertDefinition(t, ng, 1)
+	for i := 0; i < 3; i++ {
+		err := ng.updateAlertDefinition(&updateAlertDefinitionCommand{
+			ID:    alert.ID,
+			OrgID: alert.OrgID,
+			Title: "updated title",
+		})
+		require.NoError(t, err)
+	}
+
+	versions := func() []int64 {
+		var versions []int64
+		err := ng.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+			return sess.SQL("SELECT version FROM alert_definition_version WHERE alert_definition_id = ? ORDER BY version", alert.ID).Find(&versions)
+		})
+		require.NoError(t, err)
+		return versions
+	}
+	require.Equal(t, []int64{1, 2, 3, 4}, versions())
+
+	// versions 1 and 2 are older than the retention window
+	err := ng.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		if _, err := sess.Exec("UPDATE alert_definition_version SET created = ? WHERE version <= 2", mockedClock.Now().Add(-48*time.Hour)); err != nil {
+			return err
+		}
+		_, err := sess.Exec("UPDATE alert_definition_version SET created = ? WHERE version > 2", mockedClock.Now())
+		return err
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- ng.versionCleanup(ctx)
+	}()
+	runtime.Gosched()
+
+	mockedClock.Add(time.Minute)
+	require.Eventually(t, func() bool {
+		return len(versions()) == 2
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []int64{3, 4}, versions())
+
+	cancel()
+	require.NoError(t, <-done)
+
+	// the current version is kept whatever the retention
+	cmd := deleteExpiredAlertDefinitionVersionsCommand{
+		CreatedBefore: mockedClock.Now().Add(time.Hour),
+		MaxCount:      1,
+	}
+	err = ng.deleteExpiredAlertDefinitionVersions(&cmd)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), cmd.RowsAffected)
+	assert.Equal(t, []int64{4}, versions())
+}
@@ -0,0 +1,94 @@
+package ngalert
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// shardRing assigns alert definition keys to scheduler instances.
+type shardRing interface {
+	// owner returns the instance that owns the key.
+	owner(key string) string
+}
+
+// defaultRingReplicas is the number of virtual nodes per instance.
+const defaultRingReplicas = 128
+
+// consistentHashRing is a shardRing based on consistent hashing
+// so that adding or removing an instance moves only a fraction of the keys.
+type consistentHashRing struct {
+	mu       sync.RWMutex
+	replicas int
+	hashes   []uint32
+	nodes    map[uint32]string
+}
+
+func newConsistentHashRing(replicas int, nodes ...string) *consistentHashRing {
+	if replicas <= 0 {
+		replicas = defaultRingReplicas
+	}
+	r := &consistentHashRing{
+		replicas: replicas,
+		nodes:    make(map[uint32]string),
+	}
+	for _, n := range nodes {
+		r.addNode(n)
+	}
+	return r
+}
+
+func ringHash(s string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(s))
+	return h.Sum32()
+}
+
+// addNode adds an instance to the ring.
+func (r *consistentHashRing) addNode(node string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := 0; i < r.replicas; i++ {
+		h := ringHash(node + "#" + strconv.Itoa(i))
+		if _, ok := r.nodes[h]; ok {
+			continue
+		}
+		r.nodes[h] = node
+		r.hashes = append(r.hashes, h)
+	}
+	sort.Slice(r.hashes, func(i, j int) bool { return r.hashes[i] < r.hashes[j] })
+}
+
+// removeNode removes an instance from the ring.
+func (r *consistentHashRing) removeNode(node string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	hashes := r.hashes[:0]
+	for _, h := range r.hashes {
+		if r.nodes[h] == node {
+			delete(r.nodes, h)
+			continue
+		}
+		hashes = append(hashes, h)
+	}
+	r.hashes = hashes
+}
+
+func (r *consistentHashRing) owner(key string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.hashes) == 0 {
+		return ""
+	}
+
+	h := ringHash(key)
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.nodes[r.hashes[i]]
+}
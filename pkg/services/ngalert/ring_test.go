@@ -0,0 +1,57 @@
+package ngalert
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsistentHashRing(t *testing.T) {
+	ring := newConsistentHashRing(0, "instance-1", "instance-2", "instance-3")
+
+	const keysCount = 1000
+	owners := make(map[string]string, keysCount)
+	for i := 0; i < keysCount; i++ {
+		key := fmt.Sprintf("1:uid-%d", i)
+		owners[key] = ring.owner(key)
+		require.NotEmpty(t, owners[key])
+	}
+
+	ring.addNode("instance-4")
+
+	moved := 0
+	for key, previous := range owners {
+		current := ring.owner(key)
+		if current != previous {
+			// keys only move to the new instance
+			assert.Equal(t, "instance-4", current)
+			moved++
+		}
+	}
+	t.Logf("%d out of %d keys moved", moved, keysCount)
+	assert.Greater(t, moved, 0)
+	assert.Less(t, moved, keysCount/2)
+
+	ring.removeNode("instance-4")
+	for key, previous := range owners {
+		assert.Equal(t, previous, ring.owner(key))
+	}
+}
+
+func TestScheduleOwnsKey(t *testing.T) {
+	sch := newScheduler(clock.NewMock(), time.Second, log.New("ngalert.schedule.test"), nil)
+	assert.True(t, sch.ownsKey("1:uid"), "without a ring the scheduler owns every key")
+
+	ring := newConsistentHashRing(0, "instance-1", "instance-2")
+	sch.ring = ring
+	sch.instanceID = ring.owner("1:uid")
+	assert.True(t, sch.ownsKey("1:uid"))
+
+	sch.instanceID = "unknown"
+	assert.False(t, sch.ownsKey("1:uid"))
+}
@@ -0,0 +1,107 @@
+package ngalert
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+// maxResultSamples is the maximum number of evaluations of an alert definition sampled at once.
+const maxResultSamples = 10
+
+// ResultSample is an evaluation attempt of an alert definition with the raw frames
+// returned by its queries and expressions, for the post-hoc analysis of a misbehaving alert.
+type ResultSample struct {
+	EvalID int64     `json:"evalId"`
+	At     time.Time `json:"at"`
+	// TemplateValue is the value the alert definition template is expanded with, if any.
+	TemplateValue string `json:"templateValue,omitempty"`
+	// Responses are the frames of the queries and expressions by RefID;
+	// they are empty if the results were reused from the query cache.
+	Responses map[string]data.Frames `json:"responses"`
+	// Results are the results of the evaluation attempt, before the inherited labels are added.
+	Results eval.Results `json:"results"`
+	Error   string       `json:"error,omitempty"`
+}
+
+// resultSampling are the alert definitions whose evaluations are sampled.
+// The sampling of an alert definition is disabled once its number of samples is reached.
+// The samples are kept in memory until the next sampling of the alert definition.
+type resultSampling struct {
+	mu sync.Mutex
+	// remaining and samples are indexed by the key of the alert definition
+	remaining map[string]int
+	samples   map[string][]ResultSample
+}
+
+func newResultSampling() *resultSampling {
+	return &resultSampling{
+		remaining: make(map[string]int),
+		samples:   make(map[string][]ResultSample),
+	}
+}
+
+// start samples the next n evaluations of the alert definition, discarding its previous samples.
+func (s *resultSampling) start(key string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remaining[key] = n
+	s.samples[key] = make([]ResultSample, 0, n)
+}
+
+// active returns true if the evaluations of the alert definition are sampled.
+func (s *resultSampling) active(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.remaining[key] > 0
+}
+
+// record adds the sample of the alert definition unless its sampling has been disabled meanwhile.
+func (s *resultSampling) record(key string, sample ResultSample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.remaining[key] <= 0 {
+		return
+	}
+	s.samples[key] = append(s.samples[key], sample)
+	s.remaining[key]--
+	if s.remaining[key] == 0 {
+		delete(s.remaining, key)
+	}
+}
+
+func (s *resultSampling) get(key string) []ResultSample {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	samples := make([]ResultSample, len(s.samples[key]))
+	copy(samples, s.samples[key])
+	return samples
+}
+
+// SampleResults captures the raw query responses of the next n evaluation attempts of the alert definition.
+// The sampling stops once the n samples are captured.
+func (ng *AlertNG) SampleResults(orgID int64, uid string, n int) error {
+	if n <= 0 || n > maxResultSamples {
+		return fmt.Errorf("invalid number of samples: %d: it should be between 1 and %d", n, maxResultSamples)
+	}
+	key, err := ng.definitionKey(orgID, uid)
+	if err != nil {
+		return err
+	}
+	ng.schedule.resultSampling.start(key, n)
+	ng.schedule.log.Info("alert definition result sampling started", "key", key, "orgID", orgID, "uid", uid, "samples", n)
+	return nil
+}
+
+// ResultSamples returns the samples of the evaluations of the alert definition, oldest first.
+// An unknown alert definition has no samples.
+func (ng *AlertNG) ResultSamples(orgID int64, uid string) []ResultSample {
+	key, err := ng.definitionKey(orgID, uid)
+	if err != nil {
+		return nil
+	}
+	return ng.schedule.resultSampling.get(key)
+}
@@ -0,0 +1,70 @@
+package ngalert
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampleResults(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.synchronous = true
+
+	// the evaluator responds with the number of the evaluation
+	var evaluations int
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(ctx context.Context, c *eval.Condition, now time.Time) (eval.Results, error) {
+		evaluations++
+		value := float64(evaluations)
+		eval.CaptureResponse(ctx, "A", data.Frames{data.NewFrame("", data.NewField("value", nil, []*float64{&value}))})
+		return eval.Results{{Instance: data.Labels{"host": "a"}, State: eval.Normal}}, nil
+	})
+
+	alertDefinition := &AlertDefinition{ID: 1, OrgID: 1, UID: "sampled", Condition: "A", IntervalSeconds: 1, Version: 1, Enabled: true}
+	store := newInMemoryDefinitionStore()
+	store.add(alertDefinition)
+	ng.SetDefinitionStore(store)
+	ng.schedule.fetchDefinitions = func(time.Time) []*AlertDefinition {
+		return []*AlertDefinition{alertDefinition}
+	}
+
+	require.Error(t, ng.SampleResults(1, "sampled", 0))
+	require.Error(t, ng.SampleResults(1, "sampled", maxResultSamples+1))
+	require.Error(t, ng.SampleResults(1, "unknown", 1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	// the evaluation before the sampling is not captured
+	require.NoError(t, ng.tickSynchronously(ctx, time.Unix(1, 0)))
+	require.NoError(t, ng.SampleResults(1, "sampled", 2))
+	for tick := int64(2); tick <= 4; tick++ {
+		require.NoError(t, ng.tickSynchronously(ctx, time.Unix(tick, 0)))
+	}
+	require.Equal(t, 4, evaluations)
+
+	// the sampling stopped after the second sample
+	samples := ng.ResultSamples(1, "sampled")
+	require.Len(t, samples, 2)
+	for i, sample := range samples {
+		assert.Equal(t, time.Unix(int64(i+2), 0), sample.At)
+		require.Contains(t, sample.Responses, "A")
+		value, ok := sample.Responses["A"][0].Fields[0].ConcreteAt(0)
+		require.True(t, ok)
+		assert.Equal(t, float64(i+2), value)
+		assert.Len(t, sample.Results, 1)
+		assert.Empty(t, sample.Error)
+	}
+	assert.False(t, ng.schedule.resultSampling.active("1:sampled"))
+}
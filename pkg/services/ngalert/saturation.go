@@ -0,0 +1,112 @@
+package ngalert
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// defaultSaturationWindow is the default window the saturation of the evaluation slots is measured over.
+	defaultSaturationWindow = time.Minute
+	// defaultSaturationThreshold is the default ratio of saturated samples within the window
+	// beyond which the scheduler is overloaded.
+	defaultSaturationThreshold = 0.9
+)
+
+// saturationSample is the state of the evaluation slots sampled on a tick.
+type saturationSample struct {
+	at time.Time
+	// saturated is true if all the slots were in use and evaluations were waiting for one
+	saturated bool
+	waiting   int
+}
+
+// saturationMonitor samples the evaluation semaphore on every tick and reports the scheduler as overloaded
+// if the semaphore has been persistently saturated over the window: the ratio of the saturated samples
+// exceeds the threshold and the queue of the waiting evaluations has not shrunk.
+// The unlimited semaphore is never saturated.
+type saturationMonitor struct {
+	mu        sync.Mutex
+	window    time.Duration
+	threshold float64
+	// firstSample is the time of the first sample; the scheduler is not overloaded before a whole window is sampled
+	firstSample time.Time
+	samples     []saturationSample
+}
+
+func newSaturationMonitor(window time.Duration, threshold float64) *saturationMonitor {
+	return &saturationMonitor{window: window, threshold: threshold}
+}
+
+// sample records the state of the semaphore at the given time and drops the samples out of the window.
+func (m *saturationMonitor) sample(at time.Time, s *evalSemaphore) {
+	inUse, waiting := s.usage()
+	saturated := s.size > 0 && inUse >= s.size && waiting > 0
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.firstSample.IsZero() {
+		m.firstSample = at
+	}
+	m.samples = append(m.samples, saturationSample{at: at, saturated: saturated, waiting: waiting})
+	i := 0
+	for i < len(m.samples) && !m.samples[i].at.After(at.Add(-m.window)) {
+		i++
+	}
+	m.samples = m.samples[i:]
+
+	evalSaturation.Set(m.ratio())
+}
+
+// ratio returns the ratio of the saturated samples within the window.
+// It should be called with the lock held.
+func (m *saturationMonitor) ratio() float64 {
+	if len(m.samples) == 0 {
+		return 0
+	}
+	saturated := 0
+	for _, s := range m.samples {
+		if s.saturated {
+			saturated++
+		}
+	}
+	return float64(saturated) / float64(len(m.samples))
+}
+
+// overloaded returns the ratio of the saturated samples within the window
+// and whether the scheduler is overloaded at the given time.
+func (m *saturationMonitor) overloaded(now time.Time) (float64, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ratio := m.ratio()
+	if m.firstSample.IsZero() || now.Sub(m.firstSample) < m.window || len(m.samples) == 0 {
+		return ratio, false
+	}
+	first, last := m.samples[0], m.samples[len(m.samples)-1]
+	return ratio, ratio >= m.threshold && last.waiting >= first.waiting
+}
+
+// readinessReady and readinessOverloaded are the readiness statuses of the scheduler.
+const (
+	readinessReady      = "ready"
+	readinessOverloaded = "overloaded"
+)
+
+// SchedulerReadiness tells whether the instance can take more alert definitions.
+type SchedulerReadiness struct {
+	// Status is "overloaded" if the evaluation slots have been persistently saturated, "ready" otherwise.
+	Status string `json:"status"`
+	// Saturation is the ratio of the recent ticks all the evaluation slots were in use with evaluations waiting.
+	Saturation float64 `json:"saturation"`
+}
+
+// Readiness returns the readiness of the scheduler, e.g. for an autoscaler.
+func (ng *AlertNG) Readiness() SchedulerReadiness {
+	saturation, overloaded := ng.schedule.saturation.overloaded(ng.schedule.clock.Now())
+	readiness := SchedulerReadiness{Status: readinessReady, Saturation: saturation}
+	if overloaded {
+		readiness.Status = readinessOverloaded
+	}
+	return readiness
+}
@@ -0,0 +1,100 @@
+package ngalert
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// saturate takes all the slots of the semaphore and queues an evaluation waiting for one;
+// the returned function frees them.
+func saturate(t *testing.T, s *evalSemaphore) func() {
+	t.Helper()
+	for i := 0; i < s.size; i++ {
+		require.NoError(t, s.acquire(context.Background(), lowPriority))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	waiting := make(chan struct{})
+	go func() {
+		defer close(waiting)
+		if err := s.acquire(ctx, lowPriority); err == nil {
+			s.release()
+		}
+	}()
+	require.Eventually(t, func() bool {
+		_, n := s.usage()
+		return n == 1
+	}, time.Second, 10*time.Millisecond)
+
+	return func() {
+		cancel()
+		<-waiting
+		for i := 0; i < s.size; i++ {
+			s.release()
+		}
+	}
+}
+
+func TestReadinessOverloaded(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.synchronous = true
+	ng.schedule.fetchDefinitions = func(time.Time) []*AlertDefinition { return nil }
+	ng.schedule.evalSemaphore = newEvalSemaphore(ng.schedule.clock, 2)
+	ng.schedule.saturation = newSaturationMonitor(10*time.Second, 0.9)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	tick := func() {
+		mockedClock.Add(time.Second)
+		require.NoError(t, ng.tickSynchronously(ctx, mockedClock.Now()))
+	}
+
+	tick()
+	assert.Equal(t, SchedulerReadiness{Status: readinessReady}, ng.Readiness())
+
+	free := saturate(t, ng.schedule.evalSemaphore)
+	// the semaphore is saturated on 9 of the 10 ticks of the window, the first one excluded
+	for i := 0; i < 9; i++ {
+		tick()
+	}
+	readiness := ng.Readiness()
+	assert.Equal(t, readinessReady, readiness.Status, "a whole window should be sampled")
+
+	// the semaphore has been saturated on all the ticks of the window
+	tick()
+	readiness = ng.Readiness()
+	assert.Equal(t, readinessOverloaded, readiness.Status)
+	assert.Equal(t, 1.0, readiness.Saturation)
+
+	// the instance is ready again once the waiting evaluations have decreased
+	free()
+	tick()
+	readiness = ng.Readiness()
+	assert.Equal(t, readinessReady, readiness.Status)
+	assert.InDelta(t, 0.9, readiness.Saturation, 1e-9)
+}
+
+func TestSaturationMonitorUnlimitedSemaphore(t *testing.T) {
+	m := newSaturationMonitor(time.Second, 0.5)
+	s := newEvalSemaphore(clock.New(), 0)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, s.acquire(context.Background(), lowPriority))
+	}
+	start := time.Unix(0, 0)
+	for i := 0; i < 3; i++ {
+		m.sample(start.Add(time.Duration(i)*time.Second), s)
+	}
+	ratio, overloaded := m.overloaded(start.Add(2 * time.Second))
+	assert.Equal(t, 0.0, ratio)
+	assert.False(t, overloaded)
+}
@@ -2,88 +2,406 @@ package ngalert
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/expr"
 	"github.com/grafana/grafana/pkg/infra/log"
 	"github.com/grafana/grafana/pkg/services/alerting"
 	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	tlog "github.com/opentracing/opentracing-go/log"
 	"golang.org/x/sync/errgroup"
 )
 
-func (ng *AlertNG) definitionRoutine(grafanaCtx context.Context, definitionID int64, evalCh <-chan *evalContext) error {
-	ng.log.Debug("alert definition routine started", "definitionID", definitionID)
+// runDefinitionRoutine runs the alert definition routine and wraps its error
+// so that the error returned by the scheduler identifies the failed routine.
+func (ng *AlertNG) runDefinitionRoutine(grafanaCtx context.Context, key string, definitionInfo alertDefinitionInfo) error {
+	if err := ng.definitionRoutine(grafanaCtx, key, definitionInfo); err != nil {
+		return fmt.Errorf("alert definition routine %s of organisation %d failed: %w", key, definitionInfo.orgID, err)
+	}
+	return nil
+}
+
+func (ng *AlertNG) definitionRoutine(grafanaCtx context.Context, key string, definitionInfo alertDefinitionInfo) error {
+	definitionID := definitionInfo.definitionID
+	// routineCtx is cancelled when the routine is stopped or grafana is shutting down
+	routineCtx := definitionInfo.ctx
+	defer atomic.StoreInt32(definitionInfo.alive, 0)
+	ng.log.Debug("alert definition routine started", "key", key, "definitionID", definitionID)
+	handleEvaluation := ng.newEvaluationHandler(key, definitionInfo)
+
+	for {
+		select {
+		case ctx := <-definitionInfo.ch:
+			if recycled := handleEvaluation(ctx); recycled {
+				return nil
+			}
+		case <-ng.schedule.draining:
+			// the evaluation in flight, if any, has completed
+			ng.schedule.log.Debug("alert definition routine drained", "key", key, "definitionID", definitionID)
+			return nil
+		case <-routineCtx.Done():
+			if grafanaCtx.Err() != nil {
+				return grafanaCtx.Err()
+			}
+			ng.schedule.log.Debug("stopping alert definition routine", "key", key, "definitionID", definitionID)
+			return nil
+		}
+	}
+}
+
+// newEvaluationHandler loads the state of the alert definition routine and returns the function
+// evaluating the alert definition for every evaluation dispatched to the routine.
+// The function returns true once the routine has reached its maximum lifetime and should be recycled.
+func (ng *AlertNG) newEvaluationHandler(key string, definitionInfo alertDefinitionInfo) func(ctx *evalContext) bool {
+	definitionID := definitionInfo.definitionID
+	// definitionKey is shared by the routines of the expansions of a template
+	definitionKey := definitionKeyOf(key, definitionInfo.templateValue)
+	// routineCtx is cancelled when the routine is stopped or grafana is shutting down
+	routineCtx := definitionInfo.ctx
+	routineStart := ng.schedule.clock.Now()
+	ng.loadState(key)
+	ng.warmUpDatasources(routineCtx, key, definitionInfo)
 
 	evalRunning := false
 	var start, end time.Time
 	var attempt int64
 	var alertDefinition *AlertDefinition
-	for {
-		select {
-		case ctx := <-evalCh:
-			if evalRunning {
-				continue
-			}
+	var condition eval.Condition
+	var guard *eval.Condition
+	var confirm *eval.Condition
+	// inherited are the labels the alert definition inherits from its folder and its group
+	var inherited data.Labels
+	// pending are the results of the last successful attempt, applied once the attempts are over
+	// unless apply is false, e.g. because the state changes are suppressed
+	var pending eval.Results
+	// kept are the results of the instances that keep their previous state, e.g. the errors of the startup grace period
+	var kept eval.Results
+	var apply bool
+	// instances are the alert instances updated by the results of the evaluation
+	var instances []alertInstance
+	// resultBytes is the approximate memory footprint of the results of the evaluation
+	var resultBytes int64
+	// freshness is stale once no evaluation has succeeded for the maximum staleness of the alert definition
+	freshness := staleness{lastSuccess: routineStart}
+	// health is the reason the results of the last successful attempt make the alert definition
+	// unhealthy for its dependents, if any
+	var health string
+	// unhealthyDependency is the dependency the evaluations are skipped for, if any
+	var unhealthyDependency string
 
-			evaluate := func(attempt int64) error {
-				start = timeNow()
+	return func(ctx *evalContext) bool {
+		if evalRunning {
+			return false
+		}
 
-				// fetch latest alert definition version
-				if alertDefinition == nil || alertDefinition.Version < ctx.version {
-					q := getAlertDefinitionByIDQuery{ID: definitionID}
-					err := ng.getAlertDefinitionByID(&q)
+		// the successful query responses are reused by the next attempts
+		// of the same evaluation so that only the failed queries are executed again
+		queryCache := expr.NewQueryCache()
+		// evalCtx is cancelled once the evaluation is over or superseded by a newer version
+		evalCtx := routineCtx
+		// evaluate runs an attempt of the evaluation; the state of the instances
+		// is only changed and the events emitted by applyResults after the last attempt
+		// so that a failed attempt has no visible effect
+		evaluate := func(attempt int64) error {
+			start = timeNow()
+			pending, kept, apply = nil, nil, false
+			health = ""
+
+			span := opentracing.StartSpan("alert definition evaluation")
+			defer span.Finish()
+			span.SetTag("definitionID", definitionID)
+			span.SetTag("evalID", ctx.evalID)
+			span.SetTag("attempt", attempt)
+
+			// fetch latest alert definition version
+			// or refetch it if it has been changed in the store without a version bump;
+			// it's fetched by ID since the alert definitions of different routines may share a UID under a custom key
+			refresh := atomic.CompareAndSwapInt32(definitionInfo.refresh, 1, 0)
+			if alertDefinition == nil || alertDefinition.Version < ctx.version || refresh {
+				fetched, err := ng.definitionStore().GetByID(definitionID)
+				if err != nil {
+					if refresh {
+						atomic.StoreInt32(definitionInfo.refresh, 1)
+					}
+					ng.schedule.log.Error("failed to fetch alert definition", "alertDefinitionID", definitionID, "evalID", ctx.evalID)
+					return err
+				}
+				if alertDefinition != nil && alertDefinition.Version != fetched.Version {
+					ng.schedule.notifyVersionChange(key, alertDefinition.Version, fetched.Version)
+					ng.schedule.audit.record(AuditVersionUpgraded, key, definitionID, ctx.evalID, "oldVersion", alertDefinition.Version, "newVersion", fetched.Version)
+				}
+				alertDefinition = fetched
+				definitionInfo.canceller.setVersion(alertDefinition.Version)
+				if definitionInfo.templateValue != "" {
+					expanded, err := alertDefinition.expand(definitionInfo.templateValue)
 					if err != nil {
-						ng.schedule.log.Error("failed to fetch alert definition", "alertDefinitionID", alertDefinition.ID)
+						ng.schedule.log.Error("failed to expand alert definition template", "alertDefinitionID", definitionID, "value", definitionInfo.templateValue, "evalID", ctx.evalID, "error", err)
 						return err
 					}
-					alertDefinition = q.Result
-					ng.schedule.log.Debug("new alert definition version fetched", "alertDefinitionID", alertDefinition.ID, "version", alertDefinition.Version)
+					alertDefinition = expanded
+				}
+				condition = alertDefinition.getCondition()
+				condition.MaxSeries = ng.schedule.maxSeriesFor(alertDefinition)
+				condition.CacheKey = key
+				condition.CacheTTL = alertDefinition.QueryCacheTTL
+				condition.Prepare()
+				inherited = ng.schedule.inheritedLabels.resolve(alertDefinition)
+				guard = alertDefinition.getGuardCondition()
+				if guard != nil {
+					guard.Prepare()
+				}
+				confirm = alertDefinition.getConfirmCondition()
+				if confirm != nil {
+					confirm.Prepare()
 				}
+				ng.schedule.log.Debug("new alert definition version fetched", "alertDefinitionID", alertDefinition.ID, "version", alertDefinition.Version, "evalID", ctx.evalID)
+			}
 
-				condition := eval.Condition{
-					RefID:                 alertDefinition.Condition,
-					OrgID:                 alertDefinition.OrgID,
-					QueriesAndExpressions: alertDefinition.Data,
+			// the results would be misleading while a dependency is unhealthy, e.g. if the condition queries its output
+			if uid, reason, healthy := ng.schedule.definitionHealth.check(alertDefinition); !healthy {
+				if unhealthyDependency != uid {
+					ng.schedule.log.Warn("skipping the alert definition evaluations: dependency unhealthy", "key", key, "definitionID", definitionID, "dependency", uid, "reason", reason)
+					unhealthyDependency = uid
 				}
-				results, err := eval.ConditionEval(&condition, ctx.now)
-				end = timeNow()
+				return fmt.Errorf("%w: alert definition %s is %s", errDependencyUnhealthy, uid, reason)
+			}
+			if unhealthyDependency != "" {
+				ng.schedule.log.Info("resuming the alert definition evaluations: dependencies healthy", "key", key, "definitionID", definitionID)
+				unhealthyDependency = ""
+			}
+
+			// a query to an unhealthy datasource would fail every attempt
+			if datasourceID, healthy := ng.schedule.datasourceHealth.check(key, &condition); !healthy {
+				return fmt.Errorf("%w: %d", errDatasourceUnhealthy, datasourceID)
+			}
+
+			// the live evaluation excludes the previews of the alert definition
+			lock := ng.schedule.definitionLocks.get(definitionID)
+			lock.Lock()
+			defer lock.Unlock()
+
+			queryCtx := expr.WithQueryCache(opentracing.ContextWithSpan(evalCtx, span), queryCache)
+			queryCtx = ng.schedule.externalValues.context(queryCtx)
+			evaluated := &condition
+			// the sampled evaluations bypass the cached results so that the raw responses are captured
+			var capture *eval.ResponseCapture
+			if ng.schedule.resultSampling.active(definitionKey) {
+				capture = eval.NewResponseCapture()
+				queryCtx = eval.WithResponseCapture(queryCtx, capture)
+				uncached := condition
+				uncached.CacheTTL = 0
+				evaluated = &uncached
+			}
+			results, err := ng.schedule.evaluateRetryingNoData(queryCtx, key, evaluated, guard, ctx.now)
+			if err == nil && confirm != nil {
+				results, err = ng.schedule.confirmResults(queryCtx, key, results, confirm, ctx.now)
+			}
+			end = timeNow()
+			if capture != nil {
+				sample := ResultSample{EvalID: ctx.evalID, At: ctx.now, TemplateValue: definitionInfo.templateValue, Responses: capture.Responses(), Results: results}
 				if err != nil {
-					ng.schedule.log.Error("failed to evaluate alert definition", "definitionID", definitionID, "attempt", attempt, "now", ctx.now, "duration", end.Sub(start), "error", err)
-					return err
+					sample.Error = err.Error()
 				}
-				for _, r := range results {
-					ng.schedule.log.Info("alert definition result", "definitionID", definitionID, "attempt", attempt, "now", ctx.now, "duration", end.Sub(start), "instance", r.Instance, "state", r.State.String())
+				ng.schedule.resultSampling.record(definitionKey, sample)
+			}
+			if err != nil {
+				ext.Error.Set(span, true)
+				span.LogFields(tlog.Error(err))
+				ng.schedule.log.Error("failed to evaluate alert definition", "definitionID", definitionID, "evalID", ctx.evalID, "attempt", attempt, "now", ctx.now, "duration", end.Sub(start), "error", err)
+				return err
+			}
+			for _, r := range results {
+				ng.schedule.log.Debug("alert definition result", "definitionID", definitionID, "evalID", ctx.evalID, "attempt", attempt, "now", ctx.now, "duration", end.Sub(start), "instance", r.Instance, "state", r.State.String(), "error", r.Error)
+			}
+			health = resultsHealth(results)
+			results = withInheritedLabels(results, inherited)
+			results = withMinAlerting(results, alertDefinition.MinAlertingInstances)
+			if ng.schedule.inStartupGracePeriod(ctx.now) {
+				// the instances failing during the grace period keep their previous state rather than being removed
+				results, kept = splitErrors(results)
+				if len(results) == 0 {
+					ng.schedule.log.Debug("alert definition state changes suppressed during the startup grace period", "definitionID", definitionID, "evalID", ctx.evalID, "now", ctx.now)
+					return nil
 				}
+			}
+			active, err := alertDefinition.ActiveTimeIntervals.isActive(ctx.now)
+			if err != nil {
+				ng.schedule.log.Error("failed to check the active time intervals of the alert definition", "definitionID", definitionID, "evalID", ctx.evalID, "error", err)
+				return err
+			}
+			if !active {
+				ng.schedule.log.Debug("alert definition state changes suppressed outside its active time intervals", "definitionID", definitionID, "evalID", ctx.evalID, "now", ctx.now)
+				return nil
+			}
+			if alertDefinition.hasFeature(featureShadowMode) {
+				ng.schedule.log.Info("alert definition evaluated in shadow mode; state changes discarded", "definitionID", definitionID, "evalID", ctx.evalID, "now", ctx.now, "results", len(results))
 				return nil
 			}
+			pending, apply = results, true
+			return nil
+		}
+
+		applyResults := func(attempts int64) {
+			evalAttempts.Observe(float64(attempts))
+			resultBytes = pending.SizeBytes()
+			evalResultBytes.Observe(float64(resultBytes))
+			instances = ng.schedule.stateTracker.setEvaluationResults(key, alertDefinition, ctx.evalID, pending, kept)
+			if alertDefinition.hasAdaptiveInterval() {
+				threshold, ok := condition.Threshold()
+				definitionInfo.adaptive.update(pending, threshold, ok)
+			}
+			ng.saveState(key)
+			for _, instance := range instances {
+				if instance.State != instance.PreviousState {
+					ng.schedule.audit.record(AuditStateTransition, key, definitionID, ctx.evalID, "labels", instance.Labels.String(), "from", instance.PreviousState.String(), "to", instance.State.String())
+				}
+			}
+			for i := range instances {
+				instances[i].EvalAttempts = attempts
+			}
+			ng.schedule.silences.markSilenced(alertDefinition, instances)
+			ng.schedule.writeAnnotations(alertDefinition, instances)
+			ng.schedule.subscribers.emit(instances, ng.schedule.routingLabels)
+			ng.schedule.sinks.emit(instances)
+		}
+
+		func() {
+			evalRunning = true
+			defer func() {
+				evalRunning = false
+				if ng.schedule.evalApplied != nil {
+					ng.schedule.evalApplied(definitionID, ctx.now)
+				}
+			}()
+
+			// the organisation slot is acquired first so that an evaluation
+			// waiting for its organisation does not hold a global slot
+			if err := ng.schedule.orgEvalSemaphores.acquire(routineCtx, definitionInfo.orgID); err != nil {
+				return
+			}
+			defer ng.schedule.orgEvalSemaphores.release(definitionInfo.orgID)
 
-			func() {
-				evalRunning = true
-				defer func() {
-					evalRunning = false
-					if ng.schedule.evalApplied != nil {
-						ng.schedule.evalApplied(definitionID, ctx.now)
+			if err := ng.schedule.evalSemaphore.acquire(routineCtx, ctx.priority); err != nil {
+				return
+			}
+			defer ng.schedule.evalSemaphore.release()
+
+			// the retry settings are read once per evaluation
+			// so that updates are applied from the next one
+			maxAttempts := ng.schedule.getMaxAttempts()
+			backoff := ng.schedule.getBackoff()
+			evalStart := timeNow()
+			instances, resultBytes = nil, 0
+			var err error
+			defer func() {
+				duration := timeNow().Sub(evalStart)
+				ng.schedule.logEvaluationSummary(definitionID, ctx, duration, attempt, maxAttempts, instances, resultBytes, err)
+				if err != nil {
+					ng.schedule.audit.record(AuditEvaluationFailed, key, definitionID, ctx.evalID, "now", ctx.now, "duration", duration.String(), "error", err.Error())
+				} else {
+					ng.schedule.audit.record(AuditEvaluationSucceeded, key, definitionID, ctx.evalID, "now", ctx.now, "duration", duration.String(), "instances", len(instances))
+				}
+				// the deferred, the skipped and the superseded evaluations are not recorded
+				if alertDefinition != nil && !errors.Is(err, eval.ErrRateLimited) && !errors.Is(err, errDatasourceUnhealthy) && !errors.Is(err, errDependencyUnhealthy) && !errors.Is(err, errEvaluationSuperseded) {
+					ng.schedule.history.add(definitionKey, evaluationRecord{
+						At:       ctx.now,
+						Duration: duration,
+						Failed:   err != nil,
+						State:    mostSevereState(instances),
+					})
+				}
+				if err != nil && !errors.Is(err, eval.ErrRateLimited) && !errors.Is(err, errEvaluationSuperseded) {
+					definitionInfo.adaptive.reset()
+				}
+				if alertDefinition != nil && freshness.update(alertDefinition.MaxStaleness, ctx.now, err) {
+					if freshness.stale {
+						ng.schedule.log.Warn("alert definition is stale: no successful evaluation within its maximum staleness", "definitionID", definitionID, "evalID", ctx.evalID, "lastSuccess", freshness.lastSuccess, "maxStaleness", alertDefinition.MaxStaleness)
+						ng.schedule.subscribers.emitStale(staleInstance(key, alertDefinition, freshness.lastSuccess), ng.schedule.routingLabels)
+					} else {
+						ng.schedule.log.Info("alert definition is no longer stale", "definitionID", definitionID, "evalID", ctx.evalID)
+					}
+				}
+				// the deferred, the skipped and the superseded evaluations and the stopped routines neither fail nor succeed
+				switch {
+				case err == nil:
+					if ng.schedule.quarantines.succeeded(key) {
+						ng.schedule.audit.record(AuditDefinitionReleased, key, definitionID, ctx.evalID, "now", ctx.now)
+						ng.schedule.log.Info("alert definition released from quarantine", "definitionID", definitionID, "evalID", ctx.evalID)
 					}
-				}()
+				case !errors.Is(err, eval.ErrRateLimited) && !errors.Is(err, errDatasourceUnhealthy) && !errors.Is(err, errDependencyUnhealthy) && !errors.Is(err, errEvaluationSuperseded) && routineCtx.Err() == nil:
+					if ng.schedule.quarantines.failed(key) {
+						ng.schedule.audit.record(AuditDefinitionQuarantined, key, definitionID, ctx.evalID, "now", ctx.now, "error", err.Error())
+						ng.schedule.log.Warn("alert definition quarantined: too many consecutive failed evaluations", "definitionID", definitionID, "evalID", ctx.evalID, "threshold", ng.schedule.quarantines.threshold, "factor", ng.schedule.quarantines.factor, "error", err)
+						ng.schedule.subscribers.emitQuarantined(quarantinedInstance(key, definitionInfo, alertDefinition, freshness.lastSuccess), ng.schedule.routingLabels)
+					}
+				}
+				// the evaluations skipped for an unhealthy dependency keep the health of the alert definition
+				healthRef := definitionRef{orgID: definitionInfo.orgID, uid: definitionInfo.uid}
+				switch {
+				case err == nil:
+					ng.schedule.definitionHealth.set(definitionKey, healthRef, health)
+				case !errors.Is(err, eval.ErrRateLimited) && !errors.Is(err, errDependencyUnhealthy) && !errors.Is(err, errEvaluationSuperseded) && routineCtx.Err() == nil:
+					ng.schedule.definitionHealth.set(definitionKey, healthRef, dependencyError)
+				}
+			}()
 
-				for attempt = 0; attempt < ng.schedule.maxAttempts; attempt++ {
-					err := evaluate(attempt)
-					if err == nil {
+			var cancelEval context.CancelFunc
+			evalCtx, cancelEval = context.WithCancel(routineCtx)
+			defer cancelEval()
+			definitionInfo.canceller.start(cancelEval, ctx.version)
+			defer definitionInfo.canceller.stop()
+			for attempt = 0; attempt < maxAttempts; attempt++ {
+				err = evaluateRecovering(evaluate, attempt)
+				if err == nil {
+					break
+				}
+				if errors.Is(err, eval.ErrRateLimited) {
+					ng.schedule.log.Debug("alert definition evaluation deferred to the next tick", "definitionID", definitionID, "evalID", ctx.evalID)
+					evalDeferred.Inc()
+					break
+				}
+				if errors.Is(err, errDatasourceUnhealthy) || errors.Is(err, errDependencyUnhealthy) {
+					break
+				}
+				// do not retry if the routine has been stopped or the evaluation superseded
+				if evalCtx.Err() != nil {
+					break
+				}
+				if backoff > 0 && attempt+1 < maxAttempts {
+					select {
+					case <-ng.schedule.clock.After(backoff):
+					case <-evalCtx.Done():
+					}
+					if evalCtx.Err() != nil {
 						break
 					}
 				}
-			}()
-		case id := <-ng.schedule.stop:
-			if id == definitionID {
-				ng.schedule.log.Debug("stopping alert definition routine", "definitionID", definitionID)
-				// interrupt evaluation if it's running
-				return nil
 			}
-		case <-grafanaCtx.Done():
-			return grafanaCtx.Err()
+			if err != nil && evalCtx.Err() != nil && routineCtx.Err() == nil {
+				err = fmt.Errorf("%w: %v", errEvaluationSuperseded, err)
+			}
+			if err == nil && apply {
+				applyResults(attempt + 1)
+			}
+		}()
+
+		// the routine is recycled between two evaluations; the ticker restarts it
+		// on the next tick with the same registry info and the instances keep their state
+		if ng.schedule.maxRoutineLifetime > 0 && ng.schedule.clock.Now().Sub(routineStart) >= ng.schedule.maxRoutineLifetime {
+			ng.schedule.log.Debug("recycling alert definition routine", "key", key, "definitionID", definitionID, "startedAt", routineStart)
+			atomic.StoreInt32(definitionInfo.recycled, 1)
+			return true
 		}
+		return false
 	}
 }
 
@@ -94,44 +412,381 @@ type schedule struct {
 	// each alert definition gets its own channel and routine
 	registry alertDefinitionRegistry
 
-	// broadcast channel for stopping definition routines
-	stop chan int64
+	// keyFunc returns the key that uniquely identifies
+	// the alert definition routine in the registry
+	keyFunc func(*AlertDefinition) string
 
+	// maxAttempts and backoff (in nanoseconds) are accessed atomically
+	// so that they can be updated while the routines are running
 	maxAttempts int64
+	backoff     int64
+
+	// maxSeries if positive is the maximum number of series per evaluation
+	// unless overridden by the alert definition
+	maxSeries int64
+
+	// evalSemaphore limits the number of concurrent evaluations
+	evalSemaphore *evalSemaphore
+
+	// orgEvalSemaphores limit the number of concurrent evaluations per organisation;
+	// a slot of the organisation is acquired before a global one
+	orgEvalSemaphores *orgEvalSemaphores
+
+	// definitionLocks prevent the previews from overlapping with the live evaluations
+	definitionLocks *definitionLocks
+
+	evaluator eval.Evaluator
+	// previewEvaluator evaluates the previews: unlike the evaluator,
+	// it's neither rate limited nor cached
+	previewEvaluator eval.Evaluator
+
+	// stateTracker keeps the state of the alert instances
+	stateTracker *stateTracker
+
+	// subscribers receive the events of the evaluated alert instances
+	subscribers *eventSubscribers
+	// sinks receive the alert instances of every applied evaluation independently
+	sinks *resultSinks
+	// routingLabels are the labels the routing keys of the events are computed from
+	routingLabels []string
+
+	// history keeps the last evaluations of every alert definition
+	history *evaluationHistory
+
+	// silences suppress the notifications of the matching firing instances
+	silences *silenceStore
+
+	// datasourceHealth skips the evaluations querying an unhealthy datasource
+	datasourceHealth *datasourceHealthGate
+	// datasourceWarmUp warms up the datasources of the new routines
+	datasourceWarmUp *datasourceWarmUp
+
+	// ring assigns the alert definitions to the scheduler instances;
+	// if it's nil this instance schedules all of them
+	ring       shardRing
+	instanceID string
+
+	// onVersionChange if set is called whenever a routine
+	// fetches a newer version of its alert definition
+	onVersionChange   func(key string, oldVersion, newVersion int64)
+	onVersionChangeMu sync.RWMutex
+
+	// audit records the scheduler decisions
+	audit *auditLog
+
+	// annotationWriter if set annotates the alert definition panel
+	// whenever an alert instance starts firing
+	annotationWriter AnnotationWriter
 
 	clock clock.Clock
 
+	// evalSeq is the identifier of the last dispatched evaluation
+	// it's only accessed by the ticker loop
+	evalSeq int64
+
+	// dispatchJitter is the maximum random delay
+	// added to the dispatch offset of each evaluation
+	dispatchJitter time.Duration
+
+	// maxDispatchOffset if positive is the maximum delay after the tick of the dispatch of each evaluation;
+	// the evaluations are spread over it rather than over the base interval
+	maxDispatchOffset time.Duration
+
+	// maxStartupDelay is the maximum random delay of the first evaluation of a new routine,
+	// bounded by the base interval, so that the routines created at once are spread out
+	maxStartupDelay time.Duration
+
+	// maxRoutineLifetime if positive is the time after which a routine exits
+	// once its evaluation is over to be restarted by the ticker, releasing what it has cached
+	maxRoutineLifetime time.Duration
+
+	// noDataRetries is the number of times an evaluation without results is retried
+	// within the evaluation, noDataBackoff apart, before its NoData state is applied
+	noDataRetries int
+	noDataBackoff time.Duration
+
+	// cancelSuperseded cancels the evaluation in flight once a newer version of the alert definition
+	// is scheduled and evaluates the newer one immediately; the cancellations of an alert definition
+	// are at least supersedeDebounce apart
+	cancelSuperseded  bool
+	supersedeDebounce time.Duration
+
+	// evalAtDispatchTime evaluates the alert definitions at the time they are dispatched
+	// instead of the time of the tick, which is up to a base interval earlier
+	evalAtDispatchTime bool
+
+	// rand is the source of all the randomized scheduling decisions
+	// it's only accessed by the ticker loop
+	rand *rand.Rand
+
 	heartbeat *alerting.Ticker
+	// tickOrigin is the time the ticks are counted from: they are a base interval apart from it
+	tickOrigin time.Time
+
+	// startupGracePeriod is the time after the scheduler has started
+	// during which the Error and NoData results don't change the state of the alert instances
+	// since the datasources may not be ready yet.
+	startupGracePeriod time.Duration
+	// startedAt is set once the ticker starts, before any routine is started.
+	startedAt time.Time
+
+	// shutdownGracePeriod is the time the evaluations in flight are given
+	// to complete once grafana is shutting down before they are cancelled.
+	shutdownGracePeriod time.Duration
+	// orgShutdownPriorities are the priorities of the organisations whose evaluations in flight
+	// are given the most of the shutdown grace period; the other organisations have priority 0.
+	orgShutdownPriorities map[int64]int
+	// draining is closed once grafana is shutting down
+	// so that the routines exit after their evaluation in flight.
+	draining chan struct{}
+
+	// folderPauses are the folders whose alert definitions are not dispatched
+	folderPauses *folderPauses
+	// labelPauses are the alert definitions paused by their labels; they are not dispatched
+	labelPauses *labelPauses
+
+	// saturation samples the evaluation semaphore on every tick to tell whether the instance is overloaded
+	saturation *saturationMonitor
+
+	// boosts are the alert definitions temporarily evaluated more often than their interval
+	boosts *frequencyBoosts
+
+	// inheritedLabels are the labels of the folders and the groups added to the results of their alert definitions
+	inheritedLabels *inheritedLabels
+
+	// resultSampling are the alert definitions whose raw query responses are captured for inspection
+	resultSampling *resultSampling
+
+	// quarantines are the alert definition routines evaluated less often because their evaluations keep failing
+	quarantines *quarantines
+
+	// externalValues provides the values of the external expressions of the conditions
+	externalValues *externalValues
+
+	// definitionHealth skips the evaluations of the alert definitions depending on an unhealthy one
+	definitionHealth *definitionHealth
+
+	// store is the storage of the alert definitions and of the state of the alert instances;
+	// if it's nil the grafana database is used
+	store DefinitionStore
+
+	// canary if set is the built-in alert definition monitoring the scheduler
+	canary *canary
+
+	// definitionCache keeps the fetched alert definitions between the ticks
+	definitionCache *definitionCache
+
+	// fetchBudget is the maximum time the ticker waits for the alert definitions
+	// to be fetched before falling back to the ones fetched on the previous tick.
+	fetchBudget time.Duration
+
+	// fetchDefinitions is only used for tests: test code can set it to non-nil
+	// function, and then it'll be used instead of fetching the alert definitions from the store.
+	fetchDefinitions func(time.Time) []*AlertDefinition
 
 	// evalApplied is only used for tests: test code can set it to non-nil
 	// function, and then it'll be called from the event loop whenever the
 	// message from evalApplied is handled.
 	evalApplied func(int64, time.Time)
 
+	// onTick if set is called from the ticker loop
+	// with the summary of every handled tick.
+	onTick func(TickSummary)
+
+	// synchronous is only used for tests: if it's set the ticks are handled by tickSynchronously
+	// on the calling goroutine; the alert definitions are fetched without budget, no routine goroutine
+	// is started and the evaluations are run inline when dispatched, in order, ignoring the dispatch offsets.
+	// The waits of the evaluations, like the retry backoffs, still use the clock.
+	synchronous bool
+	// syncRoutines are the evaluation handlers of the routines of the synchronous mode
+	syncRoutines map[string]func(*evalContext) bool
+	// syncDefinitions are the alert definitions fetched by the last tick of the synchronous mode
+	syncDefinitions []*AlertDefinition
+
 	log log.Logger
 }
 
 // newScheduler returns a new schedule.
 func newScheduler(c clock.Clock, baseInterval time.Duration, logger log.Logger, evalApplied func(int64, time.Time)) *schedule {
-	ticker := alerting.NewTicker(c.Now(), time.Second*0, c, int64(baseInterval.Seconds()))
+	now := c.Now()
+	ticker := alerting.NewTicker(now, time.Second*0, c, int64(baseInterval.Seconds()))
 	sch := schedule{
-		registry:     alertDefinitionRegistry{alertDefinitionInfo: make(map[int64]alertDefinitionInfo)},
-		stop:         make(chan int64),
-		maxAttempts:  maxAttempts,
-		clock:        c,
-		baseInterval: baseInterval,
-		log:          logger,
-		heartbeat:    ticker,
-		evalApplied:  evalApplied,
+		registry:          alertDefinitionRegistry{alertDefinitionInfo: make(map[string]alertDefinitionInfo)},
+		keyFunc:           getKey,
+		maxAttempts:       maxAttempts,
+		evalSemaphore:     newEvalSemaphore(c, 0),
+		orgEvalSemaphores: newOrgEvalSemaphores(0),
+		definitionLocks:   newDefinitionLocks(),
+		definitionCache:   newDefinitionCache(0),
+		evaluator:         eval.DefaultEvaluator{},
+		previewEvaluator:  eval.DefaultEvaluator{},
+		stateTracker:      newStateTracker(c),
+		silences:          newSilenceStore(c),
+		datasourceHealth:  newDatasourceHealthGate(logger),
+		datasourceWarmUp:  newDatasourceWarmUp(logger),
+		subscribers:       newEventSubscribers(),
+		audit:             newAuditLog(c, logger),
+		sinks:             newResultSinks(logger),
+		history:           newEvaluationHistory(defaultHistorySize),
+		draining:          make(chan struct{}),
+		folderPauses:      newFolderPauses(),
+		labelPauses:       newLabelPauses(),
+		boosts:            newFrequencyBoosts(),
+		saturation:        newSaturationMonitor(defaultSaturationWindow, defaultSaturationThreshold),
+		inheritedLabels:   newInheritedLabels(),
+		resultSampling:    newResultSampling(),
+		quarantines:       newQuarantines(defaultQuarantineThreshold, defaultQuarantineFactor),
+		externalValues:    &externalValues{},
+		definitionHealth:  newDefinitionHealth(),
+		clock:             c,
+		baseInterval:      baseInterval,
+		log:               logger,
+		heartbeat:         ticker,
+		tickOrigin:        now,
+		fetchBudget:       baseInterval,
+		supersedeDebounce: defaultSupersedeDebounce,
+		noDataBackoff:     defaultNoDataRetryBackoff,
+		evalApplied:       evalApplied,
+		rand:              rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
 	return &sch
 }
 
+// setOnVersionChange sets the function called whenever a routine
+// fetches a newer version of its alert definition.
+func (sch *schedule) setOnVersionChange(f func(key string, oldVersion, newVersion int64)) {
+	sch.onVersionChangeMu.Lock()
+	defer sch.onVersionChangeMu.Unlock()
+	sch.onVersionChange = f
+}
+
+func (sch *schedule) notifyVersionChange(key string, oldVersion, newVersion int64) {
+	sch.onVersionChangeMu.RLock()
+	defer sch.onVersionChangeMu.RUnlock()
+	if sch.onVersionChange != nil {
+		sch.onVersionChange(key, oldVersion, newVersion)
+	}
+}
+
+// setMaxAttempts sets the maximum number of attempts of each evaluation.
+// Running routines use the new value from their next evaluation.
+func (sch *schedule) setMaxAttempts(n int64) {
+	atomic.StoreInt64(&sch.maxAttempts, n)
+}
+
+func (sch *schedule) getMaxAttempts() int64 {
+	return atomic.LoadInt64(&sch.maxAttempts)
+}
+
+// setBackoff sets the time to wait between the attempts of an evaluation.
+// Running routines use the new value from their next evaluation.
+func (sch *schedule) setBackoff(d time.Duration) {
+	atomic.StoreInt64(&sch.backoff, int64(d))
+}
+
+func (sch *schedule) getBackoff() time.Duration {
+	return time.Duration(atomic.LoadInt64(&sch.backoff))
+}
+
+// logEvaluationSummary logs a single line summarizing the evaluation
+// of an alert definition: its outcome, the state counts of its instances
+// and the approximate memory footprint of its results.
+func (sch *schedule) logEvaluationSummary(definitionID int64, ctx *evalContext, duration time.Duration, attempt, maxAttempts int64, instances []alertInstance, resultBytes int64, err error) {
+	attempts := attempt + 1
+	if attempts > maxAttempts {
+		attempts = maxAttempts
+	}
+	counts := make(map[eval.State]int, 4)
+	for _, instance := range instances {
+		counts[instance.State]++
+	}
+
+	logCtx := []interface{}{
+		"definitionID", definitionID,
+		"evalID", ctx.evalID,
+		"now", ctx.now,
+		"duration", duration,
+		"attempts", attempts,
+		"normalCount", counts[eval.Normal],
+		"alertingCount", counts[eval.Alerting],
+		"pendingCount", counts[eval.Pending],
+		"errorCount", counts[eval.Error],
+		"resultBytes", resultBytes,
+	}
+	if err != nil {
+		logCtx = append(logCtx, "error", err)
+	}
+	sch.log.Info("evaluation complete", logCtx...)
+}
+
+// maxSeriesFor returns the maximum number of series
+// an evaluation of the alert definition accepts.
+func (sch *schedule) maxSeriesFor(alertDefinition *AlertDefinition) int64 {
+	if alertDefinition.MaxSeries > 0 {
+		return alertDefinition.MaxSeries
+	}
+	return sch.maxSeries
+}
+
+// setSeed sets the seed of the randomized scheduling decisions
+// so that they are reproducible.
+func (sch *schedule) setSeed(seed int64) {
+	sch.rand = rand.New(rand.NewSource(seed))
+}
+
+// dispatchOffset returns the delay after the tick of the i-th dispatched evaluation:
+// the evaluations are spread by step and randomly delayed by up to dispatchJitter.
+func (sch *schedule) dispatchOffset(i int, step int64) time.Duration {
+	offset := time.Duration(int64(i) * step)
+	if sch.dispatchJitter > 0 {
+		offset += time.Duration(sch.rand.Int63n(int64(sch.dispatchJitter)))
+	}
+	return sch.capDispatchOffset(offset)
+}
+
+// dispatchSpread returns the time the evaluations due on a tick are spread over.
+func (sch *schedule) dispatchSpread() time.Duration {
+	if sch.maxDispatchOffset > 0 && sch.maxDispatchOffset < sch.baseInterval {
+		return sch.maxDispatchOffset
+	}
+	return sch.baseInterval
+}
+
+// capDispatchOffset clamps the dispatch offset to the maximum dispatch offset, if any.
+func (sch *schedule) capDispatchOffset(offset time.Duration) time.Duration {
+	if sch.maxDispatchOffset > 0 && offset > sch.maxDispatchOffset {
+		return sch.maxDispatchOffset
+	}
+	return offset
+}
+
+// startupDelay returns the random delay of the first evaluation of a new routine.
+func (sch *schedule) startupDelay() time.Duration {
+	maxDelay := sch.maxStartupDelay
+	if maxDelay > sch.baseInterval {
+		maxDelay = sch.baseInterval
+	}
+	if maxDelay <= 0 {
+		return 0
+	}
+	return time.Duration(sch.rand.Int63n(int64(maxDelay)))
+}
+
+// ownsKey returns true if the alert definition with the given key
+// should be scheduled by this instance.
+func (sch *schedule) ownsKey(key string) bool {
+	if sch.ring == nil {
+		return true
+	}
+	return sch.ring.owner(key) == sch.instanceID
+}
+
 func (sch *schedule) pause() error {
 	if sch == nil {
 		return fmt.Errorf("scheduler is not initialised")
 	}
 	sch.heartbeat.Pause()
+	sch.audit.record(AuditSchedulerPaused, "", 0, 0)
 	sch.log.Info("alert definition scheduler paused", "now", sch.clock.Now())
 	return nil
 }
@@ -141,122 +796,352 @@ func (sch *schedule) unpause() error {
 		return fmt.Errorf("scheduler is not initialised")
 	}
 	sch.heartbeat.Unpause()
+	sch.audit.record(AuditSchedulerUnpaused, "", 0, 0)
 	sch.log.Info("alert definition scheduler unpaused", "now", sch.clock.Now())
 	return nil
 }
 
 func (ng *AlertNG) alertingTicker(grafanaCtx context.Context) error {
-	dispatcherGroup, ctx := errgroup.WithContext(grafanaCtx)
+	// the routines are not cancelled with the grafana context
+	// so that their evaluations in flight can complete during the shutdown grace period
+	routinesCtx, cancelRoutines := context.WithCancel(context.Background())
+	defer cancelRoutines()
+	dispatcherGroup, ctx := errgroup.WithContext(routinesCtx)
+	ng.schedule.startedAt = ng.schedule.clock.Now()
+	var previousDefinitions []*AlertDefinition
 	for {
 		select {
 		case tick := <-ng.schedule.heartbeat.C:
-			tickNum := tick.Unix() / int64(ng.schedule.baseInterval.Seconds())
-			alertDefinitions := ng.fetchAllDetails(tick)
-			ng.schedule.log.Debug("alert definitions fetched", "count", len(alertDefinitions))
-
-			// registeredDefinitions is a map used for finding deleted alert definitions
-			// initially it is assigned to all known alert definitions from the previous cycle
-			// each alert definition found also in this cycle is removed
-			// so, at the end, the remaining registered alert definitions are the deleted ones
-			registeredDefinitions := ng.schedule.registry.keyMap()
-
-			type readyToRunItem struct {
-				id             int64
-				definitionInfo alertDefinitionInfo
-			}
-			readyToRun := make([]readyToRunItem, 0)
-			for _, item := range alertDefinitions {
-				itemID := item.ID
-				itemVersion := item.Version
-				newRoutine := !ng.schedule.registry.exists(itemID)
-				definitionInfo := ng.schedule.registry.getOrCreateInfo(itemID, itemVersion)
-				invalidInterval := item.IntervalSeconds%int64(ng.schedule.baseInterval.Seconds()) != 0
-
-				if newRoutine && !invalidInterval {
-					dispatcherGroup.Go(func() error {
-						return ng.definitionRoutine(ctx, itemID, definitionInfo.ch)
-					})
-				}
+			previousDefinitions = ng.processTick(ctx, dispatcherGroup, tick, previousDefinitions)
+		case <-grafanaCtx.Done():
+			return ng.shutdown(dispatcherGroup, cancelRoutines)
+		}
+	}
+}
 
-				if invalidInterval {
-					// this is expected to be always false
-					// give that we validate interval during alert definition updates
-					ng.schedule.log.Debug("alert definition with invalid interval will be ignored: interval should be divided exactly by scheduler interval", "definitionID", itemID, "interval", time.Duration(item.IntervalSeconds)*time.Second, "scheduler interval", ng.schedule.baseInterval)
-					continue
-				}
+// processTick fetches the alert definitions, starts and stops their routines
+// and dispatches the evaluations due on the tick.
+// It returns the fetched alert definitions, reused by the next tick if its fetch fails or exceeds the tick budget.
+func (ng *AlertNG) processTick(ctx context.Context, dispatcherGroup *errgroup.Group, tick time.Time, previousDefinitions []*AlertDefinition) []*AlertDefinition {
+	tickNum := tick.Unix() / int64(ng.schedule.baseInterval.Seconds())
+	ng.schedule.saturation.sample(tick, ng.schedule.evalSemaphore)
+	ng.schedule.silences.expire()
+	for _, key := range ng.schedule.boosts.expire(tick) {
+		ng.schedule.audit.record(AuditFrequencyReverted, key, 0, 0)
+		ng.schedule.log.Info("alert definition frequency boost ended", "key", key)
+	}
+	// a failed or slow fetch never stops the routines: the previously fetched alert definitions are reused
+	fetched, err := ng.fetchAllDetailsWithBudget(tick)
+	if err != nil {
+		ng.schedule.log.Warn("failed to fetch alert definitions; reusing the previously fetched ones", "now", tick, "budget", ng.schedule.fetchBudget, "count", len(previousDefinitions), "error", err)
+		fetched = previousDefinitions
+	}
+	ng.schedule.log.Debug("alert definitions fetched", "count", len(fetched))
+	alertDefinitions := expandTemplates(fetched)
 
-				itemFrequency := item.IntervalSeconds / int64(ng.schedule.baseInterval.Seconds())
-				if item.IntervalSeconds != 0 && tickNum%itemFrequency == 0 {
-					readyToRun = append(readyToRun, readyToRunItem{id: itemID, definitionInfo: definitionInfo})
-				}
+	// registeredDefinitions is a map used for finding deleted alert definitions
+	// initially it is assigned to all known alert definitions from the previous cycle
+	// each alert definition found also in this cycle is removed
+	// so, at the end, the remaining registered alert definitions are the deleted ones
+	registeredDefinitions := ng.schedule.registry.keyMap()
 
-				// remove the alert definition from the registered alert definitions
-				delete(registeredDefinitions, itemID)
+	type readyToRunItem struct {
+		key            string
+		definitionInfo alertDefinitionInfo
+		priority       evalPriority
+		// startupDelay delays the first evaluation of a new routine
+		startupDelay time.Duration
+	}
+	readyToRun := make([]readyToRunItem, 0)
+	summary := TickSummary{Tick: tick, Skipped: make(map[SkipReason]int), DispatchedByFolder: make(map[string]int)}
+	for _, item := range alertDefinitions {
+		if !item.Enabled {
+			// disabled alert definitions are handled as deleted:
+			// their routine is stopped and removed from the registry
+			summary.Skipped[SkipDisabled]++
+			continue
+		}
+
+		itemID := item.ID
+		itemVersion := item.Version
+		definitionKey := ng.schedule.keyFunc(item)
+		key := definitionKey
+		if item.templateValue != "" {
+			key = templateKey(definitionKey, item.templateValue)
+		}
+		if !ng.schedule.ownsKey(key) {
+			// alert definitions owned by other instances are handled as deleted
+			summary.Skipped[SkipNotOwned]++
+			continue
+		}
+		newRoutine := !ng.schedule.registry.exists(key)
+		definitionInfo := ng.schedule.registry.getOrCreateInfo(ctx, key, itemID, item.UID, item.OrgID, itemVersion, item.templateValue)
+		invalidInterval := item.IntervalSeconds%int64(ng.schedule.baseInterval.Seconds()) != 0
+
+		// the evaluation in flight of an older version is superseded
+		// and the new version is evaluated immediately even if it's not due
+		superseded := !newRoutine && ng.schedule.cancelSuperseded && definitionInfo.canceller.supersede(itemVersion, ng.schedule.clock.Now(), ng.schedule.supersedeDebounce)
+		if superseded {
+			ng.schedule.log.Info("alert definition evaluation superseded by a newer version; cancelling it", "key", key, "definitionID", itemID, "version", itemVersion)
+		}
+
+		// a registered routine that exited without being stopped is restarted
+		deadRoutine := !newRoutine && !invalidInterval && !definitionInfo.isAlive()
+		if deadRoutine {
+			if definitionInfo.isRecycled() {
+				ng.schedule.log.Debug("alert definition routine recycled; restarting it", "key", key, "definitionID", itemID)
+			} else {
+				ng.schedule.log.Warn("alert definition routine exited unexpectedly; restarting it", "key", key, "definitionID", itemID)
 			}
+			definitionInfo = ng.schedule.registry.restart(ctx, key)
+		}
 
-			var step int64 = 0
-			if len(readyToRun) > 0 {
-				step = ng.schedule.baseInterval.Nanoseconds() / int64(len(readyToRun))
+		var startupDelay time.Duration
+		if (newRoutine || deadRoutine) && !invalidInterval {
+			if newRoutine {
+				startupDelay = ng.schedule.startupDelay()
 			}
+			summary.Created++
+			ng.schedule.audit.record(AuditRoutineCreated, key, itemID, 0, "version", itemVersion, "restarted", deadRoutine)
+			if ng.schedule.synchronous {
+				ng.schedule.startSynchronousRoutine(key, ng.newEvaluationHandler(key, definitionInfo))
+			} else {
+				dispatcherGroup.Go(func() error {
+					return ng.runDefinitionRoutine(ctx, key, definitionInfo)
+				})
+			}
+		}
 
-			for i := range readyToRun {
-				item := readyToRun[i]
+		if invalidInterval {
+			// this is expected to be always false
+			// give that we validate interval during alert definition updates
+			ng.schedule.log.Debug("alert definition with invalid interval will be ignored: interval should be divided exactly by scheduler interval", "definitionID", itemID, "interval", time.Duration(item.IntervalSeconds)*time.Second, "scheduler interval", ng.schedule.baseInterval)
+			summary.Skipped[SkipInvalidInterval]++
+			continue
+		}
 
-				time.AfterFunc(time.Duration(int64(i)*step), func() {
-					item.definitionInfo.ch <- &evalContext{now: tick, version: item.definitionInfo.version}
-				})
+		ng.schedule.registry.setTiming(key, evalTiming{
+			intervalSeconds:    item.IntervalSeconds,
+			maxIntervalSeconds: item.MaxIntervalSeconds,
+			alignmentOffset:    item.AlignmentOffset,
+			folderUID:          item.FolderUID,
+		})
+
+		// the routines of a paused folder are kept idle
+		if ng.schedule.folderPauses.isPaused(item.OrgID, item.FolderUID) {
+			summary.Skipped[SkipFolderPaused]++
+			delete(registeredDefinitions, key)
+			continue
+		}
+		if ng.schedule.labelPauses.isPaused(definitionKey) {
+			summary.Skipped[SkipLabelsPaused]++
+			delete(registeredDefinitions, key)
+			continue
+		}
+
+		itemFrequency := item.IntervalSeconds / int64(ng.schedule.baseInterval.Seconds())
+		if item.hasAdaptiveInterval() {
+			itemFrequency *= definitionInfo.adaptive.factorFor(item)
+		}
+		if interval, ok := ng.schedule.boosts.intervalAt(definitionKey, tick); ok {
+			itemFrequency = int64(interval / ng.schedule.baseInterval)
+		}
+		itemFrequency *= ng.schedule.quarantines.frequencyFactor(key)
+		if item.IntervalSeconds != 0 && (ng.schedule.isDue(tickNum, itemFrequency, item.AlignmentOffset) || superseded) {
+			readyToRun = append(readyToRun, readyToRunItem{key: key, definitionInfo: definitionInfo, priority: priorityOf(item), startupDelay: startupDelay})
+			summary.DispatchedByFolder[item.FolderUID]++
+		} else {
+			summary.Skipped[SkipNotDue]++
+		}
+
+		// remove the alert definition from the registered alert definitions
+		delete(registeredDefinitions, key)
+	}
+
+	var step int64 = 0
+	if len(readyToRun) > 0 {
+		step = ng.schedule.dispatchSpread().Nanoseconds() / int64(len(readyToRun))
+	}
+
+	for i := range readyToRun {
+		item := readyToRun[i]
+		ng.schedule.evalSeq++
+		evalID := ng.schedule.evalSeq
+
+		if ng.schedule.synchronous {
+			now := tick
+			if ng.schedule.evalAtDispatchTime {
+				now = ng.schedule.clock.Now()
 			}
+			ng.schedule.dispatchSynchronously(item.key, item.definitionInfo, &evalContext{now: now, version: item.definitionInfo.version, evalID: evalID, priority: item.priority})
+			continue
+		}
 
-			// unregister and stop routines of the deleted alert definitions
-			for id := range registeredDefinitions {
-				ng.schedule.stop <- id
-				ng.schedule.registry.del(id)
+		dispatchID := item.definitionInfo.dispatches.reserve()
+		dispatch := func() {
+			// the dispatch has been stopped with its routine
+			if !item.definitionInfo.dispatches.release(dispatchID) {
+				return
 			}
-		case <-grafanaCtx.Done():
-			err := dispatcherGroup.Wait()
-			return err
+			now := tick
+			if ng.schedule.evalAtDispatchTime {
+				now = ng.schedule.clock.Now()
+			}
+			ng.schedule.log.Debug("alert definition dispatched", "key", item.key, "evalID", evalID, "tick", tick, "now", now)
+			// the routine may have been stopped or drained since the tick
+			select {
+			case item.definitionInfo.ch <- &evalContext{now: now, version: item.definitionInfo.version, evalID: evalID, priority: item.priority}:
+				dispatchLatency.Observe(ng.schedule.clock.Now().Sub(tick).Seconds())
+			case <-item.definitionInfo.ctx.Done():
+				ng.schedule.log.Debug("alert definition dispatch dropped: routine stopped", "key", item.key, "evalID", evalID)
+			case <-ng.schedule.draining:
+				ng.schedule.log.Debug("alert definition dispatch dropped: scheduler stopping", "key", item.key, "evalID", evalID)
+			}
+		}
+		// the offsets are driven by the scheduler clock
+		// so that they are deterministic when the clock is mocked
+		if offset := ng.schedule.capDispatchOffset(ng.schedule.dispatchOffset(i, step) + item.startupDelay); offset > 0 {
+			item.definitionInfo.dispatches.scheduled(dispatchID, ng.schedule.clock.AfterFunc(offset, dispatch))
+		} else {
+			go dispatch()
+		}
+	}
+
+	// unregister and stop routines of the deleted alert definitions
+	for key := range registeredDefinitions {
+		if info, ok := ng.schedule.registry.get(key); ok {
+			ng.schedule.audit.record(AuditRoutineStopped, key, info.definitionID, 0)
+			ng.schedule.definitionHealth.del(definitionKeyOf(key, info.templateValue))
+			ng.schedule.definitionLocks.del(info.definitionID)
 		}
+		ng.schedule.registry.del(key)
+		ng.schedule.stateTracker.del(key)
+		ng.schedule.quarantines.del(key)
+		delete(ng.schedule.syncRoutines, key)
 	}
+	summary.Dispatched = len(readyToRun)
+	summary.Deleted = len(registeredDefinitions)
+	if ng.schedule.onTick != nil {
+		ng.schedule.onTick(summary)
+	}
+	return fetched
+}
+
+// definitionKey returns the routine key of the alert definition of the organisation with the UID.
+// The boosts, the history, the sampling, the health and the label pauses
+// of the alert definitions are kept by their routine key.
+func (ng *AlertNG) definitionKey(orgID int64, uid string) (string, error) {
+	alertDefinition, err := ng.definitionStore().GetByUID(orgID, uid)
+	if err != nil {
+		return "", err
+	}
+	return ng.schedule.keyFunc(alertDefinition), nil
+}
+
+// getKey returns the default key of the alert definition routine: orgID:UID
+// The folder is not part of the key: the registry keeps the folder of every routine
+// with its timing, and moving an alert definition to another folder should neither
+// restart its routine nor reset the state of its alert instances.
+func getKey(alertDefinition *AlertDefinition) string {
+	return fmt.Sprintf("%d:%s", alertDefinition.OrgID, alertDefinition.UID)
 }
 
 type alertDefinitionRegistry struct {
 	mu                  sync.Mutex
-	alertDefinitionInfo map[int64]alertDefinitionInfo
+	alertDefinitionInfo map[string]alertDefinitionInfo
+	// observer if set is notified of the mutations of the registry
+	observer registryObserver
 }
 
 // getOrCreateInfo returns the channel for the specific alert definition
-// if it does not exists creates one and returns it
-func (r *alertDefinitionRegistry) getOrCreateInfo(definitionID int64, definitionVersion int64) alertDefinitionInfo {
+// if it does not exists creates one and returns it.
+// The context of a new routine is derived from the provided one.
+// The template value is empty unless the alert definition is an expanded template.
+func (r *alertDefinitionRegistry) getOrCreateInfo(ctx context.Context, key string, definitionID int64, uid string, orgID int64, definitionVersion int64, templateValue string) alertDefinitionInfo {
 	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	info, ok := r.alertDefinitionInfo[definitionID]
+	observer := r.observer
+	info, ok := r.alertDefinitionInfo[key]
 	if !ok {
-		r.alertDefinitionInfo[definitionID] = alertDefinitionInfo{ch: make(chan *evalContext), version: definitionVersion}
-		return r.alertDefinitionInfo[definitionID]
+		routineCtx, cancel := context.WithCancel(ctx)
+		info = alertDefinitionInfo{ch: make(chan *evalContext), definitionID: definitionID, uid: uid, orgID: orgID, version: definitionVersion, templateValue: templateValue, ctx: routineCtx, cancel: cancel, alive: newAliveFlag(), recycled: new(int32), refresh: new(int32), canceller: &evalCanceller{}, adaptive: &adaptiveInterval{}, dispatches: newPendingDispatches()}
+		r.alertDefinitionInfo[key] = info
+		r.mu.Unlock()
+		if observer != nil {
+			observer.created(key, info)
+		}
+		return info
 	}
+	previousVersion := info.version
 	info.version = definitionVersion
-	r.alertDefinitionInfo[definitionID] = info
+	r.alertDefinitionInfo[key] = info
+	r.mu.Unlock()
+	if observer != nil && previousVersion != definitionVersion {
+		observer.updated(key, info, previousVersion)
+	}
 	return info
 }
 
-func (r *alertDefinitionRegistry) exists(definitionID int64) bool {
+// restart replaces the context and the liveness flag of the alert definition routine
+// so that a new routine can be started for it.
+func (r *alertDefinitionRegistry) restart(ctx context.Context, key string) alertDefinitionInfo {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	_, ok := r.alertDefinitionInfo[definitionID]
-	return ok
+	info := r.alertDefinitionInfo[key]
+	info.cancel()
+	info.ctx, info.cancel = context.WithCancel(ctx)
+	info.alive = newAliveFlag()
+	info.recycled = new(int32)
+	r.alertDefinitionInfo[key] = info
+	return info
+}
+
+// setTiming sets how the alert definition of the routine is scheduled.
+func (r *alertDefinitionRegistry) setTiming(key string, timing evalTiming) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if info, ok := r.alertDefinitionInfo[key]; ok {
+		info.timing = timing
+		r.alertDefinitionInfo[key] = info
+	}
 }
 
-func (r *alertDefinitionRegistry) del(definitionID int64) {
+func (r *alertDefinitionRegistry) get(key string) (alertDefinitionInfo, bool) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	delete(r.alertDefinitionInfo, definitionID)
+	info, ok := r.alertDefinitionInfo[key]
+	return info, ok
 }
 
-func (r *alertDefinitionRegistry) iter() <-chan int64 {
-	c := make(chan int64)
+func (r *alertDefinitionRegistry) exists(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.alertDefinitionInfo[key]
+	return ok
+}
+
+// del stops the routine of the alert definition and removes it from the registry.
+func (r *alertDefinitionRegistry) del(key string) {
+	r.mu.Lock()
+	observer := r.observer
+	info, ok := r.alertDefinitionInfo[key]
+	if ok {
+		info.cancel()
+		info.dispatches.stop()
+	}
+	delete(r.alertDefinitionInfo, key)
+	r.mu.Unlock()
+
+	if ok && observer != nil {
+		observer.deleted(key, info)
+	}
+}
+
+func (r *alertDefinitionRegistry) iter() <-chan string {
+	c := make(chan string)
 
 	f := func() {
 		r.mu.Lock()
@@ -272,20 +1157,152 @@ func (r *alertDefinitionRegistry) iter() <-chan int64 {
 	return c
 }
 
-func (r *alertDefinitionRegistry) keyMap() map[int64]struct{} {
-	definitionsIDs := make(map[int64]struct{})
-	for definitionID := range r.iter() {
-		definitionsIDs[definitionID] = struct{}{}
+func (r *alertDefinitionRegistry) keyMap() map[string]struct{} {
+	definitionsKeys := make(map[string]struct{})
+	for key := range r.iter() {
+		definitionsKeys[key] = struct{}{}
+	}
+	return definitionsKeys
+}
+
+// refresh signals the routines of the organisation to refetch their alert definition
+// on their next evaluation and returns the number of signalled routines.
+func (r *alertDefinitionRegistry) refresh(orgID int64) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for _, info := range r.alertDefinitionInfo {
+		if info.orgID == orgID {
+			atomic.StoreInt32(info.refresh, 1)
+			n++
+		}
+	}
+	return n
+}
+
+// cancelWhere stops the routines matching the predicate, cancelling their evaluation in flight,
+// and returns the number of stopped routines. They are kept in the registry.
+func (r *alertDefinitionRegistry) cancelWhere(match func(alertDefinitionInfo) bool) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for _, info := range r.alertDefinitionInfo {
+		if match(info) {
+			info.cancel()
+			n++
+		}
+	}
+	return n
+}
+
+// versions returns the alert definition version tracked for every key
+// so that it can be compared to the version in the store.
+func (r *alertDefinitionRegistry) versions() map[string]int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	versions := make(map[string]int64, len(r.alertDefinitionInfo))
+	for key, info := range r.alertDefinitionInfo {
+		versions[key] = info.version
+	}
+	return versions
+}
+
+// snapshot returns a copy of the registered alert definition routines.
+func (r *alertDefinitionRegistry) snapshot() map[string]alertDefinitionInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	infos := make(map[string]alertDefinitionInfo, len(r.alertDefinitionInfo))
+	for key, info := range r.alertDefinitionInfo {
+		infos[key] = info
 	}
-	return definitionsIDs
+	return infos
 }
 
 type alertDefinitionInfo struct {
-	ch      chan *evalContext
-	version int64
+	ch           chan *evalContext
+	definitionID int64
+	uid          string
+	orgID        int64
+	version      int64
+	// templateValue is the value the routine expands the alert definition template with
+	templateValue string
+	// ctx is cancelled for stopping the alert definition routine
+	ctx    context.Context
+	cancel context.CancelFunc
+	// alive is set when the routine is started and cleared when it exits
+	alive *int32
+	// recycled is set when the routine exits because it reached its maximum lifetime
+	recycled *int32
+	// refresh is set for the routine to refetch the alert definition on its next evaluation
+	refresh *int32
+	// canceller cancels the evaluation in flight once it's superseded
+	canceller *evalCanceller
+	// adaptive is the adaptive interval of the alert definition
+	adaptive *adaptiveInterval
+	// dispatches are the delayed dispatches of the evaluations not fired yet
+	dispatches *pendingDispatches
+	// timing is how the alert definition is scheduled as of the last tick
+	timing evalTiming
+}
+
+// newAliveFlag returns a liveness flag for a routine that is about to start.
+func newAliveFlag() *int32 {
+	alive := int32(1)
+	return &alive
+}
+
+func (info alertDefinitionInfo) isAlive() bool {
+	return atomic.LoadInt32(info.alive) == 1
+}
+
+func (info alertDefinitionInfo) isRecycled() bool {
+	return atomic.LoadInt32(info.recycled) == 1
 }
 
 type evalContext struct {
 	now     time.Time
 	version int64
+	// evalID correlates the dispatch of an evaluation with its logs and spans
+	evalID int64
+	// priority is the priority of the evaluation waiting for a concurrency slot
+	priority evalPriority
+}
+
+// SkipReason is the reason an alert definition was not dispatched on a tick.
+type SkipReason string
+
+const (
+	// SkipDisabled is the reason of the alert definitions that are disabled.
+	SkipDisabled SkipReason = "disabled"
+	// SkipNotOwned is the reason of the alert definitions scheduled by other instances.
+	SkipNotOwned SkipReason = "not_owned"
+	// SkipInvalidInterval is the reason of the alert definitions whose interval
+	// is not a multiple of the scheduler interval.
+	SkipInvalidInterval SkipReason = "invalid_interval"
+	// SkipNotDue is the reason of the alert definitions whose interval has not elapsed.
+	SkipNotDue SkipReason = "not_due"
+	// SkipFolderPaused is the reason of the alert definitions of a paused folder.
+	SkipFolderPaused SkipReason = "folder_paused"
+	// SkipLabelsPaused is the reason of the alert definitions paused by their labels.
+	SkipLabelsPaused SkipReason = "labels_paused"
+)
+
+// TickSummary reports what the ticker loop did on a tick.
+type TickSummary struct {
+	Tick time.Time
+	// Dispatched is the number of evaluations dispatched.
+	Dispatched int
+	// DispatchedByFolder is the number of evaluations dispatched by folder UID,
+	// empty for the alert definitions without a folder.
+	DispatchedByFolder map[string]int
+	// Skipped is the number of alert definitions not dispatched by reason.
+	Skipped map[SkipReason]int
+	// Created is the number of routines started.
+	Created int
+	// Deleted is the number of routines stopped.
+	Deleted int
 }
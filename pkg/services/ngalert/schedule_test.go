@@ -2,15 +2,22 @@ package ngalert
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/grafana/grafana-plugin-sdk-go/data"
 	"github.com/grafana/grafana/pkg/infra/log"
 	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/inconshreveable/log15"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -75,41 +82,746 @@ func TestAlertingTicker(t *testing.T) {
 	expectedAlertDefinitionsEvaluated = []int64{alerts[1].ID, alerts[0].ID}
 	t.Run(fmt.Sprintf("on 3rd tick alert definitions: %s should be evaluated", concatenate(expectedAlertDefinitionsEvaluated)), func(t *testing.T) {
 		tick := advanceClock(t, mockedClock)
+		// the second alert definition is dispatched half way through the tick
+		mockedClock.Add(500 * time.Millisecond)
 		assertEvalRun(t, evalAppliedCh, tick, expectedAlertDefinitionsEvaluated...)
 	})
 
 	expectedAlertDefinitionsEvaluated = []int64{alerts[1].ID}
 	t.Run(fmt.Sprintf("on 4th tick alert definitions: %s should be evaluated", concatenate(expectedAlertDefinitionsEvaluated)), func(t *testing.T) {
+		// the rest of the tick
+		mockedClock.Add(500 * time.Millisecond)
+		tick := mockedClock.Now()
+		assertEvalRun(t, evalAppliedCh, tick, expectedAlertDefinitionsEvaluated...)
+	})
+
+	err = ng.deleteAlertDefinitionByID(&deleteAlertDefinitionByIDCommand{ID: alerts[1].ID})
+	require.NoError(t, err)
+	t.Logf("alert definition: %d deleted", alerts[1].ID)
+
+	expectedAlertDefinitionsEvaluated = []int64{}
+	t.Run(fmt.Sprintf("on 5th tick alert definitions: %s should be evaluated", concatenate(expectedAlertDefinitionsEvaluated)), func(t *testing.T) {
+		tick := advanceClock(t, mockedClock)
+		assertEvalRun(t, evalAppliedCh, tick, expectedAlertDefinitionsEvaluated...)
+	})
+
+	expectedAlertDefinitionsEvaluated = []int64{alerts[0].ID}
+	t.Run(fmt.Sprintf("on 6th tick alert definitions: %s should be evaluated", concatenate(expectedAlertDefinitionsEvaluated)), func(t *testing.T) {
+		tick := advanceClock(t, mockedClock)
+		assertEvalRun(t, evalAppliedCh, tick, expectedAlertDefinitionsEvaluated...)
+	})
+
+	// create alert definition with one second interval
+	alerts = append(alerts, createTestAlertDefinition(t, ng, 1))
+
+	expectedAlertDefinitionsEvaluated = []int64{alerts[2].ID}
+	t.Run(fmt.Sprintf("on 7th tick alert definitions: %s should be evaluated", concatenate(expectedAlertDefinitionsEvaluated)), func(t *testing.T) {
 		tick := advanceClock(t, mockedClock)
 		assertEvalRun(t, evalAppliedCh, tick, expectedAlertDefinitionsEvaluated...)
 	})
+}
+
+func TestAlertingTickerSlowFetch(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.fetchBudget = 100 * time.Millisecond
+
+	alert := createTestAlertDefinition(t, ng, 1)
+
+	var slow int32
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	ng.schedule.fetchDefinitions = func(now time.Time) []*AlertDefinition {
+		if atomic.LoadInt32(&slow) == 1 {
+			<-release
+		}
+		alertDefinitions, _ := ng.fetchAllDetails(now)
+		return alertDefinitions
+	}
+
+	evalAppliedCh := make(chan evalAppliedInfo, 1)
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	ctx := context.Background()
+	go func() {
+		err := ng.alertingTicker(ctx)
+		require.NoError(t, err)
+	}()
+	runtime.Gosched()
+
+	t.Run("on 1st tick the fetched alert definition should be evaluated", func(t *testing.T) {
+		tick := advanceClock(t, mockedClock)
+		assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+	})
+
+	atomic.StoreInt32(&slow, 1)
+
+	t.Run("on 2nd tick the slow fetch should not delay the evaluation of the previous alert definitions", func(t *testing.T) {
+		tick := advanceClock(t, mockedClock)
+
+		// the budget is measured by the scheduler clock
+		select {
+		case <-evalAppliedCh:
+			t.Fatal("the alert definition should not be evaluated before the fetch budget is exceeded")
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		var info evalAppliedInfo
+		require.Eventually(t, func() bool {
+			mockedClock.Add(ng.schedule.fetchBudget / 10)
+			select {
+			case info = <-evalAppliedCh:
+				return true
+			default:
+				return false
+			}
+		}, time.Second, 10*time.Millisecond)
+		assert.Equal(t, alert.ID, info.alertDefID)
+		assert.Equal(t, tick, info.now)
+	})
+}
+
+func TestAlertingTickerCustomKeyFunc(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.synchronous = true
+
+	// two alert definitions with the same UID that belong to different namespaces
+	namespaces := map[int64]string{1: "namespace-a", 2: "namespace-b"}
+	alertDefinitions := []*AlertDefinition{
+		{ID: 1, OrgID: 1, UID: "uid", Condition: "A", IntervalSeconds: 1, Version: 1, Enabled: true},
+		{ID: 2, OrgID: 1, UID: "uid", Condition: "B", IntervalSeconds: 1, Version: 1, Enabled: true},
+	}
+	ng.schedule.keyFunc = func(alertDefinition *AlertDefinition) string {
+		return fmt.Sprintf("%d:%s:%s", alertDefinition.OrgID, namespaces[alertDefinition.ID], alertDefinition.UID)
+	}
+	store := newInMemoryDefinitionStore()
+	store.add(alertDefinitions...)
+	ng.SetDefinitionStore(store)
+	ng.schedule.fetchDefinitions = func(time.Time) []*AlertDefinition {
+		return alertDefinitions
+	}
+
+	evaluated := make(map[string]string)
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(_ context.Context, c *eval.Condition, _ time.Time) (eval.Results, error) {
+		evaluated[c.CacheKey] = c.RefID
+		return eval.Results{}, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	require.NoError(t, ng.tickSynchronously(ctx, time.Unix(1, 0)))
+
+	keys := ng.schedule.registry.keyMap()
+	assert.Len(t, keys, 2)
+	assert.Contains(t, keys, "1:namespace-a:uid")
+	assert.Contains(t, keys, "1:namespace-b:uid")
+
+	// the routines of the custom keys evaluate their own alert definition
+	assert.Equal(t, map[string]string{"1:namespace-a:uid": "A", "1:namespace-b:uid": "B"}, evaluated)
+
+	// the alert definitions sharing their UID are kept apart by their routine key
+	assert.Len(t, ng.schedule.history.list("1:namespace-a:uid"), 1)
+	assert.Len(t, ng.schedule.history.list("1:namespace-b:uid"), 1)
+	ng.schedule.labelPauses.set([]string{"1:namespace-a:uid"}, true)
+	evaluated = make(map[string]string)
+	require.NoError(t, ng.tickSynchronously(ctx, time.Unix(2, 0)))
+	assert.Equal(t, map[string]string{"1:namespace-b:uid": "B"}, evaluated, "pausing an alert definition should not pause the one sharing its UID")
+}
+
+func TestAlertingTickerDisabledDefinition(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+
+	alert := createTestAlertDefinition(t, ng, 1)
+	key := getKey(alert)
+
+	evalAppliedCh := make(chan evalAppliedInfo, 1)
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	ctx := context.Background()
+	go func() {
+		err := ng.alertingTicker(ctx)
+		require.NoError(t, err)
+	}()
+	runtime.Gosched()
+
+	t.Run("on 1st tick the enabled alert definition should be evaluated", func(t *testing.T) {
+		tick := advanceClock(t, mockedClock)
+		assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+		require.True(t, ng.schedule.registry.exists(key))
+	})
+
+	setEnabled := func(enabled bool) {
+		err := ng.updateAlertDefinition(&updateAlertDefinitionCommand{
+			ID:      alert.ID,
+			OrgID:   alert.OrgID,
+			Enabled: &enabled,
+		})
+		require.NoError(t, err)
+	}
+
+	setEnabled(false)
+	t.Run("on 2nd tick the disabled alert definition routine should be removed", func(t *testing.T) {
+		tick := advanceClock(t, mockedClock)
+		assertEvalRun(t, evalAppliedCh, tick)
+		require.Eventually(t, func() bool {
+			return !ng.schedule.registry.exists(key)
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	setEnabled(true)
+	t.Run("on 3rd tick the re-enabled alert definition routine should be recreated", func(t *testing.T) {
+		tick := advanceClock(t, mockedClock)
+		assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+		require.True(t, ng.schedule.registry.exists(key))
+	})
+}
+
+func TestAlertingTickerEvalID(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	var mu sync.Mutex
+	records := make([]*log15.Record, 0)
+	logger := log.New("ngalert.schedule.test")
+	logger.SetHandler(log15.FuncHandler(func(r *log15.Record) error {
+		mu.Lock()
+		defer mu.Unlock()
+		records = append(records, r)
+		return nil
+	}))
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, logger, nil)
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		return nil, errors.New("evaluation failed")
+	})
+
+	alert := createTestAlertDefinition(t, ng, 1)
+
+	evalAppliedCh := make(chan evalAppliedInfo, 1)
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	ctx := context.Background()
+	go func() {
+		err := ng.alertingTicker(ctx)
+		require.NoError(t, err)
+	}()
+	runtime.Gosched()
+
+	tick := advanceClock(t, mockedClock)
+	assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+
+	mu.Lock()
+	defer mu.Unlock()
+	evalIDs := make(map[string]interface{})
+	for _, r := range records {
+		if id := logContextValue(r, "evalID"); id != nil {
+			evalIDs[r.Msg] = id
+		}
+	}
+	require.Contains(t, evalIDs, "alert definition dispatched")
+	require.Contains(t, evalIDs, "failed to evaluate alert definition")
+	assert.Equal(t, evalIDs["alert definition dispatched"], evalIDs["failed to evaluate alert definition"])
+}
+
+func TestAlertingTickerEvaluationSummary(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	var mu sync.Mutex
+	records := make([]*log15.Record, 0)
+	logger := log.New("ngalert.schedule.test")
+	logger.SetHandler(log15.FuncHandler(func(r *log15.Record) error {
+		mu.Lock()
+		defer mu.Unlock()
+		records = append(records, r)
+		return nil
+	}))
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, logger, nil)
+	results := eval.Results{
+		{Instance: data.Labels{"host": "a"}, State: eval.Alerting},
+		{Instance: data.Labels{"host": "b"}, State: eval.Normal},
+		{Instance: data.Labels{"host": "c"}, State: eval.Normal},
+	}
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		return results, nil
+	})
+
+	alert := createTestAlertDefinition(t, ng, 1)
+
+	evalAppliedCh := make(chan evalAppliedInfo, 1)
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	ctx := context.Background()
+	go func() {
+		err := ng.alertingTicker(ctx)
+		require.NoError(t, err)
+	}()
+	runtime.Gosched()
+
+	tick := advanceClock(t, mockedClock)
+	assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+
+	mu.Lock()
+	defer mu.Unlock()
+	var summaries []*log15.Record
+	for _, r := range records {
+		if r.Msg == "evaluation complete" {
+			summaries = append(summaries, r)
+		}
+		if r.Msg == "alert definition result" {
+			assert.Equal(t, log15.LvlDebug, r.Lvl)
+		}
+	}
+	require.Len(t, summaries, 1)
+	summary := summaries[0]
+	assert.Equal(t, log15.LvlInfo, summary.Lvl)
+	assert.Equal(t, alert.ID, logContextValue(summary, "definitionID"))
+	assert.NotNil(t, logContextValue(summary, "evalID"))
+	assert.NotNil(t, logContextValue(summary, "duration"))
+	assert.Equal(t, int64(1), logContextValue(summary, "attempts"))
+	assert.Equal(t, 2, logContextValue(summary, "normalCount"))
+	assert.Equal(t, 1, logContextValue(summary, "alertingCount"))
+	assert.Equal(t, 0, logContextValue(summary, "pendingCount"))
+	assert.Equal(t, 0, logContextValue(summary, "errorCount"))
+	assert.Equal(t, results.SizeBytes(), logContextValue(summary, "resultBytes"))
+	assert.Nil(t, logContextValue(summary, "error"))
+}
+
+func TestAlertingTickerStopDuringEvaluation(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+
+	var attempts int32
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		atomic.AddInt32(&attempts, 1)
+		started <- struct{}{}
+		<-release
+		return nil, errors.New("evaluation failed")
+	})
+
+	alert := createTestAlertDefinition(t, ng, 1)
+	key := getKey(alert)
+
+	evalAppliedCh := make(chan evalAppliedInfo, 1)
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	ctx := context.Background()
+	go func() {
+		err := ng.alertingTicker(ctx)
+		require.NoError(t, err)
+	}()
+	runtime.Gosched()
+
+	tick := advanceClock(t, mockedClock)
+	<-started
+
+	err := ng.deleteAlertDefinitionByID(&deleteAlertDefinitionByIDCommand{ID: alert.ID})
+	require.NoError(t, err)
+
+	// the routine is busy evaluating but stopping it should not block the ticker
+	advanceClock(t, mockedClock)
+	require.Eventually(t, func() bool {
+		return !ng.schedule.registry.exists(key)
+	}, time.Second, 10*time.Millisecond)
+
+	close(release)
+	assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+}
+
+func TestAlertingTickerVersionChange(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		return nil, nil
+	})
+
+	type versionChange struct {
+		key                    string
+		oldVersion, newVersion int64
+	}
+	versionChangeCh := make(chan versionChange, 1)
+	ng.schedule.setOnVersionChange(func(key string, oldVersion, newVersion int64) {
+		versionChangeCh <- versionChange{key: key, oldVersion: oldVersion, newVersion: newVersion}
+	})
+
+	alert := createTestAlertDefinition(t, ng, 1)
+
+	evalAppliedCh := make(chan evalAppliedInfo, 1)
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	ctx := context.Background()
+	go func() {
+		err := ng.alertingTicker(ctx)
+		require.NoError(t, err)
+	}()
+	runtime.Gosched()
+
+	tick := advanceClock(t, mockedClock)
+	assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+	require.Len(t, versionChangeCh, 0, "the initial fetch is not a version change")
+
+	title := "updated title"
+	err := ng.updateAlertDefinition(&updateAlertDefinitionCommand{
+		ID:    alert.ID,
+		OrgID: alert.OrgID,
+		Title: title,
+	})
+	require.NoError(t, err)
+
+	tick = advanceClock(t, mockedClock)
+	assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+
+	select {
+	case change := <-versionChangeCh:
+		assert.Equal(t, versionChange{key: getKey(alert), oldVersion: 1, newVersion: 2}, change)
+	default:
+		t.Fatal("version change hook was not called")
+	}
+}
+
+func TestAlertingTickerRestartsDeadRoutine(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		return nil, nil
+	})
+
+	alert := createTestAlertDefinition(t, ng, 1)
+	key := getKey(alert)
+
+	evalAppliedCh := make(chan evalAppliedInfo, 1)
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	ctx := context.Background()
+	go func() {
+		err := ng.alertingTicker(ctx)
+		require.NoError(t, err)
+	}()
+	runtime.Gosched()
+
+	tick := advanceClock(t, mockedClock)
+	assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+
+	// simulate the routine exiting without the alert definition being deleted
+	info, ok := ng.schedule.registry.get(key)
+	require.True(t, ok)
+	info.cancel()
+	require.Eventually(t, func() bool {
+		return !info.isAlive()
+	}, time.Second, 10*time.Millisecond)
+
+	tick = advanceClock(t, mockedClock)
+	assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+
+	info, ok = ng.schedule.registry.get(key)
+	require.True(t, ok)
+	assert.True(t, info.isAlive())
+}
+
+func TestAlertingTickerSetMaxAttempts(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	var attempts int64
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		atomic.AddInt64(&attempts, 1)
+		return nil, errors.New("evaluation failed")
+	})
+
+	alert := createTestAlertDefinition(t, ng, 1)
+
+	evalAppliedCh := make(chan evalAppliedInfo, 1)
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	ctx := context.Background()
+	go func() {
+		err := ng.alertingTicker(ctx)
+		require.NoError(t, err)
+	}()
+	runtime.Gosched()
+
+	tick := advanceClock(t, mockedClock)
+	assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+	require.Equal(t, maxAttempts, atomic.LoadInt64(&attempts))
+
+	// the routine is running while the setting changes
+	ng.schedule.setMaxAttempts(1)
+
+	tick = advanceClock(t, mockedClock)
+	assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+	require.Equal(t, maxAttempts+1, atomic.LoadInt64(&attempts))
+}
+
+func TestAlertingTickerEvalAttempts(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	writer := &fakeAnnotationWriter{}
+	ng.schedule.annotationWriter = writer
+	var calls int64
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		// the first two attempts fail
+		if atomic.AddInt64(&calls, 1) <= 2 {
+			return nil, errors.New("evaluation failed")
+		}
+		return eval.Results{{Instance: data.Labels{"host": "a"}, State: eval.Alerting}}, nil
+	})
+
+	alert := createTestAlertDefinition(t, ng, 1)
+	var dashboardID, panelID int64 = 1, 1
+	err := ng.updateAlertDefinition(&updateAlertDefinitionCommand{
+		ID:          alert.ID,
+		OrgID:       alert.OrgID,
+		DashboardID: &dashboardID,
+		PanelID:     &panelID,
+	})
+	require.NoError(t, err)
+
+	evalAppliedCh := make(chan evalAppliedInfo, 1)
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	attemptsSum := func() float64 {
+		var m dto.Metric
+		require.NoError(t, evalAttempts.Write(&m))
+		return m.GetHistogram().GetSampleSum()
+	}
+	before := attemptsSum()
+
+	ctx := context.Background()
+	go func() {
+		err := ng.alertingTicker(ctx)
+		require.NoError(t, err)
+	}()
+	runtime.Gosched()
+
+	tick := advanceClock(t, mockedClock)
+	assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+
+	require.Len(t, writer.annotations, 1)
+	assert.Equal(t, int64(3), writer.annotations[0].EvalAttempts)
+	assert.Equal(t, float64(3), attemptsSum()-before)
+}
+
+func TestAlertingTickerDispatchOffsets(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		return nil, nil
+	})
 
-	err = ng.deleteAlertDefinitionByID(&deleteAlertDefinitionByIDCommand{ID: alerts[1].ID})
-	require.NoError(t, err)
-	t.Logf("alert definition: %d deleted", alerts[1].ID)
+	alerts := []*AlertDefinition{
+		createTestAlertDefinition(t, ng, 1),
+		createTestAlertDefinition(t, ng, 1),
+		createTestAlertDefinition(t, ng, 1),
+		createTestAlertDefinition(t, ng, 1),
+	}
 
-	expectedAlertDefinitionsEvaluated = []int64{}
-	t.Run(fmt.Sprintf("on 5th tick alert definitions: %s should be evaluated", concatenate(expectedAlertDefinitionsEvaluated)), func(t *testing.T) {
-		tick := advanceClock(t, mockedClock)
-		assertEvalRun(t, evalAppliedCh, tick, expectedAlertDefinitionsEvaluated...)
+	evalAppliedCh := make(chan evalAppliedInfo, len(alerts))
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	ctx := context.Background()
+	go func() {
+		err := ng.alertingTicker(ctx)
+		require.NoError(t, err)
+	}()
+	runtime.Gosched()
+
+	// the alert definitions are spread every 250ms within the tick
+	tick := advanceClock(t, mockedClock)
+	seen := make(map[int64]struct{})
+	for i := range alerts {
+		if i > 0 {
+			// no other evaluation should happen until the clock reaches the next offset
+			select {
+			case info := <-evalAppliedCh:
+				t.Fatalf("alert definition %d evaluated before the clock advanced", info.alertDefID)
+			case <-time.After(100 * time.Millisecond):
+			}
+			mockedClock.Add(250 * time.Millisecond)
+		}
+
+		select {
+		case info := <-evalAppliedCh:
+			assert.Equal(t, tick, info.now)
+			seen[info.alertDefID] = struct{}{}
+		case <-time.After(time.Second):
+			t.Fatalf("offset %d was not dispatched", i)
+		}
+	}
+	assert.Len(t, seen, len(alerts))
+}
+
+func TestAlertingTickerSummary(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		return nil, nil
 	})
 
-	expectedAlertDefinitionsEvaluated = []int64{alerts[0].ID}
-	t.Run(fmt.Sprintf("on 6th tick alert definitions: %s should be evaluated", concatenate(expectedAlertDefinitionsEvaluated)), func(t *testing.T) {
+	ready := createTestAlertDefinition(t, ng, 1)
+	deleted := createTestAlertDefinition(t, ng, 1)
+	slow := createTestAlertDefinition(t, ng, 2)
+	disabled := createTestAlertDefinition(t, ng, 1)
+	enabled := false
+	err := ng.updateAlertDefinition(&updateAlertDefinitionCommand{ID: disabled.ID, OrgID: disabled.OrgID, Enabled: &enabled})
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	fetched := []int64{ready.ID, deleted.ID, slow.ID, disabled.ID}
+	ng.schedule.fetchDefinitions = func(now time.Time) []*AlertDefinition {
+		mu.Lock()
+		defer mu.Unlock()
+		var alertDefinitions []*AlertDefinition
+		all, err := ng.fetchAllDetails(now)
+		assert.NoError(t, err)
+		for _, alertDefinition := range all {
+			for _, id := range fetched {
+				if alertDefinition.ID == id {
+					alertDefinitions = append(alertDefinitions, alertDefinition)
+				}
+			}
+		}
+		return alertDefinitions
+	}
+
+	summaries := make(chan TickSummary, 1)
+	ng.schedule.onTick = func(summary TickSummary) {
+		summaries <- summary
+	}
+
+	ctx := context.Background()
+	go func() {
+		err := ng.alertingTicker(ctx)
+		require.NoError(t, err)
+	}()
+	runtime.Gosched()
+
+	nextSummary := func(t *testing.T) TickSummary {
+		select {
+		case summary := <-summaries:
+			return summary
+		case <-time.After(time.Second):
+			t.Fatal("no tick summary reported")
+		}
+		return TickSummary{}
+	}
+
+	t.Run("on 1st tick the new alert definitions should be created and the due ones dispatched", func(t *testing.T) {
 		tick := advanceClock(t, mockedClock)
-		assertEvalRun(t, evalAppliedCh, tick, expectedAlertDefinitionsEvaluated...)
+		assert.Equal(t, TickSummary{
+			Tick:               tick,
+			Dispatched:         2,
+			DispatchedByFolder: map[string]int{"": 2},
+			Skipped:            map[SkipReason]int{SkipDisabled: 1, SkipNotDue: 1},
+			Created:            3,
+		}, nextSummary(t))
 	})
 
-	// create alert definition with one second interval
-	alerts = append(alerts, createTestAlertDefinition(t, ng, 1))
+	created := createTestAlertDefinition(t, ng, 1)
+	mu.Lock()
+	fetched = []int64{ready.ID, slow.ID, disabled.ID, created.ID}
+	mu.Unlock()
 
-	expectedAlertDefinitionsEvaluated = []int64{alerts[2].ID}
-	t.Run(fmt.Sprintf("on 7th tick alert definitions: %s should be evaluated", concatenate(expectedAlertDefinitionsEvaluated)), func(t *testing.T) {
+	t.Run("on 2nd tick the removed alert definition should be deleted", func(t *testing.T) {
 		tick := advanceClock(t, mockedClock)
-		assertEvalRun(t, evalAppliedCh, tick, expectedAlertDefinitionsEvaluated...)
+		assert.Equal(t, TickSummary{
+			Tick:               tick,
+			Dispatched:         3,
+			DispatchedByFolder: map[string]int{"": 3},
+			Skipped:            map[SkipReason]int{SkipDisabled: 1},
+			Created:            1,
+			Deleted:            1,
+		}, nextSummary(t))
 	})
 }
 
+func TestScheduleDispatchOffsetSeed(t *testing.T) {
+	offsets := func(seed int64) []time.Duration {
+		sch := newScheduler(clock.NewMock(), 10*time.Second, log.New("ngalert.schedule.test"), nil)
+		sch.dispatchJitter = time.Second
+		sch.setSeed(seed)
+
+		offsets := make([]time.Duration, 0, 10)
+		for i := 0; i < 10; i++ {
+			offsets = append(offsets, sch.dispatchOffset(i, int64(time.Second)))
+		}
+		return offsets
+	}
+
+	first := offsets(42)
+	assert.Equal(t, first, offsets(42), "schedulers with the same seed should produce identical offsets")
+	assert.NotEqual(t, first, offsets(43))
+	for i, offset := range first {
+		assert.GreaterOrEqual(t, int64(offset), int64(time.Duration(i)*time.Second))
+		assert.Less(t, int64(offset), int64(time.Duration(i+1)*time.Second))
+	}
+}
+
+func logContextValue(r *log15.Record, key string) interface{} {
+	for i := 0; i+1 < len(r.Ctx); i += 2 {
+		if r.Ctx[i] == key {
+			return r.Ctx[i+1]
+		}
+	}
+	return nil
+}
+
 func assertEvalRun(t *testing.T, ch <-chan evalAppliedInfo, tick time.Time, ids ...int64) {
 	timeout := time.After(time.Second)
 
@@ -151,3 +863,471 @@ func concatenate(ids []int64) string {
 	}
 	return fmt.Sprintf("[%s]", strings.TrimLeft(strings.Join(s, ","), ","))
 }
+
+func TestScheduleMaxSeriesFor(t *testing.T) {
+	sch := newScheduler(clock.NewMock(), time.Second, log.New("ngalert.schedule.test"), nil)
+	sch.maxSeries = 100
+
+	assert.Equal(t, int64(100), sch.maxSeriesFor(&AlertDefinition{}))
+	assert.Equal(t, int64(10), sch.maxSeriesFor(&AlertDefinition{MaxSeries: 10}))
+	assert.Equal(t, int64(1000), sch.maxSeriesFor(&AlertDefinition{MaxSeries: 1000}))
+}
+
+func TestAlertDefinitionRegistryVersions(t *testing.T) {
+	r := alertDefinitionRegistry{alertDefinitionInfo: make(map[string]alertDefinitionInfo)}
+	ctx := context.Background()
+
+	assert.Empty(t, r.versions())
+
+	r.getOrCreateInfo(ctx, "1:a", 1, "a", 1, 3, "")
+	r.getOrCreateInfo(ctx, "1:b", 2, "b", 1, 1, "")
+	r.getOrCreateInfo(ctx, "2:c", 3, "c", 2, 7, "")
+	assert.Equal(t, map[string]int64{"1:a": 3, "1:b": 1, "2:c": 7}, r.versions())
+
+	// the version is updated once a newer one is fetched
+	r.getOrCreateInfo(ctx, "1:b", 2, "b", 1, 2, "")
+	r.del("2:c")
+	assert.Equal(t, map[string]int64{"1:a": 3, "1:b": 2}, r.versions())
+}
+
+func TestAlertingTickerEvalAtDispatchTime(t *testing.T) {
+	// runTick returns the times the alert definitions are evaluated at on a tick
+	runTick := func(t *testing.T, evalAtDispatchTime bool) (time.Time, []time.Time) {
+		ng := setupTestEnv(t)
+		t.Cleanup(registry.ClearOverrides)
+
+		mockedClock := clock.NewMock()
+		ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+		ng.schedule.evalAtDispatchTime = evalAtDispatchTime
+
+		var mu sync.Mutex
+		var evaluatedAt []time.Time
+		ng.schedule.evaluator = eval.EvaluatorFunc(func(_ context.Context, _ *eval.Condition, now time.Time) (eval.Results, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			evaluatedAt = append(evaluatedAt, now)
+			return nil, nil
+		})
+
+		// the second dispatched evaluation is offset by half the base interval
+		alerts := []*AlertDefinition{createTestAlertDefinition(t, ng, 1), createTestAlertDefinition(t, ng, 1)}
+
+		evalAppliedCh := make(chan evalAppliedInfo, len(alerts))
+		ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+			evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			_ = ng.alertingTicker(ctx)
+		}()
+		runtime.Gosched()
+
+		tick := advanceClock(t, mockedClock)
+		for range alerts {
+			select {
+			case <-evalAppliedCh:
+			case <-time.After(100 * time.Millisecond):
+				// the offset evaluation is dispatched once the clock reaches it
+				mockedClock.Add(500 * time.Millisecond)
+				<-evalAppliedCh
+			}
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		return tick, evaluatedAt
+	}
+
+	t.Run("the alert definitions are evaluated at the tick time by default", func(t *testing.T) {
+		tick, evaluatedAt := runTick(t, false)
+		assert.Equal(t, []time.Time{tick, tick}, evaluatedAt)
+	})
+
+	t.Run("the alert definitions are evaluated at the dispatch time if enabled", func(t *testing.T) {
+		tick, evaluatedAt := runTick(t, true)
+		assert.ElementsMatch(t, []time.Time{tick, tick.Add(500 * time.Millisecond)}, evaluatedAt)
+	})
+}
+
+func TestAlertingTickerEmitsFinalAttemptOnly(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	var calls int64
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		switch atomic.AddInt64(&calls, 1) {
+		case 2:
+			// the first attempt fails once its guard condition has been evaluated
+			return nil, errors.New("evaluation failed")
+		default:
+			return eval.Results{
+				{Instance: data.Labels{"host": "a"}, State: eval.Alerting},
+				{Instance: data.Labels{"host": "b"}, State: eval.Normal},
+			}, nil
+		}
+	})
+
+	alert := createTestAlertDefinition(t, ng, 1)
+	err := ng.updateAlertDefinition(&updateAlertDefinitionCommand{
+		ID:             alert.ID,
+		OrgID:          alert.OrgID,
+		GuardCondition: "A",
+	})
+	require.NoError(t, err)
+
+	events, unsubscribe := ng.schedule.subscribers.subscribe(10)
+	defer unsubscribe()
+
+	evalAppliedCh := make(chan evalAppliedInfo, 1)
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		_ = ng.alertingTicker(ctx)
+	}()
+	runtime.Gosched()
+
+	tick := advanceClock(t, mockedClock)
+	assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+	require.Equal(t, int64(4), atomic.LoadInt64(&calls), "the guard and the condition should be evaluated by both attempts")
+
+	var emitted []alertEvent
+drain:
+	for {
+		select {
+		case event := <-events:
+			emitted = append(emitted, event)
+		default:
+			break drain
+		}
+	}
+	require.Len(t, emitted, 2)
+	states := make(map[string]eval.State, len(emitted))
+	for _, event := range emitted {
+		assert.Equal(t, int64(2), event.Instance.EvalAttempts)
+		states[event.Instance.Labels.String()] = event.Instance.State
+	}
+	assert.Equal(t, map[string]eval.State{
+		data.Labels{"host": "a"}.String(): eval.Alerting,
+		data.Labels{"host": "b"}.String(): eval.Normal,
+	}, states)
+}
+
+func TestAlertingTickerRecyclesRoutines(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.maxRoutineLifetime = time.Second
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		return eval.Results{{Instance: data.Labels{"host": "a"}, State: eval.Alerting}}, nil
+	})
+
+	alert := createTestAlertDefinition(t, ng, 1)
+	key := getKey(alert)
+
+	evalAppliedCh := make(chan evalAppliedInfo, 1)
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		_ = ng.alertingTicker(ctx)
+	}()
+	runtime.Gosched()
+
+	tick := advanceClock(t, mockedClock)
+	assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+	instances := ng.schedule.stateTracker.get(key)
+	require.Len(t, instances, 1)
+	firingSince := instances[0].FiringSince
+
+	// the routine exits once its evaluation reaching its lifetime is over
+	tick = advanceClock(t, mockedClock)
+	assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+	info, ok := ng.schedule.registry.get(key)
+	require.True(t, ok)
+	require.Eventually(t, func() bool {
+		return !info.isAlive()
+	}, time.Second, 10*time.Millisecond)
+	assert.True(t, info.isRecycled())
+
+	// and it's restarted by the next tick
+	tick = advanceClock(t, mockedClock)
+	assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+
+	info, ok = ng.schedule.registry.get(key)
+	require.True(t, ok)
+	assert.True(t, info.isAlive())
+	assert.False(t, info.isRecycled())
+	assert.Equal(t, alert.Version, ng.schedule.registry.versions()[key])
+
+	instances = ng.schedule.stateTracker.get(key)
+	require.Len(t, instances, 1)
+	assert.Equal(t, eval.Alerting, instances[0].State)
+	assert.Equal(t, firingSince, instances[0].FiringSince, "the instances should keep their state across the recycle")
+}
+
+func TestAlertingTickerDispatchLatency(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		return nil, nil
+	})
+
+	// the evaluations are dispatched every 250ms after the tick
+	alerts := make([]*AlertDefinition, 4)
+	for i := range alerts {
+		alerts[i] = createTestAlertDefinition(t, ng, 1)
+	}
+
+	evalAppliedCh := make(chan evalAppliedInfo, len(alerts))
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	latency := func() (uint64, float64) {
+		var m dto.Metric
+		require.NoError(t, dispatchLatency.Write(&m))
+		return m.GetHistogram().GetSampleCount(), m.GetHistogram().GetSampleSum()
+	}
+	countBefore, sumBefore := latency()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		_ = ng.alertingTicker(ctx)
+	}()
+	runtime.Gosched()
+
+	advanceClock(t, mockedClock)
+	for range alerts {
+		select {
+		case <-evalAppliedCh:
+		case <-time.After(100 * time.Millisecond):
+			mockedClock.Add(250 * time.Millisecond)
+			<-evalAppliedCh
+		}
+	}
+
+	require.Eventually(t, func() bool {
+		count, _ := latency()
+		return count-countBefore == uint64(len(alerts))
+	}, time.Second, 10*time.Millisecond)
+	_, sum := latency()
+	assert.InDelta(t, 0+0.25+0.5+0.75, sum-sumBefore, 1e-9)
+}
+
+func TestScheduleStartupDelay(t *testing.T) {
+	delays := func(seed int64, maxStartupDelay time.Duration) []time.Duration {
+		sch := newScheduler(clock.NewMock(), time.Second, log.New("ngalert.schedule.test"), nil)
+		sch.maxStartupDelay = maxStartupDelay
+		sch.setSeed(seed)
+
+		delays := make([]time.Duration, 0, 10)
+		for i := 0; i < 10; i++ {
+			delays = append(delays, sch.startupDelay())
+		}
+		return delays
+	}
+
+	first := delays(42, time.Minute)
+	assert.Equal(t, first, delays(42, time.Minute), "schedulers with the same seed should produce identical delays")
+	for _, delay := range first {
+		assert.Less(t, int64(delay), int64(time.Second), "the delay should be bounded by the base interval")
+	}
+	assert.Equal(t, make([]time.Duration, 10), delays(42, 0))
+}
+
+func TestAlertingTickerStartupDelay(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.maxStartupDelay = time.Second
+	ng.schedule.evalAtDispatchTime = true
+	ng.schedule.setSeed(42)
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		return nil, nil
+	})
+
+	const routines = 20
+	store := newInMemoryDefinitionStore()
+	for i := 1; i <= routines; i++ {
+		store.add(&AlertDefinition{ID: int64(i), OrgID: 1, UID: fmt.Sprintf("uid-%d", i), Condition: "A", IntervalSeconds: 1, Version: 1, Enabled: true})
+	}
+	ng.SetDefinitionStore(store)
+
+	var mu sync.Mutex
+	firstEvaluations := make(map[int64]time.Time, routines)
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		mu.Lock()
+		defer mu.Unlock()
+		if _, ok := firstEvaluations[alertDefID]; !ok {
+			firstEvaluations[alertDefID] = now
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = ng.alertingTicker(ctx)
+	}()
+	runtime.Gosched()
+
+	tick := advanceClock(t, mockedClock)
+	// the delayed first evaluations are dispatched as the clock reaches them
+	for i := 0; i < 20; i++ {
+		mockedClock.Add(100 * time.Millisecond)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, firstEvaluations, routines)
+	distinct := make(map[time.Time]struct{}, routines)
+	delayedPastNextTick := 0
+	for _, at := range firstEvaluations {
+		distinct[at] = struct{}{}
+		assert.False(t, at.Before(tick))
+		if !at.Before(tick.Add(time.Second)) {
+			delayedPastNextTick++
+		}
+	}
+	assert.Len(t, distinct, routines, "the first evaluations should not coincide")
+	assert.Greater(t, delayedPastNextTick, 0, "the first evaluations should be spread beyond the dispatch spread of a tick")
+}
+
+func TestAlertingTickerAlignmentOffset(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	baseInterval := 30 * time.Second
+	ng.schedule = newScheduler(mockedClock, baseInterval, log.New("ngalert.schedule.test"), nil)
+
+	alert := createTestAlertDefinition(t, ng, 60)
+	var interval int64 = 60
+	offset := eval.Duration(30 * time.Second)
+	err := ng.updateAlertDefinition(&updateAlertDefinitionCommand{
+		ID:              alert.ID,
+		OrgID:           alert.OrgID,
+		IntervalSeconds: &interval,
+		AlignmentOffset: &offset,
+	})
+	require.NoError(t, err)
+
+	// the scheduler fetches the alignment offset
+	q := listAlertDefinitionsQuery{}
+	require.NoError(t, ng.getAlertDefinitions(&q))
+	require.Len(t, q.Result, 1)
+	assert.Equal(t, 30*time.Second, q.Result[0].AlignmentOffset)
+
+	evalAppliedCh := make(chan evalAppliedInfo, 1)
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		_ = ng.alertingTicker(ctx)
+	}()
+	runtime.Gosched()
+
+	// the alert definition is evaluated at :30 of each minute
+	for _, expectedEval := range []bool{true, false, true, false} {
+		mockedClock.Add(baseInterval)
+		tick := mockedClock.Now()
+		t.Logf("tick at :%02d", tick.Unix()%60)
+		if expectedEval {
+			assert.Equal(t, int64(30), tick.Unix()%60)
+			assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+		} else {
+			assertEvalRun(t, evalAppliedCh, tick)
+		}
+	}
+}
+
+func TestValidateAlignmentOffset(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	alert := &AlertDefinition{IntervalSeconds: 60}
+	for _, tc := range []struct {
+		offset time.Duration
+		valid  bool
+	}{
+		{offset: 0, valid: true},
+		{offset: 30 * time.Second, valid: true},
+		{offset: -10 * time.Second, valid: false},
+		{offset: 1500 * time.Millisecond, valid: false},
+		{offset: time.Minute, valid: false},
+	} {
+		alert.AlignmentOffset = tc.offset
+		err := ng.validateAlignmentOffset(alert)
+		if tc.valid {
+			assert.NoError(t, err, tc.offset)
+		} else {
+			assert.Error(t, err, tc.offset)
+		}
+	}
+}
+
+func TestRunDefinitionRoutineWrapsError(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+	ng.schedule = newScheduler(clock.NewMock(), time.Second, log.New("ngalert.schedule.test"), nil)
+
+	alert := createTestAlertDefinition(t, ng, 1)
+	key := getKey(alert)
+
+	// the routine fails once grafana is cancelled without the scheduler being drained
+	ctx, cancel := context.WithCancel(context.Background())
+	info := ng.schedule.registry.getOrCreateInfo(ctx, key, alert.ID, alert.UID, alert.OrgID, alert.Version, "")
+	cancel()
+
+	err := ng.runDefinitionRoutine(ctx, key, info)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Contains(t, err.Error(), fmt.Sprintf("alert definition routine %s of organisation %d failed", key, alert.OrgID))
+}
+
+func TestScheduleMaxDispatchOffset(t *testing.T) {
+	sch := newScheduler(clock.NewMock(), 10*time.Second, log.New("ngalert.schedule.test"), nil)
+	sch.maxDispatchOffset = 2 * time.Second
+	sch.dispatchJitter = time.Second
+	sch.setSeed(42)
+
+	// the evaluations due on the tick are packed within the cap
+	const ready = 500
+	assert.Equal(t, 2*time.Second, sch.dispatchSpread())
+	step := sch.dispatchSpread().Nanoseconds() / ready
+	for i := 0; i < ready; i++ {
+		offset := sch.dispatchOffset(i, step)
+		assert.LessOrEqual(t, int64(offset), int64(sch.maxDispatchOffset), "offset of evaluation %d", i)
+		assert.GreaterOrEqual(t, int64(offset), int64(time.Duration(i)*time.Duration(step)), "offset of evaluation %d", i)
+	}
+	assert.Equal(t, 2*time.Second, sch.capDispatchOffset(time.Minute), "the startup delay should be capped too")
+
+	// a cap larger than the base interval does not spread the evaluations further
+	sch.maxDispatchOffset = time.Minute
+	assert.Equal(t, 10*time.Second, sch.dispatchSpread())
+	sch.maxDispatchOffset = 0
+	assert.Equal(t, 10*time.Second, sch.dispatchSpread())
+	assert.Equal(t, time.Minute, sch.capDispatchOffset(time.Minute))
+}
@@ -0,0 +1,206 @@
+package ngalert
+
+import (
+	"container/list"
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+)
+
+// evalPriority is the priority of an evaluation waiting for a slot of the evalSemaphore.
+type evalPriority int
+
+const (
+	lowPriority evalPriority = iota
+	highPriority
+)
+
+// defaultPriorityAging is the time after which a low priority evaluation
+// waiting for a slot is served before the high priority ones.
+const defaultPriorityAging = 10 * time.Second
+
+// priorityOf returns the priority of the evaluations of the alert definition.
+func priorityOf(alertDefinition *AlertDefinition) evalPriority {
+	if alertDefinition.Priority > 0 {
+		return highPriority
+	}
+	return lowPriority
+}
+
+// evalSemaphore limits the number of alert definitions evaluated concurrently
+// and instruments the time spent waiting for a slot.
+// The high priority evaluations waiting for a slot are served first
+// unless a low priority one has been waiting for longer than the aging time,
+// so that the low priority evaluations are not starved.
+type evalSemaphore struct {
+	// size is the maximum number of concurrent evaluations;
+	// if it's not positive the number is not limited.
+	size int
+	// aging if positive is the time after which a waiting low priority evaluation is served first.
+	aging time.Duration
+	// clock measures the waiting times
+	clock clock.Clock
+
+	mu    sync.Mutex
+	inUse int
+	// waiters are the FIFO queues of the waiting evaluations by priority
+	waiters [2]*list.List
+}
+
+// semaphoreWaiter is an evaluation waiting for a slot;
+// ready is closed once the slot is handed over to it.
+type semaphoreWaiter struct {
+	ready chan struct{}
+	since time.Time
+}
+
+// newEvalSemaphore returns a new evalSemaphore.
+// If size is not positive the number of concurrent evaluations is not limited.
+func newEvalSemaphore(c clock.Clock, size int) *evalSemaphore {
+	return &evalSemaphore{
+		size:    size,
+		aging:   defaultPriorityAging,
+		clock:   c,
+		waiters: [2]*list.List{list.New(), list.New()},
+	}
+}
+
+// acquire blocks until a slot is available or the context is done.
+func (s *evalSemaphore) acquire(ctx context.Context, priority evalPriority) error {
+	if s.size <= 0 {
+		evalInFlight.Inc()
+		return nil
+	}
+
+	start := s.clock.Now()
+	s.mu.Lock()
+	if s.inUse < s.size && s.waiters[lowPriority].Len() == 0 && s.waiters[highPriority].Len() == 0 {
+		s.inUse++
+		s.mu.Unlock()
+		evalWaitDuration.Observe(s.clock.Since(start).Seconds())
+		evalInFlight.Inc()
+		return nil
+	}
+	w := &semaphoreWaiter{ready: make(chan struct{}), since: start}
+	elem := s.waiters[priority].PushBack(w)
+	s.mu.Unlock()
+
+	evalWaiting.Inc()
+	defer evalWaiting.Dec()
+
+	select {
+	case <-w.ready:
+		evalWaitDuration.Observe(s.clock.Since(start).Seconds())
+		evalInFlight.Inc()
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		select {
+		case <-w.ready:
+			// the slot has been handed over meanwhile: pass it on
+			s.handOver()
+		default:
+			s.waiters[priority].Remove(elem)
+		}
+		return ctx.Err()
+	}
+}
+
+// release frees a slot previously acquired.
+func (s *evalSemaphore) release() {
+	evalInFlight.Dec()
+	if s.size <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handOver()
+}
+
+// handOver hands the slot of a completed evaluation over to the next waiting one, if any,
+// otherwise it frees the slot. It should be called with the lock held.
+func (s *evalSemaphore) handOver() {
+	next := s.waiters[highPriority]
+	if low := s.waiters[lowPriority].Front(); low != nil {
+		aged := s.aging > 0 && s.clock.Since(low.Value.(*semaphoreWaiter).since) >= s.aging
+		if aged || next.Len() == 0 {
+			next = s.waiters[lowPriority]
+		}
+	}
+
+	front := next.Front()
+	if front == nil {
+		s.inUse--
+		return
+	}
+	next.Remove(front)
+	close(front.Value.(*semaphoreWaiter).ready)
+}
+
+// usage returns the number of slots in use and the number of evaluations waiting for one.
+func (s *evalSemaphore) usage() (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inUse, s.waiters[lowPriority].Len() + s.waiters[highPriority].Len()
+}
+
+// waiting returns the number of evaluations waiting for a slot with the priority.
+func (s *evalSemaphore) waiting(priority evalPriority) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.waiters[priority].Len()
+}
+
+// orgEvalSemaphores limit the number of alert definitions of the same organisation
+// evaluated concurrently so that an organisation can't consume the whole global budget.
+type orgEvalSemaphores struct {
+	// size is the maximum number of concurrent evaluations per organisation;
+	// if it's not positive the number is not limited.
+	size int
+
+	mu    sync.Mutex
+	slots map[int64]chan struct{}
+}
+
+// newOrgEvalSemaphores returns a new orgEvalSemaphores.
+// If maxConcurrentPerOrg is not positive the number of concurrent evaluations per organisation is not limited.
+func newOrgEvalSemaphores(maxConcurrentPerOrg int) *orgEvalSemaphores {
+	return &orgEvalSemaphores{size: maxConcurrentPerOrg, slots: make(map[int64]chan struct{})}
+}
+
+func (s *orgEvalSemaphores) get(orgID int64) chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	slots, ok := s.slots[orgID]
+	if !ok {
+		slots = make(chan struct{}, s.size)
+		s.slots[orgID] = slots
+	}
+	return slots
+}
+
+// acquire blocks until a slot of the organisation is available or the context is done.
+func (s *orgEvalSemaphores) acquire(ctx context.Context, orgID int64) error {
+	if s.size > 0 {
+		select {
+		case s.get(orgID) <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	evalInFlightPerOrg.WithLabelValues(strconv.FormatInt(orgID, 10)).Inc()
+	return nil
+}
+
+// release frees a slot of the organisation previously acquired.
+func (s *orgEvalSemaphores) release(orgID int64) {
+	evalInFlightPerOrg.WithLabelValues(strconv.FormatInt(orgID, 10)).Dec()
+	if s.size > 0 {
+		<-s.get(orgID)
+	}
+}
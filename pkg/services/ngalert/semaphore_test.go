@@ -0,0 +1,209 @@
+package ngalert
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvalSemaphoreMetrics(t *testing.T) {
+	sem := newEvalSemaphore(clock.New(), 1)
+	ctx := context.Background()
+
+	require.NoError(t, sem.acquire(ctx, lowPriority))
+	assert.Equal(t, float64(1), testutil.ToFloat64(evalInFlight))
+
+	acquired := make(chan struct{})
+	go func() {
+		err := sem.acquire(ctx, lowPriority)
+		require.NoError(t, err)
+		close(acquired)
+	}()
+
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(evalWaiting) > 0
+	}, time.Second, 10*time.Millisecond)
+
+	sem.release()
+	<-acquired
+	assert.Equal(t, float64(0), testutil.ToFloat64(evalWaiting))
+	assert.Equal(t, float64(1), testutil.ToFloat64(evalInFlight))
+
+	sem.release()
+	assert.Equal(t, float64(0), testutil.ToFloat64(evalInFlight))
+}
+
+func TestAlertingTickerMaxConcurrentPerOrg(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.orgEvalSemaphores = newOrgEvalSemaphores(1)
+
+	// the evaluations of the 1st organisation block until released
+	release := make(chan struct{})
+	var released sync.Once
+	t.Cleanup(func() { released.Do(func() { close(release) }) })
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(ctx context.Context, condition *eval.Condition, _ time.Time) (eval.Results, error) {
+		if condition.OrgID == 1 {
+			select {
+			case <-release:
+			case <-ctx.Done():
+			}
+		}
+		return nil, nil
+	})
+
+	first := createTestAlertDefinition(t, ng, 1)
+	second := createTestAlertDefinition(t, ng, 1)
+	var intervalSeconds int64 = 1
+	cmd := saveAlertDefinitionCommand{
+		OrgID:           2,
+		Title:           "another organisation",
+		Condition:       first.getCondition(),
+		IntervalSeconds: &intervalSeconds,
+	}
+	require.NoError(t, ng.saveAlertDefinition(&cmd))
+	other := cmd.Result
+
+	evalAppliedCh := make(chan evalAppliedInfo, 3)
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	ctx := context.Background()
+	go func() {
+		err := ng.alertingTicker(ctx)
+		require.NoError(t, err)
+	}()
+	runtime.Gosched()
+
+	// the three alert definitions are spread within the tick
+	advanceClock(t, mockedClock)
+	mockedClock.Add(time.Second / 3)
+	mockedClock.Add(time.Second / 3)
+
+	t.Run("the saturated organisation should not block another organisation", func(t *testing.T) {
+		select {
+		case info := <-evalAppliedCh:
+			assert.Equal(t, other.ID, info.alertDefID)
+		case <-time.After(time.Second):
+			t.Fatal("the alert definition of the other organisation was not evaluated")
+		}
+		// only one evaluation of the saturated organisation runs
+		require.Eventually(t, func() bool {
+			return testutil.ToFloat64(evalInFlightPerOrg.WithLabelValues("1")) == 1
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("the waiting evaluation should run once a slot of its organisation is released", func(t *testing.T) {
+		released.Do(func() { close(release) })
+		seen := make(map[int64]struct{})
+		for i := 0; i < 2; i++ {
+			select {
+			case info := <-evalAppliedCh:
+				seen[info.alertDefID] = struct{}{}
+			case <-time.After(time.Second):
+				t.Fatal("the alert definitions of the saturated organisation were not evaluated")
+			}
+		}
+		assert.Contains(t, seen, first.ID)
+		assert.Contains(t, seen, second.ID)
+	})
+}
+
+func TestEvalSemaphorePriority(t *testing.T) {
+	// acquireAsync returns a channel closed once the slot is acquired
+	acquireAsync := func(sem *evalSemaphore, priority evalPriority) chan struct{} {
+		acquired := make(chan struct{})
+		go func() {
+			err := sem.acquire(context.Background(), priority)
+			require.NoError(t, err)
+			close(acquired)
+		}()
+		require.Eventually(t, func() bool {
+			select {
+			case <-acquired:
+				return true
+			default:
+				return sem.waiting(priority) > 0
+			}
+		}, time.Second, time.Millisecond)
+		return acquired
+	}
+	isAcquired := func(ch chan struct{}) bool {
+		select {
+		case <-ch:
+			return true
+		case <-time.After(50 * time.Millisecond):
+			return false
+		}
+	}
+
+	t.Run("a high priority evaluation should acquire a slot before a waiting low priority one", func(t *testing.T) {
+		sem := newEvalSemaphore(clock.NewMock(), 1)
+		require.NoError(t, sem.acquire(context.Background(), lowPriority))
+
+		low := acquireAsync(sem, lowPriority)
+		high := acquireAsync(sem, highPriority)
+
+		sem.release()
+		assert.True(t, isAcquired(high))
+		assert.False(t, isAcquired(low))
+
+		sem.release()
+		assert.True(t, isAcquired(low))
+		sem.release()
+	})
+
+	t.Run("an aged low priority evaluation should acquire a slot before a high priority one", func(t *testing.T) {
+		mockedClock := clock.NewMock()
+		sem := newEvalSemaphore(mockedClock, 1)
+		require.NoError(t, sem.acquire(context.Background(), lowPriority))
+
+		low := acquireAsync(sem, lowPriority)
+		mockedClock.Add(defaultPriorityAging)
+		high := acquireAsync(sem, highPriority)
+
+		sem.release()
+		assert.True(t, isAcquired(low))
+		assert.False(t, isAcquired(high))
+
+		sem.release()
+		assert.True(t, isAcquired(high))
+		sem.release()
+	})
+
+	t.Run("a cancelled evaluation should leave the queue", func(t *testing.T) {
+		sem := newEvalSemaphore(clock.NewMock(), 1)
+		require.NoError(t, sem.acquire(context.Background(), lowPriority))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancelled := make(chan error)
+		go func() {
+			cancelled <- sem.acquire(ctx, highPriority)
+		}()
+		require.Eventually(t, func() bool {
+			return sem.waiting(highPriority) == 1
+		}, time.Second, time.Millisecond)
+		cancel()
+		assert.True(t, errors.Is(<-cancelled, context.Canceled))
+		assert.Equal(t, 0, sem.waiting(highPriority))
+
+		sem.release()
+		require.NoError(t, sem.acquire(context.Background(), lowPriority))
+		sem.release()
+	})
+}
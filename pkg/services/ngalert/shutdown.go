@@ -0,0 +1,89 @@
+package ngalert
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// shutdown stops the alert definition routines in phases once grafana is shutting down:
+// the ticker has already stopped dispatching new evaluations, then the routines
+// are drained so that the evaluations in flight complete within the shutdown grace period,
+// and finally the evaluations still running are cancelled.
+// If the organisations have shutdown priorities the grace period is split evenly
+// between the priority levels and the evaluations of the lowest priority organisations
+// still running are cancelled first, so that the higher priority ones are given the most time.
+func (ng *AlertNG) shutdown(dispatcherGroup *errgroup.Group, cancelRoutines context.CancelFunc) error {
+	ng.schedule.log.Info("alert definition scheduler stopping", "gracePeriod", ng.schedule.shutdownGracePeriod)
+	close(ng.schedule.draining)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- dispatcherGroup.Wait()
+	}()
+
+	if ng.schedule.shutdownGracePeriod > 0 {
+		levels := ng.schedule.shutdownPriorityLevels()
+		phase := ng.schedule.shutdownGracePeriod / time.Duration(len(levels))
+		for i, level := range levels {
+			select {
+			case err := <-done:
+				ng.schedule.log.Info("alert definition scheduler drained")
+				return err
+			case <-ng.schedule.clock.After(phase):
+			}
+			if i == len(levels)-1 {
+				ng.schedule.log.Warn("shutdown grace period expired; cancelling the evaluations in flight", "gracePeriod", ng.schedule.shutdownGracePeriod)
+				break
+			}
+			cancelled := ng.schedule.registry.cancelWhere(func(info alertDefinitionInfo) bool {
+				return ng.schedule.orgShutdownPriorities[info.orgID] <= level
+			})
+			ng.schedule.log.Warn("cancelling the evaluations in flight of the lower priority organisations", "priority", level, "routines", cancelled)
+		}
+	}
+
+	cancelRoutines()
+	return <-done
+}
+
+// shutdownPriorityLevels returns the distinct shutdown priorities of the organisations
+// in ascending order, including the default 0 priority of the other organisations.
+func (sch *schedule) shutdownPriorityLevels() []int {
+	levels := []int{0}
+	seen := map[int]struct{}{0: {}}
+	for _, priority := range sch.orgShutdownPriorities {
+		if _, ok := seen[priority]; !ok {
+			seen[priority] = struct{}{}
+			levels = append(levels, priority)
+		}
+	}
+	sort.Ints(levels)
+	return levels
+}
+
+// parseOrgPriorities parses the orgID:priority pairs, e.g. 1:10.
+func parseOrgPriorities(pairs []string) (map[int64]int, error) {
+	priorities := make(map[int64]int, len(pairs))
+	for _, pair := range pairs {
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid organisation priority %q: it should be orgID:priority", pair)
+		}
+		orgID, err := strconv.ParseInt(strings.TrimSpace(parts[0]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid organisation ID in %q: %w", pair, err)
+		}
+		priority, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid priority in %q: %w", pair, err)
+		}
+		priorities[orgID] = priority
+	}
+	return priorities, nil
+}
@@ -0,0 +1,191 @@
+package ngalert
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertingTickerShutdownGracePeriod(t *testing.T) {
+	const gracePeriod = 10 * time.Second
+
+	setup := func(t *testing.T) (*AlertNG, *clock.Mock, chan struct{}, chan error) {
+		ng := setupTestEnv(t)
+		t.Cleanup(registry.ClearOverrides)
+
+		mockedClock := clock.NewMock()
+		ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+		ng.schedule.shutdownGracePeriod = gracePeriod
+
+		started := make(chan struct{}, 1)
+		release := make(chan struct{})
+		evalErrs := make(chan error, 1)
+		ng.schedule.evaluator = eval.EvaluatorFunc(func(ctx context.Context, _ *eval.Condition, _ time.Time) (eval.Results, error) {
+			started <- struct{}{}
+			select {
+			case <-release:
+				evalErrs <- nil
+				return nil, nil
+			case <-ctx.Done():
+				evalErrs <- ctx.Err()
+				return nil, ctx.Err()
+			}
+		})
+		createTestAlertDefinition(t, ng, 1)
+
+		grafanaCtx, cancel := context.WithCancel(context.Background())
+		stopped := make(chan error, 1)
+		go func() {
+			stopped <- ng.alertingTicker(grafanaCtx)
+		}()
+		runtime.Gosched()
+
+		advanceClock(t, mockedClock)
+		<-started
+
+		// SIGTERM: grafana starts shutting down
+		cancel()
+		return ng, mockedClock, release, mergeErrs(stopped, evalErrs)
+	}
+
+	t.Run("the evaluations in flight should complete within the grace period", func(t *testing.T) {
+		_, _, release, errs := setup(t)
+
+		select {
+		case <-errs:
+			t.Fatal("the evaluation in flight should not be cancelled nor the ticker stopped")
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		close(release)
+		// the evaluation completes successfully then the ticker stops
+		require.NoError(t, <-errs)
+		require.NoError(t, <-errs)
+	})
+
+	t.Run("the evaluations in flight should be cancelled once the grace period expires", func(t *testing.T) {
+		_, mockedClock, _, errs := setup(t)
+
+		var evalErr error
+		require.Eventually(t, func() bool {
+			mockedClock.Add(gracePeriod)
+			select {
+			case evalErr = <-errs:
+				return true
+			default:
+				return false
+			}
+		}, time.Second, 10*time.Millisecond)
+		assert.True(t, errors.Is(evalErr, context.Canceled))
+		<-errs
+	})
+}
+
+// mergeErrs returns a channel receiving the errors of the evaluation and then of the ticker.
+func mergeErrs(stopped, evalErrs <-chan error) chan error {
+	errs := make(chan error, 2)
+	go func() {
+		errs <- <-evalErrs
+		errs <- <-stopped
+	}()
+	return errs
+}
+
+func TestAlertingTickerShutdownOrgPriorities(t *testing.T) {
+	const gracePeriod = 10 * time.Second
+
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.shutdownGracePeriod = gracePeriod
+	ng.schedule.orgShutdownPriorities = map[int64]int{1: 10}
+
+	started := make(chan int64, 2)
+	release := make(chan struct{})
+	evalErrs := map[int64]chan error{1: make(chan error, 1), 2: make(chan error, 1)}
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(ctx context.Context, condition *eval.Condition, _ time.Time) (eval.Results, error) {
+		started <- condition.OrgID
+		select {
+		case <-release:
+			evalErrs[condition.OrgID] <- nil
+			return nil, nil
+		case <-ctx.Done():
+			evalErrs[condition.OrgID] <- ctx.Err()
+			return nil, ctx.Err()
+		}
+	})
+
+	store := newInMemoryDefinitionStore()
+	store.add(
+		&AlertDefinition{ID: 1, OrgID: 1, UID: "high", Condition: "A", IntervalSeconds: 1, Version: 1, Enabled: true},
+		&AlertDefinition{ID: 2, OrgID: 2, UID: "low", Condition: "A", IntervalSeconds: 1, Version: 1, Enabled: true},
+	)
+	ng.SetDefinitionStore(store)
+
+	grafanaCtx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- ng.alertingTicker(grafanaCtx)
+	}()
+	runtime.Gosched()
+
+	advanceClock(t, mockedClock)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(100 * time.Millisecond):
+			// the second dispatched evaluation is offset by half the base interval
+			mockedClock.Add(500 * time.Millisecond)
+			<-started
+		}
+	}
+
+	// SIGTERM: grafana starts shutting down
+	cancel()
+
+	// the grace period is split between the two priority levels:
+	// the evaluation of the low priority organisation is cancelled first
+	var lowErr error
+	require.Eventually(t, func() bool {
+		mockedClock.Add(time.Second)
+		select {
+		case lowErr = <-evalErrs[2]:
+			return true
+		default:
+			return false
+		}
+	}, time.Second, 10*time.Millisecond)
+	assert.True(t, errors.Is(lowErr, context.Canceled))
+
+	// the evaluation of the high priority organisation completes within the rest of the grace period
+	select {
+	case err := <-evalErrs[1]:
+		t.Fatalf("the evaluation of the high priority organisation should not be cancelled: %v", err)
+	default:
+	}
+	close(release)
+	require.NoError(t, <-evalErrs[1])
+	require.NoError(t, <-stopped)
+}
+
+func TestParseOrgPriorities(t *testing.T) {
+	priorities, err := parseOrgPriorities([]string{"1:10", " 2 : 5 "})
+	require.NoError(t, err)
+	assert.Equal(t, map[int64]int{1: 10, 2: 5}, priorities)
+
+	for _, invalid := range []string{"1", "a:1", "1:a"} {
+		_, err := parseOrgPriorities([]string{invalid})
+		assert.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,163 @@
+package ngalert
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+var errSilenceInvalidWindow = errors.New("silence should end after it starts")
+
+// silenceMatcher matches the value of a label
+// either by equality or by an anchored regular expression.
+type silenceMatcher struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	IsRegex bool   `json:"isRegex"`
+
+	re *regexp.Regexp
+}
+
+func (m *silenceMatcher) compile() error {
+	if !m.IsRegex {
+		return nil
+	}
+	re, err := regexp.Compile("^(?:" + m.Value + ")$")
+	if err != nil {
+		return fmt.Errorf("invalid matcher %s: %w", m.Name, err)
+	}
+	m.re = re
+	return nil
+}
+
+func (m *silenceMatcher) matches(labels data.Labels) bool {
+	if m.IsRegex {
+		return m.re.MatchString(labels[m.Name])
+	}
+	return labels[m.Name] == m.Value
+}
+
+// silence suppresses the notifications of the firing alert instances
+// whose labels match all its matchers during its time window.
+type silence struct {
+	ID       string           `json:"id"`
+	Matchers []silenceMatcher `json:"matchers"`
+	StartsAt time.Time        `json:"startsAt"`
+	EndsAt   time.Time        `json:"endsAt"`
+}
+
+func (s *silence) isActive(now time.Time) bool {
+	return !now.Before(s.StartsAt) && now.Before(s.EndsAt)
+}
+
+func (s *silence) matches(labels data.Labels) bool {
+	for i := range s.Matchers {
+		if !s.Matchers[i].matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// silenceStore keeps the silences in memory.
+type silenceStore struct {
+	mu       sync.RWMutex
+	clock    clock.Clock
+	silences map[string]*silence
+}
+
+func newSilenceStore(c clock.Clock) *silenceStore {
+	return &silenceStore{
+		clock:    c,
+		silences: make(map[string]*silence),
+	}
+}
+
+// add stores the silence and returns its identifier.
+func (ss *silenceStore) add(s silence) (string, error) {
+	if !s.EndsAt.After(s.StartsAt) {
+		return "", errSilenceInvalidWindow
+	}
+	s.Matchers = append([]silenceMatcher(nil), s.Matchers...)
+	for i := range s.Matchers {
+		if err := s.Matchers[i].compile(); err != nil {
+			return "", err
+		}
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if s.ID == "" {
+		s.ID = util.GenerateShortUID()
+	}
+	ss.silences[s.ID] = &s
+	return s.ID, nil
+}
+
+// del removes the silence.
+func (ss *silenceStore) del(id string) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	delete(ss.silences, id)
+}
+
+// expire removes the silences that have ended.
+func (ss *silenceStore) expire() {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	now := ss.clock.Now()
+	for id, s := range ss.silences {
+		if !now.Before(s.EndsAt) {
+			delete(ss.silences, id)
+		}
+	}
+}
+
+// isSilenced returns true if an active silence matches the labels.
+func (ss *silenceStore) isSilenced(labels data.Labels) bool {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	now := ss.clock.Now()
+	for _, s := range ss.silences {
+		if s.isActive(now) && s.matches(labels) {
+			return true
+		}
+	}
+	return false
+}
+
+// markSilenced marks the firing instances matched by an active silence.
+// Silences are matched against the instance labels merged
+// with the labels identifying the alert definition.
+func (ss *silenceStore) markSilenced(alertDefinition *AlertDefinition, instances []alertInstance) {
+	for i := range instances {
+		if instances[i].State != eval.Alerting {
+			continue
+		}
+		instances[i].Silenced = ss.isSilenced(mergedLabels(alertDefinition, instances[i].Labels))
+	}
+}
+
+// mergedLabels returns the instance labels with the alert definition labels added;
+// the instance labels take precedence over the labels of the alert definition
+// which take precedence over its title and UID.
+func mergedLabels(alertDefinition *AlertDefinition, labels data.Labels) data.Labels {
+	merged := data.Labels{
+		"alertname":            alertDefinition.Title,
+		"alert_definition_uid": alertDefinition.UID,
+	}
+	for k, v := range alertDefinition.Labels {
+		merged[k] = v
+	}
+	for k, v := range labels {
+		merged[k] = v
+	}
+	return merged
+}
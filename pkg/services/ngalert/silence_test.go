@@ -0,0 +1,75 @@
+package ngalert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSilenceStoreMarkSilenced(t *testing.T) {
+	mockedClock := clock.NewMock()
+	ss := newSilenceStore(mockedClock)
+	st := newStateTracker(mockedClock)
+
+	alertDefinition := &AlertDefinition{OrgID: 1, UID: "uid", Title: "high load"}
+	key := getKey(alertDefinition)
+
+	_, err := ss.add(silence{
+		Matchers: []silenceMatcher{
+			{Name: "alertname", Value: "high load"},
+			{Name: "host", Value: "a|b", IsRegex: true},
+		},
+		StartsAt: mockedClock.Now(),
+		EndsAt:   mockedClock.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	results := eval.Results{
+		{Instance: data.Labels{"host": "a"}, State: eval.Alerting},
+		{Instance: data.Labels{"host": "c"}, State: eval.Alerting},
+		{Instance: data.Labels{"host": "b"}, State: eval.Normal},
+	}
+
+	instances := st.setResults(key, alertDefinition, results)
+	ss.markSilenced(alertDefinition, instances)
+	require.Len(t, instances, 3)
+	assert.True(t, instances[0].Silenced, "matching firing instance should be silenced")
+	assert.False(t, instances[1].Silenced, "non matching instance should not be silenced")
+	assert.False(t, instances[2].Silenced, "normal instance should not be silenced")
+
+	// the state of the silenced instance is preserved
+	for _, instance := range st.get(key) {
+		assert.False(t, instance.Silenced)
+		if instance.Labels["host"] == "a" {
+			assert.Equal(t, eval.Alerting, instance.State)
+		}
+	}
+
+	mockedClock.Add(time.Hour)
+	ss.expire()
+	require.Len(t, ss.silences, 0, "silence should expire")
+
+	instances = st.setResults(key, alertDefinition, results)
+	ss.markSilenced(alertDefinition, instances)
+	assert.False(t, instances[0].Silenced)
+}
+
+func TestSilenceStoreAdd(t *testing.T) {
+	mockedClock := clock.NewMock()
+	ss := newSilenceStore(mockedClock)
+
+	_, err := ss.add(silence{StartsAt: mockedClock.Now(), EndsAt: mockedClock.Now()})
+	require.Equal(t, errSilenceInvalidWindow, err)
+
+	_, err = ss.add(silence{
+		Matchers: []silenceMatcher{{Name: "host", Value: "(", IsRegex: true}},
+		StartsAt: mockedClock.Now(),
+		EndsAt:   mockedClock.Now().Add(time.Hour),
+	})
+	require.Error(t, err)
+}
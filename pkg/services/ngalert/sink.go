@@ -0,0 +1,116 @@
+package ngalert
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+)
+
+// defaultSinkBufferSize is the number of evaluations buffered per result sink.
+const defaultSinkBufferSize = 100
+
+// ResultSink receives the alert instances updated by every applied evaluation,
+// e.g. to forward them to an Alertmanager or to write the state history.
+type ResultSink interface {
+	Receive(instances []alertInstance) error
+}
+
+// ResultSinkFunc is a ResultSink function.
+type ResultSinkFunc func(instances []alertInstance) error
+
+// Receive calls f(instances).
+func (f ResultSinkFunc) Receive(instances []alertInstance) error {
+	return f(instances)
+}
+
+// resultSinks deliver the evaluated alert instances to every sink independently:
+// each sink has its own buffer and goroutine so that a slow sink drops its own batches
+// without delaying the evaluations, and a failing sink doesn't affect the other ones.
+type resultSinks struct {
+	log log.Logger
+
+	mu    sync.RWMutex
+	sinks map[string]*sinkQueue
+}
+
+type sinkQueue struct {
+	name string
+	sink ResultSink
+	ch   chan []alertInstance
+}
+
+func newResultSinks(logger log.Logger) *resultSinks {
+	return &resultSinks{log: logger, sinks: make(map[string]*sinkQueue)}
+}
+
+// add starts delivering the results to the sink buffering up to size evaluations
+// and returns the function removing it.
+func (s *resultSinks) add(name string, sink ResultSink, size int) (func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.sinks[name]; ok {
+		return nil, fmt.Errorf("result sink %s already exists", name)
+	}
+	if size <= 0 {
+		size = defaultSinkBufferSize
+	}
+	q := &sinkQueue{name: name, sink: sink, ch: make(chan []alertInstance, size)}
+	s.sinks[name] = q
+	go s.run(q)
+
+	var once sync.Once
+	remove := func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			delete(s.sinks, name)
+			close(q.ch)
+		})
+	}
+	return remove, nil
+}
+
+func (s *resultSinks) run(q *sinkQueue) {
+	for instances := range q.ch {
+		if err := s.deliver(q, instances); err != nil {
+			sinkErrors.WithLabelValues(q.name).Inc()
+			s.log.Error("result sink failed to receive the alert instances", "sink", q.name, "instances", len(instances), "error", err)
+		}
+	}
+}
+
+// deliver calls the sink recovering from its panics.
+func (s *resultSinks) deliver(q *sinkQueue, instances []alertInstance) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("result sink panicked: %v", r)
+		}
+	}()
+	return q.sink.Receive(instances)
+}
+
+// emit queues a copy of the instances for every sink
+// and drops them for the sinks whose buffer is full.
+func (s *resultSinks) emit(instances []alertInstance) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, q := range s.sinks {
+		batch := make([]alertInstance, len(instances))
+		copy(batch, instances)
+		select {
+		case q.ch <- batch:
+		default:
+			sinkDropped.WithLabelValues(q.name).Inc()
+		}
+	}
+}
+
+// AddResultSink adds a sink receiving the alert instances of every applied evaluation
+// buffering up to size evaluations, the default size if not positive.
+// It returns the function removing the sink.
+func (ng *AlertNG) AddResultSink(name string, sink ResultSink, size int) (func(), error) {
+	return ng.schedule.sinks.add(name, sink, size)
+}
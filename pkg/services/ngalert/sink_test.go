@@ -0,0 +1,104 @@
+package ngalert
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertingTickerResultSinks(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		return eval.Results{{Instance: data.Labels{"host": "a"}, State: eval.Alerting}}, nil
+	})
+
+	failed := make(chan struct{}, 2)
+	removeFailing, err := ng.AddResultSink("failing", ResultSinkFunc(func([]alertInstance) error {
+		failed <- struct{}{}
+		return errors.New("alertmanager unavailable")
+	}), 1)
+	require.NoError(t, err)
+	defer removeFailing()
+
+	received := make(chan []alertInstance, 2)
+	removeHealthy, err := ng.AddResultSink("healthy", ResultSinkFunc(func(instances []alertInstance) error {
+		received <- instances
+		return nil
+	}), 1)
+	require.NoError(t, err)
+	defer removeHealthy()
+
+	_, err = ng.AddResultSink("healthy", ResultSinkFunc(func([]alertInstance) error { return nil }), 1)
+	assert.Error(t, err, "the sink names should be unique")
+
+	errorsBefore := testutil.ToFloat64(sinkErrors.WithLabelValues("failing"))
+
+	alert := createTestAlertDefinition(t, ng, 1)
+
+	evalAppliedCh := make(chan evalAppliedInfo, 1)
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = ng.alertingTicker(ctx)
+	}()
+	runtime.Gosched()
+
+	tick := advanceClock(t, mockedClock)
+	assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+
+	select {
+	case instances := <-received:
+		require.Len(t, instances, 1)
+		assert.Equal(t, alert.UID, instances[0].DefinitionUID)
+		assert.Equal(t, eval.Alerting, instances[0].State)
+	case <-time.After(time.Second):
+		t.Fatal("the healthy sink should receive the results despite the failing one")
+	}
+
+	<-failed
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(sinkErrors.WithLabelValues("failing"))-errorsBefore == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestResultSinksPanicIsolation(t *testing.T) {
+	sinks := newResultSinks(log.New("ngalert.sink.test"))
+
+	remove, err := sinks.add("panicking", ResultSinkFunc(func([]alertInstance) error {
+		panic("unexpected")
+	}), 1)
+	require.NoError(t, err)
+	defer remove()
+
+	received := make(chan []alertInstance, 2)
+	removeHealthy, err := sinks.add("healthy", ResultSinkFunc(func(instances []alertInstance) error {
+		received <- instances
+		return nil
+	}), 2)
+	require.NoError(t, err)
+	defer removeHealthy()
+
+	sinks.emit([]alertInstance{{DefinitionUID: "first"}})
+	sinks.emit([]alertInstance{{DefinitionUID: "second"}})
+	assert.Equal(t, "first", (<-received)[0].DefinitionUID)
+	assert.Equal(t, "second", (<-received)[0].DefinitionUID, "a panicking sink should not stop the other ones")
+}
@@ -0,0 +1,44 @@
+package ngalert
+
+import (
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+// staleness tracks whether the state of an alert definition is reliable:
+// the alert definition is stale once its last successful evaluation
+// is older than its maximum staleness, e.g. because its evaluations keep failing.
+type staleness struct {
+	lastSuccess time.Time
+	stale       bool
+}
+
+// update records the outcome of the evaluation at the given time
+// and returns true if the alert definition became stale or fresh again.
+func (s *staleness) update(maxStaleness time.Duration, now time.Time, err error) bool {
+	if err == nil {
+		s.lastSuccess = now
+	}
+	stale := err != nil && maxStaleness > 0 && now.Sub(s.lastSuccess) >= maxStaleness
+	if stale == s.stale {
+		return false
+	}
+	s.stale = stale
+	return true
+}
+
+// staleInstance returns the instance of the stale event of the alert definition:
+// it has the labels of the alert definition and was last evaluated successfully at lastSuccess.
+func staleInstance(key string, alertDefinition *AlertDefinition, lastSuccess time.Time) alertInstance {
+	return alertInstance{
+		DefinitionKey:   key,
+		OrgID:           alertDefinition.OrgID,
+		DefinitionUID:   alertDefinition.UID,
+		DefinitionTitle: alertDefinition.Title,
+		Labels:          data.Labels(alertDefinition.Labels),
+		State:           eval.Error,
+		LastEvaluatedAt: lastSuccess,
+	}
+}
@@ -0,0 +1,109 @@
+package ngalert
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertingTickerStaleEvent(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.setMaxAttempts(1)
+	var failing int32 = 1
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		if atomic.LoadInt32(&failing) == 1 {
+			return nil, errors.New("datasource unavailable")
+		}
+		return eval.Results{}, nil
+	})
+
+	store := newInMemoryDefinitionStore()
+	alert := &AlertDefinition{
+		ID:              1,
+		OrgID:           1,
+		UID:             "stale",
+		Title:           "a stale alert definition",
+		Condition:       "A",
+		IntervalSeconds: 1,
+		Version:         1,
+		Enabled:         true,
+		MaxStaleness:    3 * time.Second,
+	}
+	store.add(alert)
+	ng.SetDefinitionStore(store)
+
+	events, unsubscribe := ng.schedule.subscribers.subscribe(10)
+	defer unsubscribe()
+
+	evalAppliedCh := make(chan evalAppliedInfo, 1)
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	ctx := context.Background()
+	go func() {
+		err := ng.alertingTicker(ctx)
+		require.NoError(t, err)
+	}()
+	runtime.Gosched()
+
+	// the routine starts on the first tick; the evaluations fail within the maximum staleness
+	start := mockedClock.Now().Add(time.Second)
+	for i := 0; i < 3; i++ {
+		tick := advanceClock(t, mockedClock)
+		assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+	}
+	assert.Empty(t, events)
+
+	tick := advanceClock(t, mockedClock)
+	assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+	require.Len(t, events, 1)
+	event := <-events
+	assert.True(t, event.Stale)
+	assert.Equal(t, alert.UID, event.Instance.DefinitionUID)
+	assert.Equal(t, start, event.Instance.LastEvaluatedAt)
+
+	// the stale event is only emitted once
+	tick = advanceClock(t, mockedClock)
+	assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+	assert.Empty(t, events)
+
+	// once an evaluation succeeds the alert definition is fresh
+	// and it's stale again only after the maximum staleness
+	atomic.StoreInt32(&failing, 0)
+	tick = advanceClock(t, mockedClock)
+	assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+	atomic.StoreInt32(&failing, 1)
+	tick = advanceClock(t, mockedClock)
+	assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+	assert.Empty(t, events)
+}
+
+func TestStaleness(t *testing.T) {
+	start := time.Unix(0, 0)
+	s := staleness{lastSuccess: start}
+	failure := errors.New("failure")
+
+	assert.False(t, s.update(0, start.Add(time.Hour), failure), "without maximum staleness the alert definition is never stale")
+	assert.False(t, s.update(time.Minute, start.Add(30*time.Second), failure))
+	assert.True(t, s.update(time.Minute, start.Add(time.Minute), failure))
+	assert.True(t, s.stale)
+	assert.False(t, s.update(time.Minute, start.Add(2*time.Minute), failure))
+	assert.True(t, s.update(time.Minute, start.Add(3*time.Minute), nil))
+	assert.False(t, s.stale)
+	assert.Equal(t, start.Add(3*time.Minute), s.lastSuccess)
+}
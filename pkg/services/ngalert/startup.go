@@ -0,0 +1,26 @@
+package ngalert
+
+import (
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+// inStartupGracePeriod returns true if the evaluation time is within the startup grace period.
+func (sch *schedule) inStartupGracePeriod(now time.Time) bool {
+	return sch.startupGracePeriod > 0 && now.Sub(sch.startedAt) < sch.startupGracePeriod
+}
+
+// splitErrors returns the results that are not in the Error state and the ones that are.
+func splitErrors(results eval.Results) (eval.Results, eval.Results) {
+	filtered := make(eval.Results, 0, len(results))
+	var errored eval.Results
+	for _, r := range results {
+		if r.State == eval.Error {
+			errored = append(errored, r)
+			continue
+		}
+		filtered = append(filtered, r)
+	}
+	return filtered, errored
+}
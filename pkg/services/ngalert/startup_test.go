@@ -0,0 +1,113 @@
+package ngalert
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertingTickerStartupGracePeriod(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.startupGracePeriod = 2 * time.Second
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		return eval.Results{{State: eval.Error, Error: errors.New("datasource not ready")}}, nil
+	})
+
+	alert := createTestAlertDefinition(t, ng, 1)
+	key := getKey(alert)
+
+	events, unsubscribe := ng.schedule.subscribers.subscribe(10)
+	defer unsubscribe()
+
+	evalAppliedCh := make(chan evalAppliedInfo, 1)
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	ctx := context.Background()
+	go func() {
+		err := ng.alertingTicker(ctx)
+		require.NoError(t, err)
+	}()
+	runtime.Gosched()
+
+	t.Run("the state changes should be suppressed during the grace period", func(t *testing.T) {
+		tick := advanceClock(t, mockedClock)
+		assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+
+		assert.Empty(t, ng.schedule.stateTracker.get(key))
+		select {
+		case event := <-events:
+			t.Fatalf("unexpected event during the grace period: %v", event)
+		default:
+		}
+	})
+
+	t.Run("the state changes should be emitted after the grace period", func(t *testing.T) {
+		tick := advanceClock(t, mockedClock)
+		assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+
+		instances := ng.schedule.stateTracker.get(key)
+		require.Len(t, instances, 1)
+		assert.Equal(t, eval.Error, instances[0].State)
+		select {
+		case event := <-events:
+			assert.Equal(t, eval.Error, event.Instance.State)
+		default:
+			t.Fatal("the state change should be emitted after the grace period")
+		}
+	})
+}
+
+func TestStartupGracePeriodKeepsErroredInstances(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.synchronous = true
+	ng.schedule.startupGracePeriod = time.Minute
+
+	alertDefinition := &AlertDefinition{ID: 1, OrgID: 1, UID: "uid", Condition: "A", IntervalSeconds: 1, Version: 1, Enabled: true}
+	store := newInMemoryDefinitionStore()
+	store.add(alertDefinition)
+	ng.SetDefinitionStore(store)
+	key := getKey(alertDefinition)
+
+	// both instances were alerting before the scheduler started
+	ng.schedule.stateTracker.setResults(key, alertDefinition, eval.Results{
+		{Instance: data.Labels{"host": "a"}, State: eval.Alerting},
+		{Instance: data.Labels{"host": "b"}, State: eval.Alerting},
+	})
+
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		return eval.Results{
+			{Instance: data.Labels{"host": "a"}, State: eval.Error, Error: errors.New("datasource not ready")},
+			{Instance: data.Labels{"host": "b"}, State: eval.Normal},
+		}, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	require.NoError(t, ng.tickSynchronously(ctx, time.Unix(1, 0)))
+
+	states := make(map[string]eval.State)
+	for _, instance := range ng.schedule.stateTracker.get(key) {
+		states[instance.Labels["host"]] = instance.State
+	}
+	assert.Equal(t, map[string]eval.State{"a": eval.Alerting, "b": eval.Normal}, states, "the errored instance should keep its previous state")
+}
@@ -0,0 +1,290 @@
+package ngalert
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+// alertInstance is the current state of an alert instance
+// identified by the alert definition key and the instance labels.
+type alertInstance struct {
+	DefinitionKey string
+	OrgID         int64
+	DefinitionUID string
+	Labels        data.Labels
+	State         eval.State
+	// DefinitionTitle is the title of the alert definition on the last evaluation.
+	DefinitionTitle string
+	// Value is the value of the condition on the last evaluation.
+	Value float64
+	// PreviousState is the state of the instance before the last evaluation.
+	PreviousState eval.State
+	// PendingSince is the time the instance entered the Pending state.
+	PendingSince time.Time
+	// FiringSince is the start of the current firing episode.
+	FiringSince time.Time
+	// LastAlertingAt is the last time the condition of the instance evaluated to Alerting.
+	LastAlertingAt time.Time
+	// LastEvaluatedAt is the last time the instance was evaluated.
+	LastEvaluatedAt time.Time
+	// LastNotifiedAt is the last time the firing instance was notified.
+	LastNotifiedAt time.Time
+	// Notify is true if the last evaluation should notify the firing instance:
+	// when it starts firing and then every repeat interval of the alert definition.
+	Notify bool
+	// Silenced is true if the instance is firing but its notifications
+	// are suppressed by a silence. It's set on emission and not tracked.
+	Silenced bool
+	// EvalAttempts is the number of attempts taken by the evaluation
+	// that produced the instance. It's set on emission and not tracked.
+	EvalAttempts int64
+	// Flapping is true if the flap detector considers the recent states of the instance flapping.
+	Flapping bool
+	// EvalID is the ID of the evaluation that last updated the instance.
+	EvalID int64
+
+	// recentStates are the latest states of the instance, the oldest first.
+	recentStates []eval.State
+}
+
+// stateTracker keeps the state of the alert instances
+// of all the scheduled alert definitions.
+type stateTracker struct {
+	mu    sync.RWMutex
+	clock clock.Clock
+	// instances are indexed by the alert definition key and the instance fingerprint
+	instances map[string]map[string]*alertInstance
+	// flapDetector if set flags the flapping instances
+	flapDetector FlapDetector
+	// suppressFlapping suppresses the notifications of the flapping instances
+	suppressFlapping bool
+}
+
+func newStateTracker(c clock.Clock) *stateTracker {
+	return &stateTracker{
+		clock:        c,
+		instances:    make(map[string]map[string]*alertInstance),
+		flapDetector: newSlidingWindowFlapDetector(defaultFlapWindow, defaultFlapThreshold),
+	}
+}
+
+// fingerprint returns the identifier of the alert instance labels.
+func fingerprint(labels data.Labels) string {
+	return labels.String()
+}
+
+// setResults updates the alert instances of the alert definition with the evaluation results
+// and returns a copy of the updated instances.
+// Instances missing from the results are removed.
+func (st *stateTracker) setResults(key string, alertDefinition *AlertDefinition, results eval.Results) []alertInstance {
+	return st.setEvaluationResults(key, alertDefinition, 0, results, nil)
+}
+
+// setEvaluationResults is setResults for the evaluation identified by evalID,
+// except that the tracked instances of the kept results are left as they are
+// instead of being removed; the returned instances exclude them.
+func (st *stateTracker) setEvaluationResults(key string, alertDefinition *AlertDefinition, evalID int64, results eval.Results, kept eval.Results) []alertInstance {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	now := st.clock.Now()
+	previous := st.instances[key]
+	current := make(map[string]*alertInstance, len(results))
+	updated := make([]alertInstance, 0, len(results))
+	for _, r := range results {
+		fp := fingerprint(r.Instance)
+		instance, ok := previous[fp]
+		if !ok {
+			instance = &alertInstance{DefinitionKey: key, Labels: r.Instance}
+		}
+		instance.OrgID = alertDefinition.OrgID
+		instance.DefinitionUID = alertDefinition.UID
+		instance.DefinitionTitle = alertDefinition.Title
+		instance.PreviousState = instance.State
+		instance.Value = r.Value
+
+		switch r.State {
+		case eval.Alerting:
+			switch {
+			case instance.State == eval.Alerting:
+				instance.LastAlertingAt = now
+			case instance.State != eval.Pending && alertDefinition.For > 0:
+				// the condition must hold for the For duration before the instance fires
+				instance.State = eval.Pending
+				instance.PendingSince = now
+			case instance.State == eval.Pending && now.Sub(instance.PendingSince) < alertDefinition.For:
+				// the instance keeps pending and its pending since time is preserved
+			default:
+				instance.State = eval.Alerting
+				instance.PendingSince = time.Time{}
+				instance.LastAlertingAt = now
+			}
+		default:
+			// a firing instance keeps firing for the configured duration
+			// after its condition stops being true
+			keepFiring := instance.State == eval.Alerting && now.Sub(instance.LastAlertingAt) < alertDefinition.KeepFiringFor
+			if !keepFiring {
+				instance.State = r.State
+				instance.PendingSince = time.Time{}
+			}
+		}
+		switch {
+		case instance.startedFiring():
+			instance.FiringSince = now
+		case instance.State != eval.Alerting:
+			instance.FiringSince = time.Time{}
+		}
+		instance.LastEvaluatedAt = now
+		instance.EvalID = evalID
+		instance.recentStates = appendRecentState(instance.recentStates, instance.State)
+		instance.Flapping = st.flapDetector != nil && st.flapDetector.IsFlapping(instance.recentStates)
+
+		instance.Notify = instance.State == eval.Alerting &&
+			(instance.startedFiring() || alertDefinition.RepeatInterval > 0 && now.Sub(instance.LastNotifiedAt) >= alertDefinition.RepeatInterval) &&
+			!(instance.Flapping && st.suppressFlapping)
+		if instance.Notify {
+			instance.LastNotifiedAt = now
+		}
+
+		current[fp] = instance
+		updated = append(updated, *instance)
+	}
+	for _, r := range kept {
+		fp := fingerprint(r.Instance)
+		if _, ok := current[fp]; ok {
+			continue
+		}
+		if instance, ok := previous[fp]; ok {
+			current[fp] = instance
+		}
+	}
+	st.instances[key] = current
+	return updated
+}
+
+// startedFiring returns true if the last evaluation started a new firing episode.
+func (i alertInstance) startedFiring() bool {
+	return i.PreviousState != eval.Alerting && i.State == eval.Alerting
+}
+
+// get returns a copy of the current alert instances of the alert definition.
+func (st *stateTracker) get(key string) []alertInstance {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	instances := make([]alertInstance, 0, len(st.instances[key]))
+	for _, instance := range st.instances[key] {
+		instances = append(instances, *instance)
+	}
+	return instances
+}
+
+// marshal returns the JSON encoding of the current alert instances of the alert definition.
+func (st *stateTracker) marshal(key string) ([]byte, error) {
+	return json.Marshal(st.get(key))
+}
+
+// restore sets the alert instances of the alert definition from their JSON encoding
+// unless it has tracked instances, and returns the number of restored instances.
+func (st *stateTracker) restore(key string, b []byte) (int, error) {
+	var instances []alertInstance
+	if err := json.Unmarshal(b, &instances); err != nil {
+		return 0, err
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if len(st.instances[key]) > 0 {
+		return 0, nil
+	}
+	restored := make(map[string]*alertInstance, len(instances))
+	for i := range instances {
+		restored[fingerprint(instances[i].Labels)] = &instances[i]
+	}
+	st.instances[key] = restored
+	return len(restored), nil
+}
+
+// firing returns a copy of the alert instances of the organisation currently in Alerting state.
+func (st *stateTracker) firing(orgID int64) []alertInstance {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	var instances []alertInstance
+	for _, definitionInstances := range st.instances {
+		for _, instance := range definitionInstances {
+			if instance.OrgID == orgID && instance.State == eval.Alerting {
+				instances = append(instances, *instance)
+			}
+		}
+	}
+	return instances
+}
+
+// active returns a copy of the alert instances of the organisation currently in Pending or Alerting state.
+func (st *stateTracker) active(orgID int64) []alertInstance {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	var instances []alertInstance
+	for _, definitionInstances := range st.instances {
+		for _, instance := range definitionInstances {
+			if instance.OrgID == orgID && (instance.State == eval.Alerting || instance.State == eval.Pending) {
+				instances = append(instances, *instance)
+			}
+		}
+	}
+	return instances
+}
+
+// all returns a copy of all the alert instances of the organisation.
+func (st *stateTracker) all(orgID int64) []alertInstance {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	var instances []alertInstance
+	for _, definitionInstances := range st.instances {
+		for _, instance := range definitionInstances {
+			if instance.OrgID == orgID {
+				instances = append(instances, *instance)
+			}
+		}
+	}
+	return instances
+}
+
+// del removes the alert instances of the alert definition.
+func (st *stateTracker) del(key string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	delete(st.instances, key)
+}
+
+// reset removes the alert instances of the alert definition of the organisation
+// whatever the routines it's evaluated by, and returns the keys of the routines
+// and the number of removed instances.
+func (st *stateTracker) reset(orgID int64, uid string) ([]string, int) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	var keys []string
+	removed := 0
+	for key, definitionInstances := range st.instances {
+		for _, instance := range definitionInstances {
+			if instance.OrgID == orgID && instance.DefinitionUID == uid {
+				keys = append(keys, key)
+				removed += len(definitionInstances)
+				delete(st.instances, key)
+				break
+			}
+		}
+	}
+	return keys, removed
+}
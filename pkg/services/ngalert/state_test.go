@@ -0,0 +1,130 @@
+package ngalert
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateTrackerKeepFiringFor(t *testing.T) {
+	mockedClock := clock.NewMock()
+	st := newStateTracker(mockedClock)
+
+	alertDefinition := &AlertDefinition{OrgID: 1, UID: "uid", KeepFiringFor: 30 * time.Second}
+	key := getKey(alertDefinition)
+	labels := data.Labels{"host": "a"}
+
+	evaluate := func(state eval.State) eval.State {
+		instances := st.setResults(key, alertDefinition, eval.Results{{Instance: labels, State: state}})
+		require.Len(t, instances, 1)
+		return instances[0].State
+	}
+
+	require.Equal(t, eval.Alerting, evaluate(eval.Alerting))
+
+	mockedClock.Add(10 * time.Second)
+	require.Equal(t, eval.Alerting, evaluate(eval.Normal), "instance should keep firing")
+
+	mockedClock.Add(10 * time.Second)
+	require.Equal(t, eval.Alerting, evaluate(eval.Normal), "instance should keep firing")
+
+	mockedClock.Add(11 * time.Second)
+	require.Equal(t, eval.Normal, evaluate(eval.Normal), "instance should be resolved")
+
+	require.Equal(t, eval.Normal, st.get(key)[0].State)
+}
+
+func TestStateTrackerPending(t *testing.T) {
+	mockedClock := clock.NewMock()
+	st := newStateTracker(mockedClock)
+
+	alertDefinition := &AlertDefinition{OrgID: 1, UID: "uid", For: time.Minute}
+	key := getKey(alertDefinition)
+	labels := data.Labels{"host": "a"}
+
+	evaluate := func(state eval.State) alertInstance {
+		instances := st.setResults(key, alertDefinition, eval.Results{{Instance: labels, State: state}})
+		require.Len(t, instances, 1)
+		return instances[0]
+	}
+
+	pendingSince := mockedClock.Now()
+	instance := evaluate(eval.Alerting)
+	require.Equal(t, eval.Pending, instance.State)
+	require.Equal(t, pendingSince, instance.PendingSince)
+
+	mockedClock.Add(30 * time.Second)
+	instance = evaluate(eval.Alerting)
+	require.Equal(t, eval.Pending, instance.State)
+	require.Equal(t, pendingSince, instance.PendingSince, "pending since time should be stable")
+
+	mockedClock.Add(30 * time.Second)
+	instance = evaluate(eval.Alerting)
+	require.Equal(t, eval.Alerting, instance.State)
+	require.True(t, instance.startedFiring())
+
+	mockedClock.Add(10 * time.Second)
+	instance = evaluate(eval.Normal)
+	require.Equal(t, eval.Normal, instance.State)
+
+	mockedClock.Add(10 * time.Second)
+	instance = evaluate(eval.Alerting)
+	require.Equal(t, eval.Pending, instance.State)
+	require.Equal(t, mockedClock.Now(), instance.PendingSince, "a new pending period should start")
+}
+
+func TestResetState(t *testing.T) {
+	mockedClock := clock.NewMock()
+	ng := &AlertNG{
+		log:      log.New("ngalert.test"),
+		schedule: newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil),
+	}
+	store := newInMemoryDefinitionStore()
+	ng.SetDefinitionStore(store)
+	st := ng.schedule.stateTracker
+
+	alertDefinition := &AlertDefinition{ID: 1, OrgID: 1, UID: "uid", For: 10 * time.Second}
+	other := &AlertDefinition{OrgID: 1, UID: "other"}
+	key := getKey(alertDefinition)
+	labels := data.Labels{"host": "a"}
+	alerting := eval.Results{{Instance: labels, State: eval.Alerting}}
+
+	// the instance fires once its condition held for the For duration
+	st.setResults(key, alertDefinition, alerting)
+	mockedClock.Add(10 * time.Second)
+	instances := st.setResults(key, alertDefinition, alerting)
+	require.Len(t, instances, 1)
+	require.Equal(t, eval.Alerting, instances[0].State)
+	st.setResults(getKey(other), other, alerting)
+	ng.saveState(key)
+	ng.saveState(getKey(other))
+
+	ng.ResetState("uid", 1)
+
+	require.Empty(t, st.get(key))
+	require.Len(t, st.get(getKey(other)), 1, "the other alert definitions should not be reset")
+
+	// the routine rebuilt by a new scheduler restores the persisted state
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.SetDefinitionStore(store)
+	for _, d := range []*AlertDefinition{alertDefinition, other} {
+		info := ng.schedule.registry.getOrCreateInfo(context.Background(), getKey(d), d.ID, d.UID, d.OrgID, 1, "")
+		ng.newEvaluationHandler(getKey(d), info)
+	}
+	require.Empty(t, ng.schedule.stateTracker.get(key), "the reset state should not be restored")
+	require.Len(t, ng.schedule.stateTracker.get(getKey(other)), 1)
+	st = ng.schedule.stateTracker
+
+	// the next evaluation starts from scratch: the instance is pending again
+	instances = st.setResults(key, alertDefinition, alerting)
+	require.Len(t, instances, 1)
+	require.Equal(t, eval.Pending, instances[0].State)
+	require.Equal(t, eval.Normal, instances[0].PreviousState)
+	require.True(t, instances[0].FiringSince.IsZero())
+}
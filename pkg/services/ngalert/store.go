@@ -0,0 +1,213 @@
+package ngalert
+
+import (
+	"sync"
+	"time"
+)
+
+// DefinitionStore is the storage the scheduler fetches the alert definitions from
+// and persists the state of the alert instances to.
+// It allows plugging alternative backends, e.g. in memory or remote ones.
+type DefinitionStore interface {
+	// GetByUID returns the alert definition of the organisation with the given UID.
+	GetByUID(orgID int64, uid string) (*AlertDefinition, error)
+	// GetByID returns the alert definition with the given ID.
+	GetByID(id int64) (*AlertDefinition, error)
+	// FetchAll returns all the alert definitions.
+	FetchAll() ([]*AlertDefinition, error)
+	// FetchDeltas returns the alert definitions updated since the given time, included:
+	// the updates of the same time may have been committed after the previous fetch.
+	FetchDeltas(since time.Time) ([]*AlertDefinition, error)
+	// SaveState persists the state of the alert instances of the routine with the given key.
+	// The state is opaque to the store.
+	SaveState(key string, state []byte) error
+	// LoadState returns the persisted state of the alert instances of the routine
+	// with the given key or nil if there is none.
+	LoadState(key string) ([]byte, error)
+}
+
+// sqlDefinitionStore is the DefinitionStore of the alert definitions of the grafana database.
+// The state of the alert instances is only kept in memory so it's not persisted.
+type sqlDefinitionStore struct {
+	ng *AlertNG
+}
+
+func (s sqlDefinitionStore) GetByUID(orgID int64, uid string) (*AlertDefinition, error) {
+	q := getAlertDefinitionByUIDQuery{UID: uid, OrgID: orgID}
+	if err := s.ng.getAlertDefinitionByUID(&q); err != nil {
+		return nil, err
+	}
+	return q.Result, nil
+}
+
+func (s sqlDefinitionStore) GetByID(id int64) (*AlertDefinition, error) {
+	q := getAlertDefinitionByIDQuery{ID: id}
+	if err := s.ng.getAlertDefinitionByID(&q); err != nil {
+		return nil, err
+	}
+	return q.Result, nil
+}
+
+func (s sqlDefinitionStore) FetchAll() ([]*AlertDefinition, error) {
+	q := listAlertDefinitionsQuery{}
+	if err := s.ng.getAlertDefinitions(&q); err != nil {
+		return nil, err
+	}
+	return q.Result, nil
+}
+
+func (s sqlDefinitionStore) FetchDeltas(since time.Time) ([]*AlertDefinition, error) {
+	q := listAlertDefinitionsUpdatedSinceQuery{UpdatedSince: since}
+	if err := s.ng.getAlertDefinitionsUpdatedSince(&q); err != nil {
+		return nil, err
+	}
+	return q.Result, nil
+}
+
+func (s sqlDefinitionStore) SaveState(string, []byte) error {
+	return nil
+}
+
+func (s sqlDefinitionStore) LoadState(string) ([]byte, error) {
+	return nil, nil
+}
+
+// inMemoryDefinitionStore is a DefinitionStore keeping the alert definitions
+// and the state of the alert instances in memory, e.g. for tests.
+// The alert definitions are keyed by ID so that the alert definitions
+// sharing a UID under a custom routine key are kept apart.
+type inMemoryDefinitionStore struct {
+	mu          sync.Mutex
+	definitions map[int64]*AlertDefinition
+	states      map[string][]byte
+}
+
+func newInMemoryDefinitionStore() *inMemoryDefinitionStore {
+	return &inMemoryDefinitionStore{
+		definitions: make(map[int64]*AlertDefinition),
+		states:      make(map[string][]byte),
+	}
+}
+
+// add adds or replaces the alert definitions.
+func (s *inMemoryDefinitionStore) add(alertDefinitions ...*AlertDefinition) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, alertDefinition := range alertDefinitions {
+		s.definitions[alertDefinition.ID] = alertDefinition
+	}
+}
+
+// remove removes the alert definition of the organisation with the given UID.
+func (s *inMemoryDefinitionStore) remove(orgID int64, uid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, alertDefinition := range s.definitions {
+		if alertDefinition.OrgID == orgID && alertDefinition.UID == uid {
+			delete(s.definitions, id)
+		}
+	}
+}
+
+func (s *inMemoryDefinitionStore) GetByUID(orgID int64, uid string) (*AlertDefinition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, alertDefinition := range s.definitions {
+		if alertDefinition.OrgID == orgID && alertDefinition.UID == uid {
+			return alertDefinition, nil
+		}
+	}
+	return nil, errAlertDefinitionNotFound
+}
+
+func (s *inMemoryDefinitionStore) GetByID(id int64) (*AlertDefinition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	alertDefinition, ok := s.definitions[id]
+	if !ok {
+		return nil, errAlertDefinitionNotFound
+	}
+	return alertDefinition, nil
+}
+
+func (s *inMemoryDefinitionStore) FetchAll() ([]*AlertDefinition, error) {
+	return s.FetchDeltas(time.Time{})
+}
+
+func (s *inMemoryDefinitionStore) FetchDeltas(since time.Time) ([]*AlertDefinition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	alertDefinitions := make([]*AlertDefinition, 0, len(s.definitions))
+	for _, alertDefinition := range s.definitions {
+		if since.IsZero() || !alertDefinition.Updated.Before(since) {
+			alertDefinitions = append(alertDefinitions, alertDefinition)
+		}
+	}
+	return alertDefinitions, nil
+}
+
+func (s *inMemoryDefinitionStore) SaveState(key string, state []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.states[key] = state
+	return nil
+}
+
+func (s *inMemoryDefinitionStore) LoadState(key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.states[key], nil
+}
+
+// definitionStore returns the store of the scheduler, the grafana database by default.
+// The canary alert definition, if enabled, is served in addition to the stored ones.
+func (ng *AlertNG) definitionStore() DefinitionStore {
+	var store DefinitionStore = sqlDefinitionStore{ng: ng}
+	if ng.schedule.store != nil {
+		store = ng.schedule.store
+	}
+	if ng.schedule.canary != nil {
+		return canaryStore{DefinitionStore: store, canary: ng.schedule.canary.definition}
+	}
+	return store
+}
+
+// SetDefinitionStore sets the store the scheduler fetches the alert definitions from
+// and persists the state of the alert instances to.
+func (ng *AlertNG) SetDefinitionStore(store DefinitionStore) {
+	ng.schedule.store = store
+}
+
+// saveState persists the state of the alert instances of the routine with the given key.
+func (ng *AlertNG) saveState(key string) {
+	state, err := ng.schedule.stateTracker.marshal(key)
+	if err == nil {
+		err = ng.definitionStore().SaveState(key, state)
+	}
+	if err != nil {
+		ng.schedule.log.Error("failed to save the state of the alert instances", "key", key, "error", err)
+	}
+}
+
+// loadState restores the persisted state of the alert instances of the routine with the given key
+// unless they are already tracked, e.g. because the routine has been restarted.
+func (ng *AlertNG) loadState(key string) {
+	state, err := ng.definitionStore().LoadState(key)
+	if err == nil && len(state) > 0 {
+		var restored int
+		restored, err = ng.schedule.stateTracker.restore(key, state)
+		if restored > 0 {
+			ng.schedule.log.Debug("alert instances state restored", "key", key, "instances", restored)
+		}
+	}
+	if err != nil {
+		ng.schedule.log.Error("failed to load the state of the alert instances", "key", key, "error", err)
+	}
+}
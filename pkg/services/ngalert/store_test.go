@@ -0,0 +1,157 @@
+package ngalert
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertingTickerInMemoryDefinitionStore(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		return eval.Results{
+			{Instance: data.Labels{"host": "a"}, State: eval.Alerting},
+			{Instance: data.Labels{"host": "b"}, State: eval.Normal},
+		}, nil
+	})
+
+	// the alert definition only exists in the in-memory store
+	store := newInMemoryDefinitionStore()
+	alert := &AlertDefinition{
+		ID:              1,
+		OrgID:           1,
+		UID:             "in-memory",
+		Title:           "an in-memory alert definition",
+		Condition:       "A",
+		IntervalSeconds: 1,
+		Version:         1,
+		Enabled:         true,
+		Updated:         mockedClock.Now(),
+	}
+	store.add(alert)
+	key := getKey(alert)
+
+	// the instance of host a was already alerting before the scheduler started
+	persisted, err := (&stateTracker{instances: map[string]map[string]*alertInstance{
+		key: {"a": {DefinitionKey: key, OrgID: 1, DefinitionUID: alert.UID, Labels: data.Labels{"host": "a"}, State: eval.Alerting}},
+	}}).marshal(key)
+	require.NoError(t, err)
+	require.NoError(t, store.SaveState(key, persisted))
+	ng.SetDefinitionStore(store)
+
+	evalAppliedCh := make(chan evalAppliedInfo, 1)
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	ctx := context.Background()
+	go func() {
+		err := ng.alertingTicker(ctx)
+		require.NoError(t, err)
+	}()
+	runtime.Gosched()
+
+	tick := advanceClock(t, mockedClock)
+	assertEvalRun(t, evalAppliedCh, tick, alert.ID)
+
+	instances := ng.schedule.stateTracker.get(key)
+	require.Len(t, instances, 2)
+	for _, instance := range instances {
+		if instance.Labels["host"] == "a" {
+			// the restored instance keeps firing instead of starting to fire
+			assert.Equal(t, eval.Alerting, instance.PreviousState)
+			assert.False(t, instance.startedFiring())
+		}
+	}
+
+	// the state of the evaluated instances is saved to the store
+	saved, err := store.LoadState(key)
+	require.NoError(t, err)
+	restored := &stateTracker{instances: make(map[string]map[string]*alertInstance)}
+	n, err := restored.restore(key, saved)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+}
+
+func TestInMemoryDefinitionStoreCustomKey(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.synchronous = true
+
+	// two alert definitions with the same UID that belong to different namespaces
+	namespaces := map[int64]string{1: "namespace-a", 2: "namespace-b"}
+	ng.schedule.keyFunc = func(alertDefinition *AlertDefinition) string {
+		return fmt.Sprintf("%d:%s:%s", alertDefinition.OrgID, namespaces[alertDefinition.ID], alertDefinition.UID)
+	}
+	store := newInMemoryDefinitionStore()
+	store.add(
+		&AlertDefinition{ID: 1, OrgID: 1, UID: "uid", Condition: "A", IntervalSeconds: 1, Version: 1, Enabled: true},
+		&AlertDefinition{ID: 2, OrgID: 1, UID: "uid", Condition: "B", IntervalSeconds: 1, Version: 1, Enabled: true},
+	)
+	ng.SetDefinitionStore(store)
+
+	evaluated := make(map[string]string)
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(_ context.Context, c *eval.Condition, _ time.Time) (eval.Results, error) {
+		evaluated[c.CacheKey] = c.RefID
+		return eval.Results{}, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	require.NoError(t, ng.tickSynchronously(ctx, time.Unix(1, 0)))
+
+	// every routine evaluates its own alert definition
+	assert.Equal(t, map[string]string{"1:namespace-a:uid": "A", "1:namespace-b:uid": "B"}, evaluated)
+}
+
+func TestDefinitionStoresFetchDeltasBoundary(t *testing.T) {
+	mockTimeNow()
+	defer resetTimeNow()
+
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	for i := 0; i < 3; i++ {
+		createTestAlertDefinition(t, ng, 60)
+	}
+	var sqlStore DefinitionStore = sqlDefinitionStore{ng: ng}
+	alertDefinitions, err := sqlStore.FetchAll()
+	require.NoError(t, err)
+	require.Len(t, alertDefinitions, 3)
+	sort.Slice(alertDefinitions, func(i, j int) bool { return alertDefinitions[i].Updated.Before(alertDefinitions[j].Updated) })
+	watermark := alertDefinitions[1].Updated
+
+	inMemoryStore := newInMemoryDefinitionStore()
+	inMemoryStore.add(alertDefinitions...)
+
+	// both stores include the alert definitions updated at the watermark
+	for name, store := range map[string]DefinitionStore{"sql": sqlStore, "in-memory": inMemoryStore} {
+		t.Run(name, func(t *testing.T) {
+			deltas, err := store.FetchDeltas(watermark)
+			require.NoError(t, err)
+			ids := make([]int64, 0, len(deltas))
+			for _, alertDefinition := range deltas {
+				ids = append(ids, alertDefinition.ID)
+			}
+			assert.ElementsMatch(t, []int64{alertDefinitions[1].ID, alertDefinitions[2].ID}, ids)
+		})
+	}
+}
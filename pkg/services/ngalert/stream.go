@@ -0,0 +1,120 @@
+package ngalert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+// maxStreamSteps is the maximum number of intervals evaluated by a condition evaluation stream.
+const maxStreamSteps = 1000
+
+// evalStreamInstance is the streamed state of an alert instance.
+type evalStreamInstance struct {
+	Labels data.Labels `json:"labels"`
+	State  string      `json:"state"`
+}
+
+// evalStreamResult is the streamed result of evaluating a condition at a specific time.
+type evalStreamResult struct {
+	Time      time.Time            `json:"time"`
+	Instances []evalStreamInstance `json:"instances"`
+	Error     string               `json:"error,omitempty"`
+}
+
+// validateStreamRange checks that the time range can be streamed at the interval
+// in at most maxStreamSteps evaluations.
+func validateStreamRange(from, to time.Time, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid interval: %v", interval)
+	}
+	if to.Before(from) {
+		return fmt.Errorf("invalid time range: %v is before %v", to, from)
+	}
+	if steps := int64(to.Sub(from)/interval) + 1; steps > maxStreamSteps {
+		return fmt.Errorf("invalid time range: %d intervals of %v should not be greater than %d", steps, interval, maxStreamSteps)
+	}
+	return nil
+}
+
+// streamConditionEval evaluates the condition at every interval of the time range
+// and sends each interval's results as soon as they are available.
+// The condition is evaluated by the preview evaluator: the stream is neither
+// rate limited nor served from the cached evaluations of the scheduler.
+// It stops as soon as the context is cancelled or sending fails.
+func (ng *AlertNG) streamConditionEval(ctx context.Context, condition *eval.Condition, from, to time.Time, interval time.Duration, send func(evalStreamResult) error) error {
+	if err := validateStreamRange(from, to, interval); err != nil {
+		return err
+	}
+
+	for now := from; !now.After(to); now = now.Add(interval) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		res := evalStreamResult{Time: now, Instances: []evalStreamInstance{}}
+		results, err := ng.schedule.previewEvaluator.ConditionEval(ctx, condition, now)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			res.Error = err.Error()
+		}
+		for _, r := range results {
+			res.Instances = append(res.Instances, evalStreamInstance{Labels: r.Instance, State: r.State.String()})
+		}
+
+		if err := send(res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// conditionEvalStreamEndpoint handles POST /api/alert-definitions/eval/stream.
+// It streams the results as server-sent events until the time range is exhausted
+// or the client disconnects.
+func (ng *AlertNG) conditionEvalStreamEndpoint(c *models.ReqContext, dto evalAlertConditionStreamCommand) {
+	if err := ng.validateCondition(dto.Condition, c.SignedInUser); err != nil {
+		c.JsonApiErr(400, "invalid condition", err)
+		return
+	}
+
+	intervalSeconds := defaultIntervalSeconds
+	if dto.IntervalSeconds != nil {
+		intervalSeconds = *dto.IntervalSeconds
+	}
+	interval := time.Duration(intervalSeconds) * time.Second
+	if err := validateStreamRange(dto.From, dto.To, interval); err != nil {
+		c.JsonApiErr(400, "invalid time range", err)
+		return
+	}
+
+	c.Resp.Header().Set("Content-Type", "text/event-stream")
+	c.Resp.Header().Set("Cache-Control", "no-cache")
+	c.Resp.WriteHeader(200)
+
+	send := func(res evalStreamResult) error {
+		b, err := json.Marshal(res)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(c.Resp, "data: %s\n\n", b); err != nil {
+			return err
+		}
+		c.Resp.Flush()
+		return nil
+	}
+
+	err := ng.streamConditionEval(c.Req.Context(), &dto.Condition, dto.From, dto.To, interval, send)
+	if err != nil {
+		ng.log.Debug("condition evaluation stream stopped", "error", err)
+	}
+}
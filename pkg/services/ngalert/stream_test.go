@@ -0,0 +1,88 @@
+package ngalert
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamConditionEval(t *testing.T) {
+	ng := &AlertNG{log: log.New("ngalert.stream.test")}
+	ng.schedule = newScheduler(clock.NewMock(), time.Second, ng.log, nil)
+
+	evaluated := make([]time.Time, 0)
+	ng.schedule.previewEvaluator = eval.EvaluatorFunc(func(_ context.Context, _ *eval.Condition, now time.Time) (eval.Results, error) {
+		evaluated = append(evaluated, now)
+		return eval.Results{{Instance: data.Labels{"host": "a"}, State: eval.Alerting}}, nil
+	})
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		return nil, errors.New("the stream should not use the scheduler evaluator")
+	})
+
+	from := time.Unix(0, 0)
+	to := from.Add(time.Minute)
+	condition := &eval.Condition{RefID: "A", OrgID: 1}
+
+	t.Run("results of every interval are streamed", func(t *testing.T) {
+		evaluated = evaluated[:0]
+		streamed := make([]evalStreamResult, 0)
+		err := ng.streamConditionEval(context.Background(), condition, from, to, 10*time.Second, func(res evalStreamResult) error {
+			streamed = append(streamed, res)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Len(t, streamed, 7)
+		for i, res := range streamed {
+			assert.Equal(t, from.Add(time.Duration(i)*10*time.Second), res.Time)
+			require.Len(t, res.Instances, 1)
+			assert.Equal(t, "Alerting", res.Instances[0].State)
+		}
+	})
+
+	t.Run("cancellation stops the evaluation", func(t *testing.T) {
+		evaluated = evaluated[:0]
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		streamed := 0
+		err := ng.streamConditionEval(ctx, condition, from, to, 10*time.Second, func(res evalStreamResult) error {
+			streamed++
+			if streamed == 2 {
+				// the client disconnects
+				cancel()
+			}
+			return nil
+		})
+		require.True(t, errors.Is(err, context.Canceled))
+		assert.Equal(t, 2, streamed)
+		assert.Len(t, evaluated, 2)
+	})
+
+	t.Run("invalid ranges are rejected", func(t *testing.T) {
+		evaluated = evaluated[:0]
+		send := func(evalStreamResult) error {
+			t.Fatal("nothing should be streamed")
+			return nil
+		}
+
+		err := ng.streamConditionEval(context.Background(), condition, from, to, 0, send)
+		require.Error(t, err)
+		err = ng.streamConditionEval(context.Background(), condition, to, from, 10*time.Second, send)
+		require.Error(t, err)
+		err = ng.streamConditionEval(context.Background(), condition, from, from.Add(maxStreamSteps*time.Second), time.Second, send)
+		require.Error(t, err, "the range should be rejected above the maximum number of intervals")
+		assert.Empty(t, evaluated)
+
+		err = ng.streamConditionEval(context.Background(), condition, from, from.Add((maxStreamSteps-1)*time.Second), time.Second, func(evalStreamResult) error { return nil })
+		require.NoError(t, err)
+		assert.Len(t, evaluated, maxStreamSteps)
+	})
+}
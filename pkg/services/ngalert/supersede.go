@@ -0,0 +1,68 @@
+package ngalert
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// defaultSupersedeDebounce is the minimum time between two cancellations
+// of the evaluations of an alert definition superseded by a newer version.
+const defaultSupersedeDebounce = 10 * time.Second
+
+// errEvaluationSuperseded is the error of the evaluations cancelled
+// because a newer version of their alert definition has been saved.
+var errEvaluationSuperseded = errors.New("evaluation superseded by a newer alert definition version")
+
+// evalCanceller cancels the evaluation in flight of a routine
+// once a newer version of its alert definition is scheduled.
+type evalCanceller struct {
+	mu sync.Mutex
+	// cancel is nil unless an evaluation is in flight
+	cancel context.CancelFunc
+	// version is the alert definition version being evaluated
+	version int64
+	// lastCancelled is the time of the last cancellation
+	lastCancelled time.Time
+}
+
+// start registers the evaluation in flight of the version.
+func (c *evalCanceller) start(cancel context.CancelFunc, version int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cancel, c.version = cancel, version
+}
+
+// setVersion updates the version being evaluated once it has been fetched.
+func (c *evalCanceller) setVersion(version int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.version = version
+}
+
+// stop unregisters the evaluation in flight once it's over.
+func (c *evalCanceller) stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cancel = nil
+}
+
+// supersede cancels the evaluation in flight if it evaluates a version older than the given one
+// and returns true if it has been cancelled. Rapid updates don't cancel every evaluation:
+// an evaluation is not cancelled within debounce of the previous cancellation.
+func (c *evalCanceller) supersede(version int64, now time.Time, debounce time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cancel == nil || c.version >= version {
+		return false
+	}
+	if !c.lastCancelled.IsZero() && now.Sub(c.lastCancelled) < debounce {
+		return false
+	}
+	c.cancel()
+	c.cancel = nil
+	c.lastCancelled = now
+	return true
+}
@@ -0,0 +1,102 @@
+package ngalert
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertingTickerCancelsSupersededEvaluation(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.cancelSuperseded = true
+
+	var calls int32
+	cancelled := make(chan error, 1)
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(ctx context.Context, _ *eval.Condition, _ time.Time) (eval.Results, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			// the evaluation of the first version hangs until it's cancelled
+			<-ctx.Done()
+			cancelled <- ctx.Err()
+			return nil, ctx.Err()
+		}
+		return eval.Results{{Instance: data.Labels{"host": "a"}, State: eval.Alerting}}, nil
+	})
+
+	// the alert definition is due every other tick
+	store := newInMemoryDefinitionStore()
+	alert := &AlertDefinition{ID: 1, OrgID: 1, UID: "superseded", Title: "version 1", Condition: "A", IntervalSeconds: 2, Version: 1, Enabled: true}
+	store.add(alert)
+	ng.SetDefinitionStore(store)
+
+	evalAppliedCh := make(chan evalAppliedInfo, 2)
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = ng.alertingTicker(ctx)
+	}()
+	runtime.Gosched()
+
+	// the routine is created on the first tick and the first version evaluated on the second one
+	advanceClock(t, mockedClock)
+	firstTick := advanceClock(t, mockedClock)
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&calls) == 1 }, time.Second, 10*time.Millisecond)
+
+	updated := *alert
+	updated.Title = "version 2"
+	updated.Version = 2
+	store.add(&updated)
+
+	// the newer version is evaluated on the next tick although it's not due
+	secondTick := advanceClock(t, mockedClock)
+	select {
+	case err := <-cancelled:
+		assert.Equal(t, context.Canceled, err)
+	case <-time.After(time.Second):
+		t.Fatal("the evaluation of the superseded version should be cancelled")
+	}
+	assertEvalRun(t, evalAppliedCh, firstTick, alert.ID)
+	assertEvalRun(t, evalAppliedCh, secondTick, alert.ID)
+
+	instances := ng.schedule.stateTracker.get(getKey(alert))
+	require.Len(t, instances, 1)
+	assert.Equal(t, "version 2", instances[0].DefinitionTitle)
+	assert.Len(t, ng.schedule.history.list(getKey(alert)), 1, "the superseded evaluation should not be recorded")
+}
+
+func TestEvalCancellerDebounce(t *testing.T) {
+	c := &evalCanceller{}
+	now := time.Unix(0, 0)
+
+	assert.False(t, c.supersede(2, now, time.Minute), "no evaluation is in flight")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.start(cancel, 2)
+	assert.False(t, c.supersede(2, now, time.Minute), "the evaluation in flight is of the same version")
+	assert.True(t, c.supersede(3, now, time.Minute))
+	assert.Error(t, ctx.Err())
+
+	ctx, cancel = context.WithCancel(context.Background())
+	c.start(cancel, 3)
+	assert.False(t, c.supersede(4, now.Add(30*time.Second), time.Minute), "the rapid updates should be debounced")
+	assert.NoError(t, ctx.Err())
+	assert.True(t, c.supersede(5, now.Add(time.Minute), time.Minute))
+	assert.Error(t, ctx.Err())
+}
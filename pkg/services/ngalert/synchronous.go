@@ -0,0 +1,43 @@
+package ngalert
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// startSynchronousRoutine registers the evaluation handler of a routine of the synchronous mode.
+func (sch *schedule) startSynchronousRoutine(key string, handleEvaluation func(*evalContext) bool) {
+	if sch.syncRoutines == nil {
+		sch.syncRoutines = make(map[string]func(*evalContext) bool)
+	}
+	sch.syncRoutines[key] = handleEvaluation
+}
+
+// dispatchSynchronously runs the evaluation dispatched to a routine of the synchronous mode.
+// A recycled routine is marked as exited so that the ticker restarts it on the next tick.
+func (sch *schedule) dispatchSynchronously(key string, definitionInfo alertDefinitionInfo, ctx *evalContext) {
+	handleEvaluation, ok := sch.syncRoutines[key]
+	if !ok {
+		sch.log.Debug("alert definition dispatch dropped: routine stopped", "key", key, "evalID", ctx.evalID)
+		return
+	}
+	if recycled := handleEvaluation(ctx); recycled {
+		delete(sch.syncRoutines, key)
+		atomic.StoreInt32(definitionInfo.alive, 0)
+	}
+}
+
+// tickSynchronously handles the tick on the calling goroutine in the synchronous mode:
+// the alert definitions are fetched, and the evaluations due are run, before it returns.
+func (ng *AlertNG) tickSynchronously(ctx context.Context, tick time.Time) error {
+	if !ng.schedule.synchronous {
+		return fmt.Errorf("the scheduler is not in the synchronous mode")
+	}
+	if ng.schedule.startedAt.IsZero() {
+		ng.schedule.startedAt = ng.schedule.clock.Now()
+	}
+	ng.schedule.syncDefinitions = ng.processTick(ctx, nil, tick, ng.schedule.syncDefinitions)
+	return nil
+}
@@ -0,0 +1,73 @@
+package ngalert
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertingTickerSynchronous(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.synchronous = true
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(context.Context, *eval.Condition, time.Time) (eval.Results, error) {
+		return eval.Results{{Instance: data.Labels{"host": "a"}, State: eval.Normal}}, nil
+	})
+
+	every1s := &AlertDefinition{ID: 1, OrgID: 1, UID: "every-1s", Condition: "A", IntervalSeconds: 1, Version: 1, Enabled: true}
+	every2s := &AlertDefinition{ID: 2, OrgID: 1, UID: "every-2s", Condition: "A", IntervalSeconds: 2, Version: 1, Enabled: true}
+	every3s := &AlertDefinition{ID: 3, OrgID: 1, UID: "every-3s", Condition: "A", IntervalSeconds: 3, Version: 1, Enabled: true}
+	store := newInMemoryDefinitionStore()
+	store.add(every1s, every2s, every3s)
+	ng.SetDefinitionStore(store)
+	// the alert definitions are dispatched in the order they are fetched
+	fetched := []*AlertDefinition{every3s, every1s, every2s}
+	ng.schedule.fetchDefinitions = func(time.Time) []*AlertDefinition {
+		return fetched
+	}
+
+	// no synchronization is needed: the evaluations are run by tickSynchronously
+	var evaluated []string
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evaluated = append(evaluated, fmt.Sprintf("%d@%d", alertDefID, now.Unix()))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	for tick := int64(1); tick <= 6; tick++ {
+		if tick == 5 {
+			// the deleted alert definition is no longer evaluated
+			fetched = []*AlertDefinition{every3s, every1s}
+		}
+		require.NoError(t, ng.tickSynchronously(ctx, time.Unix(tick, 0)))
+	}
+
+	assert.Equal(t, []string{
+		"1@1",
+		"1@2", "2@2",
+		"3@3", "1@3",
+		"1@4", "2@4",
+		"1@5",
+		"3@6", "1@6",
+	}, evaluated)
+	assert.Empty(t, ng.schedule.stateTracker.get(getKey(every2s)))
+	assert.Len(t, ng.schedule.stateTracker.get(getKey(every3s)), 1)
+
+	t.Run("the asynchronous scheduler is not ticked synchronously", func(t *testing.T) {
+		ng.schedule.synchronous = false
+		t.Cleanup(func() { ng.schedule.synchronous = true })
+		require.Error(t, ng.tickSynchronously(ctx, time.Unix(7, 0)))
+	})
+}
@@ -0,0 +1,84 @@
+package ngalert
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+// isTemplate returns true if the alert definition should be expanded
+// into an alert definition per template value.
+func (alertDefinition *AlertDefinition) isTemplate() bool {
+	return alertDefinition.TemplateVariable != "" && len(alertDefinition.TemplateValues) > 0
+}
+
+// expand returns a copy of the template alert definition with the
+// $variable and ${variable} placeholders of its queries and title replaced by the value.
+func (alertDefinition *AlertDefinition) expand(value string) (*AlertDefinition, error) {
+	placeholders := []string{"${" + alertDefinition.TemplateVariable + "}", "$" + alertDefinition.TemplateVariable}
+
+	// the value is escaped since it's substituted in the JSON query models
+	escaped, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	jsonValue := string(escaped[1 : len(escaped)-1])
+
+	expanded := *alertDefinition
+	expanded.templateValue = value
+	expanded.Data = make([]eval.AlertQuery, len(alertDefinition.Data))
+	for i, q := range alertDefinition.Data {
+		model := string(q.Model)
+		for _, p := range placeholders {
+			model = strings.ReplaceAll(model, p, jsonValue)
+		}
+		// the copy should not share the parsed properties of the original model
+		expanded.Data[i] = eval.AlertQuery{
+			RefID:             q.RefID,
+			QueryType:         q.QueryType,
+			RelativeTimeRange: q.RelativeTimeRange,
+			DatasourceID:      q.DatasourceID,
+			Model:             json.RawMessage(model),
+			Fallback:          q.Fallback,
+		}
+	}
+	for _, p := range placeholders {
+		expanded.Title = strings.ReplaceAll(expanded.Title, p, value)
+	}
+	return &expanded, nil
+}
+
+// expandTemplates replaces the template alert definitions
+// by an alert definition per template value.
+// The expanded alert definitions only carry the template value:
+// their routines expand the full alert definition once fetched.
+func expandTemplates(alertDefinitions []*AlertDefinition) []*AlertDefinition {
+	expanded := make([]*AlertDefinition, 0, len(alertDefinitions))
+	for _, alertDefinition := range alertDefinitions {
+		if !alertDefinition.isTemplate() {
+			expanded = append(expanded, alertDefinition)
+			continue
+		}
+		for _, value := range alertDefinition.TemplateValues {
+			instance := *alertDefinition
+			instance.templateValue = value
+			expanded = append(expanded, &instance)
+		}
+	}
+	return expanded
+}
+
+// templateKey returns the key of the routine of an expanded alert definition.
+func templateKey(key, value string) string {
+	return key + "/" + value
+}
+
+// definitionKeyOf returns the key of the alert definition of a routine:
+// the routines of the expansions of a template share the key of their alert definition.
+func definitionKeyOf(key, templateValue string) string {
+	if templateValue == "" {
+		return key
+	}
+	return strings.TrimSuffix(key, "/"+templateValue)
+}
@@ -0,0 +1,119 @@
+package ngalert
+
+import (
+	"context"
+	"encoding/json"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertDefinitionExpand(t *testing.T) {
+	template := &AlertDefinition{
+		Title: "high load on ${host}",
+		Data: []eval.AlertQuery{
+			{
+				RefID: "A",
+				Model: json.RawMessage(`{"datasource": "test", "expr": "load{host=\"$host\"}"}`),
+			},
+		},
+		TemplateVariable: "host",
+		TemplateValues:   []string{`a"b`},
+	}
+	require.True(t, template.isTemplate())
+
+	expanded, err := template.expand(`a"b`)
+	require.NoError(t, err)
+	assert.Equal(t, `high load on a"b`, expanded.Title)
+	assert.JSONEq(t, `{"datasource": "test", "expr": "load{host=\"a\"b\"}"}`, string(expanded.Data[0].Model))
+	assert.Equal(t, `a"b`, expanded.templateValue)
+
+	// the template is left unchanged
+	assert.Equal(t, "high load on ${host}", template.Title)
+	assert.Contains(t, string(template.Data[0].Model), "$host")
+}
+
+func TestAlertingTickerTemplate(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+
+	var mu sync.Mutex
+	models := make(map[string]struct{})
+	ng.schedule.evaluator = eval.EvaluatorFunc(func(_ context.Context, condition *eval.Condition, _ time.Time) (eval.Results, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		models[string(condition.QueriesAndExpressions[0].Model)] = struct{}{}
+		return nil, nil
+	})
+
+	var intervalSeconds int64 = 1
+	cmd := saveAlertDefinitionCommand{
+		OrgID: 1,
+		Title: "high load on $host",
+		Condition: eval.Condition{
+			RefID: "A",
+			QueriesAndExpressions: []eval.AlertQuery{
+				{
+					RefID: "A",
+					Model: json.RawMessage(`{"datasource":"__expr__","type":"math","expression":"$host > 1"}`),
+				},
+			},
+		},
+		IntervalSeconds:  &intervalSeconds,
+		TemplateVariable: "host",
+		TemplateValues:   []string{"1", "2", "3"},
+	}
+	require.NoError(t, ng.saveAlertDefinition(&cmd))
+
+	evalAppliedCh := make(chan evalAppliedInfo, 3)
+	ng.schedule.evalApplied = func(alertDefID int64, now time.Time) {
+		evalAppliedCh <- evalAppliedInfo{alertDefID: alertDefID, now: now}
+	}
+
+	ctx := context.Background()
+	go func() {
+		err := ng.alertingTicker(ctx)
+		require.NoError(t, err)
+	}()
+	runtime.Gosched()
+
+	// the three expanded alert definitions are spread within the tick
+	tick := advanceClock(t, mockedClock)
+	for i := 0; i < 3; i++ {
+		if i > 0 {
+			mockedClock.Add(time.Second / 3)
+		}
+		select {
+		case info := <-evalAppliedCh:
+			assert.Equal(t, cmd.Result.ID, info.alertDefID)
+			assert.Equal(t, tick, info.now)
+		case <-time.After(time.Second):
+			t.Fatalf("expanded alert definition %d was not evaluated", i)
+		}
+	}
+
+	key := getKey(cmd.Result)
+	for _, value := range cmd.TemplateValues {
+		assert.True(t, ng.schedule.registry.exists(templateKey(key, value)), "missing routine for %s", value)
+	}
+	assert.False(t, ng.schedule.registry.exists(key))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, map[string]struct{}{
+		`{"datasource":"__expr__","type":"math","expression":"1 > 1"}`: {},
+		`{"datasource":"__expr__","type":"math","expression":"2 > 1"}`: {},
+		`{"datasource":"__expr__","type":"math","expression":"3 > 1"}`: {},
+	}, models)
+}
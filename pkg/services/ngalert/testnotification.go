@@ -0,0 +1,37 @@
+package ngalert
+
+import (
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+// SendTestNotification emits a test event of a firing instance of the alert definition
+// to the subscribers so that the notifications can be checked without waiting for it to fire.
+// The instance has the labels of the alert definition and is neither tracked nor annotated;
+// the subscribers tell it apart by the Test flag of the event.
+func (ng *AlertNG) SendTestNotification(uid string, orgID int64) error {
+	q := getAlertDefinitionByUIDQuery{UID: uid, OrgID: orgID}
+	if err := ng.getAlertDefinitionByUID(&q); err != nil {
+		return err
+	}
+	alertDefinition := q.Result
+
+	now := ng.schedule.clock.Now()
+	instance := alertInstance{
+		DefinitionKey:   ng.schedule.keyFunc(alertDefinition),
+		OrgID:           alertDefinition.OrgID,
+		DefinitionUID:   alertDefinition.UID,
+		DefinitionTitle: alertDefinition.Title,
+		Labels:          data.Labels(alertDefinition.Labels),
+		State:           eval.Alerting,
+		PreviousState:   eval.Normal,
+		FiringSince:     now,
+		LastAlertingAt:  now,
+		LastEvaluatedAt: now,
+		LastNotifiedAt:  now,
+		Notify:          true,
+	}
+	ng.schedule.subscribers.emitTest([]alertInstance{instance}, ng.schedule.routingLabels)
+	ng.log.Info("test notification sent", "uid", uid, "orgID", orgID)
+	return nil
+}
@@ -0,0 +1,56 @@
+package ngalert
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendTestNotification(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+
+	alert := createTestAlertDefinition(t, ng, 1)
+	err := ng.updateAlertDefinition(&updateAlertDefinitionCommand{
+		ID:     alert.ID,
+		OrgID:  alert.OrgID,
+		Labels: map[string]string{"team": "x"},
+	})
+	require.NoError(t, err)
+
+	events, unsubscribe := ng.schedule.subscribers.subscribe(1)
+	defer unsubscribe()
+
+	require.NoError(t, ng.SendTestNotification(alert.UID, alert.OrgID))
+
+	select {
+	case event := <-events:
+		assert.True(t, event.Test)
+		assert.Equal(t, alert.UID, event.Instance.DefinitionUID)
+		assert.Equal(t, data.Labels{"team": "x"}, event.Instance.Labels)
+		assert.Equal(t, eval.Alerting, event.Instance.State)
+		assert.True(t, event.Instance.Notify)
+	default:
+		t.Fatal("the test event should reach the subscribers")
+	}
+
+	t.Run("the test notification should not change the state of the alert definition", func(t *testing.T) {
+		assert.Empty(t, ng.schedule.stateTracker.get(getKey(alert)))
+	})
+
+	t.Run("the test notification of an unknown alert definition should fail", func(t *testing.T) {
+		err := ng.SendTestNotification("unknown", alert.OrgID)
+		assert.True(t, errors.Is(err, errAlertDefinitionNotFound))
+	})
+}
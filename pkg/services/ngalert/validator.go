@@ -22,6 +22,14 @@ func (ng *AlertNG) validateAlertDefinition(alertDefinition *AlertDefinition, req
 		return fmt.Errorf("invalid interval: %v: interval should be divided exactly by scheduler interval: %v", time.Duration(alertDefinition.IntervalSeconds)*time.Second, ng.schedule.baseInterval)
 	}
 
+	if err := ng.validateAlignmentOffset(alertDefinition); err != nil {
+		return err
+	}
+
+	if err := ng.validateMaxInterval(alertDefinition); err != nil {
+		return err
+	}
+
 	// enfore max name length in SQLite
 	if len(alertDefinition.Title) > alertDefinitionMaxNameLength {
 		return fmt.Errorf("name length should not be greater than %d", alertDefinitionMaxNameLength)
@@ -31,6 +39,50 @@ func (ng *AlertNG) validateAlertDefinition(alertDefinition *AlertDefinition, req
 		return fmt.Errorf("no organisation is found")
 	}
 
+	if alertDefinition.GuardCondition != "" && len(alertDefinition.Data) > 0 && !alertDefinition.hasQuery(alertDefinition.GuardCondition) {
+		return fmt.Errorf("guard condition %s does not refer to any query or expression", alertDefinition.GuardCondition)
+	}
+
+	if alertDefinition.ConfirmCondition != "" && len(alertDefinition.Data) > 0 && !alertDefinition.hasQuery(alertDefinition.ConfirmCondition) {
+		return fmt.Errorf("confirm condition %s does not refer to any query or expression", alertDefinition.ConfirmCondition)
+	}
+
+	if !alertDefinition.Trend.IsZero() {
+		if err := alertDefinition.Trend.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if !alertDefinition.Baseline.IsZero() {
+		if err := alertDefinition.Baseline.Validate(); err != nil {
+			return err
+		}
+		if !alertDefinition.Trend.IsZero() {
+			return fmt.Errorf("invalid baseline: an alert definition can't have both a trend and a baseline")
+		}
+		if alertDefinition.Baseline.RefID == alertDefinition.Condition {
+			return fmt.Errorf("invalid baseline: %s is the condition itself", alertDefinition.Baseline.RefID)
+		}
+		if len(alertDefinition.Data) > 0 && !alertDefinition.hasQuery(alertDefinition.Baseline.RefID) {
+			return fmt.Errorf("baseline %s does not refer to any query or expression", alertDefinition.Baseline.RefID)
+		}
+	}
+
+	for _, uid := range alertDefinition.DependsOn {
+		if uid == "" {
+			return fmt.Errorf("invalid dependency: empty alert definition UID")
+		}
+		if uid == alertDefinition.UID {
+			return fmt.Errorf("invalid dependency: alert definition %s can't depend on itself", uid)
+		}
+	}
+
+	if !alertDefinition.ActiveTimeIntervals.IsZero() {
+		if err := alertDefinition.ActiveTimeIntervals.validate(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
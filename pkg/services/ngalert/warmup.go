@@ -0,0 +1,97 @@
+package ngalert
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+)
+
+// defaultDatasourceWarmUpTimeout is the default time the warm-up of the datasources of a routine is given.
+const defaultDatasourceWarmUpTimeout = 2 * time.Second
+
+// DatasourceWarmer issues a cheap request to a datasource, e.g. a health check,
+// so that its connections are set up before the first evaluation querying it.
+type DatasourceWarmer interface {
+	WarmUp(ctx context.Context, orgID, datasourceID int64) error
+}
+
+// DatasourceWarmerFunc is an adapter to use a function as a DatasourceWarmer.
+type DatasourceWarmerFunc func(ctx context.Context, orgID, datasourceID int64) error
+
+// WarmUp calls f(ctx, orgID, datasourceID).
+func (f DatasourceWarmerFunc) WarmUp(ctx context.Context, orgID, datasourceID int64) error {
+	return f(ctx, orgID, datasourceID)
+}
+
+// datasourceWarmUp warms up the datasources of the alert definitions of the new routines.
+// The warm-up runs next to the routine so that a slow or failing datasource
+// does not delay its start, and it's abandoned once the timeout expires.
+type datasourceWarmUp struct {
+	mu      sync.RWMutex
+	enabled bool
+	timeout time.Duration
+	warmer  DatasourceWarmer
+	log     log.Logger
+}
+
+func newDatasourceWarmUp(logger log.Logger) *datasourceWarmUp {
+	return &datasourceWarmUp{timeout: defaultDatasourceWarmUpTimeout, log: logger}
+}
+
+// setWarmer sets the warmer of the datasources; if it's nil they are not warmed up.
+func (w *datasourceWarmUp) setWarmer(warmer DatasourceWarmer) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.warmer = warmer
+}
+
+// active returns the warmer if the warm-up is enabled.
+func (w *datasourceWarmUp) active() (DatasourceWarmer, time.Duration, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.warmer, w.timeout, w.enabled && w.warmer != nil
+}
+
+// warmUp warms up the datasources queried by the alert definition within the timeout.
+// The failures are only logged since the evaluations report the datasource errors.
+func (w *datasourceWarmUp) warmUp(ctx context.Context, key string, alertDefinition *AlertDefinition) {
+	warmer, timeout, ok := w.active()
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for _, datasourceID := range alertDefinitionDatasources(alertDefinition) {
+		if err := warmer.WarmUp(ctx, alertDefinition.OrgID, datasourceID); err != nil {
+			w.log.Debug("failed to warm up the datasource of the alert definition", "key", key, "datasourceID", datasourceID, "error", err)
+		}
+		if ctx.Err() != nil {
+			w.log.Debug("datasource warm-up timed out", "key", key, "timeout", timeout)
+			return
+		}
+	}
+}
+
+// warmUpDatasources warms up in the background the datasources of the alert definition of a new routine.
+func (ng *AlertNG) warmUpDatasources(ctx context.Context, key string, definitionInfo alertDefinitionInfo) {
+	if _, _, ok := ng.schedule.datasourceWarmUp.active(); !ok {
+		return
+	}
+	go func() {
+		alertDefinition, err := ng.definitionStore().GetByID(definitionInfo.definitionID)
+		if err != nil {
+			ng.schedule.log.Debug("failed to fetch the alert definition to warm up its datasources", "key", key, "error", err)
+			return
+		}
+		ng.schedule.datasourceWarmUp.warmUp(ctx, key, alertDefinition)
+	}()
+}
+
+// SetDatasourceWarmer sets the warmer of the datasources of the new routines,
+// used if the datasource warm-up is enabled.
+func (ng *AlertNG) SetDatasourceWarmer(warmer DatasourceWarmer) {
+	ng.schedule.datasourceWarmUp.setWarmer(warmer)
+}
@@ -0,0 +1,118 @@
+package ngalert
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatasourceWarmUpOnRoutineStart(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	mockedClock := clock.NewMock()
+	ng.schedule = newScheduler(mockedClock, time.Second, log.New("ngalert.schedule.test"), nil)
+	ng.schedule.datasourceWarmUp.enabled = true
+
+	warmedUp := make(chan int64, 1)
+	ng.SetDatasourceWarmer(DatasourceWarmerFunc(func(_ context.Context, _, datasourceID int64) error {
+		warmedUp <- datasourceID
+		return nil
+	}))
+
+	alertDefinition := createTestAlertDefinition(t, ng, 1)
+	cmd := updateAlertDefinitionCommand{
+		ID:    alertDefinition.ID,
+		OrgID: alertDefinition.OrgID,
+		Condition: eval.Condition{
+			RefID: "A",
+			QueriesAndExpressions: []eval.AlertQuery{
+				{
+					RefID:             "A",
+					RelativeTimeRange: eval.RelativeTimeRange{From: eval.Duration(5 * time.Minute)},
+					Model:             json.RawMessage(`{"datasource": "test", "datasourceId": 7, "intervalMs": 1000, "maxDataPoints": 100}`),
+				},
+			},
+		},
+	}
+	require.NoError(t, ng.updateAlertDefinition(&cmd))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		_ = ng.alertingTicker(ctx)
+	}()
+	runtime.Gosched()
+
+	advanceClock(t, mockedClock)
+	select {
+	case datasourceID := <-warmedUp:
+		assert.Equal(t, int64(7), datasourceID)
+	case <-time.After(time.Second):
+		t.Fatal("the datasource of the new routine should be warmed up")
+	}
+}
+
+func TestDatasourceWarmUp(t *testing.T) {
+	alertDefinition := &AlertDefinition{
+		OrgID: 1,
+		Data: []eval.AlertQuery{
+			{RefID: "A", Model: json.RawMessage(`{"datasource": "test", "datasourceId": 1}`)},
+			{RefID: "B", Model: json.RawMessage(`{"datasource": "test", "datasourceId": 2}`)},
+		},
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		w := newDatasourceWarmUp(log.New("ngalert.schedule.test"))
+		var warmed []int64
+		w.setWarmer(DatasourceWarmerFunc(func(_ context.Context, _, datasourceID int64) error {
+			warmed = append(warmed, datasourceID)
+			return nil
+		}))
+		w.warmUp(context.Background(), "key", alertDefinition)
+		assert.Empty(t, warmed)
+	})
+
+	t.Run("without warmer", func(t *testing.T) {
+		w := newDatasourceWarmUp(log.New("ngalert.schedule.test"))
+		w.enabled = true
+		_, _, ok := w.active()
+		assert.False(t, ok)
+	})
+
+	t.Run("failures do not stop the warm-up", func(t *testing.T) {
+		w := newDatasourceWarmUp(log.New("ngalert.schedule.test"))
+		w.enabled = true
+		var warmed []int64
+		w.setWarmer(DatasourceWarmerFunc(func(_ context.Context, _, datasourceID int64) error {
+			warmed = append(warmed, datasourceID)
+			return errors.New("unreachable")
+		}))
+		w.warmUp(context.Background(), "key", alertDefinition)
+		assert.Equal(t, []int64{1, 2}, warmed)
+	})
+
+	t.Run("abandoned on timeout", func(t *testing.T) {
+		w := newDatasourceWarmUp(log.New("ngalert.schedule.test"))
+		w.enabled = true
+		w.timeout = 10 * time.Millisecond
+		var warmed []int64
+		w.setWarmer(DatasourceWarmerFunc(func(ctx context.Context, _, datasourceID int64) error {
+			warmed = append(warmed, datasourceID)
+			<-ctx.Done()
+			return ctx.Err()
+		}))
+		w.warmUp(context.Background(), "key", alertDefinition)
+		assert.Equal(t, []int64{1}, warmed)
+	})
+}
@@ -0,0 +1,87 @@
+package ngalert
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+const (
+	// overrideThreshold replaces the constant of the threshold expression of the condition.
+	overrideThreshold = "threshold"
+	// overrideWindow replaces the relative time range of the condition queries
+	// by the window ending at the evaluation time.
+	overrideWindow = "window"
+)
+
+// EvalWithOverride evaluates the alert definition with its parameters temporarily overridden
+// for what-if analysis, e.g. "what if the threshold was X". The overrides are never persisted
+// and like any preview the evaluation does not update the state of the alert instances.
+// The supported overrides are "threshold" (a number) and "window" (a duration, e.g. "10m").
+func (ng *AlertNG) EvalWithOverride(ctx context.Context, uid string, orgID int64, overrides map[string]interface{}, now time.Time) (eval.Results, error) {
+	q := getAlertDefinitionByUIDQuery{UID: uid, OrgID: orgID}
+	if err := ng.getAlertDefinitionByUID(&q); err != nil {
+		return nil, err
+	}
+
+	condition, err := applyOverrides(q.Result.getCondition(), overrides)
+	if err != nil {
+		return nil, err
+	}
+	return ng.EvalDefinitionNow(ctx, q.Result.ID, &condition, now)
+}
+
+// applyOverrides returns a copy of the condition with the overrides applied.
+func applyOverrides(condition eval.Condition, overrides map[string]interface{}) (eval.Condition, error) {
+	for name, value := range overrides {
+		switch name {
+		case overrideThreshold:
+			threshold, ok := toFloat64(value)
+			if !ok {
+				return condition, fmt.Errorf("invalid %s override: %v is not a number", name, value)
+			}
+			overridden, err := condition.WithThreshold(threshold)
+			if err != nil {
+				return condition, fmt.Errorf("invalid %s override: %w", name, err)
+			}
+			condition = overridden
+		case overrideWindow:
+			window, err := toDuration(value)
+			if err != nil || window <= 0 {
+				return condition, fmt.Errorf("invalid %s override: %v is not a positive duration", name, value)
+			}
+			condition = condition.WithRelativeTimeRange(eval.RelativeTimeRange{From: eval.Duration(window)})
+		default:
+			return condition, fmt.Errorf("unsupported override: %s", name)
+		}
+	}
+	return condition, nil
+}
+
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+func toDuration(value interface{}) (time.Duration, error) {
+	switch v := value.(type) {
+	case time.Duration:
+		return v, nil
+	case string:
+		return time.ParseDuration(v)
+	default:
+		return 0, fmt.Errorf("unsupported duration type %T", value)
+	}
+}
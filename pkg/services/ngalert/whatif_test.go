@@ -0,0 +1,100 @@
+package ngalert
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvalWithOverride(t *testing.T) {
+	ng := setupTestEnv(t)
+	t.Cleanup(registry.ClearOverrides)
+
+	// the evaluator compares a query always returning 90 to the threshold of the condition expression
+	thresholdRegexp := regexp.MustCompile(`\$A > ([0-9.]+)`)
+	ng.schedule.previewEvaluator = eval.EvaluatorFunc(func(_ context.Context, condition *eval.Condition, _ time.Time) (eval.Results, error) {
+		for _, q := range condition.QueriesAndExpressions {
+			if q.RefID != condition.RefID {
+				continue
+			}
+			match := thresholdRegexp.FindStringSubmatch(expression(t, q))
+			require.NotNil(t, match)
+			threshold, err := strconv.ParseFloat(match[1], 64)
+			require.NoError(t, err)
+			if 90 > threshold {
+				return eval.Results{{State: eval.Alerting}}, nil
+			}
+		}
+		return eval.Results{{State: eval.Normal}}, nil
+	})
+
+	var intervalSeconds int64 = 60
+	cmd := saveAlertDefinitionCommand{
+		OrgID: 1,
+		Title: "high cpu",
+		Condition: eval.Condition{
+			RefID: "B",
+			QueriesAndExpressions: []eval.AlertQuery{
+				{
+					RefID:             "A",
+					RelativeTimeRange: eval.RelativeTimeRange{From: eval.Duration(5 * time.Minute)},
+					Model:             json.RawMessage(`{"datasource": "__expr__", "type": "math", "expression": "90"}`),
+				},
+				{
+					RefID: "B",
+					Model: json.RawMessage(`{"datasource": "__expr__", "type": "math", "expression": "$A > 80"}`),
+				},
+			},
+		},
+		IntervalSeconds: &intervalSeconds,
+	}
+	require.NoError(t, ng.saveAlertDefinition(&cmd))
+	uid := cmd.Result.UID
+	ctx := context.Background()
+	now := time.Now()
+
+	stored, err := ng.EvalWithOverride(ctx, uid, 1, nil, now)
+	require.NoError(t, err)
+	assert.Equal(t, eval.Alerting, stored[0].State)
+
+	overridden, err := ng.EvalWithOverride(ctx, uid, 1, map[string]interface{}{"threshold": 95.0, "window": "10m"}, now)
+	require.NoError(t, err)
+	assert.Equal(t, eval.Normal, overridden[0].State)
+
+	// the stored alert definition is unchanged
+	q := getAlertDefinitionByUIDQuery{UID: uid, OrgID: 1}
+	require.NoError(t, ng.getAlertDefinitionByUID(&q))
+	assert.Equal(t, int64(1), q.Result.Version)
+	assert.Equal(t, "$A > 80", expression(t, q.Result.Data[1]))
+	assert.Equal(t, eval.Duration(5*time.Minute), q.Result.Data[0].RelativeTimeRange.From)
+	assert.Empty(t, ng.schedule.stateTracker.get(getKey(q.Result)))
+
+	t.Run("unsupported overrides should fail", func(t *testing.T) {
+		_, err := ng.EvalWithOverride(ctx, uid, 1, map[string]interface{}{"interval": 10}, now)
+		require.Error(t, err)
+		_, err = ng.EvalWithOverride(ctx, uid, 1, map[string]interface{}{"threshold": "high"}, now)
+		require.Error(t, err)
+	})
+
+	t.Run("an unknown alert definition should fail", func(t *testing.T) {
+		_, err := ng.EvalWithOverride(ctx, "unknown", 1, nil, now)
+		require.Equal(t, errAlertDefinitionNotFound, err)
+	})
+}
+
+// expression returns the expression of the query model.
+func expression(t *testing.T, q eval.AlertQuery) string {
+	model := struct {
+		Expression string `json:"expression"`
+	}{}
+	require.NoError(t, json.Unmarshal(q.Model, &model))
+	return model.Expression
+}